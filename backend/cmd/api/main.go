@@ -1,56 +1,110 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
+	"os"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	chimiddleware "github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 	"github.com/joho/godotenv"
+	"project-meetings/backend/internal/backplane"
+	"project-meetings/backend/internal/blob"
 	"project-meetings/backend/internal/database"
+	"project-meetings/backend/internal/gitrepo"
 	"project-meetings/backend/internal/handlers"
 	"project-meetings/backend/internal/middleware"
+	"project-meetings/backend/internal/permissions"
+	"project-meetings/backend/internal/store/pgstore"
 	"project-meetings/backend/internal/ws"
 )
 
-type application struct {
-	hub *ws.Hub
-}
-
 func main() {
 	err := godotenv.Load("../../.env")
 	if err != nil {
 		log.Println("No .env file found, reading from environment")
 	}
 
-	database.Connect()
-	defer database.DB.Close()
-	hub := ws.NewHub()
+	// The schema needs no database, so it is handled before connecting.
+	if len(os.Args) > 1 && os.Args[1] == "ws-schema" {
+		runSchemaCommand(os.Args[2:])
+		return
+	}
+
+	pool := database.Connect()
+	defer pool.Close()
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrateCommand(pool, os.Args[2:])
+		return
+	}
+
+	// Bring the schema up to date before serving. Set SKIP_MIGRATIONS=true when
+	// migrations are run as a separate deploy step.
+	if os.Getenv("SKIP_MIGRATIONS") != "true" {
+		applied, err := database.MigrateUp(context.Background(), pool)
+		if err != nil {
+			log.Fatalf("Failed to apply migrations: %v", err)
+		}
+		log.Printf("Database schema up to date (%d migrations applied)", applied)
+	}
+
+	st := pgstore.New(pool)
+	blobs, err := blob.FromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to set up blob storage: %v", err)
+	}
+	st.Blobs = blobs
+	go blob.SweepOrphans(context.Background(), st.Files, st.Blobs, 5*time.Minute)
+
+	// BACKPLANE=postgres or redis lets several instances serve the same
+	// projects; by default the hub only knows its own clients.
+	bp, err := backplane.FromEnv(context.Background(), pool)
+	if err != nil {
+		log.Fatalf("Failed to set up the hub backplane: %v", err)
+	}
+	hub := ws.NewHub(st, bp)
+	policies, err := ws.SendPoliciesFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to read WebSocket send policies: %v", err)
+	}
+	hub.SetSendPolicies(policies)
+	limits, err := ws.CacheLimitsFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to read WebSocket cache limits: %v", err)
+	}
+	hub.SetCacheLimits(limits)
 	go hub.Run()
 
-	app := &application{
-		hub: hub,
+	repos, err := gitrepo.FromEnv()
+	if err != nil {
+		log.Fatalf("Failed to set up git repositories: %v", err)
 	}
 
+	h := handlers.New(st, hub, repos)
+	mw := middleware.New(st)
+
 	r := chi.NewRouter()
 
 	r.Use(chimiddleware.Logger)
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"http://localhost:5173"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "If-Match", "If-None-Match"},
+		ExposedHeaders:   []string{"Link", "ETag"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
 
-	r.Get("/ws/{projectId}", app.ServeWs)
+	r.Get("/ws/{projectId}", h.ServeWs)
 	// API Routes
 	r.Route("/api/v1", func(r chi.Router) {
 		// Public routes
-		r.Post("/auth/register", handlers.RegisterUser)
-		r.Post("/auth/login", handlers.LoginUser)
+		r.Post("/auth/register", h.RegisterUser)
+		r.Post("/auth/login", h.LoginUser)
 
 		// Protected routes
 		r.Group(func(r chi.Router) {
@@ -58,41 +112,112 @@ func main() {
 
 			// --- GENERAL AUTHENTICATED ROUTES ---
 			// These routes do NOT depend on a specific project ID, so they live at the top level.
-			r.Post("/projects", handlers.CreateProject)
-			r.Get("/projects", handlers.GetUserProjects)
-			r.Post("/invites/accept", handlers.AcceptProjectInvite)
+			r.Post("/projects", h.CreateProject)
+			r.Get("/projects", h.GetUserProjects)
+			r.Get("/templates", h.ListTemplates)
+			r.Post("/invites/accept", h.AcceptProjectInvite)
+
+			// --- ACCOUNT ROUTES ---
+			r.Get("/me", h.GetMe)
+			r.Patch("/me", h.UpdateMe)
+			r.Delete("/me", h.DeleteMe)
+			r.Put("/me/password", h.ChangePassword)
+			r.Post("/me/email", h.RequestEmailChange)
+			r.Post("/me/email/confirm", h.ConfirmEmailChange)
+
+			// --- ORGANIZATION ROUTES ---
+			r.Post("/orgs", h.CreateOrganization)
+			r.Get("/orgs", h.GetUserOrganizations)
+			r.Group(func(r chi.Router) {
+				r.Use(mw.RequireOrgRole("owner", "admin", "member"))
+				r.Get("/orgs/{orgId}", h.GetOrganization)
+				r.Get("/orgs/{orgId}/members", h.GetOrganizationMembers)
+				r.Get("/orgs/{orgId}/projects", h.GetOrganizationProjects)
+				// Admins remove anyone; members may only remove themselves.
+				r.Delete("/orgs/{orgId}/members/{memberId}", h.RemoveOrganizationMember)
+			})
+			r.Group(func(r chi.Router) {
+				r.Use(mw.RequireOrgRole("owner", "admin"))
+				r.Patch("/orgs/{orgId}", h.UpdateOrganization)
+				r.Post("/orgs/{orgId}/members", h.AddOrganizationMembers)
+				r.Put("/orgs/{orgId}/members/{memberId}", h.UpdateOrganizationMemberRole)
+			})
+			r.With(mw.RequireOrgRole("owner")).Delete("/orgs/{orgId}", h.DeleteOrganization)
 
 			// --- PROJECT-SPECIFIC ROUTES (Now with RBAC) ---
 			// All routes from this point forward operate on a specific project
-			// and will be checked for membership and role.
+			// and will be checked for membership and a permission granted by the member's role.
+
+			// Project settings
+			r.Group(func(r chi.Router) {
+				r.Use(mw.RequirePermission(permissions.ProjectManage))
+				r.Put("/project/{projectId}/rename", h.RenameProject)
+				r.Delete("/project/{projectId}", h.DeleteProject)
+				r.Post("/project/{projectId}/transfer-ownership", h.TransferOwnership)
+				r.Put("/project/{projectId}/organization", h.SetProjectOrganization)
+				r.Put("/project/{projectId}/template", h.SetProjectTemplate)
+			})
+
+			// Membership, invites and roles
+			r.Group(func(r chi.Router) {
+				r.Use(mw.RequirePermission(permissions.MembersManage))
+				r.Post("/project/{projectId}/invites", h.CreateProjectInvite)
+				r.Get("/project/{projectId}/invites", h.ListProjectInvites)
+				r.Delete("/project/{projectId}/invites/{inviteId}", h.RevokeProjectInvite)
+				r.Get("/project/{projectId}/members", h.GetProjectMembers)
+				r.Put("/project/{projectId}/members/{memberId}", h.UpdateMemberRole)
+				r.Delete("/project/{projectId}/members/{memberId}", h.RemoveProjectMember)
+				r.Get("/project/{projectId}/roles", h.ListProjectRoles)
+				r.Post("/project/{projectId}/roles", h.CreateProjectRole)
+				r.Put("/project/{projectId}/roles/{roleName}", h.UpdateProjectRole)
+				r.Delete("/project/{projectId}/roles/{roleName}", h.DeleteProjectRole)
+				r.Get("/file/{fileId}/acl", h.GetFileACL)
+				r.Put("/file/{fileId}/acl", h.SetFileACL)
+			})
+
+			r.With(mw.RequirePermission(permissions.ExecRun)).Post("/project/{projectId}/execute", h.ExecuteCode)
+			r.With(mw.RequirePermission(permissions.AuditRead)).Get("/project/{projectId}/audit", h.GetAuditLog)
 
-			// Group for routes requiring OWNER role
+			// Git sync
 			r.Group(func(r chi.Router) {
-				r.Use(middleware.ProjectMemberAuth("owner"))
-				r.Post("/project/{projectId}/invites", handlers.CreateProjectInvite)
-				r.Put("/project/{projectId}/rename", handlers.RenameProject)
-				r.Delete("/project/{projectId}", handlers.DeleteProject)
-				r.Get("/project/{projectId}/members", handlers.GetProjectMembers)
-				r.Put("/project/{projectId}/members/{memberId}", app.UpdateMemberRole)
-				r.Delete("/project/{projectId}/members/{memberId}",app.RemoveProjectMember)
+				r.Use(mw.RequirePermission(permissions.GitSync))
+				r.Post("/project/{projectId}/git/import", h.ImportGitRepository)
+				r.Get("/project/{projectId}/git/status", h.GetGitStatus)
+				r.Post("/project/{projectId}/git/commit", h.CommitGitChanges)
+				r.Post("/project/{projectId}/git/push", h.PushGitBranch)
+				r.Post("/project/{projectId}/git/pull", h.PullGitBranch)
 			})
 
-			// Group for routes requiring EDITOR or OWNER roles
+			// File edits
 			r.Group(func(r chi.Router) {
-				r.Use(middleware.ProjectMemberAuth("owner", "editor"))
-				r.Post("/project/{projectId}/execute", handlers.ExecuteCode)
-				r.Post("/project/{projectId}/files", handlers.CreateFileNode)
-				r.Put("/file/{fileId}/rename", handlers.RenameFileNode)
-				r.Put("/file/{fileId}/content", handlers.SaveFileContent)
-				r.Delete("/file/{fileId}", handlers.DeleteFileNode)
+				r.Use(mw.RequirePermission(permissions.FileWrite))
+				r.Post("/project/{projectId}/files", h.CreateFileNode)
+				r.Put("/file/{fileId}/rename", h.RenameFileNode)
+				r.Put("/file/{fileId}/content", h.SaveFileContent)
+				r.Put("/project/{projectId}/fs/*", h.PutFSPath)
+				r.Post("/project/{projectId}/uploads", h.UploadFiles)
+				r.Put("/file/{fileId}/blob", h.ReplaceFileBlob)
+				r.Post("/file/{fileId}/copy", h.CopyFileNode)
 			})
+			r.With(mw.RequirePermission(permissions.FileDelete)).Delete("/file/{fileId}", h.DeleteFileNode)
+			r.With(mw.RequirePermission(permissions.FileDelete)).Delete("/project/{projectId}/fs/*", h.DeleteFSPath)
 
-			// Group for routes requiring ANY member role (VIEWER, EDITOR, or OWNER)
+			// Group for routes available to ANY member
 			r.Group(func(r chi.Router) {
-				r.Use(middleware.ProjectMemberAuth("owner", "editor", "viewer"))
-				r.Get("/project/{projectId}/whiteboardState", app.GetWhiteboardState)
-				r.Get("/project/{projectId}/files", handlers.GetFileTree)
-				r.Get("/project/{projectId}/role", handlers.GetUserRoleForProject)
+				r.Use(mw.RequirePermission(permissions.ProjectRead))
+				r.Get("/project/{projectId}/whiteboardState", h.GetWhiteboardState)
+				r.Get("/project/{projectId}/files", h.GetFileTree)
+				r.Get("/project/{projectId}/tree", h.ListFolderChildren)
+				r.Get("/project/{projectId}/role", h.GetUserRoleForProject)
+				r.Get("/project/{projectId}/export", h.ExportProject)
+				r.Get("/project/{projectId}/search", h.SearchProject)
+				r.Get("/project/{projectId}/fs/*", h.GetFSPath)
+				r.Get("/project/{projectId}/storage", h.GetProjectStorage)
+				r.Get("/file/{fileId}", h.GetFile)
+				r.Get("/file/{fileId}/download", h.DownloadFile)
+				r.Get("/file/{fileId}/access", h.GetFileAccess)
+				r.Post("/project/{projectId}/leave", h.LeaveProject)
+				r.Post("/project/{projectId}/fork", h.ForkProject)
 			})
 		})
 	})
@@ -102,17 +227,3 @@ func main() {
 		log.Fatalf("Could not start server: %s\n", err)
 	}
 }
-
-func (app *application) ServeWs(w http.ResponseWriter, r *http.Request) {
-	handlers.ServeWs(app.hub, w, r)
-}
-func (app *application) GetWhiteboardState(w http.ResponseWriter, r *http.Request) {
-	handlers.GetWhiteboardState(app.hub, w, r)
-}
-
-func (app *application) UpdateMemberRole(w http.ResponseWriter, r *http.Request) {
-	handlers.UpdateMemberRole(app.hub, w, r)
-}
-func (app *application) RemoveProjectMember(w http.ResponseWriter, r *http.Request) {
-	handlers.RemoveProjectMember(app.hub, w, r)
-}
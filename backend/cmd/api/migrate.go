@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"project-meetings/backend/internal/database"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const migrateUsage = `usage: api migrate <command>
+
+commands:
+  up           apply all pending migrations
+  down [N]     revert the last N applied migrations (default 1)
+  status       list migrations and when they were applied`
+
+// runMigrateCommand handles `api migrate ...` and exits on failure.
+func runMigrateCommand(pool *pgxpool.Pool, args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := database.MigrateUp(ctx, pool)
+		if err != nil {
+			log.Fatalf("Migrate up failed: %v", err)
+		}
+		log.Printf("Applied %d migration(s)", applied)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				log.Fatalf("Invalid step count %q", args[1])
+			}
+			steps = n
+		}
+		reverted, err := database.MigrateDown(ctx, pool, steps)
+		if err != nil {
+			log.Fatalf("Migrate down failed: %v", err)
+		}
+		log.Printf("Reverted %d migration(s)", reverted)
+
+	case "status":
+		statuses, err := database.MigrationStatuses(ctx, pool)
+		if err != nil {
+			log.Fatalf("Migrate status failed: %v", err)
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			fmt.Fprintf(tw, "%04d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		tw.Flush()
+
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+
+	"project-meetings/backend/internal/ws"
+)
+
+// runSchemaCommand handles `api ws-schema [file]`: it writes the JSON Schema
+// of the WebSocket protocol to file, or to stdout without one. The frontend
+// generates its message types from it.
+func runSchemaCommand(args []string) {
+	data, err := json.MarshalIndent(ws.JSONSchema(), "", "  ")
+	if err != nil {
+		log.Fatalf("Failed to encode the WebSocket schema: %v", err)
+	}
+	data = append(data, '\n')
+	if len(args) == 0 {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := os.WriteFile(args[0], data, 0o644); err != nil {
+		log.Fatalf("Failed to write the WebSocket schema: %v", err)
+	}
+	log.Printf("Wrote the WebSocket schema to %s", args[0])
+}
@@ -0,0 +1,245 @@
+// Package acl evaluates folder-level access overrides inside a project.
+//
+// A rule attached to a file or folder sets the access level for one role or
+// one user. Rules are inherited down the parent_id tree: the nearest node with
+// a matching rule decides, and a user rule beats a role rule on the same node.
+// Without any matching rule the member's project role decides. A rule can
+// never grant more than the role allows, and the project owner is never
+// restricted.
+package acl
+
+import (
+	"context"
+
+	"project-meetings/backend/internal/models"
+	"project-meetings/backend/internal/permissions"
+	"project-meetings/backend/internal/store"
+
+	"github.com/google/uuid"
+)
+
+type Access int
+
+const (
+	None Access = iota
+	Read
+	Write
+)
+
+func (a Access) String() string {
+	switch a {
+	case Read:
+		return "read"
+	case Write:
+		return "write"
+	default:
+		return "none"
+	}
+}
+
+// ParseAccess converts "none", "read" or "write" to an Access.
+func ParseAccess(s string) (Access, bool) {
+	switch s {
+	case "none":
+		return None, true
+	case "read":
+		return Read, true
+	case "write":
+		return Write, true
+	}
+	return None, false
+}
+
+const (
+	SubjectRole = "role"
+	SubjectUser = "user"
+)
+
+// Rule is a single access override on a file or folder.
+type Rule = models.AccessRule
+
+// Subject is the member whose access is being evaluated.
+type Subject struct {
+	UserID      string
+	Role        string
+	Permissions permissions.Set
+}
+
+// baseAccess is what the member's role allows when no rule applies.
+func (s Subject) baseAccess() Access {
+	switch {
+	case s.Permissions.Has(permissions.FileWrite):
+		return Write
+	case s.Permissions.Has(permissions.ProjectRead):
+		return Read
+	}
+	return None
+}
+
+// Evaluator answers access questions for one subject over a set of nodes.
+type Evaluator struct {
+	subject Subject
+	parents map[uuid.UUID]*uuid.UUID
+	rules   map[uuid.UUID][]Rule
+}
+
+func newEvaluator(subject Subject, parents map[uuid.UUID]*uuid.UUID, rules []Rule) *Evaluator {
+	e := &Evaluator{
+		subject: subject,
+		parents: parents,
+		rules:   make(map[uuid.UUID][]Rule),
+	}
+	for _, rule := range rules {
+		e.rules[rule.FileID] = append(e.rules[rule.FileID], rule)
+	}
+	return e
+}
+
+// LoadProject prepares an evaluator covering every node in a project.
+func LoadProject(ctx context.Context, files store.FileStore, projectID uuid.UUID, subject Subject) (*Evaluator, error) {
+	parents, err := files.Parents(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := files.ProjectRules(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return newEvaluator(subject, parents, rules), nil
+}
+
+// LoadForFile prepares an evaluator covering a single node and its ancestors.
+func LoadForFile(ctx context.Context, files store.FileStore, fileID uuid.UUID, subject Subject) (*Evaluator, error) {
+	parents, err := files.Ancestry(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uuid.UUID, 0, len(parents))
+	for id := range parents {
+		ids = append(ids, id)
+	}
+	rules, err := files.Rules(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	return newEvaluator(subject, parents, rules), nil
+}
+
+// LoadFolder prepares an evaluator covering a folder and its ancestors, or
+// nothing for the top of a project when folderID is nil. Include adds the
+// nodes below it, so listing a folder does not load the whole project.
+func LoadFolder(ctx context.Context, files store.FileStore, folderID *uuid.UUID, subject Subject) (*Evaluator, error) {
+	if folderID == nil {
+		return newEvaluator(subject, map[uuid.UUID]*uuid.UUID{}, nil), nil
+	}
+	return LoadForFile(ctx, files, *folderID, subject)
+}
+
+// Include adds nodes, given with their parents, and the rules set on them.
+// Their parents must already be covered, or be the top of the project.
+func (e *Evaluator) Include(ctx context.Context, files store.FileStore, parents map[uuid.UUID]*uuid.UUID) error {
+	ids := make([]uuid.UUID, 0, len(parents))
+	for id, parent := range parents {
+		if _, covered := e.parents[id]; !covered {
+			e.parents[id] = parent
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	rules, err := files.Rules(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		e.rules[rule.FileID] = append(e.rules[rule.FileID], rule)
+	}
+	return nil
+}
+
+// ruleFor returns the access set directly on a node for the subject, if any.
+func (e *Evaluator) ruleFor(fileID uuid.UUID) (Access, bool) {
+	var roleAccess Access
+	roleMatched := false
+	for _, rule := range e.rules[fileID] {
+		access, ok := ParseAccess(rule.Access)
+		if !ok {
+			continue
+		}
+		switch {
+		case rule.SubjectType == SubjectUser && rule.Subject == e.subject.UserID:
+			return access, true
+		case rule.SubjectType == SubjectRole && rule.Subject == e.subject.Role:
+			roleAccess, roleMatched = access, true
+		}
+	}
+	return roleAccess, roleMatched
+}
+
+// AccessTo returns the subject's effective access to a node.
+func (e *Evaluator) AccessTo(fileID uuid.UUID) Access {
+	base := e.subject.baseAccess()
+	if e.subject.Role == permissions.OwnerRole {
+		return base
+	}
+
+	// Walk up the tree; the guard stops us on a (corrupt) cycle.
+	current := &fileID
+	for depth := 0; current != nil && depth <= len(e.parents); depth++ {
+		if access, ok := e.ruleFor(*current); ok {
+			if access > base {
+				return base
+			}
+			return access
+		}
+		current = e.parents[*current]
+	}
+	return base
+}
+
+func (e *Evaluator) CanRead(fileID uuid.UUID) bool {
+	return e.AccessTo(fileID) >= Read
+}
+
+func (e *Evaluator) CanWrite(fileID uuid.UUID) bool {
+	return e.AccessTo(fileID) >= Write
+}
+
+// CanWriteSubtree reports whether the subject may write to a node and every
+// node beneath it. Only meaningful on an evaluator from LoadProject.
+func (e *Evaluator) CanWriteSubtree(fileID uuid.UUID) bool {
+	if !e.CanWrite(fileID) {
+		return false
+	}
+	for id := range e.parents {
+		if id != fileID && e.isDescendant(id, fileID) && !e.CanWrite(id) {
+			return false
+		}
+	}
+	return true
+}
+
+// ReadableChildren counts the children the subject can read below every
+// node. Only meaningful for nodes whose children are all covered, as with
+// LoadProject.
+func (e *Evaluator) ReadableChildren() map[uuid.UUID]int {
+	counts := make(map[uuid.UUID]int)
+	for id, parent := range e.parents {
+		if parent != nil && e.CanRead(id) {
+			counts[*parent]++
+		}
+	}
+	return counts
+}
+
+func (e *Evaluator) isDescendant(id, ancestor uuid.UUID) bool {
+	current := e.parents[id]
+	for depth := 0; current != nil && depth <= len(e.parents); depth++ {
+		if *current == ancestor {
+			return true
+		}
+		current = e.parents[*current]
+	}
+	return false
+}
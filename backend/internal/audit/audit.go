@@ -0,0 +1,70 @@
+// Package audit defines the entries of the append-only project audit log.
+package audit
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Event is one security-relevant or content change. Empty ProjectID and
+// ActorID are stored as NULL.
+type Event struct {
+	ProjectID  string
+	ActorID    string
+	Action     string
+	TargetType string
+	TargetID   string
+	IP         string
+	UserAgent  string
+	Details    map[string]interface{}
+}
+
+// Entry is an audit event as read back from storage.
+type Entry struct {
+	ID         int64           `json:"id"`
+	ProjectID  *string         `json:"projectId"`
+	ActorID    *string         `json:"actorId"`
+	ActorName  *string         `json:"actorName"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"targetType"`
+	TargetID   string          `json:"targetId"`
+	IP         string          `json:"ip"`
+	UserAgent  string          `json:"userAgent"`
+	Details    json.RawMessage `json:"details"`
+	CreatedAt  time.Time       `json:"createdAt"`
+}
+
+// Filter selects entries of one project, newest first. Empty fields don't
+// filter. An Action ending in ".*" matches every action with that prefix.
+// Before is the cursor: only entries with a smaller ID are returned.
+type Filter struct {
+	ProjectID  string
+	Action     string
+	ActorID    string
+	TargetType string
+	TargetID   string
+	Since      *time.Time
+	Until      *time.Time
+	Before     int64
+	Limit      int
+}
+
+// ClientIP returns the host part of the request's remote address.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// EncodeDetails marshals an event's details, using an empty object when
+// there are none.
+func EncodeDetails(details map[string]interface{}) ([]byte, error) {
+	if details == nil {
+		details = map[string]interface{}{}
+	}
+	return json.Marshal(details)
+}
@@ -0,0 +1,73 @@
+// Package backplane relays WebSocket hub events between backend instances,
+// so users of the same project see each other whichever replica they are
+// connected to. Two transports are provided: Postgres LISTEN/NOTIFY, which
+// needs nothing beyond the database, and Redis pub/sub.
+package backplane
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Channel is the Postgres notification channel and Redis pub/sub channel
+// hub events travel on.
+const Channel = "hub_events"
+
+// Backplane delivers every published message to every instance, the
+// publisher included. Delivery is best effort: messages published while an
+// instance is reconnecting are lost to it.
+type Backplane interface {
+	Publish(ctx context.Context, data []byte) error
+	// Messages returns the channel published messages arrive on. It is
+	// closed by Close.
+	Messages() <-chan []byte
+	Close() error
+}
+
+// FromEnv builds the backplane selected by BACKPLANE: "" or "none" (the
+// default) runs a single instance without one and returns nil, "postgres"
+// uses pool, and "redis" connects to REDIS_URL.
+func FromEnv(ctx context.Context, pool *pgxpool.Pool) (Backplane, error) {
+	switch kind := os.Getenv("BACKPLANE"); kind {
+	case "", "none":
+		return nil, nil
+	case "postgres":
+		log.Printf("[Backplane] Relaying hub events through Postgres channel %s", Channel)
+		return NewPostgres(ctx, pool), nil
+	case "redis":
+		url := os.Getenv("REDIS_URL")
+		if url == "" {
+			url = "redis://localhost:6379"
+		}
+		r, err := NewRedis(ctx, url)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("[Backplane] Relaying hub events through Redis at %s", r.addr)
+		return r, nil
+	default:
+		return nil, fmt.Errorf("unknown BACKPLANE %q, expected none, postgres or redis", kind)
+	}
+}
+
+// retryDelay is how long a listener waits before reconnecting after its
+// connection failed. Tests shorten it.
+var retryDelay = 2 * time.Second
+
+// messageBuffer is how many received messages may wait for the hub.
+const messageBuffer = 1024
+
+// deliver hands a received message to the hub, dropping it when the hub has
+// fallen this far behind rather than stalling the listener.
+func deliver(out chan<- []byte, data []byte) {
+	select {
+	case out <- data:
+	default:
+		log.Printf("[Backplane] Hub is not keeping up, dropping a %d byte message", len(data))
+	}
+}
@@ -0,0 +1,119 @@
+package backplane
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// maxNotifyPayload keeps NOTIFY under Postgres's 8000 byte limit. Larger
+	// messages are parked in backplane_messages and announced by row ID.
+	maxNotifyPayload = 7000
+	parkedPrefix     = "#"
+	// parkedTTL is how long parked messages are kept for slow listeners.
+	parkedTTL = time.Minute
+)
+
+// Postgres relays messages with LISTEN/NOTIFY on the application database.
+type Postgres struct {
+	pool   *pgxpool.Pool
+	out    chan []byte
+	cancel context.CancelFunc
+}
+
+// NewPostgres starts listening on a dedicated connection taken from pool.
+func NewPostgres(ctx context.Context, pool *pgxpool.Pool) *Postgres {
+	ctx, cancel := context.WithCancel(ctx)
+	p := &Postgres{pool: pool, out: make(chan []byte, messageBuffer), cancel: cancel}
+	go p.listen(ctx)
+	go p.prune(ctx)
+	return p
+}
+
+func (p *Postgres) Publish(ctx context.Context, data []byte) error {
+	payload := string(data)
+	if len(data) > maxNotifyPayload {
+		var id int64
+		if err := p.pool.QueryRow(ctx, `INSERT INTO backplane_messages (data) VALUES ($1) RETURNING id`, data).Scan(&id); err != nil {
+			return err
+		}
+		payload = parkedPrefix + strconv.FormatInt(id, 10)
+	}
+	_, err := p.pool.Exec(ctx, `SELECT pg_notify($1, $2)`, Channel, payload)
+	return err
+}
+
+func (p *Postgres) Messages() <-chan []byte {
+	return p.out
+}
+
+func (p *Postgres) Close() error {
+	p.cancel()
+	return nil
+}
+
+func (p *Postgres) listen(ctx context.Context) {
+	defer close(p.out)
+	for ctx.Err() == nil {
+		err := p.listenOnce(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("[Backplane] Lost the Postgres listener, reconnecting: %v", err)
+		select {
+		case <-ctx.Done():
+		case <-time.After(retryDelay):
+		}
+	}
+}
+
+func (p *Postgres) listenOnce(ctx context.Context) error {
+	pooled, err := p.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// A listening connection must not be handed to anyone else, so it leaves
+	// the pool for good.
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+Channel); err != nil {
+		return err
+	}
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		data := []byte(n.Payload)
+		if id, ok := strings.CutPrefix(n.Payload, parkedPrefix); ok {
+			if err := p.pool.QueryRow(ctx, `SELECT data FROM backplane_messages WHERE id = $1`, id).Scan(&data); err != nil {
+				log.Printf("[Backplane] Failed to load parked message %s: %v", id, err)
+				continue
+			}
+		}
+		deliver(p.out, data)
+	}
+}
+
+// prune removes parked messages every listener has had time to read.
+func (p *Postgres) prune(ctx context.Context) {
+	ticker := time.NewTicker(parkedTTL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		query := `DELETE FROM backplane_messages WHERE created_at < NOW() - make_interval(secs => $1)`
+		if _, err := p.pool.Exec(ctx, query, parkedTTL.Seconds()); err != nil && ctx.Err() == nil {
+			log.Printf("[Backplane] Failed to prune parked messages: %v", err)
+		}
+	}
+}
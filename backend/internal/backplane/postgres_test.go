@@ -0,0 +1,54 @@
+package backplane
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"project-meetings/backend/internal/database/dbtest"
+)
+
+func TestPostgres(t *testing.T) {
+	ctx := context.Background()
+	p := NewPostgres(ctx, dbtest.Pool(t))
+	defer p.Close()
+
+	// LISTEN starts in the background; publish until it hears something.
+	deadline := time.After(5 * time.Second)
+	for ready := false; !ready; {
+		if err := p.Publish(ctx, []byte("probe")); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-p.Messages():
+			ready = true
+		case <-time.After(50 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("the listener never received anything")
+		}
+	}
+
+	// Messages over the NOTIFY limit are parked in a table and still arrive.
+	for _, msg := range []string{`{"kind":"room"}`, strings.Repeat("x", maxNotifyPayload+1)} {
+		if err := p.Publish(ctx, []byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+		for {
+			var got string
+			select {
+			case data := <-p.Messages():
+				got = string(data)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("%.20q did not arrive", msg)
+			}
+			if got == "probe" {
+				continue
+			}
+			if got != msg {
+				t.Errorf("received %.20q, want %.20q", got, msg)
+			}
+			break
+		}
+	}
+}
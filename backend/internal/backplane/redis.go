@@ -0,0 +1,70 @@
+package backplane
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis relays messages with Redis pub/sub. The client reconnects the
+// subscription by itself after the connection drops.
+type Redis struct {
+	addr   string
+	client *redis.Client
+	sub    *redis.PubSub
+	out    chan []byte
+	cancel context.CancelFunc
+}
+
+// NewRedis connects to the server at rawURL, e.g. redis://:secret@host:6379
+// or rediss:// for TLS, and starts the subscription.
+func NewRedis(ctx context.Context, rawURL string) (*Redis, error) {
+	opts, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid REDIS_URL %q: %w", rawURL, err)
+	}
+	client := redis.NewClient(opts)
+	// Subscribe up front so a bad URL or password fails at startup.
+	sub := client.Subscribe(ctx, Channel)
+	if _, err := sub.Receive(ctx); err != nil {
+		sub.Close()
+		client.Close()
+		return nil, fmt.Errorf("redis subscribe: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	r := &Redis{addr: opts.Addr, client: client, sub: sub, out: make(chan []byte, messageBuffer), cancel: cancel}
+	go r.listen(ctx)
+	return r, nil
+}
+
+func (r *Redis) Publish(ctx context.Context, data []byte) error {
+	return r.client.Publish(ctx, Channel, data).Err()
+}
+
+func (r *Redis) Messages() <-chan []byte {
+	return r.out
+}
+
+func (r *Redis) Close() error {
+	r.cancel()
+	r.sub.Close()
+	return r.client.Close()
+}
+
+func (r *Redis) listen(ctx context.Context) {
+	defer close(r.out)
+	messages := r.sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			deliver(r.out, []byte(msg.Payload))
+		}
+	}
+}
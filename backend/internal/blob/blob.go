@@ -0,0 +1,107 @@
+// Package blob provides the store.BlobStore implementations used for
+// uploaded binary files: a directory on local disk and any S3-compatible
+// object store (AWS S3, MinIO, ...).
+package blob
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"project-meetings/backend/internal/store"
+)
+
+// FromEnv builds the blob store selected by BLOB_STORE: "local" (the
+// default) keeps blobs under BLOB_DIR, "s3" uses S3_ENDPOINT, S3_REGION,
+// S3_BUCKET, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY.
+func FromEnv(ctx context.Context) (store.BlobStore, error) {
+	switch kind := os.Getenv("BLOB_STORE"); kind {
+	case "", "local":
+		dir := os.Getenv("BLOB_DIR")
+		if dir == "" {
+			dir = "data/blobs"
+		}
+		log.Printf("[Blob] Storing uploads in %s", dir)
+		return NewLocal(dir)
+	case "s3":
+		cfg := S3Config{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Region:          os.Getenv("S3_REGION"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+		}
+		s, err := NewS3(cfg)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.EnsureBucket(ctx); err != nil {
+			return nil, err
+		}
+		log.Printf("[Blob] Storing uploads in bucket %s at %s", cfg.Bucket, s.endpoint.Host)
+		return s, nil
+	default:
+		return nil, fmt.Errorf("unknown BLOB_STORE %q, expected local or s3", kind)
+	}
+}
+
+// validKey rejects keys that could escape the store's namespace.
+func validKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") {
+		return false
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+const sweepBatchSize = 100
+
+// SweepOrphans removes blobs whose files are gone, every interval until ctx
+// is done. Blobs that fail to delete stay queued for the next round.
+func SweepOrphans(ctx context.Context, files store.FileStore, blobs store.BlobStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		sweepOnce(ctx, files, blobs)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func sweepOnce(ctx context.Context, files store.FileStore, blobs store.BlobStore) {
+	for {
+		keys, err := files.OrphanedBlobs(ctx, sweepBatchSize)
+		if err != nil {
+			log.Printf("[Blob] Failed to list orphaned blobs: %v", err)
+			return
+		}
+		deleted := make([]string, 0, len(keys))
+		for _, key := range keys {
+			if err := blobs.Delete(ctx, key); err != nil {
+				log.Printf("[Blob] Failed to delete orphaned blob %s: %v", key, err)
+				continue
+			}
+			deleted = append(deleted, key)
+		}
+		if len(deleted) > 0 {
+			if err := files.ForgetOrphanedBlobs(ctx, deleted); err != nil {
+				log.Printf("[Blob] Failed to forget deleted blobs: %v", err)
+				return
+			}
+		}
+		// A short or failing batch means we're done for this round.
+		if len(keys) < sweepBatchSize || len(deleted) < len(keys) {
+			return
+		}
+	}
+}
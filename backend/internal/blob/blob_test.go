@@ -0,0 +1,140 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"project-meetings/backend/internal/store"
+)
+
+func TestLocal(t *testing.T) {
+	ctx := context.Background()
+	local, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := local.Put(ctx, "project/file.bin", strings.NewReader("contents"), 8, "application/octet-stream"); err != nil {
+		t.Fatal(err)
+	}
+	r, err := local.Open(ctx, "project/file.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != "contents" {
+		t.Errorf("read %q", data)
+	}
+
+	// A short body fails and leaves nothing behind.
+	if err := local.Put(ctx, "project/short.bin", strings.NewReader("abc"), 10, ""); err == nil {
+		t.Error("short Put succeeded")
+	}
+	if _, err := local.Open(ctx, "project/short.bin"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Open after short Put: %v", err)
+	}
+
+	if err := local.Delete(ctx, "project/file.bin"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := local.Open(ctx, "project/file.bin"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Open after Delete: %v", err)
+	}
+	if err := local.Delete(ctx, "project/file.bin"); err != nil {
+		t.Errorf("deleting a missing blob: %v", err)
+	}
+	if err := local.Put(ctx, "../escape", strings.NewReader("x"), 1, ""); err == nil {
+		t.Error("Put with an escaping key succeeded")
+	}
+}
+
+func TestValidKey(t *testing.T) {
+	for key, want := range map[string]bool{
+		"project/file.bin": true,
+		"a/b/c":            true,
+		"":                 false,
+		"/etc/passwd":      false,
+		"../secret":        false,
+		"a/../../b":        false,
+		"a/./b":            false,
+		"a//b":             false,
+		"a/":               false,
+		"..":               false,
+	} {
+		if got := validKey(key); got != want {
+			t.Errorf("validKey(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
+
+// orphanFiles is the part of a FileStore the sweeper uses.
+type orphanFiles struct {
+	store.FileStore
+	orphans   []string
+	forgotten []string
+}
+
+func (f *orphanFiles) OrphanedBlobs(ctx context.Context, limit int) ([]string, error) {
+	keys := f.orphans
+	if len(keys) > limit {
+		keys = keys[:limit]
+	}
+	return append([]string(nil), keys...), nil
+}
+
+func (f *orphanFiles) ForgetOrphanedBlobs(ctx context.Context, keys []string) error {
+	forget := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		forget[key] = true
+	}
+	remaining := f.orphans[:0]
+	for _, key := range f.orphans {
+		if !forget[key] {
+			remaining = append(remaining, key)
+		}
+	}
+	f.orphans = remaining
+	f.forgotten = append(f.forgotten, keys...)
+	return nil
+}
+
+// flakyBlobs fails to delete the keys in failing.
+type flakyBlobs struct {
+	store.BlobStore
+	failing map[string]bool
+	deleted []string
+}
+
+func (b *flakyBlobs) Delete(ctx context.Context, key string) error {
+	if b.failing[key] {
+		return errors.New("unavailable")
+	}
+	b.deleted = append(b.deleted, key)
+	return nil
+}
+
+func TestSweepOnce(t *testing.T) {
+	ctx := context.Background()
+	files := &orphanFiles{}
+	for i := 0; i < sweepBatchSize*2+5; i++ {
+		files.orphans = append(files.orphans, fmt.Sprintf("blob%03d", i))
+	}
+	blobs := &flakyBlobs{}
+	sweepOnce(ctx, files, blobs)
+	if len(files.orphans) != 0 || len(blobs.deleted) != sweepBatchSize*2+5 || len(files.forgotten) != len(blobs.deleted) {
+		t.Fatalf("left %d orphans, deleted %d, forgot %d", len(files.orphans), len(blobs.deleted), len(files.forgotten))
+	}
+
+	// Blobs that fail to delete stay listed, and the round stops there.
+	files.orphans = []string{"a", "b", "c"}
+	files.forgotten = nil
+	blobs.failing = map[string]bool{"b": true}
+	sweepOnce(ctx, files, blobs)
+	if len(files.orphans) != 1 || files.orphans[0] != "b" || len(files.forgotten) != 2 {
+		t.Fatalf("orphans %v, forgotten %v", files.orphans, files.forgotten)
+	}
+}
@@ -0,0 +1,37 @@
+// Package dbtest gives tests a migrated Postgres database. Tests that use it
+// are skipped unless TEST_DATABASE_URL names a database they may write to.
+package dbtest
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+
+	"project-meetings/backend/internal/database"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var migrate sync.Once
+
+// Pool connects to TEST_DATABASE_URL and brings its schema up to date, or
+// skips the test when it is not set. The pool is closed with the test.
+func Pool(t testing.TB) *pgxpool.Pool {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	pool, err := pgxpool.New(context.Background(), url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+	var migrateErr error
+	migrate.Do(func() { _, migrateErr = database.MigrateUp(context.Background(), pool) })
+	if migrateErr != nil {
+		t.Fatalf("migrate test database: %v", migrateErr)
+	}
+	return pool
+}
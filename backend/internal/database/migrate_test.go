@@ -0,0 +1,94 @@
+package database
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// The baseline must stay idempotent to adopt hand-made schemas.
+func TestBaselineIsIdempotent(t *testing.T) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if migrations[0].Version != 1 {
+		t.Fatalf("first migration is %d_%s", migrations[0].Version, migrations[0].Name)
+	}
+	strict := regexp.MustCompile(`(?i)CREATE\s+(UNIQUE\s+)?(TABLE|INDEX)\s+(\w+)`)
+	for _, match := range strict.FindAllStringSubmatch(migrations[0].up, -1) {
+		if match[3] != "IF" {
+			t.Errorf("%s fails on an existing schema", match[0])
+		}
+	}
+}
+
+// TestMigrateExistingSchema migrates a schema made before migrations existed,
+// in a schema of its own so the shared test database is left alone.
+func TestMigrateExistingSchema(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	ctx := context.Background()
+	admin, err := pgxpool.New(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+	schema := "legacy_" + uuid.NewString()[:8]
+	if _, err := admin.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Exec(ctx, "DROP SCHEMA "+schema+" CASCADE")
+
+	config, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// public stays on the path for extensions installed there.
+	config.ConnConfig.RuntimeParams["search_path"] = schema + ", public"
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	// A hand-made deployment: the core tables, some data, no record of it.
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pool.Exec(ctx, migrations[0].up); err != nil {
+		t.Fatal(err)
+	}
+	_, err = pool.Exec(ctx, `INSERT INTO users (email, username, password_hash) VALUES ('old@example.com', 'old', 'hash')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := MigrateUp(ctx, pool)
+	if err != nil {
+		t.Fatalf("migrate existing schema: %v", err)
+	}
+	if applied != len(migrations) {
+		t.Errorf("applied %d migrations, want %d", applied, len(migrations))
+	}
+	var users int
+	if err := pool.QueryRow(ctx, `SELECT COUNT(*) FROM users`).Scan(&users); err != nil || users != 1 {
+		t.Errorf("%d users after migrating, %v", users, err)
+	}
+	statuses, err := MigrationStatuses(ctx, pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			t.Errorf("%d_%s not applied", status.Version, status.Name)
+		}
+	}
+}
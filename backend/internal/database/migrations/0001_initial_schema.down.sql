@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS whiteboard_shapes;
+DROP TABLE IF EXISTS files;
+DROP TABLE IF EXISTS project_invites;
+DROP TABLE IF EXISTS project_members;
+DROP TABLE IF EXISTS projects;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS email_change_requests;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS timezone,
+    DROP COLUMN IF EXISTS avatar_url,
+    DROP COLUMN IF EXISTS display_name;
@@ -0,0 +1,15 @@
+-- Profile fields and verified email changes.
+
+ALTER TABLE users
+    ADD COLUMN display_name TEXT NOT NULL DEFAULT '',
+    ADD COLUMN avatar_url   TEXT NOT NULL DEFAULT '',
+    ADD COLUMN timezone     TEXT NOT NULL DEFAULT 'UTC';
+
+-- At most one pending change per user; a new request replaces the old one.
+CREATE TABLE email_change_requests (
+    user_id    UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    new_email  TEXT NOT NULL,
+    token_hash TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
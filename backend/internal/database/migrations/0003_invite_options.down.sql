@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS project_invites_project_id_idx;
+
+ALTER TABLE project_invites
+    DROP COLUMN IF EXISTS revoked_at,
+    DROP COLUMN IF EXISTS expires_at,
+    DROP COLUMN IF EXISTS email,
+    DROP COLUMN IF EXISTS use_count,
+    DROP COLUMN IF EXISTS max_uses,
+    DROP COLUMN IF EXISTS role;
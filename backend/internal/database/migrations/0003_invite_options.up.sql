@@ -0,0 +1,11 @@
+-- Invites carry a role, usage limits, an optional expiry and recipient.
+
+ALTER TABLE project_invites
+    ADD COLUMN role       TEXT NOT NULL DEFAULT 'editor',
+    ADD COLUMN max_uses   INTEGER CHECK (max_uses IS NULL OR max_uses > 0),
+    ADD COLUMN use_count  INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN email      TEXT,
+    ADD COLUMN expires_at TIMESTAMPTZ,
+    ADD COLUMN revoked_at TIMESTAMPTZ;
+
+CREATE INDEX project_invites_project_id_idx ON project_invites (project_id);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS file_acl_rules;
+DROP TABLE IF EXISTS project_roles;
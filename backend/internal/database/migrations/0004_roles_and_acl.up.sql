@@ -0,0 +1,21 @@
+-- Custom project roles and folder-level access overrides.
+
+CREATE TABLE project_roles (
+    project_id  UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    name        TEXT NOT NULL,
+    permissions TEXT[] NOT NULL DEFAULT '{}',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (project_id, name)
+);
+
+CREATE TABLE file_acl_rules (
+    id           UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    project_id   UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    file_id      UUID NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+    subject_type TEXT NOT NULL CHECK (subject_type IN ('role', 'user')),
+    subject      TEXT NOT NULL,
+    access       TEXT NOT NULL CHECK (access IN ('none', 'read', 'write')),
+    UNIQUE (file_id, subject_type, subject)
+);
+
+CREATE INDEX file_acl_rules_project_id_idx ON file_acl_rules (project_id);
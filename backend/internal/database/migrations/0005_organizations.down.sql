@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS projects_organization_id_idx;
+ALTER TABLE projects DROP COLUMN IF EXISTS organization_id;
+DROP TABLE IF EXISTS organization_members;
+DROP TABLE IF EXISTS organizations;
@@ -0,0 +1,17 @@
+-- Append-only record of security-relevant and content events. Project and
+-- actor are kept as plain IDs so entries survive deletion of either.
+
+CREATE TABLE audit_log (
+    id          BIGSERIAL PRIMARY KEY,
+    project_id  UUID,
+    actor_id    UUID,
+    action      TEXT NOT NULL,
+    target_type TEXT NOT NULL DEFAULT '',
+    target_id   TEXT NOT NULL DEFAULT '',
+    ip          TEXT NOT NULL DEFAULT '',
+    user_agent  TEXT NOT NULL DEFAULT '',
+    details     JSONB NOT NULL DEFAULT '{}',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX audit_log_project_id_idx ON audit_log (project_id, id DESC);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS files_content_trgm_idx;
+DROP INDEX IF EXISTS files_content_fts_idx;
+-- pg_trgm is left installed; other database objects may rely on it.
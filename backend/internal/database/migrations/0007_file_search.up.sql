@@ -0,0 +1,7 @@
+-- Indexes behind project search: a full-text index for word queries and a
+-- trigram index so substring (ILIKE) and regex (~) scans can skip files.
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX files_content_fts_idx ON files USING GIN (to_tsvector('simple', COALESCE(content, '')));
+CREATE INDEX files_content_trgm_idx ON files USING GIN (content gin_trgm_ops);
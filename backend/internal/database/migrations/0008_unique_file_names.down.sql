@@ -0,0 +1 @@
+DROP INDEX IF EXISTS files_sibling_name_idx;
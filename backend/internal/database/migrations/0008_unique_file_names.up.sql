@@ -0,0 +1,13 @@
+-- Paths are resolved by name through the parent_id chain, so sibling names
+-- must be unique. Existing duplicates get the node ID appended first.
+
+UPDATE files f
+SET name = f.name || ' (' || f.id || ')'
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY project_id, parent_id, name ORDER BY created_at, id) AS n
+    FROM files
+) dup
+WHERE dup.id = f.id AND dup.n > 1;
+
+CREATE UNIQUE INDEX files_sibling_name_idx
+    ON files (project_id, COALESCE(parent_id, '00000000-0000-0000-0000-000000000000'::uuid), name);
@@ -0,0 +1,10 @@
+DROP TRIGGER IF EXISTS files_orphan_blob ON files;
+DROP FUNCTION IF EXISTS files_orphan_blob();
+DROP TABLE IF EXISTS orphaned_blobs;
+DROP INDEX IF EXISTS files_project_blobs_idx;
+
+ALTER TABLE files
+    DROP COLUMN IF EXISTS sha256,
+    DROP COLUMN IF EXISTS size,
+    DROP COLUMN IF EXISTS content_type,
+    DROP COLUMN IF EXISTS blob_key;
@@ -0,0 +1,30 @@
+-- Binary files keep their bytes in blob storage; the row holds the key and
+-- what we know about the content. Text files leave these columns NULL.
+
+ALTER TABLE files
+    ADD COLUMN blob_key     TEXT,
+    ADD COLUMN content_type TEXT,
+    ADD COLUMN size         BIGINT,
+    ADD COLUMN sha256       TEXT;
+
+CREATE INDEX files_project_blobs_idx ON files (project_id) WHERE blob_key IS NOT NULL;
+
+-- Blobs whose row went away, through a delete, a cascade or a replacement.
+-- The server removes them from blob storage in the background.
+CREATE TABLE orphaned_blobs (
+    blob_key    TEXT PRIMARY KEY,
+    orphaned_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE FUNCTION files_orphan_blob() RETURNS trigger AS $$
+BEGIN
+    IF OLD.blob_key IS NOT NULL AND (TG_OP = 'DELETE' OR NEW.blob_key IS DISTINCT FROM OLD.blob_key) THEN
+        INSERT INTO orphaned_blobs (blob_key) VALUES (OLD.blob_key) ON CONFLICT DO NOTHING;
+    END IF;
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER files_orphan_blob
+    AFTER UPDATE OF blob_key OR DELETE ON files
+    FOR EACH ROW EXECUTE FUNCTION files_orphan_blob();
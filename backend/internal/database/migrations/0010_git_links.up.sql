@@ -0,0 +1,10 @@
+-- Projects imported from a git repository remember where they came from and
+-- which commit their files match. The repository itself lives on disk.
+
+CREATE TABLE project_git_links (
+    project_id UUID PRIMARY KEY REFERENCES projects(id) ON DELETE CASCADE,
+    remote_url TEXT NOT NULL,
+    branch     TEXT NOT NULL,
+    head       TEXT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,4 @@
+-- Every change to a file or folder bumps its revision, which the REST API
+-- exposes as an ETag for conditional writes.
+
+ALTER TABLE files ADD COLUMN revision BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,4 @@
+-- A fork remembers the project it was copied from, for as long as that
+-- project exists.
+
+ALTER TABLE projects ADD COLUMN forked_from UUID REFERENCES projects(id) ON DELETE SET NULL;
@@ -0,0 +1 @@
+ALTER TABLE projects DROP COLUMN IF EXISTS is_template;
@@ -0,0 +1,3 @@
+-- Owners can offer a project as a template for new projects.
+
+ALTER TABLE projects ADD COLUMN is_template BOOLEAN NOT NULL DEFAULT FALSE;
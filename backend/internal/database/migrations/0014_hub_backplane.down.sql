@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS backplane_messages;
+DROP TRIGGER IF EXISTS files_discard_draft ON files;
+DROP FUNCTION IF EXISTS files_discard_draft();
+DROP TABLE IF EXISTS file_drafts;
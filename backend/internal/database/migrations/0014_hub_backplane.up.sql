@@ -0,0 +1,30 @@
+-- Backend instances share live editor state through the database: unsaved
+-- editor contents are kept as drafts, and hub events too large for a NOTIFY
+-- payload are parked here for the listeners to read.
+
+CREATE TABLE file_drafts (
+    file_id UUID PRIMARY KEY REFERENCES files(id) ON DELETE CASCADE,
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    content TEXT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX file_drafts_project_idx ON file_drafts (project_id);
+
+-- Saving a file supersedes its draft.
+CREATE FUNCTION files_discard_draft() RETURNS trigger AS $$
+BEGIN
+    DELETE FROM file_drafts WHERE file_id = NEW.id;
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER files_discard_draft
+    AFTER UPDATE OF content, blob_key ON files
+    FOR EACH ROW EXECUTE FUNCTION files_discard_draft();
+
+CREATE TABLE backplane_messages (
+    id BIGSERIAL PRIMARY KEY,
+    data BYTEA NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
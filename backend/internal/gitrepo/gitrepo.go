@@ -0,0 +1,490 @@
+// Package gitrepo keeps a bare git repository per project on local disk and
+// moves file trees in and out of it. It uses go-git throughout, including for
+// local remotes, so the server never shells out to a git binary.
+package gitrepo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/server"
+)
+
+func init() {
+	// Serve file:// and plain-path remotes in process instead of running
+	// git-upload-pack and git-receive-pack.
+	client.InstallProtocol("file", server.DefaultServer)
+	// Keep http(s) remotes off the server's own networks.
+	guarded := githttp.NewClient(&http.Client{Transport: guardedTransport()})
+	client.InstallProtocol("http", guarded)
+	client.InstallProtocol("https", guarded)
+}
+
+var (
+	// ErrRemoteNotAllowed is returned for remotes whose protocol the server
+	// does not accept.
+	ErrRemoteNotAllowed = errors.New("remote not allowed")
+	// ErrBranchNotFound is returned when the remote has no such branch.
+	ErrBranchNotFound = errors.New("branch not found")
+	// ErrNothingToCommit is returned when a commit would not change the tree.
+	ErrNothingToCommit = errors.New("nothing to commit")
+)
+
+const remoteName = "origin"
+
+// Repos manages the repositories below one directory.
+type Repos struct {
+	dir          string
+	allowLocal   bool
+	allowPrivate bool
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// FromEnv keeps repositories under GIT_REPO_DIR (data/repos by default).
+// Remotes on the server's own filesystem are refused unless
+// GIT_ALLOW_LOCAL_REMOTES is true, and http(s) remotes at loopback,
+// link-local or private addresses unless GIT_ALLOW_PRIVATE_REMOTES is true.
+func FromEnv() (*Repos, error) {
+	dir := os.Getenv("GIT_REPO_DIR")
+	if dir == "" {
+		dir = "data/repos"
+	}
+	return New(dir, os.Getenv("GIT_ALLOW_LOCAL_REMOTES") == "true", os.Getenv("GIT_ALLOW_PRIVATE_REMOTES") == "true")
+}
+
+func New(dir string, allowLocal, allowPrivate bool) (*Repos, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("create repository directory: %w", err)
+	}
+	return &Repos{dir: dir, allowLocal: allowLocal, allowPrivate: allowPrivate, locks: make(map[string]*sync.Mutex)}, nil
+}
+
+// Lock serializes git operations on a project. Call the returned function
+// to release it.
+func (r *Repos) Lock(projectID string) func() {
+	r.mu.Lock()
+	l, ok := r.locks[projectID]
+	if !ok {
+		l = &sync.Mutex{}
+		r.locks[projectID] = l
+	}
+	r.mu.Unlock()
+	l.Lock()
+	return l.Unlock
+}
+
+// CheckRemote accepts http(s) remotes, and local paths when allowed.
+// Credentials for a private remote go in the URL's user info. Whether an
+// http(s) remote is at an address the server may reach is only known once
+// it is dialed.
+func (r *Repos) CheckRemote(remote string) error {
+	ep, err := transport.NewEndpoint(remote)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrRemoteNotAllowed, err)
+	}
+	switch ep.Protocol {
+	case "http", "https":
+		return nil
+	case "file":
+		if r.allowLocal {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s remotes are not supported", ErrRemoteNotAllowed, ep.Protocol)
+}
+
+func (r *Repos) path(projectID string) string {
+	return filepath.Join(r.dir, projectID+".git")
+}
+
+// Clone fetches branch, or the remote's default branch when it is empty,
+// into a fresh repository for the project that replaces any earlier one.
+func (r *Repos) Clone(ctx context.Context, projectID, remote, branch string) (*Repo, error) {
+	if err := r.CheckRemote(remote); err != nil {
+		return nil, err
+	}
+	tmp, err := os.MkdirTemp(r.dir, ".clone-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	opts := &git.CloneOptions{URL: remote, RemoteName: remoteName, SingleBranch: true}
+	if branch != "" {
+		opts.ReferenceName = plumbing.NewBranchReferenceName(branch)
+	}
+	repo, err := git.PlainCloneContext(remoteContext(ctx, r.allowPrivate), tmp, true, opts)
+	if err != nil {
+		if errors.Is(err, plumbing.ErrReferenceNotFound) || errors.Is(err, git.NoMatchingRefSpecError{}) {
+			return nil, ErrBranchNotFound
+		}
+		return nil, err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		// An empty remote has no commits to import.
+		return nil, ErrBranchNotFound
+	}
+	if !head.Name().IsBranch() {
+		return nil, ErrBranchNotFound
+	}
+
+	dest := r.path(projectID)
+	if err := os.RemoveAll(dest); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		return nil, err
+	}
+	repo, err = git.PlainOpen(dest)
+	if err != nil {
+		return nil, err
+	}
+	return &Repo{repo: repo, Branch: head.Name().Short(), allowPrivate: r.allowPrivate}, nil
+}
+
+// Open opens a project's repository. If it is missing from disk, for example
+// on a new server, branch is cloned again from remote.
+func (r *Repos) Open(ctx context.Context, projectID, remote, branch string) (*Repo, error) {
+	repo, err := git.PlainOpen(r.path(projectID))
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		log.Printf("[Git] Repository for project %s is missing, cloning %s again", projectID, Redact(remote))
+		return r.Clone(ctx, projectID, remote, branch)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Repo{repo: repo, Branch: branch, allowPrivate: r.allowPrivate}, nil
+}
+
+// Redact hides the password in a remote URL, for responses and logs.
+func Redact(remote string) string {
+	u, err := url.Parse(remote)
+	if err != nil || u.User == nil {
+		return remote
+	}
+	return u.Redacted()
+}
+
+// Repo is one project's repository, working on a single branch.
+type Repo struct {
+	repo         *git.Repository
+	Branch       string
+	allowPrivate bool
+}
+
+// File is a regular file in a commit.
+type File struct {
+	Path string
+	// Hash is the git object ID of the content.
+	Hash string
+	Size int64
+	blob *object.Blob
+}
+
+// Open returns the file's content.
+func (f File) Open() (io.ReadCloser, error) {
+	return f.blob.Reader()
+}
+
+// ReadAll returns a file's whole content.
+func (f File) ReadAll() ([]byte, error) {
+	rd, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rd.Close()
+	var buf bytes.Buffer
+	buf.Grow(int(f.Size))
+	_, err = io.Copy(&buf, rd)
+	return buf.Bytes(), err
+}
+
+// Head returns the commit the local branch points at.
+func (r *Repo) Head() (string, error) {
+	ref, err := r.repo.Reference(plumbing.NewBranchReferenceName(r.Branch), true)
+	if err != nil {
+		return "", err
+	}
+	return ref.Hash().String(), nil
+}
+
+// SetHead moves the local branch to commit.
+func (r *Repo) SetHead(commit string) error {
+	ref := plumbing.NewHashReference(plumbing.NewBranchReferenceName(r.Branch), plumbing.NewHash(commit))
+	return r.repo.Storer.SetReference(ref)
+}
+
+// entry is any non-tree entry of a commit's tree: a file, a symlink or a
+// submodule.
+type entry struct {
+	mode filemode.FileMode
+	hash plumbing.Hash
+}
+
+func (r *Repo) entries(commit string) (map[string]entry, error) {
+	c, err := r.repo.CommitObject(plumbing.NewHash(commit))
+	if err != nil {
+		return nil, err
+	}
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+	entries := make(map[string]entry)
+	walker := object.NewTreeWalker(tree, true, nil)
+	defer walker.Close()
+	for {
+		name, e, err := walker.Next()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if e.Mode != filemode.Dir {
+			entries[name] = entry{mode: e.Mode, hash: e.Hash}
+		}
+	}
+}
+
+// Files returns the regular files of a commit, ordered by path. Symlinks and
+// submodules are left out.
+func (r *Repo) Files(commit string) ([]File, error) {
+	entries, err := r.entries(commit)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]File, 0, len(entries))
+	for p, e := range entries {
+		if e.mode != filemode.Regular && e.mode != filemode.Executable && e.mode != filemode.Deprecated {
+			continue
+		}
+		blob, err := r.repo.BlobObject(e.hash)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, File{Path: p, Hash: e.hash.String(), Size: blob.Size, blob: blob})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// BlobHash returns the git object ID content would get.
+func BlobHash(content []byte) string {
+	return plumbing.ComputeHash(plumbing.BlobObject, content).String()
+}
+
+// WriteBlob stores size bytes from rd as a blob and returns its object ID.
+func (r *Repo) WriteBlob(rd io.Reader, size int64) (string, error) {
+	obj := r.repo.Storer.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	obj.SetSize(size)
+	w, err := obj.Writer()
+	if err != nil {
+		return "", err
+	}
+	n, err := io.Copy(w, io.LimitReader(rd, size))
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	if n != size {
+		return "", fmt.Errorf("blob: read %d of %d bytes", n, size)
+	}
+	hash, err := r.repo.Storer.SetEncodedObject(obj)
+	return hash.String(), err
+}
+
+// Signature names the author of a commit.
+type Signature struct {
+	Name  string
+	Email string
+}
+
+// Commit records files (path -> blob ID, as returned by WriteBlob) as a new
+// commit on top of parent and moves the branch to it. Paths keep the mode
+// they had in parent; symlinks and submodules of parent are carried over.
+func (r *Repo) Commit(parent string, files map[string]string, message string, author Signature) (string, error) {
+	old, err := r.entries(parent)
+	if err != nil {
+		return "", err
+	}
+	entries := make(map[string]entry, len(files))
+	for p, hash := range files {
+		mode := filemode.Regular
+		if e, ok := old[p]; ok && e.mode == filemode.Executable {
+			mode = filemode.Executable
+		}
+		entries[p] = entry{mode: mode, hash: plumbing.NewHash(hash)}
+	}
+	for p, e := range old {
+		if e.mode == filemode.Symlink || e.mode == filemode.Submodule {
+			if _, ok := entries[p]; !ok {
+				entries[p] = e
+			}
+		}
+	}
+
+	treeHash, err := r.writeTree(entries)
+	if err != nil {
+		return "", err
+	}
+	parentCommit, err := r.repo.CommitObject(plumbing.NewHash(parent))
+	if err != nil {
+		return "", err
+	}
+	if parentCommit.TreeHash == treeHash {
+		return "", ErrNothingToCommit
+	}
+
+	sig := object.Signature{Name: author.Name, Email: author.Email, When: time.Now()}
+	commit := &object.Commit{
+		Author:       sig,
+		Committer:    sig,
+		Message:      message,
+		TreeHash:     treeHash,
+		ParentHashes: []plumbing.Hash{parentCommit.Hash},
+	}
+	obj := r.repo.Storer.NewEncodedObject()
+	if err := commit.Encode(obj); err != nil {
+		return "", err
+	}
+	hash, err := r.repo.Storer.SetEncodedObject(obj)
+	if err != nil {
+		return "", err
+	}
+	if err := r.SetHead(hash.String()); err != nil {
+		return "", err
+	}
+	return hash.String(), nil
+}
+
+// writeTree stores the trees for a flat path -> entry map and returns the
+// root tree's ID.
+func (r *Repo) writeTree(entries map[string]entry) (plumbing.Hash, error) {
+	type dir struct {
+		entries []object.TreeEntry
+		subdirs map[string]bool
+	}
+	dirs := map[string]*dir{"": {subdirs: map[string]bool{}}}
+	var ensure func(p string) *dir
+	ensure = func(p string) *dir {
+		if d, ok := dirs[p]; ok {
+			return d
+		}
+		d := &dir{subdirs: map[string]bool{}}
+		dirs[p] = d
+		parent, name := path.Split(p)
+		parent = strings.TrimSuffix(parent, "/")
+		ensure(parent).subdirs[name] = true
+		return d
+	}
+	for p, e := range entries {
+		parent, name := path.Split(p)
+		d := ensure(strings.TrimSuffix(parent, "/"))
+		d.entries = append(d.entries, object.TreeEntry{Name: name, Mode: e.mode, Hash: e.hash})
+	}
+
+	var write func(p string) (plumbing.Hash, error)
+	write = func(p string) (plumbing.Hash, error) {
+		d := dirs[p]
+		for name := range d.subdirs {
+			hash, err := write(path.Join(p, name))
+			if err != nil {
+				return plumbing.ZeroHash, err
+			}
+			d.entries = append(d.entries, object.TreeEntry{Name: name, Mode: filemode.Dir, Hash: hash})
+		}
+		// Git orders tree entries by name, comparing a directory as if its
+		// name ended in a slash.
+		sortKey := func(e object.TreeEntry) string {
+			if e.Mode == filemode.Dir {
+				return e.Name + "/"
+			}
+			return e.Name
+		}
+		sort.Slice(d.entries, func(i, j int) bool { return sortKey(d.entries[i]) < sortKey(d.entries[j]) })
+
+		obj := r.repo.Storer.NewEncodedObject()
+		if err := (&object.Tree{Entries: d.entries}).Encode(obj); err != nil {
+			return plumbing.ZeroHash, err
+		}
+		return r.repo.Storer.SetEncodedObject(obj)
+	}
+	return write("")
+}
+
+// Fetch updates the remote-tracking ref of the branch and returns the
+// commit the remote branch points at.
+func (r *Repo) Fetch(ctx context.Context) (string, error) {
+	remoteRef := plumbing.NewRemoteReferenceName(remoteName, r.Branch)
+	spec := config.RefSpec("+" + plumbing.NewBranchReferenceName(r.Branch).String() + ":" + remoteRef.String())
+	err := r.repo.FetchContext(remoteContext(ctx, r.allowPrivate), &git.FetchOptions{RemoteName: remoteName, RefSpecs: []config.RefSpec{spec}})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		if errors.Is(err, git.NoMatchingRefSpecError{}) {
+			return "", ErrBranchNotFound
+		}
+		return "", err
+	}
+	ref, err := r.repo.Reference(remoteRef, true)
+	if err != nil {
+		return "", ErrBranchNotFound
+	}
+	return ref.Hash().String(), nil
+}
+
+// IsAncestor reports whether commit ancestor is reachable from commit.
+func (r *Repo) IsAncestor(ancestor, commit string) (bool, error) {
+	if ancestor == commit {
+		return true, nil
+	}
+	a, err := r.repo.CommitObject(plumbing.NewHash(ancestor))
+	if err != nil {
+		return false, err
+	}
+	c, err := r.repo.CommitObject(plumbing.NewHash(commit))
+	if err != nil {
+		return false, err
+	}
+	return a.IsAncestor(c)
+}
+
+// Push sends the local branch to the remote. It reports whether anything
+// was pushed.
+func (r *Repo) Push(ctx context.Context) (bool, error) {
+	branch := plumbing.NewBranchReferenceName(r.Branch).String()
+	err := r.repo.PushContext(remoteContext(ctx, r.allowPrivate), &git.PushOptions{
+		RemoteName: remoteName,
+		RefSpecs:   []config.RefSpec{config.RefSpec(branch + ":" + branch)},
+	})
+	if errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,200 @@
+package gitrepo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// bareRemote returns a bare repository in a temporary directory holding one
+// commit with files.
+func bareRemote(t *testing.T, files map[string]string) string {
+	t.Helper()
+	seedDir := filepath.Join(t.TempDir(), "seed")
+	seed, err := git.PlainInit(seedDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt, err := seed.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for p, content := range files {
+		full := filepath.Join(seedDir, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := wt.Add(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sig := &object.Signature{Name: "seed", Email: "seed@example.com", When: time.Now()}
+	if _, err := wt.Commit("initial", &git.CommitOptions{Author: sig}); err != nil {
+		t.Fatal(err)
+	}
+	remoteDir := filepath.Join(t.TempDir(), "remote.git")
+	if _, err := git.PlainInit(remoteDir, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := seed.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{remoteDir}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := seed.Push(&git.PushOptions{RemoteName: "origin"}); err != nil {
+		t.Fatal(err)
+	}
+	return remoteDir
+}
+
+func TestCloneCommitPush(t *testing.T) {
+	ctx := context.Background()
+	remote := bareRemote(t, map[string]string{"README.md": "hello\n", "src/main.go": "package main\n"})
+
+	refused, err := New(t.TempDir(), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := refused.Clone(ctx, "p1", remote, ""); !errors.Is(err, ErrRemoteNotAllowed) {
+		t.Fatalf("local remote without permission: %v", err)
+	}
+
+	repos, err := New(t.TempDir(), true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repos.Clone(ctx, "p1", remote, "no-such-branch"); !errors.Is(err, ErrBranchNotFound) {
+		t.Fatalf("missing branch: %v", err)
+	}
+	repo, err := repos.Clone(ctx, "p1", remote, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := repo.Files(parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files[0].Path != "README.md" || files[1].Path != "src/main.go" {
+		t.Fatalf("files = %+v", files)
+	}
+	if content, _ := files[1].ReadAll(); string(content) != "package main\n" {
+		t.Errorf("src/main.go = %q", content)
+	}
+
+	// Export: README.md is kept, main.go changes and a file is added.
+	tree := map[string]string{"README.md": files[0].Hash}
+	for p, content := range map[string]string{"src/main.go": "package main\n\nfunc main() {}\n", "docs/new.txt": "new\n"} {
+		hash, err := repo.WriteBlob(strings.NewReader(content), int64(len(content)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hash != BlobHash([]byte(content)) {
+			t.Errorf("WriteBlob = %s, want %s", hash, BlobHash([]byte(content)))
+		}
+		tree[p] = hash
+	}
+	commit, err := repo.Commit(parent, tree, "Export", Signature{Name: "alice", Email: "alice@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Commit(commit, tree, "Again", Signature{Name: "alice"}); !errors.Is(err, ErrNothingToCommit) {
+		t.Errorf("unchanged commit: %v", err)
+	}
+	if pushed, err := repo.Push(ctx); err != nil || !pushed {
+		t.Fatalf("Push = %v, %v", pushed, err)
+	}
+	if pushed, err := repo.Push(ctx); err != nil || pushed {
+		t.Errorf("second Push = %v, %v", pushed, err)
+	}
+
+	// The remote now has the commit on its branch, on top of the old one.
+	bare, err := git.PlainOpen(remote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := bare.Reference(plumbing.NewBranchReferenceName(repo.Branch), true)
+	if err != nil || ref.Hash().String() != commit {
+		t.Fatalf("remote %s = %v, %v; want %s", repo.Branch, ref, err, commit)
+	}
+	if fetched, err := repo.Fetch(ctx); err != nil || fetched != commit {
+		t.Errorf("Fetch = %s, %v", fetched, err)
+	}
+	if ok, err := repo.IsAncestor(parent, commit); err != nil || !ok {
+		t.Errorf("IsAncestor = %v, %v", ok, err)
+	}
+	pushed, err := repo.Files(commit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, f := range pushed {
+		paths = append(paths, f.Path)
+	}
+	if strings.Join(paths, ",") != "README.md,docs/new.txt,src/main.go" {
+		t.Errorf("pushed files = %v", paths)
+	}
+}
+
+func TestPublicAddr(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"93.184.216.34":    true,
+		"2606:4700::1111":  true,
+		"127.0.0.1":        false,
+		"::1":              false,
+		"10.1.2.3":         false,
+		"172.16.0.1":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"fe80::1":          false,
+		"fd00::1":          false,
+		"100.64.0.1":       false,
+		"0.0.0.0":          false,
+		"::ffff:127.0.0.1": false,
+		"::ffff:8.8.8.8":   true,
+		"224.0.0.1":        false,
+		"255.255.255.255":  false,
+	} {
+		if got := publicAddr(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("publicAddr(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestPrivateRemotes(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	refused, err := New(t.TempDir(), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := refused.Clone(ctx, "p1", server.URL+"/repo.git", ""); !errors.Is(err, ErrRemoteNotAllowed) {
+		t.Fatalf("loopback remote: %v", err)
+	}
+
+	allowed, err := New(t.TempDir(), false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := allowed.Clone(ctx, "p1", server.URL+"/repo.git", ""); err == nil || errors.Is(err, ErrRemoteNotAllowed) {
+		t.Fatalf("loopback remote with permission: %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+package gitrepo
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// allowPrivateKey marks the context of a git operation whose http(s) remote
+// may be on a private network.
+type allowPrivateKey struct{}
+
+// sharedAddressSpace is the carrier-grade NAT range, which is not routable on
+// the internet but is not covered by netip.Addr.IsPrivate.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// remoteContext allows private remotes for ctx when allowPrivate is set.
+func remoteContext(ctx context.Context, allowPrivate bool) context.Context {
+	if !allowPrivate {
+		return ctx
+	}
+	return context.WithValue(ctx, allowPrivateKey{}, true)
+}
+
+// publicAddr reports whether ip is routable on the internet: not loopback,
+// link-local, private, unspecified or multicast.
+func publicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !sharedAddressSpace.Contains(ip)
+}
+
+// guardedTransport dials http(s) remotes only at public addresses, unless
+// the request's context allows private ones. The check is made on the
+// address actually dialed, so neither a redirect nor a name that resolves
+// differently on the second lookup gets around it. Proxies are not used, as
+// they would hide the address of the remote.
+func guardedTransport() *http.Transport {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	guarded := *dialer
+	guarded.Control = func(network, address string, _ syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		ip, err := netip.ParseAddr(host)
+		if err != nil || !publicAddr(ip) {
+			return fmt.Errorf("%w: %s is not a public address", ErrRemoteNotAllowed, host)
+		}
+		return nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		if allowed, _ := ctx.Value(allowPrivateKey{}).(bool); allowed {
+			return dialer.DialContext(ctx, network, address)
+		}
+		return guarded.DialContext(ctx, network, address)
+	}
+	return transport
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"project-meetings/backend/internal/acl"
+	"project-meetings/backend/internal/middleware"
+	"project-meetings/backend/internal/permissions"
+	"project-meetings/backend/internal/store"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// --- GET FILE ACL ---
+// Returns the overrides set directly on a node. Inherited rules are not
+// included; ask the ancestors for those.
+func (h *Handler) GetFileACL(w http.ResponseWriter, r *http.Request) {
+	fileID, err := uuid.Parse(chi.URLParam(r, "fileId"))
+	if err != nil {
+		http.Error(w, "Invalid file ID", http.StatusBadRequest)
+		return
+	}
+
+	rules, err := h.store.Files.Rules(r.Context(), []uuid.UUID{fileID})
+	if err != nil {
+		log.Printf("Failed to load access rules: %v", err)
+		http.Error(w, "Failed to retrieve access rules", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rules)
+}
+
+// --- SET FILE ACL ---
+// Replaces every override on a node with the rules in the request body.
+// An empty list removes all overrides, so the node inherits again.
+func (h *Handler) SetFileACL(w http.ResponseWriter, r *http.Request) {
+	fileID, err := uuid.Parse(chi.URLParam(r, "fileId"))
+	if err != nil {
+		http.Error(w, "Invalid file ID", http.StatusBadRequest)
+		return
+	}
+	projectIDStr, _ := r.Context().Value(middleware.ProjectIDKey).(string)
+
+	var req struct {
+		Rules []struct {
+			SubjectType string `json:"subjectType"`
+			Subject     string `json:"subject"`
+			Access      string `json:"access"`
+		} `json:"rules"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	for _, rule := range req.Rules {
+		if _, ok := acl.ParseAccess(rule.Access); !ok {
+			http.Error(w, "Access must be 'none', 'read' or 'write'", http.StatusBadRequest)
+			return
+		}
+		switch rule.SubjectType {
+		case acl.SubjectUser:
+			if _, err := uuid.Parse(rule.Subject); err != nil {
+				http.Error(w, "User rules need a valid user ID as subject", http.StatusBadRequest)
+				return
+			}
+		case acl.SubjectRole:
+			if rule.Subject == permissions.OwnerRole {
+				http.Error(w, "The owner's access cannot be restricted", http.StatusBadRequest)
+				return
+			}
+			if _, err := permissions.ForRole(r.Context(), h.store.Projects, projectIDStr, rule.Subject); err != nil {
+				http.Error(w, "Unknown role: "+rule.Subject, http.StatusBadRequest)
+				return
+			}
+		default:
+			http.Error(w, "subjectType must be 'role' or 'user'", http.StatusBadRequest)
+			return
+		}
+	}
+
+	rules := make([]acl.Rule, 0, len(req.Rules))
+	for _, rule := range req.Rules {
+		rules = append(rules, acl.Rule{SubjectType: rule.SubjectType, Subject: rule.Subject, Access: rule.Access})
+	}
+	if err := h.store.Files.ReplaceRules(r.Context(), projectIDStr, fileID, rules); err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			http.Error(w, "Duplicate rule for the same subject", http.StatusBadRequest)
+			return
+		}
+		log.Printf("Failed to replace access rules: %v", err)
+		http.Error(w, "Failed to update access rules", http.StatusInternalServerError)
+		return
+	}
+
+	// Connected clients cache what they may see; make them re-check.
+	h.hub.InvalidateFileAccess(projectIDStr)
+
+	h.auditLog(r, projectIDStr, "file.acl_update", "file", fileID.String(), map[string]interface{}{"rules": req.Rules})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// --- GET MY ACCESS ---
+// Lets the UI know whether to offer editing for a node.
+func (h *Handler) GetFileAccess(w http.ResponseWriter, r *http.Request) {
+	fileID, err := uuid.Parse(chi.URLParam(r, "fileId"))
+	if err != nil {
+		http.Error(w, "Invalid file ID", http.StatusBadRequest)
+		return
+	}
+
+	access, err := acl.LoadForFile(r.Context(), h.store.Files, fileID, aclSubject(r))
+	if err != nil {
+		log.Printf("Failed to load access rules: %v", err)
+		http.Error(w, "Failed to evaluate access", http.StatusInternalServerError)
+		return
+	}
+
+	level := access.AccessTo(fileID)
+	if level == acl.None {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"access": level.String()})
+}
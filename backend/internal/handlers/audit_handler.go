@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"project-meetings/backend/internal/audit"
+	"project-meetings/backend/internal/middleware"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 200
+)
+
+// auditLog records an action taken through the REST API by the current user.
+// A failed write is logged but never fails the request that caused it.
+func (h *Handler) auditLog(r *http.Request, projectID, action, targetType, targetID string, details map[string]interface{}) {
+	userID, _ := r.Context().Value(middleware.UserIDKey).(string)
+	err := h.store.Audit.Append(r.Context(), audit.Event{
+		ProjectID:  projectID,
+		ActorID:    userID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		IP:         audit.ClientIP(r),
+		UserAgent:  r.UserAgent(),
+		Details:    details,
+	})
+	if err != nil {
+		log.Printf("[Audit] Failed to record %s: %v", action, err)
+	}
+}
+
+// GetAuditLog lists a project's audit entries, newest first.
+// Filters: action (exact, or a prefix like "file.*"), actor, targetType,
+// targetId, since and until (RFC 3339). Pagination: limit and the opaque
+// cursor returned as nextCursor by the previous page.
+func (h *Handler) GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := audit.Filter{
+		ProjectID:  chi.URLParam(r, "projectId"),
+		Action:     q.Get("action"),
+		TargetType: q.Get("targetType"),
+		TargetID:   q.Get("targetId"),
+	}
+
+	if actor := q.Get("actor"); actor != "" {
+		if _, err := uuid.Parse(actor); err != nil {
+			http.Error(w, "Invalid actor ID", http.StatusBadRequest)
+			return
+		}
+		filter.ActorID = actor
+	}
+	for param, bound := range map[string]**time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if value := q.Get(param); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Invalid %s timestamp, expected RFC 3339", param), http.StatusBadRequest)
+				return
+			}
+			*bound = &t
+		}
+	}
+	if cursor := q.Get("cursor"); cursor != "" {
+		id, err := strconv.ParseInt(cursor, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		filter.Before = id
+	}
+
+	limit := defaultAuditPageSize
+	if value := q.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		if n > maxAuditPageSize {
+			n = maxAuditPageSize
+		}
+		limit = n
+	}
+	// Fetch one extra row to know whether there is another page.
+	filter.Limit = limit + 1
+
+	entries, err := h.store.Audit.List(r.Context(), filter)
+	if err != nil {
+		log.Printf("Failed to query audit log: %v", err)
+		http.Error(w, "Failed to retrieve audit log", http.StatusInternalServerError)
+		return
+	}
+
+	var nextCursor *string
+	if len(entries) > limit {
+		entries = entries[:limit]
+		c := strconv.FormatInt(entries[limit-1].ID, 10)
+		nextCursor = &c
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"entries":    entries,
+		"nextCursor": nextCursor,
+	})
+}
@@ -1,22 +1,17 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
 	"log"
 	"net/http"
-	"time"
 
 	"project-meetings/backend/internal/auth"
-	"project-meetings/backend/internal/database"
-	"project-meetings/backend/internal/models"
 
-	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
 // Handler for user registration
-func RegisterUser(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) RegisterUser(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Username string `json:"username"`
 		Email    string `json:"email"`
@@ -42,33 +37,20 @@ func RegisterUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Insert user into the database
-	query := `INSERT INTO users (username, email, password_hash) VALUES ($1, $2, $3) RETURNING id, created_at, updated_at`
-	var userID uuid.UUID
-	var createdAt, updatedAt time.Time
-
-	err = database.DB.QueryRow(context.Background(), query, req.Username, req.Email, string(hashedPassword)).Scan(&userID, &createdAt, &updatedAt)
+	newUser, err := h.store.Users.Create(r.Context(), req.Username, req.Email, string(hashedPassword))
 	if err != nil {
 		log.Printf("Failed to insert user: %v", err)
 		http.Error(w, "Email or username already exists", http.StatusConflict) // 409 Conflict
 		return
 	}
 
-	// Return the newly created user (without password hash)
-	newUser := models.User{
-		ID:        userID,
-		Username:  req.Username,
-		Email:     req.Email,
-		CreatedAt: createdAt,
-		UpdatedAt: updatedAt,
-	}
-
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(newUser)
 }
 
 // Handler for user login
-func LoginUser(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) LoginUser(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
@@ -80,9 +62,7 @@ func LoginUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Find user by email
-	var user models.User
-	query := `SELECT id, email, username, password_hash FROM users WHERE email = $1`
-	err := database.DB.QueryRow(context.Background(), query, req.Email).Scan(&user.ID, &user.Email, &user.Username, &user.PasswordHash)
+	user, err := h.store.Users.GetByEmail(r.Context(), req.Email)
 	if err != nil {
 		// User not found, but give a generic error for security
 		http.Error(w, "Invalid email or password", http.StatusUnauthorized)
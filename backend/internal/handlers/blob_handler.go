@@ -0,0 +1,419 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"project-meetings/backend/internal/models"
+	"project-meetings/backend/internal/store"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxUploadSize caps a whole upload request.
+	maxUploadSize = 100 << 20
+	// defaultStorageQuota applies when PROJECT_STORAGE_QUOTA_BYTES is unset.
+	defaultStorageQuota = 1 << 30
+)
+
+// storageQuota returns the number of blob bytes each project may store.
+func storageQuota() int64 {
+	if value := os.Getenv("PROJECT_STORAGE_QUOTA_BYTES"); value != "" {
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil && n >= 0 {
+			return n
+		}
+		log.Printf("Ignoring invalid PROJECT_STORAGE_QUOTA_BYTES %q", value)
+	}
+	return defaultStorageQuota
+}
+
+// spooledUpload is one uploaded file, buffered on disk so its size and hash
+// are known before it goes to blob storage.
+type spooledUpload struct {
+	file        *os.File
+	size        int64
+	sha256      string
+	contentType string
+}
+
+func (u *spooledUpload) Close() {
+	u.file.Close()
+	os.Remove(u.file.Name())
+}
+
+// spool copies a multipart file part to a temporary file. The content type
+// the client sent is kept unless it is missing or generic, in which case it
+// is sniffed from the first bytes.
+func spool(part *multipart.Part) (*spooledUpload, error) {
+	tmp, err := os.CreateTemp("", "upload-*")
+	if err != nil {
+		return nil, err
+	}
+	u := &spooledUpload{file: tmp}
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), part)
+	if err != nil {
+		u.Close()
+		return nil, err
+	}
+	u.size = size
+	u.sha256 = hex.EncodeToString(hash.Sum(nil))
+
+	u.contentType = part.Header.Get("Content-Type")
+	if u.contentType == "" || u.contentType == "application/octet-stream" {
+		head := make([]byte, 512)
+		n, _ := tmp.ReadAt(head, 0)
+		u.contentType = http.DetectContentType(head[:n])
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		u.Close()
+		return nil, err
+	}
+	return u, nil
+}
+
+// storeUpload writes a spooled upload to blob storage under a fresh key.
+func (h *Handler) storeUpload(r *http.Request, projectID uuid.UUID, u *spooledUpload) (models.Blob, error) {
+	blob := models.Blob{
+		Key:         fmt.Sprintf("%s/%s", projectID, uuid.New()),
+		ContentType: u.contentType,
+		Size:        u.size,
+		SHA256:      u.sha256,
+	}
+	return blob, h.store.Blobs.Put(r.Context(), blob.Key, u.file, u.size, u.contentType)
+}
+
+// uploadError maps a failed upload to a response.
+func uploadError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		http.Error(w, fmt.Sprintf("Upload exceeds the %d byte limit", maxUploadSize), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, store.ErrQuotaExceeded):
+		http.Error(w, "This upload would exceed the project's storage quota", http.StatusInsufficientStorage)
+	case errors.Is(err, store.ErrConflict):
+		http.Error(w, "A file or folder with that name already exists.", http.StatusConflict)
+	default:
+		log.Printf("Failed to store upload: %v", err)
+		http.Error(w, "Failed to store upload", http.StatusInternalServerError)
+	}
+}
+
+// discardUploads removes the files of an upload that failed part way. Their
+// blobs are left to the orphan sweep.
+func (h *Handler) discardUploads(ctx context.Context, nodes []models.FileNode) {
+	for _, node := range nodes {
+		if _, err := h.store.Files.Delete(ctx, node.ID, node.Revision); err != nil {
+			log.Printf("Failed to roll back upload of %s: %v", node.ID, err)
+		}
+	}
+}
+
+// --- UPLOAD FILES ---
+// UploadFiles creates binary files from a multipart/form-data body. Every
+// part named "file" becomes a file named after its filename; an optional
+// "parentId" field, sent before the files, picks the folder. The upload is
+// all or nothing: if any part fails, the files created before it are removed.
+func (h *Handler) UploadFiles(w http.ResponseWriter, r *http.Request) {
+	projectIDStr := chi.URLParam(r, "projectId")
+	projectID, err := uuid.Parse(projectIDStr)
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, "Expected a multipart/form-data body", http.StatusBadRequest)
+		return
+	}
+
+	quota := storageQuota()
+	var parentID *uuid.UUID
+	parentChecked := false
+	created := make([]models.FileNode, 0)
+	committed := false
+	defer func() {
+		if !committed {
+			// The client may be gone; the rollback must still happen.
+			h.discardUploads(context.WithoutCancel(r.Context()), created)
+		}
+	}()
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			uploadError(w, err)
+			return
+		}
+
+		switch part.FormName() {
+		case "parentId":
+			value, _ := io.ReadAll(io.LimitReader(part, 64))
+			if len(created) > 0 {
+				http.Error(w, "parentId must come before the files", http.StatusBadRequest)
+				return
+			}
+			if s := strings.TrimSpace(string(value)); s != "" {
+				parsed, err := uuid.Parse(s)
+				if err != nil {
+					http.Error(w, "Invalid parent ID", http.StatusBadRequest)
+					return
+				}
+				parent, err := h.store.Files.Get(r.Context(), parsed)
+				if err != nil || parent.ProjectID != projectID || !parent.IsFolder {
+					http.Error(w, "Parent folder not found", http.StatusBadRequest)
+					return
+				}
+				parentID = &parsed
+			}
+		case "file":
+			name := path.Base(part.FileName())
+			if !validNodeName(name) {
+				http.Error(w, "Every file needs a filename", http.StatusBadRequest)
+				return
+			}
+			if !parentChecked {
+				if !h.canWriteParent(r, parentID) {
+					http.Error(w, "You do not have write access to this folder", http.StatusForbidden)
+					return
+				}
+				parentChecked = true
+			}
+
+			node, err := h.createUpload(r, projectID, parentID, name, part, quota)
+			if err != nil {
+				uploadError(w, err)
+				return
+			}
+			created = append(created, node)
+		}
+		part.Close()
+	}
+
+	if len(created) == 0 {
+		http.Error(w, "No files in upload", http.StatusBadRequest)
+		return
+	}
+	committed = true
+	for _, node := range created {
+		h.auditLog(r, projectIDStr, "file.upload", "file", node.ID.String(), map[string]interface{}{
+			"name":        node.Name,
+			"parentId":    node.ParentID,
+			"contentType": node.Blob.ContentType,
+			"size":        node.Blob.Size,
+			"sha256":      node.Blob.SHA256,
+		})
+		h.broadcastToProject(projectIDStr, "file_created", map[string]string{"id": node.ID.String()})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+func (h *Handler) createUpload(r *http.Request, projectID uuid.UUID, parentID *uuid.UUID, name string, part *multipart.Part, quota int64) (models.FileNode, error) {
+	upload, err := spool(part)
+	if err != nil {
+		return models.FileNode{}, err
+	}
+	defer upload.Close()
+
+	// Cheap early check; CreateBlob repeats it atomically.
+	if used, err := h.store.Files.StorageUsed(r.Context(), projectID); err == nil && used+upload.size > quota {
+		return models.FileNode{}, store.ErrQuotaExceeded
+	}
+
+	blob, err := h.storeUpload(r, projectID, upload)
+	if err != nil {
+		return models.FileNode{}, err
+	}
+	node := models.FileNode{ProjectID: projectID, ParentID: parentID, Name: name, Blob: &blob}
+	if err := h.store.Files.CreateBlob(r.Context(), &node, quota); err != nil {
+		if delErr := h.store.Blobs.Delete(r.Context(), blob.Key); delErr != nil {
+			log.Printf("Failed to clean up blob %s: %v", blob.Key, delErr)
+		}
+		return models.FileNode{}, err
+	}
+	return node, nil
+}
+
+// --- REPLACE FILE CONTENT WITH AN UPLOAD ---
+// ReplaceFileBlob replaces a file's content with the single "file" part of a
+// multipart body. A text file becomes a binary file.
+func (h *Handler) ReplaceFileBlob(w http.ResponseWriter, r *http.Request) {
+	fileIDStr := chi.URLParam(r, "fileId")
+	fileID, err := uuid.Parse(fileIDStr)
+	if err != nil {
+		http.Error(w, "Invalid file ID", http.StatusBadRequest)
+		return
+	}
+	if !h.canWriteFile(r, fileID) {
+		http.Error(w, "You do not have write access to this file", http.StatusForbidden)
+		return
+	}
+	node, err := h.store.Files.Get(r.Context(), fileID)
+	if err != nil || node.IsFolder {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	rev, ok := ifMatchRevision(r, node)
+	if !ok {
+		h.preconditionFailed(w, r, fileID)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, "Expected a multipart/form-data body", http.StatusBadRequest)
+		return
+	}
+	var part *multipart.Part
+	for {
+		part, err = reader.NextPart()
+		if err != nil {
+			if err == io.EOF {
+				http.Error(w, "No file in upload", http.StatusBadRequest)
+			} else {
+				uploadError(w, err)
+			}
+			return
+		}
+		if part.FormName() == "file" {
+			break
+		}
+		part.Close()
+	}
+
+	upload, err := spool(part)
+	if err != nil {
+		uploadError(w, err)
+		return
+	}
+	defer upload.Close()
+
+	quota := storageQuota()
+	blob, err := h.storeUpload(r, node.ProjectID, upload)
+	if err != nil {
+		uploadError(w, err)
+		return
+	}
+	revision, err := h.store.Files.ReplaceBlob(r.Context(), fileID, blob, quota, rev)
+	if err != nil {
+		if delErr := h.store.Blobs.Delete(r.Context(), blob.Key); delErr != nil {
+			log.Printf("Failed to clean up blob %s: %v", blob.Key, delErr)
+		}
+		if errors.Is(err, store.ErrStale) {
+			h.preconditionFailed(w, r, fileID)
+			return
+		}
+		uploadError(w, err)
+		return
+	}
+
+	h.auditLog(r, node.ProjectID.String(), "file.upload", "file", fileIDStr, map[string]interface{}{
+		"name":        node.Name,
+		"contentType": blob.ContentType,
+		"size":        blob.Size,
+		"sha256":      blob.SHA256,
+		"replaced":    true,
+	})
+
+	node.Content = nil
+	node.Blob = &blob
+	node.Revision = revision
+	w.Header().Set("ETag", fileETag(fileID, revision))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(node)
+}
+
+// --- DOWNLOAD FILE ---
+// DownloadFile sends a file's raw bytes: the blob of a binary file or the
+// text of a text file.
+func (h *Handler) DownloadFile(w http.ResponseWriter, r *http.Request) {
+	fileIDStr := chi.URLParam(r, "fileId")
+	fileID, err := uuid.Parse(fileIDStr)
+	if err != nil {
+		http.Error(w, "Invalid file ID", http.StatusBadRequest)
+		return
+	}
+	if !h.canReadFile(r, fileID) {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	node, err := h.store.Files.Get(r.Context(), fileID)
+	if err != nil || node.IsFolder {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	if notModified(w, r, node) {
+		return
+	}
+
+	h.auditLog(r, node.ProjectID.String(), "file.download", "file", fileIDStr, nil)
+
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": node.Name}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if node.Blob == nil {
+		content := ""
+		if node.Content != nil {
+			content = *node.Content
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		io.WriteString(w, content)
+		return
+	}
+
+	body, err := h.store.Blobs.Open(r.Context(), node.Blob.Key)
+	if err != nil {
+		log.Printf("Failed to open blob %s: %v", node.Blob.Key, err)
+		http.Error(w, "Failed to read file", http.StatusInternalServerError)
+		return
+	}
+	defer body.Close()
+	w.Header().Set("Content-Type", node.Blob.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(node.Blob.Size, 10))
+	if _, err := io.Copy(w, body); err != nil {
+		log.Printf("Failed to send blob %s: %v", node.Blob.Key, err)
+	}
+}
+
+// --- GET PROJECT STORAGE ---
+func (h *Handler) GetProjectStorage(w http.ResponseWriter, r *http.Request) {
+	projectID, err := uuid.Parse(chi.URLParam(r, "projectId"))
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+	used, err := h.store.Files.StorageUsed(r.Context(), projectID)
+	if err != nil {
+		log.Printf("Failed to compute storage usage: %v", err)
+		http.Error(w, "Failed to retrieve storage usage", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{
+		"used":  used,
+		"quota": storageQuota(),
+	})
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"project-meetings/backend/internal/permissions"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// upload posts files, by name and content, as one multipart upload.
+func (api *testAPI) upload(t *testing.T, files ...[2]string) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for _, file := range files {
+		part, err := form.CreateFormFile("file", file[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write([]byte(file[1]))
+	}
+	form.Close()
+	req := httptest.NewRequest("POST", "/project/"+api.project.ID.String()+"/uploads", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("X-Test-User", api.owner.ID.String())
+	rec := httptest.NewRecorder()
+	api.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestUploadFiles(t *testing.T) {
+	api := newTestAPI(t)
+	api.router.Group(func(r chi.Router) {
+		r.Use(api.mw.RequirePermission(permissions.FileWrite))
+		r.Post("/project/{projectId}/uploads", api.handler.UploadFiles)
+	})
+	api.router.With(api.mw.RequirePermission(permissions.ProjectRead)).Get("/file/{fileId}/download", api.handler.DownloadFile)
+	ctx := context.Background()
+
+	// The third file clashes with the first: nothing of the upload remains.
+	rec := api.upload(t, [2]string{"a.bin", "one"}, [2]string{"b.bin", "two"}, [2]string{"a.bin", "three"})
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("status %d (%s), want %d", rec.Code, rec.Body.String(), http.StatusConflict)
+	}
+	if files, err := api.store.Files.List(ctx, api.project.ID); err != nil || len(files) != 0 {
+		t.Fatalf("%d files left after the failed upload, %v", len(files), err)
+	}
+
+	rec = api.upload(t, [2]string{"page.html", "<script>alert(1)</script>"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status %d (%s), want %d", rec.Code, rec.Body.String(), http.StatusCreated)
+	}
+	files, err := api.store.Files.List(ctx, api.project.ID)
+	if err != nil || len(files) != 1 {
+		t.Fatalf("%d files after the upload, %v", len(files), err)
+	}
+	rec = api.do(t, api.owner, "GET", "/file/"+files[0].ID.String()+"/download", nil)
+	if rec.Code != http.StatusOK || rec.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Errorf("download: status %d, headers %v", rec.Code, rec.Header())
+	}
+}
@@ -0,0 +1,412 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+
+	"project-meetings/backend/internal/acl"
+	"project-meetings/backend/internal/middleware"
+	"project-meetings/backend/internal/models"
+	"project-meetings/backend/internal/store"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// maxCopyNodes caps how many files and folders one copy or fork may create.
+const maxCopyNodes = 10000
+
+// maxCopyNameAttempts caps how many "copy N" names a copy tries before
+// giving up on finding a free one.
+const maxCopyNameAttempts = 20
+
+var errTooManyNodes = fmt.Errorf("copies of more than %d files and folders are not supported", maxCopyNodes)
+
+// copyError maps a failed copy or fork to a response.
+func copyError(w http.ResponseWriter, err error, action string) {
+	switch {
+	case errors.Is(err, store.ErrQuotaExceeded):
+		http.Error(w, "The copy would exceed the project's storage quota", http.StatusInsufficientStorage)
+	case errors.Is(err, store.ErrConflict):
+		http.Error(w, "A file or folder with that name already exists.", http.StatusConflict)
+	case errors.Is(err, errTooManyNodes):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	default:
+		log.Printf("Failed to %s: %v", action, err)
+		http.Error(w, "Failed to "+action, http.StatusInternalServerError)
+	}
+}
+
+// copyName names the n-th copy of a node that stays next to the original,
+// keeping the extension of files: "notes copy.txt", "notes copy 2.txt".
+func copyName(name string, isFolder bool, n int) string {
+	suffix := " copy"
+	if n > 1 {
+		suffix = fmt.Sprintf(" copy %d", n)
+	}
+	ext := path.Ext(name)
+	if isFolder || ext == name {
+		ext = ""
+	}
+	return strings.TrimSuffix(name, ext) + suffix + ext
+}
+
+// sameFolder reports whether two parent IDs name the same folder, or are both
+// the top of a project.
+func sameFolder(a, b *uuid.UUID) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+// readableTree returns the nodes below parentID (the top of the project when
+// nil) that the subject can read, parents first. A hidden folder hides
+// everything in it.
+func readableTree(files []models.FileNode, access *acl.Evaluator, parentID *uuid.UUID) []models.FileNode {
+	children := make(map[uuid.UUID][]models.FileNode)
+	var queue []models.FileNode
+	for _, node := range files {
+		switch {
+		case sameFolder(node.ParentID, parentID):
+			queue = append(queue, node)
+		case node.ParentID != nil:
+			children[*node.ParentID] = append(children[*node.ParentID], node)
+		}
+	}
+	tree := make([]models.FileNode, 0, len(files))
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		if !access.CanRead(node.ID) {
+			continue
+		}
+		tree = append(tree, node)
+		queue = append(queue, children[node.ID]...)
+	}
+	return tree
+}
+
+// cloneNodes gives nodes new IDs inside projectID, hanging the ones whose
+// parent is not among them below parentID. Text files take the content of
+// an open editor from live, and blobs are copied to new keys, which are
+// returned so a failed insert can remove them again.
+func (h *Handler) cloneNodes(ctx context.Context, nodes []models.FileNode, live map[string]string, projectID uuid.UUID, parentID *uuid.UUID) ([]models.FileNode, []string, error) {
+	ids := make(map[uuid.UUID]uuid.UUID, len(nodes))
+	for _, node := range nodes {
+		ids[node.ID] = uuid.New()
+	}
+	clones := make([]models.FileNode, 0, len(nodes))
+	var keys []string
+	for _, node := range nodes {
+		clone := models.FileNode{ID: ids[node.ID], ProjectID: projectID, ParentID: parentID, IsFolder: node.IsFolder, Name: node.Name}
+		if node.ParentID != nil {
+			if id, ok := ids[*node.ParentID]; ok {
+				clone.ParentID = &id
+			}
+		}
+		switch content, ok := live[node.ID.String()]; {
+		case node.IsFolder:
+		case ok:
+			clone.Content = &content
+		case node.Blob != nil:
+			blob, err := h.duplicateBlob(ctx, projectID, *node.Blob)
+			if err != nil {
+				return nil, keys, err
+			}
+			keys = append(keys, blob.Key)
+			clone.Blob = &blob
+		default:
+			content := ""
+			if node.Content != nil {
+				content = *node.Content
+			}
+			clone.Content = &content
+		}
+		clones = append(clones, clone)
+	}
+	return clones, keys, nil
+}
+
+// duplicateBlob stores a second copy of a blob for projectID. Files never
+// share a key, since removing a file queues its blob for deletion.
+func (h *Handler) duplicateBlob(ctx context.Context, projectID uuid.UUID, blob models.Blob) (models.Blob, error) {
+	body, err := h.store.Blobs.Open(ctx, blob.Key)
+	if err != nil {
+		return models.Blob{}, err
+	}
+	defer body.Close()
+	blob.Key = fmt.Sprintf("%s/%s", projectID, uuid.New())
+	return blob, h.store.Blobs.Put(ctx, blob.Key, body, blob.Size, blob.ContentType)
+}
+
+// deleteBlobs removes blobs copied for a copy that did not go through.
+func (h *Handler) deleteBlobs(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		if err := h.store.Blobs.Delete(ctx, key); err != nil {
+			log.Printf("Failed to clean up blob %s: %v", key, err)
+		}
+	}
+}
+
+// blobBytes adds up the size of the blobs among nodes.
+func blobBytes(nodes []models.FileNode) int64 {
+	var size int64
+	for _, node := range nodes {
+		if node.Blob != nil {
+			size += node.Blob.Size
+		}
+	}
+	return size
+}
+
+// --- COPY FILE OR FOLDER ---
+// CopyFileNode duplicates a file, or a folder with everything in it the
+// caller can read, inside the same project. parentId picks the target
+// folder (null for the top level) and defaults to the original's; name
+// defaults to the original's, or "<name> copy" when that is taken.
+func (h *Handler) CopyFileNode(w http.ResponseWriter, r *http.Request) {
+	fileIDStr := chi.URLParam(r, "fileId")
+	fileID, err := uuid.Parse(fileIDStr)
+	if err != nil {
+		http.Error(w, "Invalid file ID", http.StatusBadRequest)
+		return
+	}
+	var req struct {
+		ParentID json.RawMessage `json:"parentId"`
+		Name     string          `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name != "" && !validNodeName(req.Name) {
+		http.Error(w, "Invalid name", http.StatusBadRequest)
+		return
+	}
+
+	source, err := h.store.Files.Get(r.Context(), fileID)
+	if err != nil || source.ProjectID.String() != contextProjectID(r) {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	parentID := source.ParentID
+	if len(req.ParentID) > 0 {
+		parentID = nil
+		if !bytes.Equal(req.ParentID, []byte("null")) {
+			var id uuid.UUID
+			if err := json.Unmarshal(req.ParentID, &id); err != nil {
+				http.Error(w, "Invalid parent ID", http.StatusBadRequest)
+				return
+			}
+			parentID = &id
+		}
+	}
+	if parentID != nil {
+		parent, err := h.store.Files.Get(r.Context(), *parentID)
+		if err != nil || parent.ProjectID != source.ProjectID || !parent.IsFolder {
+			http.Error(w, "Target folder not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	access, err := acl.LoadProject(r.Context(), h.store.Files, source.ProjectID, aclSubject(r))
+	if err != nil {
+		log.Printf("Failed to load access rules: %v", err)
+		http.Error(w, "Failed to copy file", http.StatusInternalServerError)
+		return
+	}
+	if !access.CanRead(fileID) {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	if !h.canWriteParent(r, parentID) {
+		http.Error(w, "You do not have write access to the target folder", http.StatusForbidden)
+		return
+	}
+
+	files, err := h.store.Files.List(r.Context(), source.ProjectID)
+	if err != nil {
+		log.Printf("Failed to list files: %v", err)
+		http.Error(w, "Failed to copy file", http.StatusInternalServerError)
+		return
+	}
+	nodes := []models.FileNode{source}
+	if source.IsFolder {
+		nodes = append(nodes, readableTree(files, access, &source.ID)...)
+	}
+	if len(nodes) > maxCopyNodes {
+		copyError(w, errTooManyNodes, "copy file")
+		return
+	}
+	quota := storageQuota()
+	used, err := h.store.Files.StorageUsed(r.Context(), source.ProjectID)
+	if err == nil && used+blobBytes(nodes) > quota {
+		err = store.ErrQuotaExceeded
+	}
+	if err != nil {
+		copyError(w, err, "copy file")
+		return
+	}
+
+	editing, err := h.hub.EditorContents(r.Context(), source.ProjectID.String())
+	if err != nil {
+		copyError(w, err, "copy file")
+		return
+	}
+	clones, keys, err := h.cloneNodes(r.Context(), nodes, editing, source.ProjectID, parentID)
+	if err != nil {
+		h.deleteBlobs(r.Context(), keys)
+		copyError(w, err, "copy file")
+		return
+	}
+	// Without a name, look for a free one next to the original.
+	names := []string{req.Name}
+	if req.Name == "" {
+		names = names[:0]
+		if !sameFolder(parentID, source.ParentID) {
+			names = append(names, source.Name)
+		}
+		for n := 1; n <= maxCopyNameAttempts; n++ {
+			names = append(names, copyName(source.Name, source.IsFolder, n))
+		}
+	}
+	for _, name := range names {
+		clones[0].Name = name
+		if err = h.store.Files.CreateTree(r.Context(), source.ProjectID, clones, quota); !errors.Is(err, store.ErrConflict) {
+			break
+		}
+	}
+	if err != nil {
+		h.deleteBlobs(r.Context(), keys)
+		copyError(w, err, "copy file")
+		return
+	}
+
+	root, err := h.store.Files.Get(r.Context(), clones[0].ID)
+	if err != nil {
+		log.Printf("Failed to load copied file %s: %v", clones[0].ID, err)
+		http.Error(w, "Failed to copy file", http.StatusInternalServerError)
+		return
+	}
+	projectIDStr := source.ProjectID.String()
+	h.auditLog(r, projectIDStr, "file.copy", "file", root.ID.String(), map[string]interface{}{
+		"sourceId": fileIDStr,
+		"name":     root.Name,
+		"parentId": root.ParentID,
+		"nodes":    len(clones),
+	})
+	h.broadcastToProject(projectIDStr, "file_created", map[string]string{"id": root.ID.String()})
+
+	w.Header().Set("ETag", fileETag(root.ID, root.Revision))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(root)
+}
+
+// projectSeed snapshots the files of a project that subject can read, with
+// copies of their blobs, and with whiteboard set its shapes. The keys of the
+// copied blobs are returned so a failed create can remove them again.
+func (h *Handler) projectSeed(ctx context.Context, projectID uuid.UUID, subject acl.Subject, whiteboard bool) (store.ProjectSeed, []string, error) {
+	var seed store.ProjectSeed
+	access, err := acl.LoadProject(ctx, h.store.Files, projectID, subject)
+	if err != nil {
+		return seed, nil, err
+	}
+	files, err := h.store.Files.List(ctx, projectID)
+	if err != nil {
+		return seed, nil, err
+	}
+	nodes := readableTree(files, access, nil)
+	if len(nodes) > maxCopyNodes {
+		return seed, nil, errTooManyNodes
+	}
+	if blobBytes(nodes) > storageQuota() {
+		return seed, nil, store.ErrQuotaExceeded
+	}
+	if whiteboard {
+		if seed.Shapes, err = h.store.Whiteboards.Shapes(ctx, projectID.String()); err != nil {
+			return seed, nil, err
+		}
+	}
+	// The new project's ID is only known once it exists, so its blobs are
+	// copied under the source project's prefix; the store places the nodes.
+	editing, err := h.hub.EditorContents(ctx, projectID.String())
+	if err != nil {
+		return seed, nil, err
+	}
+	var keys []string
+	seed.Files, keys, err = h.cloneNodes(ctx, nodes, editing, projectID, nil)
+	return seed, keys, err
+}
+
+// --- FORK PROJECT ---
+// ForkProject creates a project owned by the caller with a copy of every
+// file they can read and, with whiteboard set, the whiteboard shapes. The
+// fork records the project it came from.
+func (h *Handler) ForkProject(w http.ResponseWriter, r *http.Request) {
+	projectIDStr := chi.URLParam(r, "projectId")
+	projectID, err := uuid.Parse(projectIDStr)
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+	userID, _ := r.Context().Value(middleware.UserIDKey).(string)
+
+	var req struct {
+		Name           string     `json:"name"`
+		Whiteboard     bool       `json:"whiteboard"`
+		OrganizationID *uuid.UUID `json:"organizationId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	source, err := h.store.Projects.Get(r.Context(), projectIDStr)
+	if err != nil {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	}
+	if req.Name = strings.TrimSpace(req.Name); req.Name == "" {
+		req.Name = source.Name + " (fork)"
+	}
+	// Any member of an organization may start a project inside it.
+	if req.OrganizationID != nil {
+		if _, err := h.orgRole(r.Context(), req.OrganizationID.String(), userID); err != nil {
+			http.Error(w, "You are not a member of that organization", http.StatusForbidden)
+			return
+		}
+	}
+
+	seed, keys, err := h.projectSeed(r.Context(), projectID, aclSubject(r), req.Whiteboard)
+	if err != nil {
+		h.deleteBlobs(r.Context(), keys)
+		copyError(w, err, "fork project")
+		return
+	}
+	seed.ForkedFrom = &source.ID
+	fork, err := h.store.Projects.CreateFrom(r.Context(), req.Name, userID, req.OrganizationID, seed)
+	if err != nil {
+		h.deleteBlobs(r.Context(), keys)
+		copyError(w, err, "fork project")
+		return
+	}
+
+	h.auditLog(r, projectIDStr, "project.fork", "project", fork.ID.String(), map[string]interface{}{"name": fork.Name})
+	h.auditLog(r, fork.ID.String(), "project.create", "project", fork.ID.String(), map[string]interface{}{
+		"name":       fork.Name,
+		"forkedFrom": projectIDStr,
+		"files":      len(seed.Files),
+		"shapes":     len(seed.Shapes),
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(fork)
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"project-meetings/backend/internal/models"
+	"project-meetings/backend/internal/store"
+
+	"github.com/google/uuid"
+)
+
+// fileETag is the entity tag of a node at its current revision. It names
+// the node too, so a file deleted and recreated at the same path gets a new
+// tag.
+func fileETag(fileID uuid.UUID, revision int64) string {
+	return fmt.Sprintf(`"%s-%d"`, fileID, revision)
+}
+
+// etagList splits an If-Match or If-None-Match header into its tags.
+func etagList(header string) []string {
+	tags := make([]string, 0, 1)
+	for _, tag := range strings.Split(header, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// ifMatchRevision checks a write's If-Match header against node. It returns
+// the revision the store must still find, zero when any revision will do,
+// and false when the header rules out the node's current version. Weak tags
+// never match, as If-Match requires.
+func ifMatchRevision(r *http.Request, node models.FileNode) (int64, bool) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return 0, true
+	}
+	current := fileETag(node.ID, node.Revision)
+	for _, tag := range etagList(header) {
+		if tag == "*" || tag == current {
+			return node.Revision, true
+		}
+	}
+	return 0, false
+}
+
+// notModified answers a read with 304 Not Modified when its If-None-Match
+// header names the node's current version.
+func notModified(w http.ResponseWriter, r *http.Request, node models.FileNode) bool {
+	current := fileETag(node.ID, node.Revision)
+	w.Header().Set("ETag", current)
+	for _, tag := range etagList(r.Header.Get("If-None-Match")) {
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == current {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatchFor resolves the If-Match header of a write to fileID, loading the
+// node only when there is a header. It returns the revision to pass to the
+// store, or false after answering the request itself.
+func (h *Handler) ifMatchFor(w http.ResponseWriter, r *http.Request, fileID uuid.UUID) (int64, bool) {
+	if r.Header.Get("If-Match") == "" {
+		return 0, true
+	}
+	node, err := h.store.Files.Get(r.Context(), fileID)
+	if err != nil {
+		h.preconditionFailed(w, r, fileID)
+		return 0, false
+	}
+	rev, ok := ifMatchRevision(r, node)
+	if !ok {
+		h.preconditionFailed(w, r, fileID)
+	}
+	return rev, ok
+}
+
+// preconditionFailed answers a conditional write that lost the race with
+// 412 Precondition Failed and the node's current version, so the client can
+// merge and retry with the new ETag.
+func (h *Handler) preconditionFailed(w http.ResponseWriter, r *http.Request, fileID uuid.UUID) {
+	node, err := h.store.Files.Get(r.Context(), fileID)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "The file no longer exists", http.StatusPreconditionFailed)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to load file after a failed precondition: %v", err)
+		http.Error(w, "Failed to load file", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("ETag", fileETag(node.ID, node.Revision))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusPreconditionFailed)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":   "The file has changed since you read it",
+		"current": node,
+	})
+}
@@ -9,9 +9,11 @@ import (
 	"net/http"
 	"os/exec"
 	"time"
+
+	"github.com/go-chi/chi/v5"
 )
 
-func ExecuteCode(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) ExecuteCode(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Language string `json:"language"`
 		Code     string `json:"code"`
@@ -53,6 +55,13 @@ func ExecuteCode(w http.ResponseWriter, r *http.Request) {
 	// Run the command
 	err := cmd.Run()
 
+	projectID := chi.URLParam(r, "projectId")
+	h.auditLog(r, projectID, "exec.run", "project", projectID, map[string]interface{}{
+		"language":  req.Language,
+		"codeBytes": len(req.Code),
+		"succeeded": err == nil,
+	})
+
 	if err != nil {
 		// This can happen if the command times out or returns a non-zero exit code.
 		log.Printf("Error executing docker command: %v", err)
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+
+	"project-meetings/backend/internal/acl"
+	"project-meetings/backend/internal/models"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// readablePath builds the slash-separated path of a node from its ancestors'
+// names, or returns false if the node or any of its ancestors is hidden from
+// the caller.
+func readablePath(nodes map[uuid.UUID]*models.FileNode, access *acl.Evaluator, id uuid.UUID) (string, bool) {
+	parts := []string{}
+	current := &id
+	for depth := 0; current != nil && depth <= len(nodes); depth++ {
+		node, ok := nodes[*current]
+		if !ok || !access.CanRead(*current) {
+			return "", false
+		}
+		parts = append([]string{node.Name}, parts...)
+		current = node.ParentID
+	}
+	return path.Join(parts...), true
+}
+
+// ExportProject streams the project's files as a zip archive. Only nodes the
+// caller can read are included, so hidden folders never leave the server.
+func (h *Handler) ExportProject(w http.ResponseWriter, r *http.Request) {
+	projectID, err := uuid.Parse(chi.URLParam(r, "projectId"))
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	access, err := acl.LoadProject(r.Context(), h.store.Files, projectID, aclSubject(r))
+	if err != nil {
+		log.Printf("Failed to load access rules: %v", err)
+		http.Error(w, "Failed to export project", http.StatusInternalServerError)
+		return
+	}
+
+	files, err := h.store.Files.List(r.Context(), projectID)
+	if err != nil {
+		log.Printf("Failed to load files for export: %v", err)
+		http.Error(w, "Failed to export project", http.StatusInternalServerError)
+		return
+	}
+	nodes := make(map[uuid.UUID]*models.FileNode, len(files))
+	for i := range files {
+		nodes[files[i].ID] = &files[i]
+	}
+
+	h.auditLog(r, projectID.String(), "project.export", "project", projectID.String(), nil)
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="project-%s.zip"`, projectID))
+
+	archive := zip.NewWriter(w)
+	defer archive.Close()
+	for id, node := range nodes {
+		name, ok := readablePath(nodes, access, id)
+		if !ok {
+			continue
+		}
+		if node.IsFolder {
+			if _, err := archive.Create(name + "/"); err != nil {
+				log.Printf("Failed to write folder %s to archive: %v", name, err)
+				return
+			}
+			continue
+		}
+		f, err := archive.Create(name)
+		if err != nil {
+			log.Printf("Failed to write file %s to archive: %v", name, err)
+			return
+		}
+		if node.Blob != nil {
+			if err := h.copyBlob(r, f, node.Blob.Key); err != nil {
+				log.Printf("Failed to write file %s to archive: %v", name, err)
+				return
+			}
+		} else if node.Content != nil {
+			if _, err := f.Write([]byte(*node.Content)); err != nil {
+				log.Printf("Failed to write file %s to archive: %v", name, err)
+				return
+			}
+		}
+	}
+}
+
+// copyBlob writes the bytes of a stored blob to w.
+func (h *Handler) copyBlob(r *http.Request, w io.Writer, key string) error {
+	body, err := h.store.Blobs.Open(r.Context(), key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	_, err = io.Copy(w, body)
+	return err
+}
@@ -1,18 +1,77 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
-	"project-meetings/backend/internal/database"
+	"project-meetings/backend/internal/acl"
+	"project-meetings/backend/internal/middleware"
 	"project-meetings/backend/internal/models"
+	"project-meetings/backend/internal/permissions"
+	"project-meetings/backend/internal/store"
 	"log"
+	"strings"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 )
 
+// aclSubject describes the caller for folder access checks, using what the
+// permission middleware put in the request context.
+func aclSubject(r *http.Request) acl.Subject {
+	userID, _ := r.Context().Value(middleware.UserIDKey).(string)
+	role, _ := r.Context().Value(middleware.ProjectRoleKey).(string)
+	perms, _ := r.Context().Value(middleware.PermissionsKey).(permissions.Set)
+	return acl.Subject{UserID: userID, Role: role, Permissions: perms}
+}
+
+// contextProjectID returns the project resolved by the permission middleware,
+// which is how file routes keyed by {fileId} learn their project.
+func contextProjectID(r *http.Request) string {
+	projectID, _ := r.Context().Value(middleware.ProjectIDKey).(string)
+	return projectID
+}
+
+// fileAccess returns the caller's access to a single node, after folder-level
+// overrides. A failure to load the rules allows nothing.
+func (h *Handler) fileAccess(r *http.Request, fileID uuid.UUID) acl.Access {
+	access, err := acl.LoadForFile(r.Context(), h.store.Files, fileID, aclSubject(r))
+	if err != nil {
+		log.Printf("Failed to load access rules for %s: %v", fileID, err)
+		return acl.None
+	}
+	return access.AccessTo(fileID)
+}
+
+// canReadFile checks folder-level access overrides for a single node.
+func (h *Handler) canReadFile(r *http.Request, fileID uuid.UUID) bool {
+	return h.fileAccess(r, fileID) >= acl.Read
+}
+
+// canWriteFile checks folder-level access overrides for a single node.
+func (h *Handler) canWriteFile(r *http.Request, fileID uuid.UUID) bool {
+	return h.fileAccess(r, fileID) >= acl.Write
+}
+
+// canWriteParent checks whether the caller may create a node under parentID,
+// or at the top level of the project when parentID is nil.
+func (h *Handler) canWriteParent(r *http.Request, parentID *uuid.UUID) bool {
+	if parentID == nil {
+		// Rules hang off nodes, so the top level is governed by the role alone.
+		return aclSubject(r).Permissions.Has(permissions.FileWrite)
+	}
+	return h.canWriteFile(r, *parentID)
+}
+
+// validNodeName reports whether name can name a file or folder: it must not
+// be empty, "." or "..", nor contain "/" or NUL.
+func validNodeName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, "/\x00")
+}
+
 // GetFileTree handles fetching all files and folders for a project and structuring them as a tree.
-func GetFileTree(w http.ResponseWriter, r *http.Request) {
+// Contents are left out; they come from GetFile, and large projects should
+// page through ListFolderChildren instead.
+func (h *Handler) GetFileTree(w http.ResponseWriter, r *http.Request) {
 	projectIDStr := chi.URLParam(r, "projectId")
 	projectID, err := uuid.Parse(projectIDStr)
 	if err != nil {
@@ -21,25 +80,35 @@ func GetFileTree(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Fetch all nodes for the project from the database
-	query := `SELECT id, parent_id, is_folder, name, content, created_at, updated_at FROM files WHERE project_id = $1 ORDER BY name ASC`
-	rows, err := database.DB.Query(context.Background(), query, projectID)
+	files, err := h.store.Files.Outline(r.Context(), projectID)
+	if err != nil {
+		http.Error(w, "Failed to retrieve file structure", http.StatusInternalServerError)
+		return
+	}
+
+	access, err := acl.LoadProject(r.Context(), h.store.Files, projectID, aclSubject(r))
 	if err != nil {
+		log.Printf("Failed to load access rules: %v", err)
 		http.Error(w, "Failed to retrieve file structure", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
 
+	counts := access.ReadableChildren()
 	nodes := make(map[uuid.UUID]*models.FileNode)
 	var allNodes []*models.FileNode
-	for rows.Next() {
-		var node models.FileNode
-		node.ProjectID = projectID
-		if err := rows.Scan(&node.ID, &node.ParentID, &node.IsFolder, &node.Name, &node.Content, &node.CreatedAt, &node.UpdatedAt); err != nil {
-			http.Error(w, "Failed to scan file node", http.StatusInternalServerError)
-			return
+	for i := range files {
+		node := &files[i]
+		// Hidden nodes are left out; their children then have no parent
+		// to attach to and drop out of the tree as well.
+		if !access.CanRead(node.ID) {
+			continue
 		}
-		nodes[node.ID] = &node
-		allNodes = append(allNodes, &node)
+		if node.IsFolder {
+			count := counts[node.ID]
+			node.ChildCount = &count
+		}
+		nodes[node.ID] = node
+		allNodes = append(allNodes, node)
 	}
 
 	// Build the tree structure
@@ -61,7 +130,7 @@ func GetFileTree(w http.ResponseWriter, r *http.Request) {
 }
 
 // CreateFileNode handles creating a new file or folder.
-func CreateFileNode(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) CreateFileNode(w http.ResponseWriter, r *http.Request) {
 	projectIDStr := chi.URLParam(r, "projectId")
 	projectID, err := uuid.Parse(projectIDStr)
 	if err != nil {
@@ -78,6 +147,10 @@ func CreateFileNode(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	if !validNodeName(req.Name) {
+		http.Error(w, "Invalid name", http.StatusBadRequest)
+		return
+	}
 
 	var parentID *uuid.UUID
 	if req.ParentID != nil {
@@ -86,30 +159,56 @@ func CreateFileNode(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Invalid parent ID", http.StatusBadRequest)
 			return
 		}
+		parent, err := h.store.Files.Get(r.Context(), parsed)
+		if errors.Is(err, store.ErrNotFound) || (err == nil && parent.ProjectID != projectID) {
+			http.Error(w, "Parent folder not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to load parent %s: %v", parsed, err)
+			http.Error(w, "Failed to create file/folder", http.StatusInternalServerError)
+			return
+		}
+		if !parent.IsFolder {
+			http.Error(w, "Parent must be a folder", http.StatusBadRequest)
+			return
+		}
 		parentID = &parsed
 	}
-    
-    // For new files, provide empty content.
-    content := ""
-    var contentPtr *string
-    if !req.IsFolder {
-        contentPtr = &content
-    }
 
-	query := `INSERT INTO files (project_id, parent_id, is_folder, name, content) VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at, updated_at`
-	var newNode models.FileNode
-	err = database.DB.QueryRow(context.Background(), query, projectID, parentID, req.IsFolder, req.Name, contentPtr).Scan(&newNode.ID, &newNode.CreatedAt, &newNode.UpdatedAt)
-	if err != nil {
+	if !h.canWriteParent(r, parentID) {
+		http.Error(w, "You do not have write access to this folder", http.StatusForbidden)
+		return
+	}
+
+	// For new files, provide empty content.
+	content := ""
+	var contentPtr *string
+	if !req.IsFolder {
+		contentPtr = &content
+	}
+
+	newNode := models.FileNode{
+		ProjectID: projectID,
+		ParentID:  parentID,
+		IsFolder:  req.IsFolder,
+		Name:      req.Name,
+		Content:   contentPtr,
+	}
+	if err := h.store.Files.Create(r.Context(), &newNode); err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			http.Error(w, "A file or folder with that name already exists.", http.StatusConflict)
+			return
+		}
 		http.Error(w, "Failed to create file/folder. Check for duplicate names.", http.StatusInternalServerError)
 		return
 	}
-    
-    // Populate the rest of the response struct
-    newNode.ProjectID = projectID
-    newNode.ParentID = parentID
-    newNode.IsFolder = req.IsFolder
-    newNode.Name = req.Name
-    newNode.Content = contentPtr
+
+	h.auditLog(r, projectIDStr, "file.create", "file", newNode.ID.String(), map[string]interface{}{
+		"name":     req.Name,
+		"isFolder": req.IsFolder,
+		"parentId": req.ParentID,
+	})
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -119,7 +218,7 @@ func CreateFileNode(w http.ResponseWriter, r *http.Request) {
 // TODO: We will also need handlers for Update (rename, move, save content) and Delete.
 // Let's build Get and Create first.
 // In file_handlers.go
-func SaveFileContent(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) SaveFileContent(w http.ResponseWriter, r *http.Request) {
     fileIDStr := chi.URLParam(r, "fileId")
     fileID, err := uuid.Parse(fileIDStr)
     if err != nil {
@@ -135,18 +234,36 @@ func SaveFileContent(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    query := `UPDATE files SET content = $1, updated_at = NOW() WHERE id = $2`
-    _, err = database.DB.Exec(context.Background(), query, req.Content, fileID)
+    if !h.canWriteFile(r, fileID) {
+        http.Error(w, "You do not have write access to this file", http.StatusForbidden)
+        return
+    }
+    rev, ok := h.ifMatchFor(w, r, fileID)
+    if !ok {
+        return
+    }
+
+    revision, err := h.store.Files.UpdateContent(r.Context(), fileID, req.Content, rev)
     if err != nil {
-        log.Printf("Failed to save file content: %v", err)
-        http.Error(w, "Failed to save file", http.StatusInternalServerError)
+        switch {
+        case errors.Is(err, store.ErrStale):
+            h.preconditionFailed(w, r, fileID)
+        case errors.Is(err, store.ErrNotFound):
+            http.Error(w, "File not found", http.StatusNotFound)
+        default:
+            log.Printf("Failed to save file content: %v", err)
+            http.Error(w, "Failed to save file", http.StatusInternalServerError)
+        }
         return
     }
 
+    h.auditLog(r, contextProjectID(r), "file.save", "file", fileIDStr, map[string]interface{}{"bytes": len(req.Content)})
+
+    w.Header().Set("ETag", fileETag(fileID, revision))
     w.WriteHeader(http.StatusOK)
 }
 // RenameFileNode handles renaming a file or folder.
-func RenameFileNode(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) RenameFileNode(w http.ResponseWriter, r *http.Request) {
 	fileIDStr := chi.URLParam(r, "fileId")
 	fileID, err := uuid.Parse(fileIDStr)
 	if err != nil {
@@ -161,25 +278,43 @@ func RenameFileNode(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
-	if req.NewName == "" {
-		http.Error(w, "New name is required", http.StatusBadRequest)
+	if !validNodeName(req.NewName) {
+		http.Error(w, "Invalid name", http.StatusBadRequest)
 		return
 	}
 
-	query := `UPDATE files SET name = $1, updated_at = NOW() WHERE id = $2`
-	_, err = database.DB.Exec(context.Background(), query, req.NewName, fileID)
+	if !h.canWriteFile(r, fileID) {
+		http.Error(w, "You do not have write access to this file", http.StatusForbidden)
+		return
+	}
+	rev, ok := h.ifMatchFor(w, r, fileID)
+	if !ok {
+		return
+	}
+
+	revision, err := h.store.Files.Rename(r.Context(), fileID, req.NewName, rev)
 	if err != nil {
-		// This can fail due to the UNIQUE constraint if the name already exists
-		log.Printf("Failed to rename file: %v", err)
-		http.Error(w, "Failed to rename. A file or folder with that name may already exist.", http.StatusConflict)
+		switch {
+		case errors.Is(err, store.ErrStale):
+			h.preconditionFailed(w, r, fileID)
+		case errors.Is(err, store.ErrNotFound):
+			http.Error(w, "File not found", http.StatusNotFound)
+		default:
+			// This can fail due to the UNIQUE constraint if the name already exists
+			log.Printf("Failed to rename file: %v", err)
+			http.Error(w, "Failed to rename. A file or folder with that name may already exist.", http.StatusConflict)
+		}
 		return
 	}
 
+	h.auditLog(r, contextProjectID(r), "file.rename", "file", fileIDStr, map[string]interface{}{"newName": req.NewName})
+
+	w.Header().Set("ETag", fileETag(fileID, revision))
 	w.WriteHeader(http.StatusOK)
 }
 
 // DeleteFileNode handles deleting a file or folder (and its children recursively).
-func DeleteFileNode(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) DeleteFileNode(w http.ResponseWriter, r *http.Request) {
 	fileIDStr := chi.URLParam(r, "fileId")
 	fileID, err := uuid.Parse(fileIDStr)
 	if err != nil {
@@ -187,15 +322,41 @@ func DeleteFileNode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Because of `ON DELETE CASCADE` in our SQL schema, PostgreSQL will handle
-	// deleting all children automatically when a folder is deleted.
-	query := `DELETE FROM files WHERE id = $1`
-	_, err = database.DB.Exec(context.Background(), query, fileID)
+	// Deleting a folder removes everything below it, so the caller needs
+	// write access to the whole subtree, not just the folder itself.
+	projectID, _ := uuid.Parse(r.Context().Value(middleware.ProjectIDKey).(string))
+	access, err := acl.LoadProject(r.Context(), h.store.Files, projectID, aclSubject(r))
 	if err != nil {
+		log.Printf("Failed to load access rules: %v", err)
+		http.Error(w, "Failed to delete file or folder", http.StatusInternalServerError)
+		return
+	}
+	if !access.CanWriteSubtree(fileID) {
+		http.Error(w, "You do not have write access to everything in this folder", http.StatusForbidden)
+		return
+	}
+	rev, ok := h.ifMatchFor(w, r, fileID)
+	if !ok {
+		return
+	}
+
+	// The store deletes all children along with a folder.
+	deleted, err := h.store.Files.Delete(r.Context(), fileID, rev)
+	if err != nil {
+		if errors.Is(err, store.ErrStale) {
+			h.preconditionFailed(w, r, fileID)
+			return
+		}
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
 		log.Printf("Failed to delete file: %v", err)
 		http.Error(w, "Failed to delete file or folder", http.StatusInternalServerError)
 		return
 	}
 
+	h.auditLog(r, projectID.String(), "file.delete", "file", fileIDStr, map[string]interface{}{"name": deleted.Name, "isFolder": deleted.IsFolder})
+
 	w.WriteHeader(http.StatusNoContent) // 204 No Content is standard for a successful DELETE
 }
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"project-meetings/backend/internal/models"
+	"project-meetings/backend/internal/permissions"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+func TestCreateFileNodeParent(t *testing.T) {
+	api := newTestAPI(t)
+	api.router.With(api.mw.RequirePermission(permissions.FileWrite)).Post("/project/{projectId}/files", api.handler.CreateFileNode)
+	content := "text"
+	folder := api.file(t, nil, "src", nil)
+	file := api.file(t, nil, "README", &content)
+	other, err := api.store.Projects.Create(context.Background(), "other", api.owner.ID.String(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	elsewhere := models.FileNode{ProjectID: other.ID, Name: "elsewhere", IsFolder: true}
+	if err := api.store.Files.Create(context.Background(), &elsewhere); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		name   string
+		parent interface{}
+		want   int
+	}{
+		{"top level", nil, http.StatusCreated},
+		{"folder", folder.ID.String(), http.StatusCreated},
+		{"file", file.ID.String(), http.StatusBadRequest},
+		{"folder of another project", elsewhere.ID.String(), http.StatusNotFound},
+		{"unknown", uuid.NewString(), http.StatusNotFound},
+		{"malformed", "src", http.StatusBadRequest},
+	} {
+		body := map[string]interface{}{"name": "new.txt", "parentId": c.parent}
+		rec := api.do(t, api.owner, "POST", "/project/"+api.project.ID.String()+"/files", body)
+		if rec.Code != c.want {
+			t.Errorf("%s: status %d (%s), want %d", c.name, rec.Code, rec.Body.String(), c.want)
+		}
+	}
+	children, err := api.store.Files.Children(context.Background(), other.ID, &elsewhere.ID, "", 10)
+	if err != nil || len(children) != 0 {
+		t.Errorf("other project's folder has %d children, %v", len(children), err)
+	}
+}
+
+func TestNodeNames(t *testing.T) {
+	api := newTestAPI(t)
+	api.router.Group(func(r chi.Router) {
+		r.Use(api.mw.RequirePermission(permissions.FileWrite))
+		r.Post("/project/{projectId}/files", api.handler.CreateFileNode)
+		r.Put("/file/{fileId}/rename", api.handler.RenameFileNode)
+	})
+	content := "text"
+	file := api.file(t, nil, "README", &content)
+
+	for _, name := range []string{"", ".", "..", "a/b", "/", "nul\x00"} {
+		rec := api.do(t, api.owner, "POST", "/project/"+api.project.ID.String()+"/files", map[string]string{"name": name})
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("create %q: status %d, want %d", name, rec.Code, http.StatusBadRequest)
+		}
+		rec = api.do(t, api.owner, "PUT", "/file/"+file.ID.String()+"/rename", map[string]string{"newName": name})
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("rename to %q: status %d, want %d", name, rec.Code, http.StatusBadRequest)
+		}
+	}
+	if rec := api.do(t, api.owner, "PUT", "/file/"+file.ID.String()+"/rename", map[string]string{"newName": "README.md"}); rec.Code != http.StatusOK {
+		t.Errorf("rename to README.md: status %d (%s)", rec.Code, rec.Body.String())
+	}
+}
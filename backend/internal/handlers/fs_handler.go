@@ -0,0 +1,424 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"project-meetings/backend/internal/acl"
+	"project-meetings/backend/internal/models"
+	"project-meetings/backend/internal/store"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// maxFSFileSize caps the body of a PUT to a file path.
+const maxFSFileSize = 10 << 20
+
+var errBadPath = errors.New(`path segments may not be "." or ".." or contain NUL`)
+
+// fsPath splits the wildcard part of a /fs/* route into names. A trailing
+// slash (or an empty path) marks a folder.
+func fsPath(r *http.Request) ([]string, bool, error) {
+	raw := chi.URLParam(r, "*")
+	// chi routes on the escaped path when the URL has one.
+	if r.URL.RawPath != "" {
+		unescaped, err := url.PathUnescape(raw)
+		if err != nil {
+			return nil, false, err
+		}
+		raw = unescaped
+	}
+
+	segments := make([]string, 0)
+	for _, name := range strings.Split(raw, "/") {
+		if name == "" {
+			continue
+		}
+		if !validNodeName(name) {
+			return nil, false, errBadPath
+		}
+		segments = append(segments, name)
+	}
+	return segments, raw == "" || strings.HasSuffix(raw, "/"), nil
+}
+
+// fsView indexes a project's nodes for path building and access checks.
+type fsView struct {
+	nodes  map[uuid.UUID]*models.FileNode
+	access *acl.Evaluator
+}
+
+func (h *Handler) loadFSView(r *http.Request, projectID uuid.UUID) (*fsView, error) {
+	access, err := acl.LoadProject(r.Context(), h.store.Files, projectID, aclSubject(r))
+	if err != nil {
+		return nil, err
+	}
+	outline, err := h.store.Files.Outline(r.Context(), projectID)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make(map[uuid.UUID]*models.FileNode, len(outline))
+	for i := range outline {
+		nodes[outline[i].ID] = &outline[i]
+	}
+	return &fsView{nodes: nodes, access: access}, nil
+}
+
+// entries lists the readable nodes below folderID (the project root when
+// nil): its children, or every descendant when recursive.
+func (v *fsView) entries(folderID *uuid.UUID, recursive bool) []models.FileNode {
+	list := make([]models.FileNode, 0)
+	for id, node := range v.nodes {
+		if !v.isBelow(node, folderID, recursive) {
+			continue
+		}
+		p, ok := readablePath(v.nodes, v.access, id)
+		if !ok {
+			continue
+		}
+		entry := *node
+		entry.Path = p
+		list = append(list, entry)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Path < list[j].Path })
+	return list
+}
+
+func (v *fsView) isBelow(node *models.FileNode, folderID *uuid.UUID, recursive bool) bool {
+	parent := node.ParentID
+	if !recursive {
+		return (parent == nil && folderID == nil) || (parent != nil && folderID != nil && *parent == *folderID)
+	}
+	if folderID == nil {
+		return true
+	}
+	for depth := 0; parent != nil && depth <= len(v.nodes); depth++ {
+		if *parent == *folderID {
+			return true
+		}
+		next, ok := v.nodes[*parent]
+		if !ok {
+			return false
+		}
+		parent = next.ParentID
+	}
+	return false
+}
+
+// GetFSPath returns the file at a path with its content, or lists a folder.
+// Folder listings hold direct children unless ?recursive is given.
+func (h *Handler) GetFSPath(w http.ResponseWriter, r *http.Request) {
+	projectID, err := uuid.Parse(chi.URLParam(r, "projectId"))
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+	segments, _, err := fsPath(r)
+	if err != nil {
+		http.Error(w, "Invalid path: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	recursive := r.URL.Query().Has("recursive") && r.URL.Query().Get("recursive") != "false"
+
+	view, err := h.loadFSView(r, projectID)
+	if err != nil {
+		log.Printf("Failed to load file tree: %v", err)
+		http.Error(w, "Failed to read path", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if len(segments) == 0 {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"path":    "",
+			"entries": view.entries(nil, recursive),
+		})
+		return
+	}
+
+	node, err := h.store.Files.ResolvePath(r.Context(), projectID, segments)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		log.Printf("Failed to resolve path: %v", err)
+		http.Error(w, "Failed to read path", http.StatusInternalServerError)
+		return
+	}
+	// Hidden nodes are reported as missing so their names don't leak.
+	nodePath, readable := readablePath(view.nodes, view.access, node.ID)
+	if err != nil || !readable {
+		http.Error(w, "Path not found", http.StatusNotFound)
+		return
+	}
+
+	if node.IsFolder {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"path":    nodePath,
+			"entries": view.entries(&node.ID, recursive),
+		})
+		return
+	}
+	if notModified(w, r, node) {
+		return
+	}
+	node.Path = nodePath
+	json.NewEncoder(w).Encode(node)
+}
+
+// PutFSPath writes the request body to the file at a path, creating it and
+// any missing parent folders. A path ending in a slash creates folders only.
+func (h *Handler) PutFSPath(w http.ResponseWriter, r *http.Request) {
+	projectIDStr := chi.URLParam(r, "projectId")
+	projectID, err := uuid.Parse(projectIDStr)
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+	segments, isFolder, err := fsPath(r)
+	if err != nil {
+		http.Error(w, "Invalid path: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(segments) == 0 {
+		http.Error(w, "A path is required", http.StatusBadRequest)
+		return
+	}
+
+	var content string
+	if !isFolder {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxFSFileSize))
+		if err != nil {
+			http.Error(w, "File is too large or could not be read", http.StatusRequestEntityTooLarge)
+			return
+		}
+		content = string(body)
+	}
+
+	folders := segments
+	if !isFolder {
+		folders = segments[:len(segments)-1]
+	}
+	var node models.FileNode
+	var parentID *uuid.UUID
+	created := false
+	for i := range folders {
+		folder, made, status, msg := h.ensureFolder(r, projectID, parentID, segments[:i+1])
+		if status != 0 {
+			http.Error(w, msg, status)
+			return
+		}
+		created = created || made
+		node = folder
+		parentID = &folder.ID
+	}
+
+	status := http.StatusOK
+	if isFolder {
+		if created {
+			status = http.StatusCreated
+		}
+	} else {
+		node, err = h.store.Files.ResolvePath(r.Context(), projectID, segments)
+		switch {
+		case err == nil:
+			// Hidden nodes are reported as missing so their names don't leak.
+			access := h.fileAccess(r, node.ID)
+			if access < acl.Read {
+				http.Error(w, "Path not found", http.StatusNotFound)
+				return
+			}
+			if node.IsFolder {
+				http.Error(w, fmt.Sprintf("%s is a folder", strings.Join(segments, "/")), http.StatusConflict)
+				return
+			}
+			if access < acl.Write {
+				http.Error(w, "You do not have write access to this file", http.StatusForbidden)
+				return
+			}
+			// If-None-Match: * asks to create the file only.
+			if r.Header.Get("If-None-Match") == "*" {
+				w.Header().Set("ETag", fileETag(node.ID, node.Revision))
+				http.Error(w, "The file already exists", http.StatusPreconditionFailed)
+				return
+			}
+			rev, ok := ifMatchRevision(r, node)
+			if !ok {
+				h.preconditionFailed(w, r, node.ID)
+				return
+			}
+			node.Revision, err = h.store.Files.UpdateContent(r.Context(), node.ID, content, rev)
+			if errors.Is(err, store.ErrStale) {
+				h.preconditionFailed(w, r, node.ID)
+				return
+			}
+			if err != nil {
+				log.Printf("Failed to save file content: %v", err)
+				http.Error(w, "Failed to save file", http.StatusInternalServerError)
+				return
+			}
+			h.auditLog(r, projectIDStr, "file.save", "file", node.ID.String(), map[string]interface{}{"bytes": len(content), "path": strings.Join(segments, "/")})
+			// Open editors pick the new content up through the hub.
+			h.broadcastToProject(projectIDStr, "editor_update", map[string]string{"fileId": node.ID.String(), "content": content})
+		case errors.Is(err, store.ErrNotFound):
+			if !h.canWriteParent(r, parentID) {
+				http.Error(w, "You do not have write access to this folder", http.StatusForbidden)
+				return
+			}
+			// If-Match needs a current version to match.
+			if r.Header.Get("If-Match") != "" {
+				http.Error(w, "The file no longer exists", http.StatusPreconditionFailed)
+				return
+			}
+			node = models.FileNode{ProjectID: projectID, ParentID: parentID, Name: segments[len(segments)-1], Content: &content}
+			if err := h.store.Files.Create(r.Context(), &node); err != nil {
+				if errors.Is(err, store.ErrConflict) {
+					http.Error(w, "The file was created by someone else at the same time", http.StatusConflict)
+					return
+				}
+				log.Printf("Failed to create file: %v", err)
+				http.Error(w, "Failed to create file", http.StatusInternalServerError)
+				return
+			}
+			h.auditLog(r, projectIDStr, "file.create", "file", node.ID.String(), map[string]interface{}{
+				"name":     node.Name,
+				"isFolder": false,
+				"path":     strings.Join(segments, "/"),
+			})
+			created = true
+			status = http.StatusCreated
+		default:
+			log.Printf("Failed to resolve path: %v", err)
+			http.Error(w, "Failed to write path", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if created {
+		h.broadcastToProject(projectIDStr, "file_created", map[string]string{"id": node.ID.String()})
+	}
+
+	node.Content = nil
+	node.Path = strings.Join(segments, "/")
+	if !isFolder {
+		w.Header().Set("ETag", fileETag(node.ID, node.Revision))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(node)
+}
+
+// ensureFolder returns the folder at segments, creating it under parentID if
+// it doesn't exist. A non-zero status and message describe a failure.
+func (h *Handler) ensureFolder(r *http.Request, projectID uuid.UUID, parentID *uuid.UUID, segments []string) (models.FileNode, bool, int, string) {
+	folderPath := strings.Join(segments, "/")
+	node, err := h.store.Files.ResolvePath(r.Context(), projectID, segments)
+	if errors.Is(err, store.ErrNotFound) {
+		if !h.canWriteParent(r, parentID) {
+			return node, false, http.StatusForbidden, "You do not have write access to this folder"
+		}
+		node = models.FileNode{ProjectID: projectID, ParentID: parentID, IsFolder: true, Name: segments[len(segments)-1]}
+		err = h.store.Files.Create(r.Context(), &node)
+		if err == nil {
+			h.auditLog(r, projectID.String(), "file.create", "file", node.ID.String(), map[string]interface{}{
+				"name":     node.Name,
+				"isFolder": true,
+				"path":     folderPath,
+			})
+			return node, true, 0, ""
+		}
+		// Someone else created it first; use theirs.
+		if errors.Is(err, store.ErrConflict) {
+			node, err = h.store.Files.ResolvePath(r.Context(), projectID, segments)
+		}
+	}
+	if err != nil {
+		log.Printf("Failed to create folder %s: %v", folderPath, err)
+		return node, false, http.StatusInternalServerError, "Failed to create folder"
+	}
+	if !h.canReadFile(r, node.ID) {
+		return node, false, http.StatusNotFound, "Path not found"
+	}
+	if !node.IsFolder {
+		return node, false, http.StatusConflict, fmt.Sprintf("%s is a file", folderPath)
+	}
+	return node, false, 0, ""
+}
+
+// DeleteFSPath deletes the file or folder at a path, with everything below it.
+func (h *Handler) DeleteFSPath(w http.ResponseWriter, r *http.Request) {
+	projectIDStr := chi.URLParam(r, "projectId")
+	projectID, err := uuid.Parse(projectIDStr)
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+	segments, _, err := fsPath(r)
+	if err != nil {
+		http.Error(w, "Invalid path: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(segments) == 0 {
+		http.Error(w, "The project root cannot be deleted", http.StatusBadRequest)
+		return
+	}
+
+	node, err := h.store.Files.ResolvePath(r.Context(), projectID, segments)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Path not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to resolve path: %v", err)
+		http.Error(w, "Failed to delete path", http.StatusInternalServerError)
+		return
+	}
+
+	access, err := acl.LoadProject(r.Context(), h.store.Files, projectID, aclSubject(r))
+	if err != nil {
+		log.Printf("Failed to load access rules: %v", err)
+		http.Error(w, "Failed to delete path", http.StatusInternalServerError)
+		return
+	}
+	if !access.CanRead(node.ID) {
+		http.Error(w, "Path not found", http.StatusNotFound)
+		return
+	}
+	if !access.CanWriteSubtree(node.ID) {
+		http.Error(w, "You do not have write access to everything at this path", http.StatusForbidden)
+		return
+	}
+	rev, ok := ifMatchRevision(r, node)
+	if !ok {
+		h.preconditionFailed(w, r, node.ID)
+		return
+	}
+
+	if _, err := h.store.Files.Delete(r.Context(), node.ID, rev); err != nil {
+		if errors.Is(err, store.ErrStale) {
+			h.preconditionFailed(w, r, node.ID)
+			return
+		}
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Path not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Failed to delete file: %v", err)
+		http.Error(w, "Failed to delete path", http.StatusInternalServerError)
+		return
+	}
+
+	h.auditLog(r, projectIDStr, "file.delete", "file", node.ID.String(), map[string]interface{}{
+		"name":     node.Name,
+		"isFolder": node.IsFolder,
+		"path":     strings.Join(segments, "/"),
+	})
+	h.broadcastToProject(projectIDStr, "file_deleted", map[string]string{"id": node.ID.String()})
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"project-meetings/backend/internal/acl"
+	"project-meetings/backend/internal/models"
+	"project-meetings/backend/internal/permissions"
+)
+
+func TestPutFSPathAccess(t *testing.T) {
+	api := newTestAPI(t)
+	api.router.With(api.mw.RequirePermission(permissions.FileWrite)).Put("/project/{projectId}/fs/*", api.handler.PutFSPath)
+	projectID := api.project.ID.String()
+	restrict := func(node models.FileNode, access string) {
+		err := api.store.Files.ReplaceRules(context.Background(), projectID, node.ID, []models.AccessRule{
+			{FileID: node.ID, SubjectType: acl.SubjectRole, Subject: "editor", Access: access},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	text := "text"
+	hidden := api.file(t, nil, "hidden", nil)
+	api.file(t, &hidden.ID, "inside.txt", &text)
+	restrict(hidden, "none")
+	restrict(api.file(t, nil, "secret.txt", &text), "none")
+	restrict(api.file(t, nil, "readonly.txt", &text), "read")
+	api.file(t, nil, "open.txt", &text)
+	editor := api.member(t, "editor", "editor")
+
+	for _, c := range []struct {
+		path string
+		want int
+	}{
+		{"hidden/inside.txt", http.StatusNotFound},
+		{"hidden/new.txt", http.StatusNotFound},
+		{"hidden/", http.StatusNotFound},
+		{"hidden", http.StatusNotFound},
+		{"secret.txt", http.StatusNotFound},
+		{"readonly.txt", http.StatusForbidden},
+		{"open.txt", http.StatusOK},
+		{"new/file.txt", http.StatusCreated},
+	} {
+		if rec := api.do(t, editor, "PUT", "/project/"+projectID+"/fs/"+c.path, "content"); rec.Code != c.want {
+			t.Errorf("PUT %s: status %d (%s), want %d", c.path, rec.Code, rec.Body.String(), c.want)
+		}
+	}
+	if rec := api.do(t, api.owner, "PUT", "/project/"+projectID+"/fs/hidden/inside.txt", "content"); rec.Code != http.StatusOK {
+		t.Errorf("owner: status %d", rec.Code)
+	}
+}
@@ -0,0 +1,753 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"project-meetings/backend/internal/acl"
+	"project-meetings/backend/internal/gitrepo"
+	"project-meetings/backend/internal/middleware"
+	"project-meetings/backend/internal/models"
+	"project-meetings/backend/internal/store"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/google/uuid"
+)
+
+// maxGitImportFiles caps the number of files an import or pull may create.
+const maxGitImportFiles = 10000
+
+var errTooManyFiles = fmt.Errorf("repositories with more than %d files cannot be imported", maxGitImportFiles)
+
+// gitError maps a failed git operation to a response.
+func gitError(w http.ResponseWriter, err error, action string) {
+	switch {
+	case errors.Is(err, gitrepo.ErrRemoteNotAllowed):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, gitrepo.ErrBranchNotFound):
+		http.Error(w, "Branch not found on the remote", http.StatusNotFound)
+	case errors.Is(err, transport.ErrRepositoryNotFound):
+		http.Error(w, "Repository not found", http.StatusNotFound)
+	case errors.Is(err, transport.ErrAuthenticationRequired), errors.Is(err, transport.ErrAuthorizationFailed):
+		http.Error(w, "The remote rejected the credentials in its URL", http.StatusBadRequest)
+	case errors.Is(err, store.ErrQuotaExceeded):
+		http.Error(w, "The repository would exceed the project's storage quota", http.StatusInsufficientStorage)
+	case errors.Is(err, errTooManyFiles):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	default:
+		log.Printf("Failed to %s: %v", action, err)
+		http.Error(w, "Failed to "+action, http.StatusInternalServerError)
+	}
+}
+
+// gitLinkJSON describes a link without the credentials in its remote URL.
+func gitLinkJSON(link models.GitLink) map[string]interface{} {
+	return map[string]interface{}{
+		"remoteUrl": gitrepo.Redact(link.RemoteURL),
+		"branch":    link.Branch,
+		"head":      link.Head,
+		"updatedAt": link.UpdatedAt,
+	}
+}
+
+// openGitRepo loads a project's link and repository, answering the request
+// itself when that fails.
+func (h *Handler) openGitRepo(w http.ResponseWriter, r *http.Request, projectID uuid.UUID) (models.GitLink, *gitrepo.Repo, bool) {
+	link, err := h.store.Git.Link(r.Context(), projectID)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "This project is not linked to a git repository", http.StatusNotFound)
+		return link, nil, false
+	}
+	if err != nil {
+		log.Printf("Failed to load git link: %v", err)
+		http.Error(w, "Failed to load git repository", http.StatusInternalServerError)
+		return link, nil, false
+	}
+	repo, err := h.git.Open(r.Context(), projectID.String(), link.RemoteURL, link.Branch)
+	if err != nil {
+		gitError(w, err, "open git repository")
+		return link, nil, false
+	}
+	return link, repo, true
+}
+
+// nodesByPath indexes a project's nodes by their path.
+func nodesByPath(outline []models.FileNode) map[string]models.FileNode {
+	byID := make(map[uuid.UUID]*models.FileNode, len(outline))
+	for i := range outline {
+		byID[outline[i].ID] = &outline[i]
+	}
+	paths := make(map[string]models.FileNode, len(outline))
+	for _, node := range byID {
+		parts := []string{node.Name}
+		for parent := node.ParentID; parent != nil && len(parts) <= len(byID); {
+			folder, ok := byID[*parent]
+			if !ok {
+				break
+			}
+			parts = append([]string{folder.Name}, parts...)
+			parent = folder.ParentID
+		}
+		paths[path.Join(parts...)] = *node
+	}
+	return paths
+}
+
+// gitTreeNodes turns the files of a commit into project nodes, with folders
+// for their directories. Text files keep their content in the node; other
+// files go to blob storage, and the keys written are returned for cleanup.
+// A node whose path and kind match one in existing takes over its ID, so
+// access rules and open editors stay with it.
+func (h *Handler) gitTreeNodes(r *http.Request, projectID uuid.UUID, repo *gitrepo.Repo, commit string, quota int64, existing map[string]models.FileNode) ([]models.FileNode, []string, error) {
+	files, err := repo.Files(commit)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(files) > maxGitImportFiles {
+		return nil, nil, errTooManyFiles
+	}
+
+	nodes := make([]models.FileNode, 0, len(files))
+	folders := make(map[string]uuid.UUID)
+	var ensureFolder func(dir string) *uuid.UUID
+	ensureFolder = func(dir string) *uuid.UUID {
+		if dir == "." || dir == "" {
+			return nil
+		}
+		if id, ok := folders[dir]; ok {
+			return &id
+		}
+		parentID := ensureFolder(path.Dir(dir))
+		id := uuid.New()
+		if old, ok := existing[dir]; ok && old.IsFolder {
+			id = old.ID
+		}
+		folders[dir] = id
+		nodes = append(nodes, models.FileNode{ID: id, ProjectID: projectID, ParentID: parentID, IsFolder: true, Name: path.Base(dir)})
+		return &id
+	}
+
+	var keys []string
+	var stored int64
+	for _, file := range files {
+		node := models.FileNode{ID: uuid.New(), ProjectID: projectID, ParentID: ensureFolder(path.Dir(file.Path)), Name: path.Base(file.Path)}
+		if old, ok := existing[file.Path]; ok && !old.IsFolder {
+			node.ID = old.ID
+		}
+		if file.Size <= maxFSFileSize {
+			content, err := file.ReadAll()
+			if err != nil {
+				return nil, keys, err
+			}
+			if utf8.Valid(content) && !bytes.Contains(content, []byte{0}) {
+				text := string(content)
+				node.Content = &text
+				nodes = append(nodes, node)
+				continue
+			}
+		}
+
+		stored += file.Size
+		if stored > quota {
+			return nil, keys, store.ErrQuotaExceeded
+		}
+		blob, err := h.storeGitFile(r, projectID, file)
+		if err != nil {
+			return nil, keys, err
+		}
+		keys = append(keys, blob.Key)
+		node.Blob = &blob
+		nodes = append(nodes, node)
+	}
+	return nodes, keys, nil
+}
+
+// storeGitFile copies a binary file from a commit to blob storage.
+func (h *Handler) storeGitFile(r *http.Request, projectID uuid.UUID, file gitrepo.File) (models.Blob, error) {
+	rd, err := file.Open()
+	if err != nil {
+		return models.Blob{}, err
+	}
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(rd, head)
+	hash := sha256.New()
+	hash.Write(head[:n])
+	_, err = io.Copy(hash, rd)
+	rd.Close()
+	if err != nil {
+		return models.Blob{}, err
+	}
+
+	blob := models.Blob{
+		Key:         fmt.Sprintf("%s/%s", projectID, uuid.New()),
+		ContentType: http.DetectContentType(head[:n]),
+		Size:        file.Size,
+		SHA256:      hex.EncodeToString(hash.Sum(nil)),
+	}
+	rd, err = file.Open()
+	if err != nil {
+		return models.Blob{}, err
+	}
+	defer rd.Close()
+	return blob, h.store.Blobs.Put(r.Context(), blob.Key, rd, blob.Size, blob.ContentType)
+}
+
+// replaceWithCommit swaps the project's files for the tree of a commit and
+// tells connected clients. Paths that stay keep their node IDs and with them
+// their access rules. It returns the number of nodes created.
+func (h *Handler) replaceWithCommit(r *http.Request, projectID uuid.UUID, repo *gitrepo.Repo, commit string) (int, error) {
+	old, err := h.store.Files.Outline(r.Context(), projectID)
+	if err != nil {
+		return 0, err
+	}
+	quota := storageQuota()
+	nodes, keys, err := h.gitTreeNodes(r, projectID, repo, commit, quota, nodesByPath(old))
+	if err == nil {
+		err = h.store.Files.ReplaceTree(r.Context(), projectID, nodes, quota)
+	}
+	if err != nil {
+		for _, key := range keys {
+			if delErr := h.store.Blobs.Delete(r.Context(), key); delErr != nil {
+				log.Printf("Failed to clean up blob %s: %v", key, delErr)
+			}
+		}
+		return 0, err
+	}
+
+	projectIDStr := projectID.String()
+	for _, node := range old {
+		if node.ParentID == nil {
+			h.broadcastToProject(projectIDStr, "file_deleted", map[string]string{"id": node.ID.String()})
+		}
+	}
+	for _, node := range nodes {
+		if node.ParentID == nil {
+			h.broadcastToProject(projectIDStr, "file_created", map[string]string{"id": node.ID.String()})
+		}
+	}
+	// Open editors of files that kept their IDs would otherwise go on
+	// showing, and saving, the content from before.
+	live, err := h.hub.EditorContents(r.Context(), projectIDStr)
+	if err != nil {
+		log.Printf("Failed to load open editors of project %s: %v", projectIDStr, err)
+	}
+	for _, node := range nodes {
+		if _, open := live[node.ID.String()]; open && node.Content != nil {
+			h.broadcastToProject(projectIDStr, "editor_update", map[string]string{"fileId": node.ID.String(), "content": *node.Content})
+		}
+	}
+	return len(nodes), nil
+}
+
+// gitWorkFile is a file of the project as it is now. Text files carry the
+// content of an open editor in place of the saved copy.
+type gitWorkFile struct {
+	node    models.FileNode
+	content string
+}
+
+// loadGitWorkTree returns the project's files by path. Files the caller
+// cannot read are left out, which the bool reports.
+func (h *Handler) loadGitWorkTree(r *http.Request, projectID uuid.UUID) (map[string]*gitWorkFile, bool, error) {
+	access, err := acl.LoadProject(r.Context(), h.store.Files, projectID, aclSubject(r))
+	if err != nil {
+		return nil, false, err
+	}
+	files, err := h.store.Files.List(r.Context(), projectID)
+	if err != nil {
+		return nil, false, err
+	}
+	nodes := make(map[uuid.UUID]*models.FileNode, len(files))
+	for i := range files {
+		nodes[files[i].ID] = &files[i]
+	}
+	live, err := h.hub.EditorContents(r.Context(), projectID.String())
+	if err != nil {
+		return nil, false, err
+	}
+
+	work := make(map[string]*gitWorkFile)
+	complete := true
+	for id, node := range nodes {
+		p, ok := readablePath(nodes, access, id)
+		if !ok {
+			complete = false
+			continue
+		}
+		if node.IsFolder {
+			continue
+		}
+		file := &gitWorkFile{node: *node}
+		if node.Content != nil {
+			file.content = *node.Content
+		}
+		if content, ok := live[id.String()]; ok {
+			file.content = content
+			file.node.Blob = nil
+		}
+		work[p] = file
+	}
+	return work, complete, nil
+}
+
+// sameAsCommitted reports whether a project file still matches its version
+// in a commit.
+func sameAsCommitted(file *gitWorkFile, committed gitrepo.File) (bool, error) {
+	if file.node.Blob == nil {
+		return gitrepo.BlobHash([]byte(file.content)) == committed.Hash, nil
+	}
+	if file.node.Blob.Size != committed.Size {
+		return false, nil
+	}
+	rd, err := committed.Open()
+	if err != nil {
+		return false, err
+	}
+	defer rd.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, rd); err != nil {
+		return false, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)) == file.node.Blob.SHA256, nil
+}
+
+type gitChange struct {
+	Path   string `json:"path"`
+	Status string `json:"status"` // added, modified or deleted
+}
+
+// gitChanges compares the project's files with a commit. Empty folders are
+// not tracked by git and never show up as changes.
+func gitChanges(work map[string]*gitWorkFile, committed []gitrepo.File) ([]gitChange, error) {
+	changes := make([]gitChange, 0)
+	seen := make(map[string]bool, len(committed))
+	for _, c := range committed {
+		seen[c.Path] = true
+		file, ok := work[c.Path]
+		if !ok {
+			changes = append(changes, gitChange{Path: c.Path, Status: "deleted"})
+			continue
+		}
+		same, err := sameAsCommitted(file, c)
+		if err != nil {
+			return nil, err
+		}
+		if !same {
+			changes = append(changes, gitChange{Path: c.Path, Status: "modified"})
+		}
+	}
+	for p := range work {
+		if !seen[p] {
+			changes = append(changes, gitChange{Path: p, Status: "added"})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+// --- IMPORT FROM GIT ---
+// ImportGitRepository replaces the project's files with the tree of a
+// remote branch and links the project to it.
+func (h *Handler) ImportGitRepository(w http.ResponseWriter, r *http.Request) {
+	projectIDStr := chi.URLParam(r, "projectId")
+	projectID, err := uuid.Parse(projectIDStr)
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+	var req struct {
+		URL    string `json:"url"`
+		Branch string `json:"branch"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.URL = strings.TrimSpace(req.URL)
+	if req.URL == "" {
+		http.Error(w, "A repository URL is required", http.StatusBadRequest)
+		return
+	}
+
+	unlock := h.git.Lock(projectIDStr)
+	defer unlock()
+
+	if _, complete, err := h.loadGitWorkTree(r, projectID); err != nil {
+		gitError(w, err, "import repository")
+		return
+	} else if !complete {
+		http.Error(w, "You can only import into a project whose files you can all read", http.StatusForbidden)
+		return
+	}
+	repo, err := h.git.Clone(r.Context(), projectIDStr, req.URL, strings.TrimSpace(req.Branch))
+	if err != nil {
+		gitError(w, err, "clone repository")
+		return
+	}
+	head, err := repo.Head()
+	if err != nil {
+		gitError(w, err, "clone repository")
+		return
+	}
+	count, err := h.replaceWithCommit(r, projectID, repo, head)
+	if err != nil {
+		gitError(w, err, "import repository")
+		return
+	}
+	link := models.GitLink{ProjectID: projectID, RemoteURL: req.URL, Branch: repo.Branch, Head: head}
+	if err := h.store.Git.SaveLink(r.Context(), &link); err != nil {
+		gitError(w, err, "save git link")
+		return
+	}
+
+	h.auditLog(r, projectIDStr, "git.import", "project", projectIDStr, map[string]interface{}{
+		"remoteUrl": gitrepo.Redact(req.URL),
+		"branch":    repo.Branch,
+		"head":      head,
+		"nodes":     count,
+	})
+	log.Printf("[Git] Imported %s@%s into project %s", gitrepo.Redact(req.URL), repo.Branch, projectIDStr)
+
+	resp := gitLinkJSON(link)
+	resp["nodes"] = count
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// --- GIT STATUS ---
+// GetGitStatus lists the files added, modified or deleted since the last
+// imported or committed commit.
+func (h *Handler) GetGitStatus(w http.ResponseWriter, r *http.Request) {
+	projectID, err := uuid.Parse(chi.URLParam(r, "projectId"))
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+	unlock := h.git.Lock(projectID.String())
+	defer unlock()
+
+	link, repo, ok := h.openGitRepo(w, r, projectID)
+	if !ok {
+		return
+	}
+	work, _, err := h.loadGitWorkTree(r, projectID)
+	if err != nil {
+		gitError(w, err, "compute git status")
+		return
+	}
+	committed, err := repo.Files(link.Head)
+	if err != nil {
+		gitError(w, err, "compute git status")
+		return
+	}
+	changes, err := gitChanges(work, committed)
+	if err != nil {
+		gitError(w, err, "compute git status")
+		return
+	}
+
+	resp := gitLinkJSON(link)
+	resp["changes"] = changes
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// --- GIT COMMIT ---
+// CommitGitChanges records the project's current files as a commit on the
+// linked branch, authored by the caller. Open editors' content is included.
+func (h *Handler) CommitGitChanges(w http.ResponseWriter, r *http.Request) {
+	projectIDStr := chi.URLParam(r, "projectId")
+	projectID, err := uuid.Parse(projectIDStr)
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+	var req struct {
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Message) == "" {
+		http.Error(w, "A commit message is required", http.StatusBadRequest)
+		return
+	}
+
+	userID, _ := r.Context().Value(middleware.UserIDKey).(string)
+	username, _ := r.Context().Value(middleware.UsernameKey).(string)
+	user, err := h.store.Users.Get(r.Context(), userID)
+	if err != nil {
+		log.Printf("Failed to load commit author: %v", err)
+		http.Error(w, "Failed to load user", http.StatusInternalServerError)
+		return
+	}
+	if username == "" {
+		username = user.Username
+	}
+
+	unlock := h.git.Lock(projectIDStr)
+	defer unlock()
+
+	link, repo, ok := h.openGitRepo(w, r, projectID)
+	if !ok {
+		return
+	}
+	work, complete, err := h.loadGitWorkTree(r, projectID)
+	if err != nil {
+		gitError(w, err, "commit")
+		return
+	}
+	// A commit holds the whole tree, so it must not carry files the caller
+	// is not allowed to see off to the remote.
+	if !complete {
+		http.Error(w, "You can only commit a project whose files you can all read", http.StatusForbidden)
+		return
+	}
+	committed, err := repo.Files(link.Head)
+	if err != nil {
+		gitError(w, err, "commit")
+		return
+	}
+	previous := make(map[string]gitrepo.File, len(committed))
+	for _, c := range committed {
+		previous[c.Path] = c
+	}
+
+	files := make(map[string]string, len(work))
+	for p, file := range work {
+		if c, ok := previous[p]; ok {
+			same, err := sameAsCommitted(file, c)
+			if err != nil {
+				gitError(w, err, "commit")
+				return
+			}
+			if same {
+				files[p] = c.Hash
+				continue
+			}
+		}
+		hash, err := h.writeGitBlob(r, repo, file)
+		if err != nil {
+			gitError(w, err, "commit")
+			return
+		}
+		files[p] = hash
+	}
+
+	commit, err := repo.Commit(link.Head, files, req.Message, gitrepo.Signature{Name: username, Email: user.Email})
+	if errors.Is(err, gitrepo.ErrNothingToCommit) {
+		http.Error(w, "There are no changes to commit", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		gitError(w, err, "commit")
+		return
+	}
+	link.Head = commit
+	if err := h.store.Git.SaveLink(r.Context(), &link); err != nil {
+		gitError(w, err, "save git link")
+		return
+	}
+
+	h.auditLog(r, projectIDStr, "git.commit", "project", projectIDStr, map[string]interface{}{
+		"branch":  link.Branch,
+		"commit":  commit,
+		"message": req.Message,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(gitLinkJSON(link))
+}
+
+// writeGitBlob stores a project file in the repository.
+func (h *Handler) writeGitBlob(r *http.Request, repo *gitrepo.Repo, file *gitWorkFile) (string, error) {
+	if file.node.Blob == nil {
+		return repo.WriteBlob(strings.NewReader(file.content), int64(len(file.content)))
+	}
+	body, err := h.store.Blobs.Open(r.Context(), file.node.Blob.Key)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	return repo.WriteBlob(body, file.node.Blob.Size)
+}
+
+// --- GIT PUSH ---
+// PushGitBranch sends the linked branch to its remote. It refuses when the
+// remote has commits the project hasn't pulled.
+func (h *Handler) PushGitBranch(w http.ResponseWriter, r *http.Request) {
+	projectIDStr := chi.URLParam(r, "projectId")
+	projectID, err := uuid.Parse(projectIDStr)
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+	unlock := h.git.Lock(projectIDStr)
+	defer unlock()
+
+	link, repo, ok := h.openGitRepo(w, r, projectID)
+	if !ok {
+		return
+	}
+	remoteHead, err := repo.Fetch(r.Context())
+	if err != nil && !errors.Is(err, gitrepo.ErrBranchNotFound) {
+		gitError(w, err, "fetch from remote")
+		return
+	}
+	if remoteHead != "" {
+		ahead, err := repo.IsAncestor(remoteHead, link.Head)
+		if err != nil {
+			gitError(w, err, "push")
+			return
+		}
+		if !ahead {
+			http.Error(w, "The remote branch has commits this project doesn't have; pull first", http.StatusConflict)
+			return
+		}
+	}
+	pushed, err := repo.Push(r.Context())
+	if err != nil {
+		gitError(w, err, "push")
+		return
+	}
+
+	if pushed {
+		h.auditLog(r, projectIDStr, "git.push", "project", projectIDStr, map[string]interface{}{
+			"branch": link.Branch,
+			"head":   link.Head,
+		})
+	}
+
+	resp := gitLinkJSON(link)
+	resp["pushed"] = pushed
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// --- GIT PULL ---
+// PullGitBranch fast-forwards the project to the remote branch, replacing
+// its files. Uncommitted changes or diverged histories are refused unless
+// the body says {"force": true}, which discards them.
+func (h *Handler) PullGitBranch(w http.ResponseWriter, r *http.Request) {
+	projectIDStr := chi.URLParam(r, "projectId")
+	projectID, err := uuid.Parse(projectIDStr)
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+	var req struct {
+		Force bool `json:"force"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	unlock := h.git.Lock(projectIDStr)
+	defer unlock()
+
+	link, repo, ok := h.openGitRepo(w, r, projectID)
+	if !ok {
+		return
+	}
+	remoteHead, err := repo.Fetch(r.Context())
+	if err != nil {
+		gitError(w, err, "fetch from remote")
+		return
+	}
+	upToDate, err := repo.IsAncestor(remoteHead, link.Head)
+	if err != nil {
+		gitError(w, err, "pull")
+		return
+	}
+	if upToDate && !req.Force {
+		resp := gitLinkJSON(link)
+		resp["updated"] = false
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	// A pull replaces every file, so it would also take away files the
+	// caller can't see.
+	work, complete, err := h.loadGitWorkTree(r, projectID)
+	if err != nil {
+		gitError(w, err, "pull")
+		return
+	}
+	if !complete {
+		http.Error(w, "You can only pull into a project whose files you can all read", http.StatusForbidden)
+		return
+	}
+
+	if !req.Force {
+		fastForward, err := repo.IsAncestor(link.Head, remoteHead)
+		if err != nil {
+			gitError(w, err, "pull")
+			return
+		}
+		if !fastForward {
+			http.Error(w, "The project and the remote branch have diverged", http.StatusConflict)
+			return
+		}
+		committed, err := repo.Files(link.Head)
+		if err != nil {
+			gitError(w, err, "pull")
+			return
+		}
+		changes, err := gitChanges(work, committed)
+		if err != nil {
+			gitError(w, err, "pull")
+			return
+		}
+		if len(changes) > 0 {
+			http.Error(w, "The project has uncommitted changes", http.StatusConflict)
+			return
+		}
+	}
+
+	count, err := h.replaceWithCommit(r, projectID, repo, remoteHead)
+	if err != nil {
+		gitError(w, err, "pull")
+		return
+	}
+	if err := repo.SetHead(remoteHead); err != nil {
+		gitError(w, err, "pull")
+		return
+	}
+	previous := link.Head
+	link.Head = remoteHead
+	if err := h.store.Git.SaveLink(r.Context(), &link); err != nil {
+		gitError(w, err, "save git link")
+		return
+	}
+
+	h.auditLog(r, projectIDStr, "git.pull", "project", projectIDStr, map[string]interface{}{
+		"branch": link.Branch,
+		"from":   previous,
+		"to":     remoteHead,
+		"force":  req.Force,
+	})
+
+	resp := gitLinkJSON(link)
+	resp["updated"] = true
+	resp["nodes"] = count
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
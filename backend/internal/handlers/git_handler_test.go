@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"project-meetings/backend/internal/acl"
+	"project-meetings/backend/internal/gitrepo"
+	"project-meetings/backend/internal/models"
+	"project-meetings/backend/internal/permissions"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/google/uuid"
+)
+
+// gitRemote returns a bare repository in a temporary directory holding one
+// commit with files.
+func gitRemote(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "seed")
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for p, content := range files {
+		full := filepath.Join(dir, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := wt.Add(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sig := &object.Signature{Name: "seed", Email: "seed@example.com", When: time.Now()}
+	if _, err := wt.Commit("initial", &git.CommitOptions{Author: sig}); err != nil {
+		t.Fatal(err)
+	}
+	remote := filepath.Join(t.TempDir(), "remote.git")
+	if _, err := git.PlainInit(remote, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{remote}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Push(&git.PushOptions{RemoteName: "origin"}); err != nil {
+		t.Fatal(err)
+	}
+	return remote
+}
+
+func TestGitImportKeepsRules(t *testing.T) {
+	api := newTestAPI(t)
+	repos, err := gitrepo.New(t.TempDir(), true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.handler.git = repos
+	api.router.With(api.mw.RequirePermission(permissions.GitSync)).Post("/project/{projectId}/git/import", api.handler.ImportGitRepository)
+	ctx := context.Background()
+	projectID := api.project.ID.String()
+	err = api.store.Projects.CreateRole(ctx, projectID, models.ProjectRole{Name: "syncer", Permissions: []string{"project.read", "file.write", "git.sync"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	syncer := api.member(t, "syncer", "syncer")
+	old := "old"
+	docs := api.file(t, nil, "docs", nil)
+	kept := api.file(t, &docs.ID, "kept.txt", &old)
+	rules := []models.AccessRule{{FileID: kept.ID, SubjectType: acl.SubjectRole, Subject: "syncer", Access: "none"}}
+	if err := api.store.Files.ReplaceRules(ctx, projectID, kept.ID, rules); err != nil {
+		t.Fatal(err)
+	}
+	remote := gitRemote(t, map[string]string{"docs/kept.txt": "from git", "docs/new.txt": "new"})
+	path := "/project/" + projectID + "/git/import"
+
+	// Importing replaces every file, hidden ones included.
+	if rec := api.do(t, syncer, "POST", path, map[string]string{"url": remote}); rec.Code != http.StatusForbidden {
+		t.Fatalf("import over a hidden file: status %d, want %d", rec.Code, http.StatusForbidden)
+	}
+	if node, err := api.store.Files.Get(ctx, kept.ID); err != nil || *node.Content != "old" {
+		t.Fatalf("hidden file after refused import: %+v, %v", node, err)
+	}
+
+	if rec := api.do(t, api.owner, "POST", path, map[string]string{"url": remote}); rec.Code != http.StatusOK {
+		t.Fatalf("import: status %d (%s)", rec.Code, bytes.TrimSpace(rec.Body.Bytes()))
+	}
+	node, err := api.store.Files.Get(ctx, kept.ID)
+	if err != nil || *node.Content != "from git" {
+		t.Fatalf("kept file after import: %+v, %v", node, err)
+	}
+	if node.ParentID == nil || *node.ParentID != docs.ID {
+		t.Errorf("kept file moved to parent %v, want %s", node.ParentID, docs.ID)
+	}
+	got, err := api.store.Files.Rules(ctx, []uuid.UUID{kept.ID})
+	if err != nil || len(got) != 1 || got[0].Subject != "syncer" {
+		t.Errorf("rules after import: %+v, %v", got, err)
+	}
+}
@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"project-meetings/backend/internal/gitrepo"
+	"project-meetings/backend/internal/store"
+	"project-meetings/backend/internal/ws"
+)
+
+// Handler serves the HTTP API. Every route handler is a method on it so they
+// share one store and, where live clients must hear about a change, the hub.
+type Handler struct {
+	store *store.Store
+	hub   *ws.Hub
+	git   *gitrepo.Repos
+}
+
+func New(st *store.Store, hub *ws.Hub, repos *gitrepo.Repos) *Handler {
+	return &Handler{store: st, hub: hub, git: repos}
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"project-meetings/backend/internal/middleware"
+	"project-meetings/backend/internal/models"
+	"project-meetings/backend/internal/permissions"
+	"project-meetings/backend/internal/store"
+	"project-meetings/backend/internal/store/memstore"
+	"project-meetings/backend/internal/ws"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// testAPI serves routes backed by memstore. Requests name their user in the
+// X-Test-User header, which stands in for the JWT middleware.
+type testAPI struct {
+	store   *store.Store
+	handler *Handler
+	mw      *middleware.Middleware
+	router  chi.Router
+	project models.Project
+	owner   models.User
+}
+
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+	st := memstore.New()
+	hub := ws.NewHub(st, nil)
+	go hub.Run()
+	api := &testAPI{store: st, handler: New(st, hub, nil), mw: middleware.New(st), router: chi.NewRouter()}
+	api.router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), middleware.UserIDKey, r.Header.Get("X-Test-User"))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+	api.owner = api.user(t, "owner")
+	project, err := st.Projects.Create(context.Background(), "project", api.owner.ID.String(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.project = project
+	return api
+}
+
+func (api *testAPI) user(t *testing.T, name string) models.User {
+	t.Helper()
+	user, err := api.store.Users.Create(context.Background(), name, name+"@example.com", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// member adds a user to the project with role, through an invite.
+func (api *testAPI) member(t *testing.T, name, role string) models.User {
+	t.Helper()
+	user := api.user(t, name)
+	ctx := context.Background()
+	inv := models.ProjectInvite{ProjectID: api.project.ID, Code: "code-" + name, CreatedBy: api.owner.ID, Role: role}
+	if err := api.store.Invites.Create(ctx, &inv); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := api.store.Invites.Accept(ctx, inv.Code, user.ID.String(), func(models.ProjectInvite) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// do sends a request as user and returns the recorded response.
+func (api *testAPI) do(t *testing.T, user models.User, method, path string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("X-Test-User", user.ID.String())
+	rec := httptest.NewRecorder()
+	api.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRolesCannotExceedCaller(t *testing.T) {
+	api := newTestAPI(t)
+	api.router.Group(func(r chi.Router) {
+		r.Use(api.mw.RequirePermission(permissions.MembersManage))
+		r.Put("/project/{projectId}/members/{memberId}", api.handler.UpdateMemberRole)
+		r.Delete("/project/{projectId}/members/{memberId}", api.handler.RemoveProjectMember)
+		r.Post("/project/{projectId}/invites", api.handler.CreateProjectInvite)
+		r.Post("/project/{projectId}/roles", api.handler.CreateProjectRole)
+		r.Put("/project/{projectId}/roles/{roleName}", api.handler.UpdateProjectRole)
+	})
+	ctx := context.Background()
+	projectID := api.project.ID.String()
+	err := api.store.Projects.CreateRole(ctx, projectID, models.ProjectRole{Name: "manager", Permissions: []string{"project.read", "members.manage"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = api.store.Projects.CreateRole(ctx, projectID, models.ProjectRole{Name: "auditor", Permissions: []string{"project.read", "audit.read"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager := api.member(t, "manager", "manager")
+	auditor := api.member(t, "auditor", "auditor")
+	editor := api.member(t, "editor", "editor")
+	peer := api.member(t, "peer", "manager")
+	base := "/project/" + projectID
+
+	for _, c := range []struct {
+		name, method, path string
+		body               interface{}
+		want               int
+	}{
+		{"create wider role", "POST", base + "/roles", map[string]interface{}{"name": "admin", "permissions": []string{"members.manage", "project.manage"}}, http.StatusForbidden},
+		{"widen role", "PUT", base + "/roles/manager", map[string]interface{}{"permissions": []string{"members.manage", "audit.read"}}, http.StatusForbidden},
+		{"assign wider role to self", "PUT", base + "/members/" + manager.ID.String(), map[string]string{"role": "auditor"}, http.StatusForbidden},
+		{"assign editor to self", "PUT", base + "/members/" + manager.ID.String(), map[string]string{"role": "editor"}, http.StatusForbidden},
+		{"demote member with wider role", "PUT", base + "/members/" + auditor.ID.String(), map[string]string{"role": "manager"}, http.StatusForbidden},
+		{"remove member with wider role", "DELETE", base + "/members/" + editor.ID.String(), nil, http.StatusForbidden},
+		{"invite with wider role", "POST", base + "/invites", map[string]string{"role": "auditor"}, http.StatusForbidden},
+		{"create narrower role", "POST", base + "/roles", map[string]interface{}{"name": "reader", "permissions": []string{"project.read"}}, http.StatusCreated},
+		{"invite as viewer, who can join calls", "POST", base + "/invites", map[string]string{"role": "viewer"}, http.StatusForbidden},
+		{"invite with own permissions", "POST", base + "/invites", map[string]string{"role": "reader"}, http.StatusCreated},
+		{"narrow member with own permissions", "PUT", base + "/members/" + peer.ID.String(), map[string]string{"role": "reader"}, http.StatusOK},
+		{"remove member with own permissions", "DELETE", base + "/members/" + peer.ID.String(), nil, http.StatusNoContent},
+	} {
+		if rec := api.do(t, manager, c.method, c.path, c.body); rec.Code != c.want {
+			t.Errorf("%s: status %d (%s), want %d", c.name, rec.Code, bytes.TrimSpace(rec.Body.Bytes()), c.want)
+		}
+	}
+	if role, _ := api.store.Projects.ResolveRole(ctx, projectID, manager.ID.String()); role != "manager" {
+		t.Errorf("manager's role = %q", role)
+	}
+	for _, member := range []models.User{auditor, editor} {
+		if _, err := api.store.Projects.ResolveRole(ctx, projectID, member.ID.String()); err != nil {
+			t.Errorf("%s lost their membership: %v", member.Username, err)
+		}
+	}
+}
+
+func TestCreateInviteValidation(t *testing.T) {
+	api := newTestAPI(t)
+	api.router.With(api.mw.RequirePermission(permissions.MembersManage)).Post("/project/{projectId}/invites", api.handler.CreateProjectInvite)
+	path := "/project/" + api.project.ID.String() + "/invites"
+
+	for _, email := range []string{"ana", "Ana <ana@example.com>", "ana@example.com\r\nBcc: eve@example.com"} {
+		rec := api.do(t, api.owner, "POST", path, map[string]string{"role": "viewer", "email": email})
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("email %q: status %d, want %d", email, rec.Code, http.StatusBadRequest)
+		}
+	}
+
+	// An expiry far beyond the limit is capped rather than overflowing.
+	rec := api.do(t, api.owner, "POST", path, map[string]interface{}{"role": "viewer", "expiresInHours": int64(1) << 50})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status %d (%s)", rec.Code, bytes.TrimSpace(rec.Body.Bytes()))
+	}
+	var invite models.ProjectInvite
+	json.NewDecoder(rec.Body).Decode(&invite)
+	if invite.ExpiresAt == nil || invite.ExpiresAt.Before(time.Now()) || invite.ExpiresAt.After(time.Now().Add(maxInviteTTL)) {
+		t.Errorf("expiresAt = %v, want within %v from now", invite.ExpiresAt, maxInviteTTL)
+	}
+}
@@ -0,0 +1,432 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"project-meetings/backend/internal/middleware"
+	"project-meetings/backend/internal/permissions"
+	"project-meetings/backend/internal/store"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// orgRole returns the user's role in an organization, or store.ErrNotFound.
+func (h *Handler) orgRole(ctx context.Context, orgID, userID string) (string, error) {
+	return h.store.Organizations.MemberRole(ctx, orgID, userID)
+}
+
+// validDefaultProjectRole keeps org defaults to the built-in, non-owner roles,
+// since custom roles only exist inside a single project.
+func validDefaultProjectRole(role string) bool {
+	return role != permissions.OwnerRole && permissions.IsBuiltin(role)
+}
+
+// syncOrgAccess re-evaluates a user's access to every project of an
+// organization after their org membership changed.
+func (h *Handler) syncOrgAccess(ctx context.Context, orgID, userID string) {
+	projects, err := h.store.Projects.ListForOrganization(ctx, orgID)
+	if err != nil {
+		log.Printf("Failed to list organization projects: %v", err)
+		return
+	}
+	for _, p := range projects {
+		h.syncProjectAccess(ctx, p.ID.String(), userID)
+	}
+}
+
+// --- CREATE ORGANIZATION ---
+func (h *Handler) CreateOrganization(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(w, "Could not retrieve user ID from context", http.StatusInternalServerError)
+		return
+	}
+
+	var req struct {
+		Name               string `json:"name"`
+		DefaultProjectRole string `json:"defaultProjectRole"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		http.Error(w, "Organization name is required", http.StatusBadRequest)
+		return
+	}
+	if req.DefaultProjectRole == "" {
+		req.DefaultProjectRole = "editor"
+	}
+	if !validDefaultProjectRole(req.DefaultProjectRole) {
+		http.Error(w, "Invalid default project role. Must be 'editor' or 'viewer'.", http.StatusBadRequest)
+		return
+	}
+
+	org, err := h.store.Organizations.Create(r.Context(), req.Name, req.DefaultProjectRole, userID)
+	if err != nil {
+		log.Printf("Failed to insert organization: %v", err)
+		http.Error(w, "Failed to create organization", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(org)
+}
+
+// --- LIST MY ORGANIZATIONS ---
+func (h *Handler) GetUserOrganizations(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(w, "Could not retrieve user ID from context", http.StatusInternalServerError)
+		return
+	}
+
+	orgs, err := h.store.Organizations.ListForUser(r.Context(), userID)
+	if err != nil {
+		log.Printf("Failed to query organizations: %v", err)
+		http.Error(w, "Failed to retrieve organizations", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(orgs)
+}
+
+// --- GET ORGANIZATION ---
+func (h *Handler) GetOrganization(w http.ResponseWriter, r *http.Request) {
+	orgIDStr := chi.URLParam(r, "orgId")
+
+	org, err := h.store.Organizations.Get(r.Context(), orgIDStr)
+	if err != nil {
+		http.Error(w, "Organization not found", http.StatusNotFound)
+		return
+	}
+	org.Role, _ = r.Context().Value(middleware.OrgRoleKey).(string)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(org)
+}
+
+// --- UPDATE ORGANIZATION ---
+func (h *Handler) UpdateOrganization(w http.ResponseWriter, r *http.Request) {
+	orgIDStr := chi.URLParam(r, "orgId")
+
+	var req struct {
+		Name               *string `json:"name"`
+		DefaultProjectRole *string `json:"defaultProjectRole"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Name != nil && strings.TrimSpace(*req.Name) == "" {
+		http.Error(w, "Organization name cannot be empty", http.StatusBadRequest)
+		return
+	}
+	if req.DefaultProjectRole != nil && !validDefaultProjectRole(*req.DefaultProjectRole) {
+		http.Error(w, "Invalid default project role. Must be 'editor' or 'viewer'.", http.StatusBadRequest)
+		return
+	}
+
+	org, err := h.store.Organizations.Update(r.Context(), orgIDStr, req.Name, req.DefaultProjectRole)
+	if err != nil {
+		log.Printf("Failed to update organization: %v", err)
+		http.Error(w, "Failed to update organization", http.StatusInternalServerError)
+		return
+	}
+
+	// A new default changes the effective role of every plain org member.
+	if req.DefaultProjectRole != nil {
+		members, err := h.store.Organizations.Members(r.Context(), orgIDStr)
+		if err == nil {
+			for _, m := range members {
+				if m.Role == "member" {
+					h.syncOrgAccess(r.Context(), orgIDStr, m.UserID.String())
+				}
+			}
+		}
+	}
+
+	org.Role, _ = r.Context().Value(middleware.OrgRoleKey).(string)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(org)
+}
+
+// --- DELETE ORGANIZATION ---
+// Projects are not deleted; they simply stop belonging to an organization
+// (ON DELETE SET NULL) and keep their explicit members.
+func (h *Handler) DeleteOrganization(w http.ResponseWriter, r *http.Request) {
+	orgIDStr := chi.URLParam(r, "orgId")
+
+	// Remember who relied on the org for access before it disappears.
+	projects, err := h.store.Projects.ListForOrganization(r.Context(), orgIDStr)
+	if err != nil {
+		log.Printf("Failed to list organization access: %v", err)
+		http.Error(w, "Failed to delete organization", http.StatusInternalServerError)
+		return
+	}
+	members, err := h.store.Organizations.Members(r.Context(), orgIDStr)
+	if err != nil {
+		log.Printf("Failed to list organization access: %v", err)
+		http.Error(w, "Failed to delete organization", http.StatusInternalServerError)
+		return
+	}
+	type access struct{ projectID, userID string }
+	var affected []access
+	for _, p := range projects {
+		for _, m := range members {
+			affected = append(affected, access{p.ID.String(), m.UserID.String()})
+		}
+	}
+
+	if err := h.store.Organizations.Delete(r.Context(), orgIDStr); err != nil {
+		log.Printf("Failed to delete organization: %v", err)
+		http.Error(w, "Failed to delete organization", http.StatusInternalServerError)
+		return
+	}
+
+	for _, a := range affected {
+		h.syncProjectAccess(r.Context(), a.projectID, a.userID)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// --- LIST ORGANIZATION MEMBERS ---
+func (h *Handler) GetOrganizationMembers(w http.ResponseWriter, r *http.Request) {
+	orgIDStr := chi.URLParam(r, "orgId")
+
+	members, err := h.store.Organizations.Members(r.Context(), orgIDStr)
+	if err != nil {
+		log.Printf("Failed to get organization members: %v", err)
+		http.Error(w, "Failed to retrieve organization members", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(members)
+}
+
+// --- ADD ORGANIZATION MEMBERS ---
+// Adds existing users by email, in bulk. Users that are already members keep
+// their current role; unknown emails are reported back.
+func (h *Handler) AddOrganizationMembers(w http.ResponseWriter, r *http.Request) {
+	orgIDStr := chi.URLParam(r, "orgId")
+
+	var req struct {
+		Members []struct {
+			Email string `json:"email"`
+			Role  string `json:"role"`
+		} `json:"members"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Members) == 0 {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	for i, m := range req.Members {
+		if m.Role == "" {
+			req.Members[i].Role = "member"
+		} else if m.Role != "admin" && m.Role != "member" {
+			http.Error(w, "Invalid role. Must be 'admin' or 'member'.", http.StatusBadRequest)
+			return
+		}
+	}
+
+	added := make([]string, 0)
+	notFound := make([]string, 0)
+	for _, m := range req.Members {
+		user, err := h.store.Users.GetByEmail(r.Context(), strings.TrimSpace(m.Email))
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				notFound = append(notFound, m.Email)
+				continue
+			}
+			http.Error(w, "Failed to look up user", http.StatusInternalServerError)
+			return
+		}
+
+		joined, err := h.store.Organizations.AddMember(r.Context(), orgIDStr, user.ID.String(), m.Role)
+		if err != nil {
+			log.Printf("Failed to add organization member: %v", err)
+			http.Error(w, "Failed to add members", http.StatusInternalServerError)
+			return
+		}
+		if joined {
+			added = append(added, user.ID.String())
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]string{
+		"added":    added,
+		"notFound": notFound,
+	})
+}
+
+// --- UPDATE ORGANIZATION MEMBER ROLE ---
+func (h *Handler) UpdateOrganizationMemberRole(w http.ResponseWriter, r *http.Request) {
+	orgIDStr := chi.URLParam(r, "orgId")
+	memberIDStr := chi.URLParam(r, "memberId")
+
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Role != "admin" && req.Role != "member" {
+		http.Error(w, "Invalid role. Must be 'admin' or 'member'.", http.StatusBadRequest)
+		return
+	}
+
+	currentRole, err := h.orgRole(r.Context(), orgIDStr, memberIDStr)
+	if err != nil {
+		http.Error(w, "Member not found", http.StatusNotFound)
+		return
+	}
+	if currentRole == "owner" {
+		http.Error(w, "The organization owner's role cannot be changed.", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.store.Organizations.UpdateMemberRole(r.Context(), orgIDStr, memberIDStr, req.Role); err != nil {
+		log.Printf("Failed to update organization member role: %v", err)
+		http.Error(w, "Failed to update member role", http.StatusInternalServerError)
+		return
+	}
+
+	h.syncOrgAccess(r.Context(), orgIDStr, memberIDStr)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// --- REMOVE ORGANIZATION MEMBER ---
+// Admins can remove anyone but the owner, and every member can remove
+// themselves to leave the organization.
+func (h *Handler) RemoveOrganizationMember(w http.ResponseWriter, r *http.Request) {
+	orgIDStr := chi.URLParam(r, "orgId")
+	memberIDStr := chi.URLParam(r, "memberId")
+	userIDStr, _ := r.Context().Value(middleware.UserIDKey).(string)
+	callerRole, _ := r.Context().Value(middleware.OrgRoleKey).(string)
+
+	if memberIDStr != userIDStr && callerRole != "owner" && callerRole != "admin" {
+		http.Error(w, "Forbidden: only organization admins can remove other members", http.StatusForbidden)
+		return
+	}
+
+	targetRole, err := h.orgRole(r.Context(), orgIDStr, memberIDStr)
+	if err != nil {
+		http.Error(w, "Member not found", http.StatusNotFound)
+		return
+	}
+	if targetRole == "owner" {
+		http.Error(w, "The organization owner cannot be removed.", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.store.Organizations.RemoveMember(r.Context(), orgIDStr, memberIDStr); err != nil {
+		log.Printf("Failed to remove organization member: %v", err)
+		http.Error(w, "Failed to remove member", http.StatusInternalServerError)
+		return
+	}
+
+	h.syncOrgAccess(r.Context(), orgIDStr, memberIDStr)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// --- LIST ORGANIZATION PROJECTS ---
+func (h *Handler) GetOrganizationProjects(w http.ResponseWriter, r *http.Request) {
+	orgIDStr := chi.URLParam(r, "orgId")
+
+	projects, err := h.store.Projects.ListForOrganization(r.Context(), orgIDStr)
+	if err != nil {
+		log.Printf("Failed to query organization projects: %v", err)
+		http.Error(w, "Failed to retrieve projects", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(projects)
+}
+
+// --- MOVE PROJECT INTO / OUT OF AN ORGANIZATION ---
+// Moving a project into an organization requires admin rights there.
+func (h *Handler) SetProjectOrganization(w http.ResponseWriter, r *http.Request) {
+	projectIDStr := chi.URLParam(r, "projectId")
+	userIDStr, _ := r.Context().Value(middleware.UserIDKey).(string)
+
+	var req struct {
+		OrganizationID *uuid.UUID `json:"organizationId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.OrganizationID != nil {
+		role, err := h.orgRole(r.Context(), req.OrganizationID.String(), userIDStr)
+		if err != nil || (role != "owner" && role != "admin") {
+			http.Error(w, "You must be an admin of the target organization", http.StatusForbidden)
+			return
+		}
+	}
+
+	// Collect org members of the old and new organization whose access may change.
+	project, err := h.store.Projects.Get(r.Context(), projectIDStr)
+	if err != nil {
+		log.Printf("Failed to load project: %v", err)
+		http.Error(w, "Failed to move project", http.StatusInternalServerError)
+		return
+	}
+	var orgIDs []string
+	if project.OrganizationID != nil {
+		orgIDs = append(orgIDs, project.OrganizationID.String())
+	}
+	if req.OrganizationID != nil {
+		orgIDs = append(orgIDs, req.OrganizationID.String())
+	}
+	seen := make(map[string]bool)
+	var affected []string
+	for _, orgID := range orgIDs {
+		members, err := h.store.Organizations.Members(r.Context(), orgID)
+		if err != nil {
+			log.Printf("Failed to list affected organization members: %v", err)
+			http.Error(w, "Failed to move project", http.StatusInternalServerError)
+			return
+		}
+		for _, m := range members {
+			if id := m.UserID.String(); !seen[id] {
+				seen[id] = true
+				affected = append(affected, id)
+			}
+		}
+	}
+
+	if err := h.store.Projects.SetOrganization(r.Context(), projectIDStr, req.OrganizationID); err != nil {
+		log.Printf("Failed to move project: %v", err)
+		http.Error(w, "Failed to move project", http.StatusInternalServerError)
+		return
+	}
+
+	for _, userID := range affected {
+		h.syncProjectAccess(r.Context(), projectIDStr, userID)
+	}
+
+	targetOrg := ""
+	if req.OrganizationID != nil {
+		targetOrg = req.OrganizationID.String()
+	}
+	h.auditLog(r, projectIDStr, "project.move_organization", "organization", targetOrg, nil)
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -4,35 +4,32 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
-	"project-meetings/backend/internal/database"
 	"project-meetings/backend/internal/middleware"
+	"project-meetings/backend/internal/permissions"
+	"project-meetings/backend/internal/store"
 	"project-meetings/backend/internal/ws"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 )
 
-func GetUserRoleForProject(w http.ResponseWriter, r *http.Request) {
-	userIDStr, _ := r.Context().Value(middleware.UserIDKey).(string)
-	projectIDStr := chi.URLParam(r, "projectId")
-
-	var role string
-	query := `SELECT role FROM project_members WHERE user_id = $1 AND project_id = $2`
-	err := database.DB.QueryRow(context.Background(), query, userIDStr, projectIDStr).Scan(&role)
-	if err != nil {
-		// This shouldn't happen if RBAC middleware is working, but it's good practice
-		http.Error(w, "Could not find role for user in project", http.StatusNotFound)
-		return
-	}
+func (h *Handler) GetUserRoleForProject(w http.ResponseWriter, r *http.Request) {
+	// The permission middleware has already resolved both for us.
+	role, _ := r.Context().Value(middleware.ProjectRoleKey).(string)
+	perms, _ := r.Context().Value(middleware.PermissionsKey).(permissions.Set)
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"role": role})
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"role":        role,
+		"permissions": perms.List(),
+	})
 }
 
 // --- RENAME PROJECT ---
-func RenameProject(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) RenameProject(w http.ResponseWriter, r *http.Request) {
 	projectIDStr := chi.URLParam(r, "projectId")
 
 	var req struct {
@@ -47,78 +44,53 @@ func RenameProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	query := `UPDATE projects SET name = $1, updated_at = NOW() WHERE id = $2`
-	_, err := database.DB.Exec(context.Background(), query, req.Name, projectIDStr)
+	err := h.store.Projects.Rename(r.Context(), projectIDStr, req.Name)
 	if err != nil {
 		log.Printf("Failed to rename project: %v", err)
 		http.Error(w, "Failed to rename project", http.StatusInternalServerError)
 		return
 	}
 
+	h.auditLog(r, projectIDStr, "project.rename", "project", projectIDStr, map[string]interface{}{"name": req.Name})
+
 	w.WriteHeader(http.StatusOK)
 }
 
 // --- DELETE PROJECT ---
-func DeleteProject(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) DeleteProject(w http.ResponseWriter, r *http.Request) {
 	projectIDStr := chi.URLParam(r, "projectId")
 
-	// The `ON DELETE CASCADE` in our database schema will handle cleaning up
-	// project_members, files, whiteboard_shapes, and invites automatically.
-	query := `DELETE FROM projects WHERE id = $1`
-	_, err := database.DB.Exec(context.Background(), query, projectIDStr)
+	// The store cleans up members, files, whiteboard shapes and invites along
+	// with the project.
+	err := h.store.Projects.Delete(r.Context(), projectIDStr)
 	if err != nil {
 		log.Printf("Failed to delete project: %v", err)
 		http.Error(w, "Failed to delete project", http.StatusInternalServerError)
 		return
 	}
 
+	h.auditLog(r, projectIDStr, "project.delete", "project", projectIDStr, nil)
+
 	w.WriteHeader(http.StatusNoContent) // 204 is standard for successful deletion
 }
 
 // --- GET PROJECT MEMBERS ---
-// We need a struct to hold the combined user/member info
-type ProjectMemberInfo struct {
-	UserID   uuid.UUID `json:"userId"`
-	Username string    `json:"username"`
-	Email    string    `json:"email"`
-	Role     string    `json:"role"`
-}
-
-func GetProjectMembers(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) GetProjectMembers(w http.ResponseWriter, r *http.Request) {
 	projectIDStr := chi.URLParam(r, "projectId")
 
-	query := `
-		SELECT u.id, u.username, u.email, pm.role
-		FROM project_members pm
-		JOIN users u ON pm.user_id = u.id
-		WHERE pm.project_id = $1
-		ORDER BY u.username
-	`
-	rows, err := database.DB.Query(context.Background(), query, projectIDStr)
+	members, err := h.store.Projects.Members(r.Context(), projectIDStr)
 	if err != nil {
 		log.Printf("Failed to get project members: %v", err)
 		http.Error(w, "Failed to retrieve project members", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-
-	var members []ProjectMemberInfo
-	for rows.Next() {
-		var member ProjectMemberInfo
-		if err := rows.Scan(&member.UserID, &member.Username, &member.Email, &member.Role); err != nil {
-			log.Printf("Failed to scan project member: %v", err)
-			http.Error(w, "Error processing member list", http.StatusInternalServerError)
-			return
-		}
-		members = append(members, member)
-	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(members)
 }
 
 // --- UPDATE MEMBER ROLE ---
-func UpdateMemberRole(hub *ws.Hub, w http.ResponseWriter, r *http.Request) {
+func (h *Handler) UpdateMemberRole(w http.ResponseWriter, r *http.Request) {
 	projectIDStr := chi.URLParam(r, "projectId")
 	memberIDStr := chi.URLParam(r, "memberId")
 
@@ -131,64 +103,184 @@ func UpdateMemberRole(hub *ws.Hub, w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate the role to prevent arbitrary strings
-	if req.Role != "editor" && req.Role != "viewer" {
-		http.Error(w, "Invalid role. Must be 'editor' or 'viewer'.", http.StatusBadRequest)
+	perms, err := h.assignableRole(r, projectIDStr, req.Role)
+	if err != nil {
+		writeRoleError(w, err)
 		return
 	}
 
-	// Prevent the owner from changing their own role
-	ownerIDStr, _ := r.Context().Value(middleware.UserIDKey).(string)
-	if ownerIDStr == memberIDStr {
+	// The owner's role can only change through an ownership transfer
+	if h.isProjectOwner(r.Context(), projectIDStr, memberIDStr) {
 		http.Error(w, "Project owner's role cannot be changed.", http.StatusBadRequest)
 		return
 	}
+	if err := h.manageableMember(r, projectIDStr, memberIDStr); err != nil {
+		writeRoleError(w, err)
+		return
+	}
 
-	query := `UPDATE project_members SET role = $1 WHERE project_id = $2 AND user_id = $3`
-	_, err := database.DB.Exec(context.Background(), query, req.Role, projectIDStr, memberIDStr)
+	err = h.store.Projects.UpdateMemberRole(r.Context(), projectIDStr, memberIDStr, req.Role)
 	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Member not found", http.StatusNotFound)
+			return
+		}
 		log.Printf("Failed to update member role: %v", err)
 		http.Error(w, "Failed to update member role", http.StatusInternalServerError)
 		return
 	}
 
-	if targetClient, ok := hub.UserMap[memberIDStr]; ok {
-		log.Printf("[API] Notifying user %s of role change to %s", targetClient.Username, req.Role)
-		payload, _ := json.Marshal(map[string]string{"newRole": req.Role})
-		msg, _ := json.Marshal(ws.WsMessage{Type: "permission_updated", Payload: payload})
-		targetClient.Send <- msg
-	}
+	h.hub.UpdatePermissions(&ws.PermissionUpdate{UserID: memberIDStr, ProjectID: projectIDStr, Role: req.Role, Permissions: perms})
+
+	h.auditLog(r, projectIDStr, "member.role_update", "user", memberIDStr, map[string]interface{}{"role": req.Role})
 
 	w.WriteHeader(http.StatusOK)
 }
 
 // --- REMOVE PROJECT MEMBER ---
-func RemoveProjectMember(hub *ws.Hub, w http.ResponseWriter, r *http.Request) {
+func (h *Handler) RemoveProjectMember(w http.ResponseWriter, r *http.Request) {
 	projectIDStr := chi.URLParam(r, "projectId")
 	memberIDStr := chi.URLParam(r, "memberId")
 
-	// Prevent the owner from removing themselves
-	ownerIDStr, _ := r.Context().Value(middleware.UserIDKey).(string)
-	if ownerIDStr == memberIDStr {
+	// The owner can never be removed, whoever is asking
+	if h.isProjectOwner(r.Context(), projectIDStr, memberIDStr) {
 		http.Error(w, "Project owner cannot be removed from the project.", http.StatusBadRequest)
 		return
 	}
+	if err := h.manageableMember(r, projectIDStr, memberIDStr); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeRoleError(w, err)
+		return
+	}
 
-	query := `DELETE FROM project_members WHERE project_id = $1 AND user_id = $2`
-	_, err := database.DB.Exec(context.Background(), query, projectIDStr, memberIDStr)
-	if err != nil {
+	err := h.store.Projects.RemoveMember(r.Context(), projectIDStr, memberIDStr)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
 		log.Printf("Failed to remove project member: %v", err)
 		http.Error(w, "Failed to remove member", http.StatusInternalServerError)
 		return
 	}
-	if targetClient, ok := hub.UserMap[memberIDStr]; ok {
-		log.Printf("[API] Notifying user %s they have been removed from the project", targetClient.Username)
-		payload, _ := json.Marshal(map[string]string{"reason": "You have been removed from this project by the owner."})
-		msg, _ := json.Marshal(ws.WsMessage{Type: "force_disconnect", Payload: payload})
+	log.Printf("[API] Notifying user %s they have been removed from the project", memberIDStr)
+	h.hub.KickUser(memberIDStr, projectIDStr, "You have been removed from this project by the owner.")
+
+	h.auditLog(r, projectIDStr, "member.remove", "user", memberIDStr, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// isProjectOwner reports whether userID is the owner recorded on the project.
+func (h *Handler) isProjectOwner(ctx context.Context, projectID, userID string) bool {
+	project, err := h.store.Projects.Get(ctx, projectID)
+	return err == nil && project.OwnerID.String() == userID
+}
+
+// syncProjectAccess re-resolves a user's role in a project after something
+// it depends on changed, e.g. their organization membership. A connected
+// client is sent its new permissions, or disconnected if access is gone.
+func (h *Handler) syncProjectAccess(ctx context.Context, projectID, userID string) {
+	role, perms, err := permissions.Resolve(ctx, h.store.Projects, projectID, userID)
+	if err == nil {
+		h.hub.UpdatePermissions(&ws.PermissionUpdate{UserID: userID, ProjectID: projectID, Role: role, Permissions: perms})
+		return
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		log.Printf("Failed to re-resolve access for user %s in project %s: %v", userID, projectID, err)
+		return
+	}
+	log.Printf("[API] User %s lost access to project %s, disconnecting", userID, projectID)
+	h.hub.KickUser(userID, projectID, "You no longer have access to this project.")
+}
+
+// broadcastToProject pushes a server-originated message to every client in a
+// project room. It goes through the hub with no sender, so nobody is skipped.
+func (h *Handler) broadcastToProject(projectID, msgType string, payload interface{}) {
+	payloadBytes, _ := json.Marshal(payload)
+	msg, _ := json.Marshal(ws.WsMessage{Type: msgType, Payload: payloadBytes})
+	h.hub.Broadcast(&ws.Message{ProjectID: projectID, Data: msg})
+}
+
+// --- TRANSFER OWNERSHIP ---
+func (h *Handler) TransferOwnership(w http.ResponseWriter, r *http.Request) {
+	projectIDStr := chi.URLParam(r, "projectId")
+	ownerIDStr, _ := r.Context().Value(middleware.UserIDKey).(string)
+
+	var req struct {
+		NewOwnerID string `json:"newOwnerId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if _, err := uuid.Parse(req.NewOwnerID); err != nil {
+		http.Error(w, "Invalid new owner ID", http.StatusBadRequest)
+		return
+	}
+	if req.NewOwnerID == ownerIDStr {
+		http.Error(w, "You already own this project.", http.StatusBadRequest)
+		return
+	}
+
+	err := h.store.Projects.TransferOwnership(r.Context(), projectIDStr, ownerIDStr, req.NewOwnerID)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	case errors.Is(err, store.ErrNotOwner):
+		http.Error(w, "Only the current owner can transfer ownership", http.StatusForbidden)
+		return
+	case errors.Is(err, store.ErrNotMember):
+		http.Error(w, "The new owner must already be a member of the project", http.StatusBadRequest)
+		return
+	case err != nil:
+		log.Printf("Failed to transfer ownership: %v", err)
+		http.Error(w, "Failed to transfer ownership", http.StatusInternalServerError)
+		return
+	}
+
+	for userID, role := range map[string]string{req.NewOwnerID: permissions.OwnerRole, ownerIDStr: "editor"} {
+		perms := permissions.NewSet(permissions.BuiltinRoles[role]...)
+		h.hub.UpdatePermissions(&ws.PermissionUpdate{UserID: userID, ProjectID: projectIDStr, Role: role, Permissions: perms})
+	}
+	h.broadcastToProject(projectIDStr, "ownership_transferred", map[string]string{
+		"previousOwnerId": ownerIDStr,
+		"newOwnerId":      req.NewOwnerID,
+	})
+
+	h.auditLog(r, projectIDStr, "project.transfer_ownership", "user", req.NewOwnerID, map[string]interface{}{
+		"previousOwnerId": ownerIDStr,
+	})
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// --- LEAVE PROJECT ---
+func (h *Handler) LeaveProject(w http.ResponseWriter, r *http.Request) {
+	projectIDStr := chi.URLParam(r, "projectId")
+	userIDStr, _ := r.Context().Value(middleware.UserIDKey).(string)
+
+	project, err := h.store.Projects.Get(r.Context(), projectIDStr)
+	if err != nil {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	}
+	if project.OwnerID.String() == userIDStr {
+		http.Error(w, "The owner cannot leave the project. Transfer ownership first.", http.StatusBadRequest)
+		return
+	}
 
-		// Send the message and then immediately unregister them from the hub
-		targetClient.Send <- msg
-		hub.Unregister <- targetClient
+	err = h.store.Projects.RemoveMember(r.Context(), projectIDStr, userIDStr)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		log.Printf("Failed to leave project: %v", err)
+		http.Error(w, "Failed to leave project", http.StatusInternalServerError)
+		return
 	}
 
+	h.hub.KickUser(userIDStr, projectIDStr, "You have left this project.")
+	h.broadcastToProject(projectIDStr, "member_left", map[string]string{"userId": userIDStr})
+
+	h.auditLog(r, projectIDStr, "member.leave", "user", userIDStr, nil)
+
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -1,16 +1,18 @@
 package handlers
 
 import (
-	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"io"
 	"log"
 	"net/http"
-	"project-meetings/backend/internal/database"
+	"project-meetings/backend/internal/mail"
 	"project-meetings/backend/internal/middleware"
 	"project-meetings/backend/internal/models"
-	"project-meetings/backend/internal/ws"
+	"project-meetings/backend/internal/store"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -18,7 +20,7 @@ import (
 )
 
 // CreateProject handles the creation of a new project.
-func CreateProject(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) CreateProject(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
 	if !ok {
 		http.Error(w, "Could not retrieve user ID from context", http.StatusInternalServerError)
@@ -26,7 +28,9 @@ func CreateProject(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		Name string `json:"name"`
+		Name           string     `json:"name"`
+		OrganizationID *uuid.UUID `json:"organizationId"`
+		TemplateID     string     `json:"templateId"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
@@ -37,131 +41,73 @@ func CreateProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var newProject models.Project
-
-	tx, err := database.DB.Begin(context.Background())
-	if err != nil {
-		http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
-		return
+	// Any member of an organization may start a project inside it.
+	if req.OrganizationID != nil {
+		if _, err := h.orgRole(r.Context(), req.OrganizationID.String(), userID); err != nil {
+			http.Error(w, "You are not a member of that organization", http.StatusForbidden)
+			return
+		}
 	}
-	defer tx.Rollback(context.Background())
 
-	projectQuery := `INSERT INTO projects (name, owner_id) VALUES ($1, $2) RETURNING id, owner_id, name, created_at, updated_at`
-	err = tx.QueryRow(context.Background(), projectQuery, req.Name, userID).Scan(&newProject.ID, &newProject.OwnerID, &newProject.Name, &newProject.CreatedAt, &newProject.UpdatedAt)
+	seed, keys, err := h.templateSeed(r.Context(), userID, req.TemplateID)
 	if err != nil {
-		log.Printf("Failed to insert project: %v", err)
-		http.Error(w, "Failed to create project", http.StatusInternalServerError)
+		h.deleteBlobs(r.Context(), keys)
+		if errors.Is(err, errUnknownTemplate) {
+			http.Error(w, "Template not found", http.StatusBadRequest)
+			return
+		}
+		copyError(w, err, "create project")
 		return
 	}
 
-	memberQuery := `INSERT INTO project_members (project_id, user_id, role) VALUES ($1, $2, 'owner')`
-	_, err = tx.Exec(context.Background(), memberQuery, newProject.ID, userID)
+	newProject, err := h.store.Projects.CreateFrom(r.Context(), req.Name, userID, req.OrganizationID, seed)
 	if err != nil {
-		log.Printf("Failed to add owner to project members: %v", err)
+		h.deleteBlobs(r.Context(), keys)
+		log.Printf("Failed to insert project: %v", err)
 		http.Error(w, "Failed to create project", http.StatusInternalServerError)
 		return
 	}
 
-	if err := tx.Commit(context.Background()); err != nil {
-		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
-		return
+	details := map[string]interface{}{"name": newProject.Name}
+	if req.TemplateID != "" {
+		details["template"] = req.TemplateID
 	}
+	h.auditLog(r, newProject.ID.String(), "project.create", "project", newProject.ID.String(), details)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(newProject)
 }
 
-// GetUserProjects handles listing all projects a user is a member of.
-func GetUserProjects(w http.ResponseWriter, r *http.Request) {
+// GetUserProjects handles listing all projects a user is a member of,
+// directly or through one of their organizations.
+func (h *Handler) GetUserProjects(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
 	if !ok {
 		http.Error(w, "Could not retrieve user ID from context", http.StatusInternalServerError)
 		return
 	}
-	query := `
-		SELECT p.id, p.owner_id, p.name, p.created_at, p.updated_at
-		FROM projects p
-		JOIN project_members pm ON p.id = pm.project_id
-		WHERE pm.user_id = $1
-		ORDER BY p.created_at DESC`
-
-	rows, err := database.DB.Query(context.Background(), query, userID)
+	projects, err := h.store.Projects.ListForUser(r.Context(), userID)
 	if err != nil {
 		log.Printf("Failed to query projects: %v", err)
 		http.Error(w, "Failed to retrieve projects", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-	projects := make([]models.Project, 0)
-	for rows.Next() {
-		var p models.Project
-		if err := rows.Scan(&p.ID, &p.OwnerID, &p.Name, &p.CreatedAt, &p.UpdatedAt); err != nil {
-			http.Error(w, "Failed to scan project row", http.StatusInternalServerError)
-			return
-		}
-		projects = append(projects, p)
-	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(projects)
 }
 
 
-// GetWhiteboardState retrieves the current in-memory state of the whiteboard for a project.
-func GetWhiteboardState(hub *ws.Hub, w http.ResponseWriter, r *http.Request) {
+// GetWhiteboardState returns the whiteboard as the hub holds it, which is
+// loaded from the database the first time anyone asks for the project.
+func (h *Handler) GetWhiteboardState(w http.ResponseWriter, r *http.Request) {
 	projectId := chi.URLParam(r, "projectId")
 
-	// 1. Always ensure the top-level project state exists in the hub map.
-	// This makes it safe to access state.WhiteboardShapes later.
-	// This is thread-safe enough for our purposes without a mutex because
-	// even if two requests create it, they'll just overwrite with the same empty struct.
-	if _, ok := hub.ProjectStates[projectId]; !ok {
-		log.Printf("[API] Initializing in-memory state for project %s via GetWhiteboardState.", projectId)
-		hub.ProjectStates[projectId] = &ws.ProjectState{
-			EditorContents:   make(map[string]string),
-			WhiteboardShapes: make(map[string]string),
-		}
-	}
-
-	state := hub.ProjectStates[projectId]
-
-	// 2. Check if the in-memory shape cache has been populated yet.
-	// If its length is 0, it means this is the first time anyone has asked for
-	// the whiteboard since the server started. This is our trigger to load from the DB.
-	if len(state.WhiteboardShapes) == 0 {
-		log.Printf("[API] In-memory whiteboard for %s is empty. Loading from DB.", projectId)
-		
-		query := `SELECT id, shape_data FROM whiteboard_shapes WHERE project_id = $1`
-		rows, err := database.DB.Query(context.Background(), query, projectId)
-		if err != nil {
-			log.Printf("[API] Failed to load whiteboard state from DB: %v", err)
-			http.Error(w, "Failed to load whiteboard state", http.StatusInternalServerError)
-			return
-		}
-		defer rows.Close()
-
-		shapeCount := 0
-		for rows.Next() {
-			var id string
-			var shapeData []byte
-			if err := rows.Scan(&id, &shapeData); err != nil {
-				http.Error(w, "Failed to scan shape data", http.StatusInternalServerError)
-				return
-			}
-			// Populate the in-memory map (the cache) with data from the database.
-			state.WhiteboardShapes[id] = string(shapeData)
-			shapeCount++
-		}
-		log.Printf("[API] Loaded %d shapes from DB into memory for project %s.", shapeCount, projectId)
-	} else {
-		log.Printf("[API] Serving whiteboard for %s from in-memory cache.", projectId)
-	}
-
-	// 3. Now, serve the shapes from the (potentially newly populated) in-memory map.
-	shapeMap := state.WhiteboardShapes
-	shapes := make([]json.RawMessage, 0, len(shapeMap))
-	for _, shapeJSON := range shapeMap {
-		shapes = append(shapes, json.RawMessage(shapeJSON))
+	shapes, err := h.hub.GetWhiteboardSnapshot(r.Context(), projectId)
+	if err != nil {
+		log.Printf("[API] Failed to load whiteboard state: %v", err)
+		http.Error(w, "Failed to load whiteboard state", http.StatusInternalServerError)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -177,8 +123,29 @@ func generateInviteCode(length int) (string, error) {
 }
 
 
-func CreateProjectInvite(w http.ResponseWriter, r *http.Request) {
-    // --- Authentication and Authorization ---
+// Reasons an invite can't be accepted, reported from inside the store's
+// accept so they are checked against the locked invite.
+var (
+	errInviteExpired    = errors.New("invite expired")
+	errInviteUsedUp     = errors.New("invite used up")
+	errInviteWrongEmail = errors.New("invite issued to another email")
+)
+
+// Defaults applied when the owner doesn't say otherwise.
+const (
+	defaultInviteMaxUses = 1
+	defaultInviteTTL     = 24 * time.Hour
+	// maxInviteTTL caps expiresInHours, which also keeps the Duration from
+	// overflowing.
+	maxInviteTTL = 30 * 24 * time.Hour
+)
+
+// CreateProjectInvite creates an invite code for the project.
+// Optional settings: role (any assignable role, default editor), maxUses
+// (default 1, 0 for unlimited), expiresInHours (default 24, 0 for never) and
+// email, which restricts the invite to the user registered with that address.
+func (h *Handler) CreateProjectInvite(w http.ResponseWriter, r *http.Request) {
+	// --- Authentication and Authorization ---
 	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
 	if !ok {
 		http.Error(w, "Could not retrieve user ID from context", http.StatusInternalServerError)
@@ -192,105 +159,207 @@ func CreateProjectInvite(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-    // --- Check if the user is the owner (this is our first use of RBAC!) ---
-    var ownerID uuid.UUID
-    query := `SELECT owner_id FROM projects WHERE id = $1`
-    err = database.DB.QueryRow(context.Background(), query, projectID).Scan(&ownerID)
-    if err != nil {
-        http.Error(w, "Project not found", http.StatusNotFound)
-        return
-    }
-
-    if ownerID.String() != userID {
-        http.Error(w, "Only the project owner can create invites", http.StatusForbidden)
-        return
-    }
-
-    // --- Generate Invite Code ---
-    inviteCode, err := generateInviteCode(8) // Creates a 16-character hex string
-    if err != nil {
-        http.Error(w, "Failed to generate invite code", http.StatusInternalServerError)
-        return
-    }
-    
-    // Invites expire in 24 hours
-    expiresAt := time.Now().Add(24 * time.Hour)
-
-    // --- Save to Database ---
-    insertQuery := `INSERT INTO project_invites (project_id, code, created_by, expires_at) VALUES ($1, $2, $3, $4) RETURNING code, expires_at`
-    var createdCode string
-    var createdExpiresAt time.Time
-
-    err = database.DB.QueryRow(context.Background(), insertQuery, projectID, inviteCode, userID, expiresAt).Scan(&createdCode, &createdExpiresAt)
-    if err != nil {
-        log.Printf("Failed to create invite: %v", err)
-        http.Error(w, "Failed to create invite", http.StatusInternalServerError)
-        return
-    }
-
-    w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(map[string]interface{}{
-        "inviteCode": createdCode,
-        "expiresAt": createdExpiresAt,
-    })
+	var req struct {
+		Role           string  `json:"role"`
+		MaxUses        *int    `json:"maxUses"`
+		ExpiresInHours *int    `json:"expiresInHours"`
+		Email          *string `json:"email"`
+	}
+	// An empty body is allowed and yields the defaults.
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Role == "" {
+		req.Role = "editor"
+	}
+	if _, err := h.assignableRole(r, projectIDStr, req.Role); err != nil {
+		writeRoleError(w, err)
+		return
+	}
+
+	var maxUses *int
+	switch {
+	case req.MaxUses == nil:
+		n := defaultInviteMaxUses
+		maxUses = &n
+	case *req.MaxUses < 0:
+		http.Error(w, "maxUses cannot be negative", http.StatusBadRequest)
+		return
+	case *req.MaxUses > 0:
+		maxUses = req.MaxUses
+	}
+
+	var expiresAt *time.Time
+	switch {
+	case req.ExpiresInHours == nil:
+		t := time.Now().Add(defaultInviteTTL)
+		expiresAt = &t
+	case *req.ExpiresInHours < 0:
+		http.Error(w, "expiresInHours cannot be negative", http.StatusBadRequest)
+		return
+	case *req.ExpiresInHours > 0:
+		ttl := maxInviteTTL
+		if *req.ExpiresInHours < int(maxInviteTTL/time.Hour) {
+			ttl = time.Duration(*req.ExpiresInHours) * time.Hour
+		}
+		t := time.Now().Add(ttl)
+		expiresAt = &t
+	}
+
+	var email *string
+	if req.Email != nil && strings.TrimSpace(*req.Email) != "" {
+		e := strings.TrimSpace(*req.Email)
+		if !mail.ValidAddress(e) {
+			http.Error(w, "Invalid email address", http.StatusBadRequest)
+			return
+		}
+		email = &e
+	}
+
+	// --- Generate Invite Code ---
+	inviteCode, err := generateInviteCode(8) // Creates a 16-character hex string
+	if err != nil {
+		http.Error(w, "Failed to generate invite code", http.StatusInternalServerError)
+		return
+	}
+
+	// --- Save to Database ---
+	invite := models.ProjectInvite{
+		ProjectID: projectID,
+		Code:      inviteCode,
+		CreatedBy: uuid.MustParse(userID),
+		Role:      req.Role,
+		MaxUses:   maxUses,
+		Email:     email,
+		ExpiresAt: expiresAt,
+	}
+	err = h.store.Invites.Create(r.Context(), &invite)
+	if err != nil {
+		log.Printf("Failed to create invite: %v", err)
+		http.Error(w, "Failed to create invite", http.StatusInternalServerError)
+		return
+	}
+
+	h.auditLog(r, projectIDStr, "invite.create", "invite", invite.ID.String(), map[string]interface{}{
+		"role":      invite.Role,
+		"maxUses":   invite.MaxUses,
+		"email":     invite.Email,
+		"expiresAt": invite.ExpiresAt,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(invite)
 }
 
-func AcceptProjectInvite(w http.ResponseWriter, r *http.Request) {
-    userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+// ListProjectInvites returns the invites of a project that can still be used.
+func (h *Handler) ListProjectInvites(w http.ResponseWriter, r *http.Request) {
+	projectIDStr := chi.URLParam(r, "projectId")
+
+	invites, err := h.store.Invites.ListActive(r.Context(), projectIDStr)
+	if err != nil {
+		log.Printf("Failed to list invites: %v", err)
+		http.Error(w, "Failed to retrieve invites", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(invites)
+}
+
+// RevokeProjectInvite disables an invite. The row is kept so that its
+// usage history is not lost.
+func (h *Handler) RevokeProjectInvite(w http.ResponseWriter, r *http.Request) {
+	projectIDStr := chi.URLParam(r, "projectId")
+	inviteIDStr := chi.URLParam(r, "inviteId")
+	if _, err := uuid.Parse(inviteIDStr); err != nil {
+		http.Error(w, "Invalid invite ID", http.StatusBadRequest)
+		return
+	}
+
+	err := h.store.Invites.Revoke(r.Context(), projectIDStr, inviteIDStr)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Invite not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Failed to revoke invite: %v", err)
+		http.Error(w, "Failed to revoke invite", http.StatusInternalServerError)
+		return
+	}
+
+	h.auditLog(r, projectIDStr, "invite.revoke", "invite", inviteIDStr, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) AcceptProjectInvite(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
 	if !ok {
 		http.Error(w, "Could not retrieve user ID from context", http.StatusInternalServerError)
 		return
 	}
 
-    var req struct {
-        InviteCode string `json:"inviteCode"`
-    }
+	var req struct {
+		InviteCode string `json:"inviteCode"`
+	}
 
-    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-    // --- Find the invite and its associated project ---
-    var projectID uuid.UUID
-    var expiresAt time.Time
-    
-    findQuery := `SELECT project_id, expires_at FROM project_invites WHERE code = $1 AND is_used = FALSE`
-    err := database.DB.QueryRow(context.Background(), findQuery, req.InviteCode).Scan(&projectID, &expiresAt)
-    if err != nil {
-        http.Error(w, "Invite code is invalid or has already been used", http.StatusNotFound)
-        return
-    }
-
-    if time.Now().After(expiresAt) {
-        http.Error(w, "Invite code has expired", http.StatusBadRequest)
-        return
-    }
-
-    // --- Add user to the project as a member ---
-    // The role 'editor' is the default from our schema.
-    // ON CONFLICT DO NOTHING prevents an error if the user is already a member.
-    addMemberQuery := `
-        INSERT INTO project_members (project_id, user_id, role) VALUES ($1, $2, 'editor')
-        ON CONFLICT (project_id, user_id) DO NOTHING
-    `
-    _, err = database.DB.Exec(context.Background(), addMemberQuery, projectID, userID)
-    if err != nil {
-        http.Error(w, "Failed to add user to project", http.StatusInternalServerError)
-        return
-    }
-
-    // --- Mark the invite as used (or delete it for single-use) ---
-    // Deleting is simpler and cleaner for true single-use invites.
-    deleteQuery := `DELETE FROM project_invites WHERE code = $1`
-    _, err = database.DB.Exec(context.Background(), deleteQuery, req.InviteCode)
-    if err != nil {
-        log.Printf("Warning: failed to delete used invite code %s: %v", req.InviteCode, err)
-    }
-
-    w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(map[string]interface{}{
-        "message": "Successfully joined project!",
-        "projectId": projectID,
-    })
-}
\ No newline at end of file
+	user, err := h.loadUser(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	// --- Check the invite and add the user to the project ---
+	// The store locks the invite while check runs, so concurrent accepts of
+	// the same code can't be raced past the use limit. An existing member
+	// doesn't consume the invite.
+	invite, joined, err := h.store.Invites.Accept(r.Context(), req.InviteCode, userID, func(invite models.ProjectInvite) error {
+		if invite.ExpiresAt != nil && time.Now().After(*invite.ExpiresAt) {
+			return errInviteExpired
+		}
+		if invite.MaxUses != nil && invite.UseCount >= *invite.MaxUses {
+			return errInviteUsedUp
+		}
+		if invite.Email != nil && !strings.EqualFold(user.Email, *invite.Email) {
+			return errInviteWrongEmail
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		http.Error(w, "Invite code is invalid or has been revoked", http.StatusNotFound)
+		return
+	case errors.Is(err, errInviteExpired):
+		http.Error(w, "Invite code has expired", http.StatusBadRequest)
+		return
+	case errors.Is(err, errInviteUsedUp):
+		http.Error(w, "Invite code has already been used", http.StatusGone)
+		return
+	case errors.Is(err, errInviteWrongEmail):
+		http.Error(w, "This invite was issued to a different email address", http.StatusForbidden)
+		return
+	case err != nil:
+		log.Printf("Failed to accept invite: %v", err)
+		http.Error(w, "Failed to accept invite", http.StatusInternalServerError)
+		return
+	}
+
+	if joined {
+		h.auditLog(r, invite.ProjectID.String(), "member.join", "invite", invite.ID.String(), map[string]interface{}{"role": invite.Role})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":   "Successfully joined project!",
+		"projectId": invite.ProjectID,
+		"role":      invite.Role,
+	})
+}
@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+
+	"project-meetings/backend/internal/middleware"
+	"project-meetings/backend/internal/models"
+	"project-meetings/backend/internal/permissions"
+	"project-meetings/backend/internal/store"
+	"project-meetings/backend/internal/ws"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,31}$`)
+
+// RoleInfo describes a role and the permissions it grants.
+type RoleInfo struct {
+	Name        string                   `json:"name"`
+	Permissions []permissions.Permission `json:"permissions"`
+	Builtin     bool                     `json:"builtin"`
+}
+
+// errRoleExceedsCaller is returned when a role grants permissions the caller
+// does not hold.
+var errRoleExceedsCaller = errors.New("role grants permissions the caller does not have")
+
+// callerPermissions returns what the permission middleware resolved for the
+// caller.
+func callerPermissions(r *http.Request) permissions.Set {
+	perms, _ := r.Context().Value(middleware.PermissionsKey).(permissions.Set)
+	return perms
+}
+
+// assignableRole checks that role can be given to a member through a role
+// change or an invite. Ownership is only ever handed over by a transfer, and
+// nobody can hand out permissions they do not hold themselves.
+func (h *Handler) assignableRole(r *http.Request, projectID, role string) (permissions.Set, error) {
+	if role == permissions.OwnerRole {
+		return nil, permissions.ErrUnknownRole
+	}
+	perms, err := permissions.ForRole(r.Context(), h.store.Projects, projectID, role)
+	if err != nil {
+		return nil, err
+	}
+	if !callerPermissions(r).Includes(perms) {
+		return nil, errRoleExceedsCaller
+	}
+	return perms, nil
+}
+
+// errMemberExceedsCaller is returned when a member holds permissions the
+// caller does not.
+var errMemberExceedsCaller = errors.New("member has permissions the caller does not have")
+
+// manageableMember checks that the caller may change or remove a member.
+// Nobody can act on a member whose current permissions go beyond their own.
+func (h *Handler) manageableMember(r *http.Request, projectID, memberID string) error {
+	_, perms, err := permissions.Resolve(r.Context(), h.store.Projects, projectID, memberID)
+	if err != nil {
+		return err
+	}
+	if !callerPermissions(r).Includes(perms) {
+		return errMemberExceedsCaller
+	}
+	return nil
+}
+
+// writeRoleError answers a request whose role failed assignableRole or whose
+// member failed manageableMember.
+func writeRoleError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		http.Error(w, "Member not found", http.StatusNotFound)
+	case errors.Is(err, errMemberExceedsCaller):
+		http.Error(w, "Forbidden: You cannot manage a member with permissions you do not have", http.StatusForbidden)
+	case errors.Is(err, permissions.ErrUnknownRole):
+		http.Error(w, "Invalid role. Must be 'editor', 'viewer' or a custom project role.", http.StatusBadRequest)
+	case errors.Is(err, errRoleExceedsCaller):
+		http.Error(w, "Forbidden: You cannot grant permissions you do not have", http.StatusForbidden)
+	default:
+		http.Error(w, "Failed to look up role", http.StatusInternalServerError)
+	}
+}
+
+// parsePermissions validates a list of permission names from a request body.
+func parsePermissions(names []string) ([]permissions.Permission, bool) {
+	perms := make([]permissions.Permission, 0, len(names))
+	for _, name := range names {
+		p := permissions.Permission(name)
+		if !permissions.Valid(p) {
+			return nil, false
+		}
+		perms = append(perms, p)
+	}
+	return permissions.NewSet(perms...).List(), true
+}
+
+// permissionNames converts validated permissions back to the names stored
+// with a custom role.
+func permissionNames(perms []permissions.Permission) []string {
+	names := make([]string, len(perms))
+	for i, p := range perms {
+		names[i] = string(p)
+	}
+	return names
+}
+
+// pushRolePermissions refreshes the cached permissions of connected members
+// holding the given role.
+func (h *Handler) pushRolePermissions(ctx context.Context, projectID, role string, perms permissions.Set) {
+	userIDs, err := h.store.Projects.MemberIDsWithRole(ctx, projectID, role)
+	if err != nil {
+		log.Printf("Failed to list members with role %s: %v", role, err)
+		return
+	}
+	for _, userID := range userIDs {
+		h.hub.UpdatePermissions(&ws.PermissionUpdate{UserID: userID, ProjectID: projectID, Role: role, Permissions: perms})
+	}
+}
+
+// --- LIST ROLES ---
+func (h *Handler) ListProjectRoles(w http.ResponseWriter, r *http.Request) {
+	projectIDStr := chi.URLParam(r, "projectId")
+
+	roles := make([]RoleInfo, 0)
+	for _, name := range []string{permissions.OwnerRole, "editor", "viewer"} {
+		roles = append(roles, RoleInfo{Name: name, Permissions: permissions.NewSet(permissions.BuiltinRoles[name]...).List(), Builtin: true})
+	}
+
+	custom, err := h.store.Projects.Roles(r.Context(), projectIDStr)
+	if err != nil {
+		log.Printf("Failed to list project roles: %v", err)
+		http.Error(w, "Failed to retrieve roles", http.StatusInternalServerError)
+		return
+	}
+	for _, role := range custom {
+		perms, _ := parsePermissions(role.Permissions)
+		roles = append(roles, RoleInfo{Name: role.Name, Permissions: perms})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(roles)
+}
+
+// --- CREATE CUSTOM ROLE ---
+func (h *Handler) CreateProjectRole(w http.ResponseWriter, r *http.Request) {
+	projectIDStr := chi.URLParam(r, "projectId")
+
+	var req struct {
+		Name        string   `json:"name"`
+		Permissions []string `json:"permissions"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !roleNamePattern.MatchString(req.Name) {
+		http.Error(w, "Role names must be 2-32 lowercase letters, digits, '-' or '_'", http.StatusBadRequest)
+		return
+	}
+	if permissions.IsBuiltin(req.Name) {
+		http.Error(w, "Built-in roles cannot be redefined", http.StatusConflict)
+		return
+	}
+	perms, ok := parsePermissions(req.Permissions)
+	if !ok {
+		http.Error(w, "Unknown permission in list", http.StatusBadRequest)
+		return
+	}
+	if !callerPermissions(r).Includes(permissions.NewSet(perms...)) {
+		http.Error(w, "Forbidden: You cannot grant permissions you do not have", http.StatusForbidden)
+		return
+	}
+
+	err := h.store.Projects.CreateRole(r.Context(), projectIDStr, models.ProjectRole{Name: req.Name, Permissions: permissionNames(perms)})
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			http.Error(w, "A role with that name already exists", http.StatusConflict)
+			return
+		}
+		log.Printf("Failed to create project role: %v", err)
+		http.Error(w, "Failed to create role", http.StatusInternalServerError)
+		return
+	}
+
+	h.auditLog(r, projectIDStr, "role.create", "role", req.Name, map[string]interface{}{"permissions": perms})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(RoleInfo{Name: req.Name, Permissions: perms})
+}
+
+// --- UPDATE CUSTOM ROLE ---
+func (h *Handler) UpdateProjectRole(w http.ResponseWriter, r *http.Request) {
+	projectIDStr := chi.URLParam(r, "projectId")
+	roleName := chi.URLParam(r, "roleName")
+
+	if permissions.IsBuiltin(roleName) {
+		http.Error(w, "Built-in roles cannot be modified", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Permissions []string `json:"permissions"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	perms, ok := parsePermissions(req.Permissions)
+	if !ok {
+		http.Error(w, "Unknown permission in list", http.StatusBadRequest)
+		return
+	}
+	if !callerPermissions(r).Includes(permissions.NewSet(perms...)) {
+		http.Error(w, "Forbidden: You cannot grant permissions you do not have", http.StatusForbidden)
+		return
+	}
+
+	err := h.store.Projects.UpdateRole(r.Context(), projectIDStr, models.ProjectRole{Name: roleName, Permissions: permissionNames(perms)})
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Role not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Failed to update project role: %v", err)
+		http.Error(w, "Failed to update role", http.StatusInternalServerError)
+		return
+	}
+
+	h.auditLog(r, projectIDStr, "role.update", "role", roleName, map[string]interface{}{"permissions": perms})
+
+	h.pushRolePermissions(r.Context(), projectIDStr, roleName, permissions.NewSet(perms...))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RoleInfo{Name: roleName, Permissions: perms})
+}
+
+// --- DELETE CUSTOM ROLE ---
+func (h *Handler) DeleteProjectRole(w http.ResponseWriter, r *http.Request) {
+	projectIDStr := chi.URLParam(r, "projectId")
+	roleName := chi.URLParam(r, "roleName")
+
+	if permissions.IsBuiltin(roleName) {
+		http.Error(w, "Built-in roles cannot be deleted", http.StatusBadRequest)
+		return
+	}
+
+	inUse, err := h.store.Projects.RoleInUse(r.Context(), projectIDStr, roleName)
+	if err != nil {
+		http.Error(w, "Failed to check role usage", http.StatusInternalServerError)
+		return
+	}
+	if inUse {
+		http.Error(w, "Role is still assigned to members or open invites", http.StatusConflict)
+		return
+	}
+
+	err = h.store.Projects.DeleteRole(r.Context(), projectIDStr, roleName)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Role not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Failed to delete project role: %v", err)
+		http.Error(w, "Failed to delete role", http.StatusInternalServerError)
+		return
+	}
+
+	h.auditLog(r, projectIDStr, "role.delete", "role", roleName, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"project-meetings/backend/internal/middleware"
+	"project-meetings/backend/internal/permissions"
+	"project-meetings/backend/internal/store/memstore"
+)
+
+func TestAssignableRole(t *testing.T) {
+	h := New(memstore.New(), nil, nil)
+	// A manager who may add members and read the project, but not edit.
+	caller := permissions.NewSet(permissions.MembersManage, permissions.ProjectRead, permissions.CallJoin)
+	r := httptest.NewRequest("PUT", "/", nil)
+	r = r.WithContext(context.WithValue(r.Context(), middleware.PermissionsKey, caller))
+
+	for _, c := range []struct {
+		role string
+		want int
+	}{
+		{"viewer", http.StatusOK},
+		{"editor", http.StatusForbidden},
+		{permissions.OwnerRole, http.StatusBadRequest},
+		{"unknown", http.StatusBadRequest},
+	} {
+		rec := httptest.NewRecorder()
+		if _, err := h.assignableRole(r, "project", c.role); err != nil {
+			writeRoleError(rec, err)
+		}
+		if rec.Code != c.want {
+			t.Errorf("%s: status %d, want %d", c.role, rec.Code, c.want)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"project-meetings/backend/internal/acl"
+	"project-meetings/backend/internal/models"
+	"project-meetings/backend/internal/search"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultSearchContext = 2
+	maxSearchContext     = 10
+	defaultSearchLimit   = 200
+	maxSearchLimit       = 1000
+)
+
+type searchResult struct {
+	FileID uuid.UUID `json:"fileId"`
+	Path   string    `json:"path"`
+	// Live is set when the matches come from an open editor rather than the
+	// saved file.
+	Live    bool          `json:"live"`
+	Matches []search.Line `json:"matches"`
+}
+
+// SearchProject searches the contents of a project's files.
+// Parameters: q (required), mode ("text", "literal" or "regex"; default
+// text), case=true for a case-sensitive literal or regex search, context
+// (lines around each match) and limit (total matching lines). Files the caller
+// can't read are skipped, and unsaved editor contents are searched in place of
+// the saved copy.
+func (h *Handler) SearchProject(w http.ResponseWriter, r *http.Request) {
+	projectID, err := uuid.Parse(chi.URLParam(r, "projectId"))
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	q := r.URL.Query()
+	query := search.Query{Text: q.Get("q"), Mode: search.ModeText, CaseSensitive: q.Get("case") == "true"}
+	if value := q.Get("mode"); value != "" {
+		mode, ok := search.ParseMode(value)
+		if !ok {
+			http.Error(w, "Invalid mode. Must be 'text', 'literal' or 'regex'.", http.StatusBadRequest)
+			return
+		}
+		query.Mode = mode
+	}
+	contextLines, ok := intParam(q.Get("context"), defaultSearchContext, 0, maxSearchContext)
+	if !ok {
+		http.Error(w, "Invalid context", http.StatusBadRequest)
+		return
+	}
+	limit, ok := intParam(q.Get("limit"), defaultSearchLimit, 1, maxSearchLimit)
+	if !ok {
+		http.Error(w, "Invalid limit", http.StatusBadRequest)
+		return
+	}
+
+	matcher, err := search.Compile(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	access, err := acl.LoadProject(r.Context(), h.store.Files, projectID, aclSubject(r))
+	if err != nil {
+		log.Printf("Failed to load access rules: %v", err)
+		http.Error(w, "Failed to search project", http.StatusInternalServerError)
+		return
+	}
+	outline, err := h.store.Files.Outline(r.Context(), projectID)
+	if err != nil {
+		log.Printf("Failed to load file tree for search: %v", err)
+		http.Error(w, "Failed to search project", http.StatusInternalServerError)
+		return
+	}
+	nodes := make(map[uuid.UUID]*models.FileNode, len(outline))
+	for i := range outline {
+		nodes[outline[i].ID] = &outline[i]
+	}
+	candidates, err := h.store.Files.Search(r.Context(), projectID, query)
+	if err != nil {
+		log.Printf("Failed to search files: %v", err)
+		http.Error(w, "Failed to search project", http.StatusInternalServerError)
+		return
+	}
+	editing, err := h.hub.EditorContents(r.Context(), projectID.String())
+	if err != nil {
+		log.Printf("Failed to load open editors for search: %v", err)
+		http.Error(w, "Failed to search project", http.StatusInternalServerError)
+		return
+	}
+
+	// Open editors win over the saved copy, so results match what people see.
+	contents := make(map[uuid.UUID]string, len(candidates))
+	live := make(map[uuid.UUID]bool)
+	for _, file := range candidates {
+		if file.Content != nil {
+			contents[file.ID] = *file.Content
+		}
+	}
+	for fileIDStr, content := range editing {
+		fileID, err := uuid.Parse(fileIDStr)
+		if err != nil {
+			continue
+		}
+		if node, ok := nodes[fileID]; ok && !node.IsFolder {
+			contents[fileID] = content
+			live[fileID] = true
+		}
+	}
+
+	type candidate struct {
+		id   uuid.UUID
+		path string
+	}
+	readable := make([]candidate, 0, len(contents))
+	for id := range contents {
+		if p, ok := readablePath(nodes, access, id); ok {
+			readable = append(readable, candidate{id: id, path: p})
+		}
+	}
+	sort.Slice(readable, func(i, j int) bool { return readable[i].path < readable[j].path })
+
+	results := make([]searchResult, 0)
+	remaining := limit
+	truncated := false
+	for _, c := range readable {
+		content := contents[c.id]
+		if !matcher.MatchesFile(content) {
+			continue
+		}
+		if remaining == 0 {
+			truncated = true
+			break
+		}
+		lines := matcher.Grep(content, contextLines, remaining)
+		if len(lines) == 0 {
+			continue
+		}
+		remaining -= len(lines)
+		results = append(results, searchResult{FileID: c.id, Path: c.path, Live: live[c.id], Matches: lines})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"results":   results,
+		"truncated": truncated,
+	})
+}
+
+// intParam parses an optional integer query parameter, clamping it to max.
+func intParam(value string, def, min, max int) (int, bool) {
+	if value == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min {
+		return 0, false
+	}
+	if n > max {
+		n = max
+	}
+	return n, true
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"project-meetings/backend/internal/acl"
+	"project-meetings/backend/internal/models"
+	"project-meetings/backend/internal/permissions"
+
+	"github.com/google/uuid"
+)
+
+// file creates a file or, with nil content, a folder in the test project.
+func (api *testAPI) file(t *testing.T, parent *uuid.UUID, name string, content *string) models.FileNode {
+	t.Helper()
+	node := models.FileNode{ProjectID: api.project.ID, ParentID: parent, Name: name, IsFolder: content == nil, Content: content}
+	if err := api.store.Files.Create(context.Background(), &node); err != nil {
+		t.Fatal(err)
+	}
+	return node
+}
+
+func TestSearchProject(t *testing.T) {
+	api := newTestAPI(t)
+	api.router.With(api.mw.RequirePermission(permissions.ProjectRead)).Get("/project/{projectId}/search", api.handler.SearchProject)
+	text := func(s string) *string { return &s }
+	api.file(t, nil, "main.go", text("package main\n\n// Hello world\nfunc main() {}\n"))
+	api.file(t, nil, "notes.md", text("nothing here"))
+	secret := api.file(t, nil, "secret", nil)
+	api.file(t, &secret.ID, "keys.txt", text("hello from the vault"))
+	err := api.store.Files.ReplaceRules(context.Background(), api.project.ID.String(), secret.ID, []models.AccessRule{
+		{FileID: secret.ID, SubjectType: acl.SubjectRole, Subject: "viewer", Access: "none"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	viewer := api.member(t, "viewer", "viewer")
+
+	search := func(user models.User, query string) ([]searchResult, int) {
+		rec := api.do(t, user, "GET", "/project/"+api.project.ID.String()+"/search?"+query, nil)
+		var body struct {
+			Results []searchResult `json:"results"`
+		}
+		json.NewDecoder(rec.Body).Decode(&body)
+		return body.Results, rec.Code
+	}
+	paths := func(results []searchResult) []string {
+		var paths []string
+		for _, result := range results {
+			paths = append(paths, result.Path)
+		}
+		return paths
+	}
+
+	// The default text mode ignores case; the hidden folder stays hidden.
+	results, code := search(api.owner, "q=hello")
+	if code != http.StatusOK || len(results) != 2 {
+		t.Fatalf("owner: status %d, results %v", code, paths(results))
+	}
+	results, _ = search(viewer, "q=hello&context=1")
+	if len(results) != 1 || results[0].Path != "main.go" {
+		t.Fatalf("viewer: results %v", paths(results))
+	}
+	match := results[0].Matches[0]
+	if match.Number != 3 || match.Text != "// Hello world" || len(match.Before) != 1 || len(match.After) != 1 {
+		t.Errorf("match = %+v", match)
+	}
+
+	if results, _ := search(api.owner, "q=Hello&mode=literal&case=true"); len(results) != 1 {
+		t.Errorf("case-sensitive literal: results %v", paths(results))
+	}
+	if results, _ := search(api.owner, "q="+url.QueryEscape(`func\s+main`)+"&mode=regex"); len(results) != 1 {
+		t.Errorf("regex: results %v", paths(results))
+	}
+	for _, query := range []string{"q=", "q=x&mode=fuzzy", "q=(&mode=regex", "q=x&limit=0"} {
+		if _, code := search(api.owner, query); code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", query, code)
+		}
+	}
+}
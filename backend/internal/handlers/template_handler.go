@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"project-meetings/backend/internal/acl"
+	"project-meetings/backend/internal/middleware"
+	"project-meetings/backend/internal/permissions"
+	"project-meetings/backend/internal/store"
+	"project-meetings/backend/internal/templates"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+var errUnknownTemplate = errors.New("unknown template")
+
+// templateInfo is one entry of the template list: a built-in starter, or a
+// project marked as a template, whose ID is the project ID.
+type templateInfo struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Builtin     bool   `json:"builtin"`
+}
+
+// templateSeed builds what a project created from templateID starts out
+// with. A project template is copied the way the caller sees it, whiteboard
+// included, and only when they can open it. The keys of copied blobs are
+// returned so a failed create can remove them again.
+func (h *Handler) templateSeed(ctx context.Context, userID, templateID string) (store.ProjectSeed, []string, error) {
+	if templateID == "" {
+		return store.ProjectSeed{}, nil, nil
+	}
+	if _, ok := templates.Lookup(templateID); ok {
+		files, err := templates.Tree(templateID)
+		return store.ProjectSeed{Files: files}, nil, err
+	}
+
+	projectID, err := uuid.Parse(templateID)
+	if err != nil {
+		return store.ProjectSeed{}, nil, errUnknownTemplate
+	}
+	project, err := h.store.Projects.Get(ctx, templateID)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && !project.IsTemplate) {
+		return store.ProjectSeed{}, nil, errUnknownTemplate
+	}
+	if err != nil {
+		return store.ProjectSeed{}, nil, err
+	}
+	role, perms, err := permissions.Resolve(ctx, h.store.Projects, templateID, userID)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && !perms.Has(permissions.ProjectRead)) {
+		return store.ProjectSeed{}, nil, errUnknownTemplate
+	}
+	if err != nil {
+		return store.ProjectSeed{}, nil, err
+	}
+	return h.projectSeed(ctx, projectID, acl.Subject{UserID: userID, Role: role, Permissions: perms}, true)
+}
+
+// --- LIST TEMPLATES ---
+// ListTemplates lists the built-in starters, then the template projects the
+// caller can open.
+func (h *Handler) ListTemplates(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(w, "Could not retrieve user ID from context", http.StatusInternalServerError)
+		return
+	}
+	projects, err := h.store.Projects.ListForUser(r.Context(), userID)
+	if err != nil {
+		log.Printf("Failed to query projects: %v", err)
+		http.Error(w, "Failed to retrieve templates", http.StatusInternalServerError)
+		return
+	}
+
+	list := make([]templateInfo, 0, len(templates.Starters))
+	for _, s := range templates.Starters {
+		list = append(list, templateInfo{ID: s.ID, Name: s.Name, Description: s.Description, Builtin: true})
+	}
+	for _, p := range projects {
+		if p.IsTemplate {
+			list = append(list, templateInfo{ID: p.ID.String(), Name: p.Name})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// --- MARK PROJECT AS TEMPLATE ---
+func (h *Handler) SetProjectTemplate(w http.ResponseWriter, r *http.Request) {
+	projectIDStr := chi.URLParam(r, "projectId")
+
+	var req struct {
+		IsTemplate *bool `json:"isTemplate"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.IsTemplate == nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.store.Projects.SetTemplate(r.Context(), projectIDStr, *req.IsTemplate); err != nil {
+		log.Printf("Failed to update project template flag: %v", err)
+		http.Error(w, "Failed to update project", http.StatusInternalServerError)
+		return
+	}
+
+	h.auditLog(r, projectIDStr, "project.template", "project", projectIDStr, map[string]interface{}{"isTemplate": *req.IsTemplate})
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"project-meetings/backend/internal/acl"
+	"project-meetings/backend/internal/models"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultTreePageSize = 100
+	maxTreePageSize     = 500
+)
+
+// treeCursor encodes the name of the last node on a page. Sibling names are
+// unique, so the next page simply starts after it.
+func treeCursor(name string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(name))
+}
+
+// nodeParents maps nodes to their parent.
+func nodeParents(nodes []models.FileNode) map[uuid.UUID]*uuid.UUID {
+	parents := make(map[uuid.UUID]*uuid.UUID, len(nodes))
+	for _, node := range nodes {
+		parents[node.ID] = node.ParentID
+	}
+	return parents
+}
+
+// --- LIST FOLDER CHILDREN ---
+// ListFolderChildren lists one level of a project's tree, without contents:
+// the top level, or the children of the folder given as parentId. Folders
+// carry childCount so clients can load them lazily. Pagination: limit and
+// the opaque cursor returned as nextCursor by the previous page.
+func (h *Handler) ListFolderChildren(w http.ResponseWriter, r *http.Request) {
+	projectID, err := uuid.Parse(chi.URLParam(r, "projectId"))
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+	q := r.URL.Query()
+
+	var parentID *uuid.UUID
+	if value := q.Get("parentId"); value != "" {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			http.Error(w, "Invalid parent ID", http.StatusBadRequest)
+			return
+		}
+		parentID = &id
+	}
+	after := ""
+	if cursor := q.Get("cursor"); cursor != "" {
+		name, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		after = string(name)
+	}
+	limit, ok := intParam(q.Get("limit"), defaultTreePageSize, 1, maxTreePageSize)
+	if !ok {
+		http.Error(w, "Invalid limit", http.StatusBadRequest)
+		return
+	}
+
+	if parentID != nil {
+		parent, err := h.store.Files.Get(r.Context(), *parentID)
+		if err != nil || parent.ProjectID != projectID || !parent.IsFolder {
+			http.Error(w, "Folder not found", http.StatusNotFound)
+			return
+		}
+	}
+	// Rules are only loaded along the folder's ancestors and for the nodes
+	// read below it, not for the whole project on every page.
+	access, err := acl.LoadFolder(r.Context(), h.store.Files, parentID, aclSubject(r))
+	if err != nil {
+		log.Printf("Failed to load access rules: %v", err)
+		http.Error(w, "Failed to retrieve file structure", http.StatusInternalServerError)
+		return
+	}
+	if parentID != nil && !access.CanRead(*parentID) {
+		http.Error(w, "Folder not found", http.StatusNotFound)
+		return
+	}
+
+	// Hidden nodes are skipped, so keep reading until the page is full and
+	// one more readable node shows there is another page.
+	children := make([]models.FileNode, 0, limit+1)
+	for len(children) <= limit {
+		batch, err := h.store.Files.Children(r.Context(), projectID, parentID, after, limit+1)
+		if err == nil {
+			err = access.Include(r.Context(), h.store.Files, nodeParents(batch))
+		}
+		if err != nil {
+			log.Printf("Failed to list children of %v: %v", parentID, err)
+			http.Error(w, "Failed to retrieve file structure", http.StatusInternalServerError)
+			return
+		}
+		for _, node := range batch {
+			if access.CanRead(node.ID) {
+				children = append(children, node)
+			}
+		}
+		if len(batch) <= limit {
+			break
+		}
+		after = batch[len(batch)-1].Name
+	}
+
+	var nextCursor *string
+	if len(children) > limit {
+		children = children[:limit]
+		c := treeCursor(children[limit-1].Name)
+		nextCursor = &c
+	}
+
+	// Folder sizes count the readable nodes one level further down.
+	var folders []uuid.UUID
+	for _, node := range children {
+		if node.IsFolder {
+			folders = append(folders, node.ID)
+		}
+	}
+	if len(folders) > 0 {
+		grandchildren, err := h.store.Files.ChildParents(r.Context(), folders)
+		if err == nil {
+			err = access.Include(r.Context(), h.store.Files, grandchildren)
+		}
+		if err != nil {
+			log.Printf("Failed to count children below %v: %v", parentID, err)
+			http.Error(w, "Failed to retrieve file structure", http.StatusInternalServerError)
+			return
+		}
+	}
+	counts := access.ReadableChildren()
+	for i := range children {
+		if children[i].IsFolder {
+			count := counts[children[i].ID]
+			children[i].ChildCount = &count
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"parentId":   parentID,
+		"children":   children,
+		"nextCursor": nextCursor,
+	})
+}
+
+// --- GET FILE ---
+// GetFile returns a single node with its text content. Binary files only
+// carry their blob details; their bytes come from DownloadFile.
+func (h *Handler) GetFile(w http.ResponseWriter, r *http.Request) {
+	fileID, err := uuid.Parse(chi.URLParam(r, "fileId"))
+	if err != nil {
+		http.Error(w, "Invalid file ID", http.StatusBadRequest)
+		return
+	}
+	if !h.canReadFile(r, fileID) {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	node, err := h.store.Files.Get(r.Context(), fileID)
+	if err != nil {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	if notModified(w, r, node) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(node)
+}
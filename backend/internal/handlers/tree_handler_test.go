@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"project-meetings/backend/internal/acl"
+	"project-meetings/backend/internal/models"
+	"project-meetings/backend/internal/permissions"
+	"project-meetings/backend/internal/store"
+
+	"github.com/google/uuid"
+)
+
+// folderScoped fails the project-wide loads, so listing a folder must not
+// use them.
+type folderScoped struct{ store.FileStore }
+
+func (folderScoped) Parents(context.Context, uuid.UUID) (map[uuid.UUID]*uuid.UUID, error) {
+	return nil, errors.New("project-wide parents loaded")
+}
+
+func (folderScoped) ProjectRules(context.Context, uuid.UUID) ([]models.AccessRule, error) {
+	return nil, errors.New("project-wide rules loaded")
+}
+
+func TestListFolderChildren(t *testing.T) {
+	api := newTestAPI(t)
+	api.router.With(api.mw.RequirePermission(permissions.ProjectRead)).Get("/project/{projectId}/tree", api.handler.ListFolderChildren)
+	ctx := context.Background()
+	projectID := api.project.ID.String()
+	hide := func(node models.FileNode) {
+		err := api.store.Files.ReplaceRules(ctx, projectID, node.ID, []models.AccessRule{
+			{FileID: node.ID, SubjectType: acl.SubjectRole, Subject: "viewer", Access: "none"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	text := "text"
+	docs := api.file(t, nil, "docs", nil)
+	private := api.file(t, nil, "private", nil)
+	hide(private)
+	inside := api.file(t, &private.ID, "inside", nil)
+	guides := api.file(t, &docs.ID, "guides", nil)
+	for i := 0; i < 3; i++ {
+		api.file(t, &guides.ID, fmt.Sprint("guide", i), &text)
+	}
+	hide(api.file(t, &guides.ID, "draft", &text))
+	for i := 0; i < 4; i++ {
+		api.file(t, &docs.ID, fmt.Sprint("page", i), &text)
+	}
+	hide(api.file(t, &docs.ID, "page1b", &text))
+	viewer := api.member(t, "viewer", "viewer")
+	api.store.Files = folderScoped{api.store.Files}
+
+	type page struct {
+		Children   []models.FileNode `json:"children"`
+		NextCursor *string           `json:"nextCursor"`
+	}
+	list := func(user models.User, query string) (page, int) {
+		rec := api.do(t, user, "GET", "/project/"+projectID+"/tree?"+query, nil)
+		var p page
+		json.NewDecoder(rec.Body).Decode(&p)
+		return p, rec.Code
+	}
+	names := func(p page) []string {
+		var names []string
+		for _, node := range p.Children {
+			names = append(names, node.Name)
+		}
+		return names
+	}
+
+	top, code := list(viewer, "")
+	if code != 200 || fmt.Sprint(names(top)) != "[docs]" || *top.Children[0].ChildCount != 5 {
+		t.Fatalf("top level: %d %v", code, names(top))
+	}
+	if top, _ := list(api.owner, ""); fmt.Sprint(names(top)) != "[docs private]" || *top.Children[1].ChildCount != 1 {
+		t.Fatalf("owner's top level: %v", names(top))
+	}
+	// The hidden folder and what is below it stay hidden, though the rule
+	// sits on an ancestor.
+	for _, folder := range []models.FileNode{private, inside} {
+		if _, code := list(viewer, "parentId="+folder.ID.String()); code != 404 {
+			t.Errorf("%s: status %d, want 404", folder.Name, code)
+		}
+	}
+
+	// Pages skip hidden nodes and still come out full.
+	var seen []string
+	query := "limit=2&parentId=" + docs.ID.String()
+	for {
+		p, code := list(viewer, query)
+		if code != 200 {
+			t.Fatalf("status %d", code)
+		}
+		for _, node := range p.Children {
+			if node.Name == "guides" && *node.ChildCount != 3 {
+				t.Errorf("guides has %d readable children, want 3", *node.ChildCount)
+			}
+		}
+		seen = append(seen, names(p)...)
+		if p.NextCursor == nil {
+			break
+		}
+		if len(p.Children) != 2 {
+			t.Fatalf("short page %v", names(p))
+		}
+		query = "limit=2&parentId=" + docs.ID.String() + "&cursor=" + *p.NextCursor
+	}
+	if fmt.Sprint(seen) != "[guides page0 page1 page2 page3]" {
+		t.Errorf("pages = %v", seen)
+	}
+}
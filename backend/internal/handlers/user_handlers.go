@@ -0,0 +1,377 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"project-meetings/backend/internal/auth"
+	"project-meetings/backend/internal/mail"
+	"project-meetings/backend/internal/middleware"
+	"project-meetings/backend/internal/models"
+	"project-meetings/backend/internal/permissions"
+	"project-meetings/backend/internal/store"
+	"project-meetings/backend/internal/ws"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Email change links are valid for one hour.
+const emailChangeTTL = time.Hour
+
+// loadUser fetches the full profile of a user, including the password hash.
+func (h *Handler) loadUser(ctx context.Context, userID string) (models.User, error) {
+	return h.store.Users.Get(ctx, userID)
+}
+
+// hashToken returns the hex-encoded sha256 of a one-time token so that the
+// raw value never has to be stored.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// --- GET CURRENT USER ---
+func (h *Handler) GetMe(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(w, "Could not retrieve user ID from context", http.StatusInternalServerError)
+		return
+	}
+
+	user, err := h.loadUser(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Failed to load user %s: %v", userID, err)
+		http.Error(w, "Failed to load profile", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// --- UPDATE CURRENT USER ---
+// Only the fields present in the request body are changed. If the username
+// changes a fresh token is returned, since the old one carries the old name.
+func (h *Handler) UpdateMe(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(w, "Could not retrieve user ID from context", http.StatusInternalServerError)
+		return
+	}
+
+	var req struct {
+		Username    *string `json:"username"`
+		DisplayName *string `json:"displayName"`
+		AvatarURL   *string `json:"avatarUrl"`
+		Timezone    *string `json:"timezone"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.loadUser(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	oldUsername := user.Username
+
+	if req.Username != nil {
+		name := strings.TrimSpace(*req.Username)
+		if name == "" {
+			http.Error(w, "Username cannot be empty", http.StatusBadRequest)
+			return
+		}
+		user.Username = name
+	}
+	if req.DisplayName != nil {
+		name := strings.TrimSpace(*req.DisplayName)
+		if len(name) > 100 {
+			http.Error(w, "Display name must be at most 100 characters", http.StatusBadRequest)
+			return
+		}
+		user.DisplayName = name
+	}
+	if req.AvatarURL != nil {
+		avatar := strings.TrimSpace(*req.AvatarURL)
+		if avatar != "" {
+			u, err := url.Parse(avatar)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				http.Error(w, "Avatar URL must be an absolute http(s) URL", http.StatusBadRequest)
+				return
+			}
+		}
+		user.AvatarURL = avatar
+	}
+	if req.Timezone != nil {
+		if _, err := time.LoadLocation(*req.Timezone); err != nil || *req.Timezone == "" {
+			http.Error(w, "Invalid timezone. Use an IANA name such as 'Europe/Berlin'.", http.StatusBadRequest)
+			return
+		}
+		user.Timezone = *req.Timezone
+	}
+
+	err = h.store.Users.UpdateProfile(r.Context(), &user)
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			http.Error(w, "Username already exists", http.StatusConflict)
+			return
+		}
+		log.Printf("Failed to update user %s: %v", userID, err)
+		http.Error(w, "Failed to update profile", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{"user": user}
+	if user.Username != oldUsername {
+		token, err := auth.CreateJWT(user.ID.String(), user.Username)
+		if err != nil {
+			http.Error(w, "Failed to create token", http.StatusInternalServerError)
+			return
+		}
+		response["token"] = token
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// --- CHANGE PASSWORD ---
+func (h *Handler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(w, "Could not retrieve user ID from context", http.StatusInternalServerError)
+		return
+	}
+
+	var req struct {
+		CurrentPassword string `json:"currentPassword"`
+		NewPassword     string `json:"newPassword"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.NewPassword) < 8 {
+		http.Error(w, "New password must be at least 8 characters", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.loadUser(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.CurrentPassword)); err != nil {
+		http.Error(w, "Current password is incorrect", http.StatusUnauthorized)
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		http.Error(w, "Failed to hash password", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.store.Users.UpdatePassword(r.Context(), userID, string(hashedPassword)); err != nil {
+		log.Printf("Failed to change password for user %s: %v", userID, err)
+		http.Error(w, "Failed to change password", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// --- REQUEST EMAIL CHANGE ---
+// The new address is not applied until the user proves they own it by
+// submitting the token we mail to it.
+func (h *Handler) RequestEmailChange(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(w, "Could not retrieve user ID from context", http.StatusInternalServerError)
+		return
+	}
+
+	var req struct {
+		NewEmail string `json:"newEmail"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.NewEmail = strings.TrimSpace(req.NewEmail)
+	if !mail.ValidAddress(req.NewEmail) {
+		http.Error(w, "A valid email address is required", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.loadUser(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+		http.Error(w, "Password is incorrect", http.StatusUnauthorized)
+		return
+	}
+
+	exists, err := h.store.Users.EmailInUse(r.Context(), req.NewEmail)
+	if err != nil {
+		http.Error(w, "Failed to check email", http.StatusInternalServerError)
+		return
+	}
+	if exists {
+		http.Error(w, "Email already in use", http.StatusConflict)
+		return
+	}
+
+	token, err := generateInviteCode(32)
+	if err != nil {
+		http.Error(w, "Failed to generate verification token", http.StatusInternalServerError)
+		return
+	}
+
+	// Only one pending change per user: a new request replaces the old one.
+	err = h.store.Users.RequestEmailChange(r.Context(), userID, req.NewEmail, hashToken(token), time.Now().Add(emailChangeTTL))
+	if err != nil {
+		log.Printf("Failed to store email change request: %v", err)
+		http.Error(w, "Failed to request email change", http.StatusInternalServerError)
+		return
+	}
+
+	body := fmt.Sprintf("Use this code to confirm your new email address: %s\nThe code expires in one hour.", token)
+	if err := mail.Send(req.NewEmail, "Confirm your new email address", body); err != nil {
+		log.Printf("Failed to send email verification to %s: %v", req.NewEmail, err)
+		http.Error(w, "Failed to send verification email", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// --- CONFIRM EMAIL CHANGE ---
+func (h *Handler) ConfirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(w, "Could not retrieve user ID from context", http.StatusInternalServerError)
+		return
+	}
+
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	newEmail, err := h.store.Users.ConfirmEmailChange(r.Context(), userID, hashToken(req.Token))
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		http.Error(w, "Verification code is invalid", http.StatusNotFound)
+		return
+	case errors.Is(err, store.ErrExpired):
+		http.Error(w, "Verification code has expired", http.StatusBadRequest)
+		return
+	case errors.Is(err, store.ErrConflict):
+		http.Error(w, "Email already in use", http.StatusConflict)
+		return
+	case err != nil:
+		log.Printf("Failed to update email for user %s: %v", userID, err)
+		http.Error(w, "Failed to confirm email change", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"email": newEmail})
+}
+
+// --- DELETE ACCOUNT ---
+// Projects owned by the user are handed over to the member named in
+// `transfers` (projectId -> new owner's userId) or deleted if none is given.
+func (h *Handler) DeleteMe(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(w, "Could not retrieve user ID from context", http.StatusInternalServerError)
+		return
+	}
+
+	var req struct {
+		Password  string            `json:"password"`
+		Transfers map[string]string `json:"transfers"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.loadUser(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+		http.Error(w, "Password is incorrect", http.StatusUnauthorized)
+		return
+	}
+
+	for _, newOwnerID := range req.Transfers {
+		if newOwnerID == userID {
+			http.Error(w, "Cannot transfer a project to yourself", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Remember what the user could open, to find the projects that went with
+	// them afterwards.
+	projects, err := h.store.Projects.ListForUser(r.Context(), userID)
+	if err != nil {
+		log.Printf("Failed to list projects of user %s: %v", userID, err)
+		http.Error(w, "Failed to delete account", http.StatusInternalServerError)
+		return
+	}
+
+	// Owned projects without a transfer are deleted, as are organizations the
+	// user owns; their projects survive without an organization. Memberships,
+	// invites and pending email changes go with the user.
+	transferred, err := h.store.Users.Delete(r.Context(), userID, req.Transfers)
+	if err != nil {
+		if errors.Is(err, store.ErrNotMember) {
+			http.Error(w, fmt.Sprintf("New owner must already be a member (%v)", err), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Failed to delete user %s: %v", userID, err)
+		http.Error(w, "Failed to delete account", http.StatusInternalServerError)
+		return
+	}
+
+	for projectID, newOwnerID := range transferred {
+		h.auditLog(r, projectID, "project.transfer_ownership", "user", newOwnerID, map[string]interface{}{"reason": "account_deleted"})
+		perms := permissions.NewSet(permissions.BuiltinRoles[permissions.OwnerRole]...)
+		h.hub.UpdatePermissions(&ws.PermissionUpdate{UserID: newOwnerID, ProjectID: projectID, Role: permissions.OwnerRole, Permissions: perms})
+	}
+	h.hub.KickUser(userID, "", "Your account has been deleted.")
+	for _, project := range projects {
+		projectID := project.ID.String()
+		if _, err := h.store.Projects.Get(r.Context(), projectID); errors.Is(err, store.ErrNotFound) {
+			h.hub.CloseProject(projectID, "This project has been deleted.")
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
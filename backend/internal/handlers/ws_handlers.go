@@ -1,17 +1,20 @@
 package handlers
 
 import (
-	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"project-meetings/backend/internal/audit"
 	"project-meetings/backend/internal/auth" // Import the auth package
-	"project-meetings/backend/internal/database"
+	"project-meetings/backend/internal/permissions"
+	"project-meetings/backend/internal/store"
 	"project-meetings/backend/internal/ws"
+	"strconv"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
-	"github.com/jackc/pgx/v5"
 )
 
 var upgrader = websocket.Upgrader{
@@ -22,7 +25,7 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
-func ServeWs(hub *ws.Hub, w http.ResponseWriter, r *http.Request) {
+func (h *Handler) ServeWs(w http.ResponseWriter, r *http.Request) {
 	projectId := chi.URLParam(r, "projectId")
 	if projectId == "" {
 		http.Error(w, "Project ID is required in URL", http.StatusBadRequest)
@@ -48,13 +51,14 @@ func ServeWs(hub *ws.Hub, w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Invalid auth token", http.StatusUnauthorized)
 			return
 		}
-		
+
 		userIdStr = claims.UserID
 		username = claims.Username
 	}
-	
+
 	// --- START OF FIX ---
 	var userRole string
+	var userPerms permissions.Set
 
 	if projectId == "sfu-internal-channel" {
 		userRole = "sfu"
@@ -62,22 +66,21 @@ func ServeWs(hub *ws.Hub, w http.ResponseWriter, r *http.Request) {
 		// 1. Parse both project and user IDs into proper UUID types
 		projectUUID, err := uuid.Parse(projectId)
 		if err != nil {
-			 http.Error(w, "Invalid Project ID format", http.StatusBadRequest)
-			 return
+			http.Error(w, "Invalid Project ID format", http.StatusBadRequest)
+			return
 		}
 		userUUID, err := uuid.Parse(userIdStr)
 		if err != nil {
-			 http.Error(w, "Invalid User ID format in token", http.StatusInternalServerError)
-			 return
+			http.Error(w, "Invalid User ID format in token", http.StatusInternalServerError)
+			return
 		}
 
-		// 2. Execute the query with the correct UUID types
-		query := `SELECT role FROM project_members WHERE project_id = $1 AND user_id = $2`
-		err = database.DB.QueryRow(context.Background(), query, projectUUID, userUUID).Scan(&userRole)
-		
+		// 2. Resolve the user's role and what it allows them to do
+		userRole, userPerms, err = permissions.Resolve(r.Context(), h.store.Projects, projectUUID.String(), userUUID.String())
+
 		// 3. Handle the error properly
 		if err != nil {
-			if err == pgx.ErrNoRows {
+			if errors.Is(err, store.ErrNotFound) {
 				log.Printf("WebSocket connection denied for user %s in project %s: not a member.", username, projectId)
 				http.Error(w, "Forbidden: You are not a member of this project", http.StatusForbidden)
 			} else {
@@ -89,6 +92,31 @@ func ServeWs(hub *ws.Hub, w http.ResponseWriter, r *http.Request) {
 	}
 	// --- END OF FIX ---
 
+	// A client reconnecting after a drop passes the epoch and sequence of the
+	// last broadcast it saw, to be sent what it missed.
+	resumeEpoch := r.URL.Query().Get("epoch")
+	var resumeSeq uint64
+	if resumeEpoch != "" {
+		var err error
+		resumeSeq, err = strconv.ParseUint(r.URL.Query().Get("lastSeq"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid lastSeq", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// The protocol version is agreed before upgrading, so a client asking for
+	// one this server does not speak gets a plain HTTP error.
+	protocol := ws.MinProtocolVersion
+	if value := r.URL.Query().Get("protocol"); value != "" {
+		var err error
+		protocol, err = strconv.Atoi(value)
+		if err != nil || protocol < ws.MinProtocolVersion || protocol > ws.ProtocolVersion {
+			http.Error(w, fmt.Sprintf("Unsupported protocol version %q, this server speaks %d to %d",
+				value, ws.MinProtocolVersion, ws.ProtocolVersion), http.StatusBadRequest)
+			return
+		}
+	}
 
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -97,17 +125,23 @@ func ServeWs(hub *ws.Hub, w http.ResponseWriter, r *http.Request) {
 	}
 
 	client := &ws.Client{
-		Hub:       hub,
-		Conn:      conn,
-		Send:      make(chan []byte, 256),
-		ProjectID: projectId,
-		UserID:    userIdStr, // Keep the string version for the client struct
-		Username:  username,
-		Role:      userRole, // Now this will have the correct role ('owner', 'editor', etc.)
+		Hub:         h.hub,
+		Conn:        conn,
+		Send:        make(chan []byte, 256),
+		ProjectID:   projectId,
+		UserID:      userIdStr, // Keep the string version for the client struct
+		Username:    username,
+		Role:        userRole, // Now this will have the correct role ('owner', 'editor', etc.)
+		Permissions: userPerms,
+		IP:          audit.ClientIP(r),
+		UserAgent:   r.UserAgent(),
+		ResumeEpoch: resumeEpoch,
+		ResumeSeq:   resumeSeq,
+		Protocol:    protocol,
 	}
-	client.Hub.Register <- client
+	client.Hub.Register(client)
 
 	go client.WritePump()
 	go client.ReadPump()
 
-}
\ No newline at end of file
+}
@@ -0,0 +1,60 @@
+package mail
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"strings"
+)
+
+// ErrInvalidHeader is returned by Send when the recipient or subject would
+// break out of its header line.
+var ErrInvalidHeader = errors.New("mail: header contains a line break")
+
+// ValidAddress reports whether s is a bare email address such as
+// "ana@example.com", without a display name or angle brackets.
+func ValidAddress(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Name == "" && addr.Address == s
+}
+
+// Send delivers a plain-text email. If SMTP_HOST is configured the message is
+// relayed through it, otherwise it is only written to the log, which is what we
+// want for local development.
+func Send(to, subject, body string) error {
+	if strings.ContainsAny(to, "\r\n") || strings.ContainsAny(subject, "\r\n") {
+		return ErrInvalidHeader
+	}
+	if !ValidAddress(to) {
+		return fmt.Errorf("mail: invalid recipient %q", to)
+	}
+
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		log.Printf("[Mail] To: %s | Subject: %s\n%s", to, subject, body)
+		return nil
+	}
+
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+	from := os.Getenv("SMTP_FROM")
+	if from == "" {
+		from = "no-reply@localhost"
+	}
+	if strings.ContainsAny(from, "\r\n") {
+		return ErrInvalidHeader
+	}
+
+	var auth smtp.Auth
+	if user := os.Getenv("SMTP_USERNAME"); user != "" {
+		auth = smtp.PlainAuth("", user, os.Getenv("SMTP_PASSWORD"), host)
+	}
+
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\n\r\n%s\r\n", from, to, subject, body)
+	return smtp.SendMail(host+":"+port, auth, from, []string{to}, []byte(msg))
+}
@@ -0,0 +1,37 @@
+package mail
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidAddress(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"ana@example.com":             true,
+		"ana.b+tag@sub.example.org":   true,
+		"":                            false,
+		"ana":                         false,
+		"ana@":                        false,
+		"Ana <ana@example.com>":       false,
+		"<ana@example.com>":           false,
+		" ana@example.com":            false,
+		"ana@example.com\r\nBcc: x@y": false,
+	} {
+		if got := ValidAddress(addr); got != want {
+			t.Errorf("ValidAddress(%q) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestSendRejectsHeaderInjection(t *testing.T) {
+	t.Setenv("SMTP_HOST", "")
+	if err := Send("ana@example.com", "Hello\r\nBcc: eve@example.com", "body"); !errors.Is(err, ErrInvalidHeader) {
+		t.Errorf("subject with CRLF: %v", err)
+	}
+	if err := Send("ana@example.com\nBcc: eve@example.com", "Hello", "body"); !errors.Is(err, ErrInvalidHeader) {
+		t.Errorf("recipient with LF: %v", err)
+	}
+	if err := Send("ana@example.com", "Hello", "line one\r\nline two"); err != nil {
+		t.Errorf("line breaks in the body: %v", err)
+	}
+}
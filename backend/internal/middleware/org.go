@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"project-meetings/backend/internal/store"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const OrgRoleKey contextKey = "orgRole"
+
+// RequireOrgRole is a middleware that checks if a user belongs to the
+// organization in the {orgId} URL parameter with one of the given roles.
+// Organization roles are a fixed owner/admin/member ladder, so plain role
+// names are enough here.
+func (m *Middleware) RequireOrgRole(allowedRoles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := r.Context().Value(UserIDKey).(string)
+			if !ok {
+				http.Error(w, "Could not retrieve user ID from context", http.StatusInternalServerError)
+				return
+			}
+
+			orgID, err := uuid.Parse(chi.URLParam(r, "orgId"))
+			if err != nil {
+				http.Error(w, "Invalid organization ID format", http.StatusBadRequest)
+				return
+			}
+
+			role, err := m.store.Organizations.MemberRole(r.Context(), orgID.String(), userID)
+			if err != nil {
+				if errors.Is(err, store.ErrNotFound) {
+					http.Error(w, "Forbidden: You are not a member of this organization", http.StatusForbidden)
+					return
+				}
+				http.Error(w, "Failed to verify organization membership", http.StatusInternalServerError)
+				return
+			}
+
+			isAllowed := false
+			for _, allowed := range allowedRoles {
+				if role == allowed {
+					isAllowed = true
+					break
+				}
+			}
+			if !isAllowed {
+				http.Error(w, "Forbidden: You do not have the required organization role for this action", http.StatusForbidden)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), OrgRoleKey, role)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -3,70 +3,87 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"net/http"
-	"project-meetings/backend/internal/database"
+	"project-meetings/backend/internal/permissions"
+	"project-meetings/backend/internal/store"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
-	"github.com/jackc/pgx/v5"
 )
 
-// ProjectMemberAuth is a middleware that checks if a user is a member of a project
-// with at least one of the required roles.
-func ProjectMemberAuth(requiredRoles ...string) func(http.Handler) http.Handler {
+const ProjectIDKey contextKey = "projectID"
+const ProjectRoleKey contextKey = "projectRole"
+const PermissionsKey contextKey = "permissions"
+
+// Middleware holds the access checks that need to look things up in the
+// store. Auth only needs the token, so it stays a plain function.
+type Middleware struct {
+	store *store.Store
+}
+
+func New(st *store.Store) *Middleware {
+	return &Middleware{store: st}
+}
+
+// projectIDFromRequest works out which project a request is about, either
+// from the {projectId} URL parameter or by looking up the owner of {fileId}.
+// On failure it writes the error response itself and returns false.
+func (m *Middleware) projectIDFromRequest(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	if projectIDStr := chi.URLParam(r, "projectId"); projectIDStr != "" {
+		projectID, err := uuid.Parse(projectIDStr)
+		if err != nil {
+			http.Error(w, "Invalid project ID format", http.StatusBadRequest)
+			return uuid.Nil, false
+		}
+		return projectID, true
+	}
+
+	// If no projectId, try to get it from a fileId
+	fileIDStr := chi.URLParam(r, "fileId")
+	if fileIDStr == "" {
+		http.Error(w, "Could not determine project context from URL", http.StatusBadRequest)
+		return uuid.Nil, false
+	}
+	fileID, err := uuid.Parse(fileIDStr)
+	if err != nil {
+		http.Error(w, "Invalid file ID format", http.StatusBadRequest)
+		return uuid.Nil, false
+	}
+
+	// Look up the project that owns this file
+	file, err := m.store.Files.Get(r.Context(), fileID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "File not found", http.StatusNotFound)
+			return uuid.Nil, false
+		}
+		http.Error(w, "Failed to determine project from file", http.StatusInternalServerError)
+		return uuid.Nil, false
+	}
+	return file.ProjectID, true
+}
+
+// RequirePermission is a middleware that checks if a user is a member of a
+// project whose role grants the given permission. The resolved project ID,
+// role and permission set are added to the request context.
+func (m *Middleware) RequirePermission(required permissions.Permission) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			userIDStr, ok := r.Context().Value(UserIDKey).(string)
+			userID, ok := r.Context().Value(UserIDKey).(string)
 			if !ok {
 				http.Error(w, "Could not retrieve user ID from context", http.StatusInternalServerError)
 				return
 			}
-			userID, _ := uuid.Parse(userIDStr)
-
-			var projectID uuid.UUID
-			var err error
 
-			// Determine how to get the project ID from the URL
-			projectIDStr := chi.URLParam(r, "projectId")
-			if projectIDStr != "" {
-				projectID, err = uuid.Parse(projectIDStr)
-				if err != nil {
-					http.Error(w, "Invalid project ID format", http.StatusBadRequest)
-					return
-				}
-			} else {
-				// If no projectId, try to get it from a fileId
-				fileIDStr := chi.URLParam(r, "fileId")
-				if fileIDStr != "" {
-					fileID, err := uuid.Parse(fileIDStr)
-					if err != nil {
-						http.Error(w, "Invalid file ID format", http.StatusBadRequest)
-						return
-					}
-					// Query the database to find the project_id for this file_id
-					query := `SELECT project_id FROM files WHERE id = $1`
-					err = database.DB.QueryRow(context.Background(), query, fileID).Scan(&projectID)
-					if err != nil {
-						if err == pgx.ErrNoRows {
-							http.Error(w, "File not found", http.StatusNotFound)
-							return
-						}
-						http.Error(w, "Failed to determine project from file", http.StatusInternalServerError)
-						return
-					}
-				} else {
-					http.Error(w, "Could not determine project context from URL", http.StatusBadRequest)
-					return
-				}
+			projectID, ok := m.projectIDFromRequest(w, r)
+			if !ok {
+				return
 			}
 
-			// Now check the user's role in the determined project
-			var userRole string
-			roleQuery := `SELECT role FROM project_members WHERE project_id = $1 AND user_id = $2`
-			err = database.DB.QueryRow(context.Background(), roleQuery, projectID, userID).Scan(&userRole)
-
+			role, perms, err := permissions.Resolve(r.Context(), m.store.Projects, projectID.String(), userID)
 			if err != nil {
-				if err == pgx.ErrNoRows {
+				if errors.Is(err, store.ErrNotFound) {
 					// User is not a member of this project
 					http.Error(w, "Forbidden: You are not a member of this project", http.StatusForbidden)
 					return
@@ -75,21 +92,15 @@ func ProjectMemberAuth(requiredRoles ...string) func(http.Handler) http.Handler
 				return
 			}
 
-			// Check if the user's role is in the list of required roles
-			isAllowed := false
-			for _, role := range requiredRoles {
-				if userRole == role {
-					isAllowed = true
-					break
-				}
-			}
-
-			if !isAllowed {
+			if !perms.Has(required) {
 				http.Error(w, "Forbidden: You do not have the required permissions for this action", http.StatusForbidden)
 				return
 			}
 
-			next.ServeHTTP(w, r)
+			ctx := context.WithValue(r.Context(), ProjectIDKey, projectID.String())
+			ctx = context.WithValue(ctx, ProjectRoleKey, role)
+			ctx = context.WithValue(ctx, PermissionsKey, perms)
+			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,13 @@
+package models
+
+import "github.com/google/uuid"
+
+// AccessRule is a single access override on a file or folder, for either a
+// role (SubjectType "role") or a user ("user").
+type AccessRule struct {
+	ID          uuid.UUID `json:"id"`
+	FileID      uuid.UUID `json:"fileId"`
+	SubjectType string    `json:"subjectType"`
+	Subject     string    `json:"subject"`
+	Access      string    `json:"access"`
+}
@@ -5,6 +5,14 @@ import (
 	"github.com/google/uuid"
 )
 
+// Blob describes the stored bytes of an uploaded binary file.
+type Blob struct {
+	Key         string `json:"-"`
+	ContentType string `json:"contentType"`
+	Size        int64  `json:"size"`
+	SHA256      string `json:"sha256"`
+}
+
 // FileNode represents a file or a folder in the project structure.
 type FileNode struct {
 	ID        uuid.UUID  `json:"id"`
@@ -13,8 +21,14 @@ type FileNode struct {
 	IsFolder  bool       `json:"isFolder"`
 	Name      string     `json:"name"`
 	Content   *string    `json:"content,omitempty"` // Pointer for NULL, omitempty for clean JSON
+	Blob      *Blob      `json:"blob,omitempty"`    // Set for uploaded binary files instead of Content
+	Revision  int64      `json:"revision"`          // Bumped by every change to the node
 	CreatedAt time.Time  `json:"createdAt"`
 	UpdatedAt time.Time  `json:"updatedAt"`
     // This will be populated by our handler to represent children in the tree
     Children []*FileNode `json:"children,omitempty"` 
+    // Full slash-separated path, filled in by the path-based file API
+    Path string `json:"path,omitempty"`
+    // Number of readable children of a folder, filled in by the tree API
+    ChildCount *int `json:"childCount,omitempty"`
 }
\ No newline at end of file
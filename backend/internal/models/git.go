@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GitLink ties a project to the git branch it was imported from. Head is the
+// commit the project's files were last imported from or committed as.
+type GitLink struct {
+	ProjectID uuid.UUID `json:"projectId"`
+	RemoteURL string    `json:"remoteUrl"`
+	Branch    string    `json:"branch"`
+	Head      string    `json:"head"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
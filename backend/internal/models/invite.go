@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ProjectInvite is a shareable code that grants a role on a project.
+// A nil MaxUses means unlimited uses, a nil ExpiresAt means it never expires
+// and a non-nil Email restricts the invite to the user with that address.
+type ProjectInvite struct {
+	ID        uuid.UUID  `json:"id"`
+	ProjectID uuid.UUID  `json:"projectId"`
+	Code      string     `json:"inviteCode"`
+	CreatedBy uuid.UUID  `json:"createdBy"`
+	Role      string     `json:"role"`
+	MaxUses   *int       `json:"maxUses"`
+	UseCount  int        `json:"useCount"`
+	Email     *string    `json:"email"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Organization groups users and projects. Members of an organization get
+// DefaultProjectRole on every project it owns unless they were added to a
+// project explicitly; org owners and admins get full control.
+type Organization struct {
+	ID                 uuid.UUID `json:"id"`
+	Name               string    `json:"name"`
+	DefaultProjectRole string    `json:"defaultProjectRole"`
+	CreatedAt          time.Time `json:"createdAt"`
+	UpdatedAt          time.Time `json:"updatedAt"`
+	// Role of the requesting user, filled in when listing their organizations.
+	Role string `json:"role,omitempty"`
+}
+
+type OrganizationMember struct {
+	UserID   uuid.UUID `json:"userId"`
+	Username string    `json:"username"`
+	Email    string    `json:"email"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joinedAt"`
+}
@@ -7,9 +7,26 @@ import (
 )
 
 type Project struct {
-	ID        uuid.UUID `json:"id"`
-	OwnerID   uuid.UUID `json:"ownerId"`
-	Name      string    `json:"name"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
-}
\ No newline at end of file
+	ID             uuid.UUID  `json:"id"`
+	OwnerID        uuid.UUID  `json:"ownerId"`
+	OrganizationID *uuid.UUID `json:"organizationId"`
+	ForkedFrom     *uuid.UUID `json:"forkedFrom,omitempty"`
+	IsTemplate     bool       `json:"isTemplate"`
+	Name           string     `json:"name"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+}
+
+// ProjectMember is a user with an explicit role on a project.
+type ProjectMember struct {
+	UserID   uuid.UUID `json:"userId"`
+	Username string    `json:"username"`
+	Email    string    `json:"email"`
+	Role     string    `json:"role"`
+}
+
+// ProjectRole is a custom role defined for a single project.
+type ProjectRole struct {
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+}
@@ -9,7 +9,10 @@ type User struct {
 	ID           uuid.UUID `json:"id"`
 	Email        string    `json:"email"`
 	Username     string    `json:"username"`
+	DisplayName  string    `json:"displayName"`
+	AvatarURL    string    `json:"avatarUrl"`
+	Timezone     string    `json:"timezone"`
 	PasswordHash string    `json:"-"` 
 	CreatedAt    time.Time `json:"createdAt"`
 	UpdatedAt    time.Time `json:"updatedAt"`
-}
\ No newline at end of file
+}
@@ -0,0 +1,139 @@
+// Package permissions maps project roles to the actions they allow.
+// Routes and the WebSocket hub ask for a Permission rather than a role name,
+// so adding a role never means touching the places that enforce access.
+package permissions
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"project-meetings/backend/internal/store"
+)
+
+type Permission string
+
+const (
+	ProjectRead    Permission = "project.read"
+	ProjectManage  Permission = "project.manage"
+	MembersManage  Permission = "members.manage"
+	FileWrite      Permission = "file.write"
+	FileDelete     Permission = "file.delete"
+	ExecRun        Permission = "exec.run"
+	WhiteboardEdit Permission = "whiteboard.edit"
+	CallJoin       Permission = "call.join"
+	AuditRead      Permission = "audit.read"
+	GitSync        Permission = "git.sync"
+)
+
+// All lists every permission known to the server.
+var All = []Permission{
+	ProjectRead, ProjectManage, MembersManage, FileWrite, FileDelete, ExecRun, WhiteboardEdit, CallJoin, AuditRead,
+	GitSync,
+}
+
+// OwnerRole is special: there is exactly one per project and it can only be
+// handed over through an ownership transfer.
+const OwnerRole = "owner"
+
+// BuiltinRoles are available in every project and cannot be redefined.
+var BuiltinRoles = map[string][]Permission{
+	OwnerRole: All,
+	"editor":  {ProjectRead, FileWrite, FileDelete, ExecRun, WhiteboardEdit, CallJoin},
+	"viewer":  {ProjectRead, CallJoin},
+}
+
+// ErrUnknownRole is returned when a role is neither built in nor defined
+// for the project.
+var ErrUnknownRole = errors.New("unknown role")
+
+// Set is the collection of permissions granted to a member.
+type Set map[Permission]bool
+
+func NewSet(perms ...Permission) Set {
+	s := make(Set, len(perms))
+	for _, p := range perms {
+		s[p] = true
+	}
+	return s
+}
+
+func (s Set) Has(p Permission) bool {
+	return s[p]
+}
+
+// Includes reports whether s grants every permission in other.
+func (s Set) Includes(other Set) bool {
+	for p := range other {
+		if !s[p] {
+			return false
+		}
+	}
+	return true
+}
+
+// List returns the permissions in a stable order, for JSON responses.
+func (s Set) List() []Permission {
+	list := make([]Permission, 0, len(s))
+	for p := range s {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i] < list[j] })
+	return list
+}
+
+// Valid reports whether p is a known permission.
+func Valid(p Permission) bool {
+	for _, known := range All {
+		if p == known {
+			return true
+		}
+	}
+	return false
+}
+
+// IsBuiltin reports whether role is one of the built-in roles.
+func IsBuiltin(role string) bool {
+	_, ok := BuiltinRoles[role]
+	return ok
+}
+
+// ForRole returns the permissions granted by a role in a project, looking up
+// custom roles in the project's role store.
+func ForRole(ctx context.Context, projects store.ProjectStore, projectID, role string) (Set, error) {
+	if perms, ok := BuiltinRoles[role]; ok {
+		return NewSet(perms...), nil
+	}
+
+	names, err := projects.RolePermissions(ctx, projectID, role)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, ErrUnknownRole
+		}
+		return nil, err
+	}
+
+	set := make(Set, len(names))
+	for _, name := range names {
+		set[Permission(name)] = true
+	}
+	return set, nil
+}
+
+// Resolve looks up a user's role in a project and the permissions it grants.
+// An explicit project membership always wins. Otherwise, if the project
+// belongs to an organization, org owners and admins act as project owners and
+// other org members get the organization's default project role.
+// It returns store.ErrNotFound if the user has no access at all.
+func Resolve(ctx context.Context, projects store.ProjectStore, projectID, userID string) (string, Set, error) {
+	role, err := projects.ResolveRole(ctx, projectID, userID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	perms, err := ForRole(ctx, projects, projectID, role)
+	if err != nil {
+		return "", nil, err
+	}
+	return role, perms, nil
+}
@@ -0,0 +1,25 @@
+package permissions
+
+import "testing"
+
+func TestIncludes(t *testing.T) {
+	editor := NewSet(BuiltinRoles["editor"]...)
+	viewer := NewSet(BuiltinRoles["viewer"]...)
+	owner := NewSet(BuiltinRoles[OwnerRole]...)
+	for _, c := range []struct {
+		name       string
+		set, other Set
+		want       bool
+	}{
+		{"editor includes viewer", editor, viewer, true},
+		{"viewer lacks editor", viewer, editor, false},
+		{"editor lacks owner", editor, owner, false},
+		{"owner includes editor", owner, editor, true},
+		{"anything includes nothing", NewSet(), NewSet(), true},
+		{"nothing lacks one", NewSet(), NewSet(ProjectRead), false},
+	} {
+		if got := c.set.Includes(c.other); got != c.want {
+			t.Errorf("%s: Includes = %v", c.name, got)
+		}
+	}
+}
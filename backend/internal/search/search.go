@@ -0,0 +1,216 @@
+// Package search finds lines in project files that match a user query.
+//
+// Stores use their indexes to narrow a project down to candidate files; a
+// Matcher then confirms each candidate and picks out the matching lines. The
+// same Matcher runs over live editor contents, which never reach the store
+// until they are saved.
+package search
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type Mode string
+
+const (
+	// ModeText matches files containing every word of the query, in any order.
+	ModeText Mode = "text"
+	// ModeLiteral matches the query as an exact substring.
+	ModeLiteral Mode = "literal"
+	// ModeRegex matches the query as an RE2 regular expression.
+	ModeRegex Mode = "regex"
+)
+
+// ParseMode converts "text", "literal" or "regex" to a Mode.
+func ParseMode(s string) (Mode, bool) {
+	switch m := Mode(s); m {
+	case ModeText, ModeLiteral, ModeRegex:
+		return m, true
+	}
+	return "", false
+}
+
+// maxLineLength caps the text returned for one line, so a match in a
+// minified file doesn't send the whole file back.
+const maxLineLength = 500
+
+var ErrEmptyQuery = errors.New("search query has no words")
+
+type Query struct {
+	Text          string
+	Mode          Mode
+	CaseSensitive bool
+}
+
+// Terms returns the lower-cased words of a text query.
+func (q Query) Terms() []string {
+	return strings.FieldsFunc(strings.ToLower(q.Text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Literals returns substrings every matching file must contain, ignoring
+// case, so a store can narrow candidates down with a substring index. A
+// regex with no required literal (e.g. `\d+`) returns none.
+func (q Query) Literals() []string {
+	switch q.Mode {
+	case ModeText:
+		return q.Terms()
+	case ModeLiteral:
+		return []string{q.Text}
+	}
+	re, err := syntax.Parse(q.Text, syntax.Perl)
+	if err != nil {
+		return nil
+	}
+	return requiredLiterals(re.Simplify())
+}
+
+func requiredLiterals(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return []string{string(re.Rune)}
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredLiterals(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min > 0 {
+			return requiredLiterals(re.Sub[0])
+		}
+	case syntax.OpConcat:
+		var literals []string
+		for _, sub := range re.Sub {
+			literals = append(literals, requiredLiterals(sub)...)
+		}
+		return literals
+	}
+	return nil
+}
+
+// Line is one matching line with the lines around it.
+type Line struct {
+	Number int      `json:"line"`
+	Text   string   `json:"text"`
+	Before []string `json:"before"`
+	After  []string `json:"after"`
+}
+
+// Matcher is a compiled Query.
+type Matcher struct {
+	query Query
+	terms []string
+	line  *regexp.Regexp
+}
+
+// Compile validates a query and prepares it for matching.
+func Compile(q Query) (*Matcher, error) {
+	m := &Matcher{query: q}
+	var pattern string
+	switch q.Mode {
+	case ModeText:
+		m.terms = q.Terms()
+		if len(m.terms) == 0 {
+			return nil, ErrEmptyQuery
+		}
+		quoted := make([]string, len(m.terms))
+		for i, term := range m.terms {
+			quoted[i] = regexp.QuoteMeta(term)
+		}
+		// Word queries are never case sensitive, like the full-text index.
+		pattern = "(?i)" + strings.Join(quoted, "|")
+	case ModeLiteral, ModeRegex:
+		if q.Text == "" {
+			return nil, ErrEmptyQuery
+		}
+		pattern = q.Text
+		if q.Mode == ModeLiteral {
+			pattern = regexp.QuoteMeta(q.Text)
+		}
+		if !q.CaseSensitive {
+			pattern = "(?i)" + pattern
+		}
+	default:
+		return nil, fmt.Errorf("unknown search mode %q", q.Mode)
+	}
+
+	// (?m) lets ^ and $ anchor at line ends when a whole file is checked.
+	re, err := regexp.Compile("(?m)" + pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression: %w", err)
+	}
+	m.line = re
+	return m, nil
+}
+
+func (m *Matcher) Query() Query {
+	return m.query
+}
+
+// MatchesFile reports whether content as a whole satisfies the query. For a
+// text query every word has to appear somewhere in the file.
+func (m *Matcher) MatchesFile(content string) bool {
+	if m.query.Mode != ModeText {
+		return m.line.MatchString(content)
+	}
+	lower := strings.ToLower(content)
+	for _, term := range m.terms {
+		if !strings.Contains(lower, term) {
+			return false
+		}
+	}
+	return true
+}
+
+// Grep returns up to limit matching lines of content, numbered from 1, each
+// with up to contextLines lines before and after it.
+func (m *Matcher) Grep(content string, contextLines, limit int) []Line {
+	lines := strings.Split(content, "\n")
+	matches := make([]Line, 0)
+	for i, text := range lines {
+		if len(matches) >= limit {
+			break
+		}
+		if !m.line.MatchString(text) {
+			continue
+		}
+		from, to := i-contextLines, i+contextLines+1
+		if from < 0 {
+			from = 0
+		}
+		if to > len(lines) {
+			to = len(lines)
+		}
+		matches = append(matches, Line{
+			Number: i + 1,
+			Text:   truncate(text),
+			Before: truncateAll(lines[from:i]),
+			After:  truncateAll(lines[i+1 : to]),
+		})
+	}
+	return matches
+}
+
+func truncate(line string) string {
+	line = strings.TrimSuffix(line, "\r")
+	if len(line) <= maxLineLength {
+		return line
+	}
+	cut := maxLineLength
+	for cut > 0 && !utf8.RuneStart(line[cut]) {
+		cut--
+	}
+	return line[:cut]
+}
+
+func truncateAll(lines []string) []string {
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		out[i] = truncate(line)
+	}
+	return out
+}
@@ -0,0 +1,113 @@
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"project-meetings/backend/internal/audit"
+)
+
+type auditStore struct{ *db }
+
+func optionalID(id string) (*string, bool) {
+	if id == "" {
+		return nil, true
+	}
+	if _, ok := parseIDs(id); !ok {
+		return nil, false
+	}
+	return &id, true
+}
+
+func (s *auditStore) Append(ctx context.Context, e audit.Event) error {
+	details, err := audit.EncodeDetails(e.Details)
+	if err != nil {
+		return fmt.Errorf("encode details: %w", err)
+	}
+	projectID, ok := optionalID(e.ProjectID)
+	if !ok {
+		return fmt.Errorf("invalid project id %q", e.ProjectID)
+	}
+	actorID, ok := optionalID(e.ActorID)
+	if !ok {
+		return fmt.Errorf("invalid actor id %q", e.ActorID)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastAuditEntry++
+	s.auditLog = append(s.auditLog, audit.Entry{
+		ID:         s.lastAuditEntry,
+		ProjectID:  projectID,
+		ActorID:    actorID,
+		Action:     e.Action,
+		TargetType: e.TargetType,
+		TargetID:   e.TargetID,
+		IP:         e.IP,
+		UserAgent:  e.UserAgent,
+		Details:    details,
+		CreatedAt:  time.Now(),
+	})
+	return nil
+}
+
+// auditFilter evaluates an audit.Filter the way the Postgres query does.
+type auditFilter audit.Filter
+
+func (f auditFilter) matches(e audit.Entry) bool {
+	if e.ProjectID == nil || *e.ProjectID != f.ProjectID {
+		return false
+	}
+	if f.Action != "" {
+		if prefix := strings.TrimSuffix(f.Action, "*"); strings.HasSuffix(f.Action, ".*") {
+			if !strings.HasPrefix(e.Action, prefix) {
+				return false
+			}
+		} else if e.Action != f.Action {
+			return false
+		}
+	}
+	if f.ActorID != "" && (e.ActorID == nil || *e.ActorID != f.ActorID) {
+		return false
+	}
+	if f.TargetType != "" && e.TargetType != f.TargetType {
+		return false
+	}
+	if f.TargetID != "" && e.TargetID != f.TargetID {
+		return false
+	}
+	if f.Since != nil && e.CreatedAt.Before(*f.Since) {
+		return false
+	}
+	if f.Until != nil && !e.CreatedAt.Before(*f.Until) {
+		return false
+	}
+	return f.Before <= 0 || e.ID < f.Before
+}
+
+func (s *auditStore) List(ctx context.Context, f audit.Filter) ([]audit.Entry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// The log is appended in ID order, so walking it backwards is newest first.
+	entries := make([]audit.Entry, 0, f.Limit)
+	for i := len(s.auditLog) - 1; i >= 0 && len(entries) < f.Limit; i-- {
+		e := s.auditLog[i]
+		if !auditFilter(f).matches(e) {
+			continue
+		}
+		if e.ActorID != nil {
+			if ids, ok := parseIDs(*e.ActorID); ok {
+				if u, ok := s.users[ids[0]]; ok {
+					name := u.Username
+					e.ActorName = &name
+				}
+			}
+		}
+		e.Details = append([]byte(nil), e.Details...)
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
@@ -0,0 +1,45 @@
+package memstore
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+
+	"project-meetings/backend/internal/store"
+)
+
+// blobStore keeps blobs in memory. It has its own lock, like a separate
+// storage service would.
+type blobStore struct {
+	mu    sync.RWMutex
+	blobs map[string][]byte
+}
+
+func (s *blobStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	data, err := io.ReadAll(io.LimitReader(r, size))
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blobs[key] = data
+	return nil
+}
+
+func (s *blobStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	data, ok := s.blobs[key]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s *blobStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.blobs, key)
+	return nil
+}
@@ -0,0 +1,44 @@
+package memstore
+
+import (
+	"context"
+
+	"project-meetings/backend/internal/store"
+
+	"github.com/google/uuid"
+)
+
+type draftStore struct{ *db }
+
+func (s *draftStore) Get(ctx context.Context, fileID uuid.UUID) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	content, ok := s.drafts[fileID]
+	if !ok {
+		return "", store.ErrNotFound
+	}
+	return content, nil
+}
+
+func (s *draftStore) List(ctx context.Context, projectID uuid.UUID) (map[uuid.UUID]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	drafts := make(map[uuid.UUID]string)
+	for id, content := range s.drafts {
+		if s.files[id].ProjectID == projectID {
+			drafts[id] = content
+		}
+	}
+	return drafts, nil
+}
+
+func (s *draftStore) Save(ctx context.Context, projectID uuid.UUID, drafts map[uuid.UUID]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, content := range drafts {
+		if f, ok := s.files[id]; ok && f.ProjectID == projectID {
+			s.drafts[id] = content
+		}
+	}
+	return nil
+}
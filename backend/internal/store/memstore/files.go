@@ -0,0 +1,451 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"project-meetings/backend/internal/models"
+	"project-meetings/backend/internal/search"
+	"project-meetings/backend/internal/store"
+
+	"github.com/google/uuid"
+)
+
+type fileStore struct{ *db }
+
+// copyNode detaches a node from the stored one so callers can't mutate it.
+func copyNode(node models.FileNode) models.FileNode {
+	node.Content = copyString(node.Content)
+	if node.Blob != nil {
+		blob := *node.Blob
+		node.Blob = &blob
+	}
+	if node.ParentID != nil {
+		parent := *node.ParentID
+		node.ParentID = &parent
+	}
+	node.Children = nil
+	return node
+}
+
+func (s *fileStore) List(ctx context.Context, projectID uuid.UUID) ([]models.FileNode, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	nodes := make([]models.FileNode, 0)
+	for _, node := range s.files {
+		if node.ProjectID == projectID {
+			nodes = append(nodes, copyNode(node))
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+	return nodes, nil
+}
+
+func (s *fileStore) Outline(ctx context.Context, projectID uuid.UUID) ([]models.FileNode, error) {
+	nodes, err := s.List(ctx, projectID)
+	for i := range nodes {
+		nodes[i].Content = nil
+	}
+	return nodes, err
+}
+
+func (s *fileStore) Children(ctx context.Context, projectID uuid.UUID, parentID *uuid.UUID, after string, limit int) ([]models.FileNode, error) {
+	nodes, err := s.Outline(ctx, projectID)
+	children := make([]models.FileNode, 0)
+	for _, node := range nodes {
+		if sameParent(node.ParentID, parentID) && node.Name > after && len(children) < limit {
+			children = append(children, node)
+		}
+	}
+	return children, err
+}
+
+func (s *fileStore) ResolvePath(ctx context.Context, projectID uuid.UUID, segments []string) (models.FileNode, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var node models.FileNode
+	var parentID *uuid.UUID
+	for _, name := range segments {
+		child, ok := s.childLocked(projectID, parentID, name)
+		if !ok {
+			return models.FileNode{}, store.ErrNotFound
+		}
+		node = child
+		parentID = &child.ID
+	}
+	if parentID == nil {
+		return models.FileNode{}, store.ErrNotFound
+	}
+	return copyNode(node), nil
+}
+
+func (s *fileStore) Search(ctx context.Context, projectID uuid.UUID, q search.Query) ([]models.FileNode, error) {
+	matcher, err := search.Compile(q)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := s.List(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	matches := make([]models.FileNode, 0)
+	for _, node := range nodes {
+		if !node.IsFolder && node.Content != nil && matcher.MatchesFile(*node.Content) {
+			matches = append(matches, node)
+		}
+	}
+	return matches, nil
+}
+
+func (s *fileStore) Get(ctx context.Context, fileID uuid.UUID) (models.FileNode, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	node, ok := s.files[fileID]
+	if !ok {
+		return models.FileNode{}, store.ErrNotFound
+	}
+	return copyNode(node), nil
+}
+
+func (s *fileStore) Create(ctx context.Context, node *models.FileNode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.projects[node.ProjectID]; !ok {
+		return store.ErrNotFound
+	}
+	if node.ParentID != nil {
+		if _, ok := s.files[*node.ParentID]; !ok {
+			return store.ErrNotFound
+		}
+	}
+	if _, taken := s.childLocked(node.ProjectID, node.ParentID, node.Name); taken {
+		return store.ErrConflict
+	}
+	now := time.Now()
+	node.ID = uuid.New()
+	node.Revision = 1
+	node.CreatedAt = now
+	node.UpdatedAt = now
+	s.files[node.ID] = copyNode(*node)
+	return nil
+}
+
+// update applies fn to a stored node at revision rev (any revision when
+// zero) and bumps its revision and UpdatedAt, unless fn fails.
+func (s *fileStore) update(fileID uuid.UUID, rev int64, fn func(node *models.FileNode) error) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	node, ok := s.files[fileID]
+	if !ok {
+		return 0, store.ErrNotFound
+	}
+	if rev != 0 && node.Revision != rev {
+		return 0, store.ErrStale
+	}
+	if err := fn(&node); err != nil {
+		return 0, err
+	}
+	node.Revision++
+	node.UpdatedAt = time.Now()
+	s.files[fileID] = node
+	return node.Revision, nil
+}
+
+func (s *fileStore) UpdateContent(ctx context.Context, fileID uuid.UUID, content string, rev int64) (int64, error) {
+	return s.update(fileID, rev, func(node *models.FileNode) error {
+		if node.IsFolder {
+			return store.ErrNotFound
+		}
+		s.orphanBlobLocked(node.Blob)
+		node.Content = &content
+		node.Blob = nil
+		// Saving supersedes the draft, like the files_discard_draft trigger.
+		delete(s.drafts, node.ID)
+		return nil
+	})
+}
+
+func (s *fileStore) Rename(ctx context.Context, fileID uuid.UUID, name string, rev int64) (int64, error) {
+	return s.update(fileID, rev, func(node *models.FileNode) error {
+		if sibling, taken := s.childLocked(node.ProjectID, node.ParentID, name); taken && sibling.ID != node.ID {
+			return store.ErrConflict
+		}
+		node.Name = name
+		return nil
+	})
+}
+
+func (s *fileStore) Delete(ctx context.Context, fileID uuid.UUID, rev int64) (models.FileNode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	node, ok := s.files[fileID]
+	if !ok {
+		return models.FileNode{}, store.ErrNotFound
+	}
+	if rev != 0 && node.Revision != rev {
+		return models.FileNode{}, store.ErrStale
+	}
+	s.deleteFileLocked(fileID)
+	return copyNode(node), nil
+}
+
+func (s *fileStore) Parents(ctx context.Context, projectID uuid.UUID) (map[uuid.UUID]*uuid.UUID, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	parents := make(map[uuid.UUID]*uuid.UUID)
+	for id, node := range s.files {
+		if node.ProjectID == projectID {
+			parents[id] = copyNode(node).ParentID
+		}
+	}
+	return parents, nil
+}
+
+func (s *fileStore) Ancestry(ctx context.Context, fileID uuid.UUID) (map[uuid.UUID]*uuid.UUID, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	parents := make(map[uuid.UUID]*uuid.UUID)
+	current := &fileID
+	for current != nil {
+		if _, seen := parents[*current]; seen {
+			break
+		}
+		node, ok := s.files[*current]
+		if !ok {
+			break
+		}
+		parents[node.ID] = copyNode(node).ParentID
+		current = node.ParentID
+	}
+	return parents, nil
+}
+
+func (s *fileStore) ChildParents(ctx context.Context, parentIDs []uuid.UUID) (map[uuid.UUID]*uuid.UUID, error) {
+	wanted := make(map[uuid.UUID]bool, len(parentIDs))
+	for _, id := range parentIDs {
+		wanted[id] = true
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	parents := make(map[uuid.UUID]*uuid.UUID)
+	for id, node := range s.files {
+		if node.ParentID != nil && wanted[*node.ParentID] {
+			parents[id] = copyNode(node).ParentID
+		}
+	}
+	return parents, nil
+}
+
+func (s *fileStore) ProjectRules(ctx context.Context, projectID uuid.UUID) ([]models.AccessRule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rules := make([]models.AccessRule, 0)
+	for _, r := range s.rules {
+		if r.projectID == projectID {
+			rules = append(rules, r.AccessRule)
+		}
+	}
+	return rules, nil
+}
+
+func (s *fileStore) Rules(ctx context.Context, fileIDs []uuid.UUID) ([]models.AccessRule, error) {
+	wanted := make(map[uuid.UUID]bool, len(fileIDs))
+	for _, id := range fileIDs {
+		wanted[id] = true
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rules := make([]models.AccessRule, 0)
+	for _, r := range s.rules {
+		if wanted[r.FileID] {
+			rules = append(rules, r.AccessRule)
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].SubjectType != rules[j].SubjectType {
+			return rules[i].SubjectType < rules[j].SubjectType
+		}
+		return rules[i].Subject < rules[j].Subject
+	})
+	return rules, nil
+}
+
+func (s *fileStore) ReplaceRules(ctx context.Context, projectID string, fileID uuid.UUID, rules []models.AccessRule) error {
+	ids, ok := parseIDs(projectID)
+	if !ok {
+		return store.ErrNotFound
+	}
+
+	type subject struct{ kind, name string }
+	seen := make(map[subject]bool, len(rules))
+	for _, r := range rules {
+		key := subject{r.SubjectType, r.Subject}
+		if seen[key] {
+			return store.ErrConflict
+		}
+		seen[key] = true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.files[fileID]; !ok {
+		return store.ErrNotFound
+	}
+	for id, r := range s.rules {
+		if r.FileID == fileID {
+			delete(s.rules, id)
+		}
+	}
+	for _, r := range rules {
+		r.ID = uuid.New()
+		r.FileID = fileID
+		s.rules[r.ID] = rule{projectID: ids[0], AccessRule: r}
+	}
+	return nil
+}
+
+// storageUsedLocked sums the blob sizes of a project's files, skipping one.
+func (s *fileStore) storageUsedLocked(projectID, except uuid.UUID) int64 {
+	var used int64
+	for id, node := range s.files {
+		if node.ProjectID == projectID && node.Blob != nil && id != except {
+			used += node.Blob.Size
+		}
+	}
+	return used
+}
+
+func (s *fileStore) CreateBlob(ctx context.Context, node *models.FileNode, quota int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.projects[node.ProjectID]; !ok {
+		return store.ErrNotFound
+	}
+	if node.ParentID != nil {
+		if _, ok := s.files[*node.ParentID]; !ok {
+			return store.ErrNotFound
+		}
+	}
+	if _, taken := s.childLocked(node.ProjectID, node.ParentID, node.Name); taken {
+		return store.ErrConflict
+	}
+	if s.storageUsedLocked(node.ProjectID, uuid.Nil)+node.Blob.Size > quota {
+		return store.ErrQuotaExceeded
+	}
+	now := time.Now()
+	node.ID = uuid.New()
+	node.Content = nil
+	node.Revision = 1
+	node.CreatedAt = now
+	node.UpdatedAt = now
+	s.files[node.ID] = copyNode(*node)
+	return nil
+}
+
+func (s *fileStore) ReplaceBlob(ctx context.Context, fileID uuid.UUID, blob models.Blob, quota int64, rev int64) (int64, error) {
+	return s.update(fileID, rev, func(node *models.FileNode) error {
+		if node.IsFolder {
+			return store.ErrNotFound
+		}
+		if s.storageUsedLocked(node.ProjectID, node.ID)+blob.Size > quota {
+			return store.ErrQuotaExceeded
+		}
+		s.orphanBlobLocked(node.Blob)
+		node.Content = nil
+		node.Blob = &blob
+		delete(s.drafts, node.ID)
+		return nil
+	})
+}
+
+func (s *fileStore) StorageUsed(ctx context.Context, projectID uuid.UUID) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.storageUsedLocked(projectID, uuid.Nil), nil
+}
+
+func (s *fileStore) OrphanedBlobs(ctx context.Context, limit int) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if limit > len(s.orphanedBlobs) {
+		limit = len(s.orphanedBlobs)
+	}
+	return copyStrings(s.orphanedBlobs[:limit]), nil
+}
+
+func (s *fileStore) ForgetOrphanedBlobs(ctx context.Context, keys []string) error {
+	forget := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		forget[key] = true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := s.orphanedBlobs[:0]
+	for _, key := range s.orphanedBlobs {
+		if !forget[key] {
+			kept = append(kept, key)
+		}
+	}
+	s.orphanedBlobs = kept
+	return nil
+}
+
+func (s *fileStore) ReplaceTree(ctx context.Context, projectID uuid.UUID, nodes []models.FileNode, quota int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.projects[projectID]; !ok {
+		return store.ErrNotFound
+	}
+	size, err := s.checkTreeLocked(projectID, nodes, false)
+	if err != nil {
+		return err
+	}
+	if size > quota {
+		return store.ErrQuotaExceeded
+	}
+
+	keep := make(map[uuid.UUID]bool, len(nodes))
+	for _, node := range nodes {
+		keep[node.ID] = true
+	}
+	var kept []rule
+	for _, r := range s.rules {
+		if r.projectID == projectID && keep[r.FileID] {
+			kept = append(kept, r)
+		}
+	}
+	for id, node := range s.files {
+		if node.ProjectID == projectID && node.ParentID == nil {
+			s.deleteFileLocked(id)
+		}
+	}
+	s.insertTreeLocked(projectID, nodes)
+	for _, r := range kept {
+		s.rules[r.ID] = r
+	}
+	return nil
+}
+
+func (s *fileStore) CreateTree(ctx context.Context, projectID uuid.UUID, nodes []models.FileNode, quota int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.projects[projectID]; !ok {
+		return store.ErrNotFound
+	}
+	size, err := s.checkTreeLocked(projectID, nodes, true)
+	if err != nil {
+		return err
+	}
+	if s.storageUsedLocked(projectID, uuid.Nil)+size > quota {
+		return store.ErrQuotaExceeded
+	}
+	s.insertTreeLocked(projectID, nodes)
+	return nil
+}
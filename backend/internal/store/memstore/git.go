@@ -0,0 +1,34 @@
+package memstore
+
+import (
+	"context"
+	"time"
+
+	"project-meetings/backend/internal/models"
+	"project-meetings/backend/internal/store"
+
+	"github.com/google/uuid"
+)
+
+type gitStore struct{ *db }
+
+func (s *gitStore) Link(ctx context.Context, projectID uuid.UUID) (models.GitLink, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	link, ok := s.gitLinks[projectID]
+	if !ok {
+		return models.GitLink{}, store.ErrNotFound
+	}
+	return link, nil
+}
+
+func (s *gitStore) SaveLink(ctx context.Context, link *models.GitLink) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.projects[link.ProjectID]; !ok {
+		return store.ErrNotFound
+	}
+	link.UpdatedAt = time.Now()
+	s.gitLinks[link.ProjectID] = *link
+	return nil
+}
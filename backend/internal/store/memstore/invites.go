@@ -0,0 +1,119 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"project-meetings/backend/internal/models"
+	"project-meetings/backend/internal/store"
+
+	"github.com/google/uuid"
+)
+
+type inviteStore struct{ *db }
+
+// copyInvite detaches an invite's pointer fields from the stored one.
+func copyInvite(inv models.ProjectInvite) models.ProjectInvite {
+	if inv.MaxUses != nil {
+		maxUses := *inv.MaxUses
+		inv.MaxUses = &maxUses
+	}
+	inv.Email = copyString(inv.Email)
+	if inv.ExpiresAt != nil {
+		expiresAt := *inv.ExpiresAt
+		inv.ExpiresAt = &expiresAt
+	}
+	return inv
+}
+
+func (s *inviteStore) Create(ctx context.Context, inv *models.ProjectInvite) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.projects[inv.ProjectID]; !ok {
+		return store.ErrNotFound
+	}
+	for _, existing := range s.invites {
+		if existing.Code == inv.Code {
+			return store.ErrConflict
+		}
+	}
+	inv.ID = uuid.New()
+	inv.UseCount = 0
+	inv.CreatedAt = time.Now()
+	s.invites[inv.ID] = &invite{ProjectInvite: copyInvite(*inv)}
+	return nil
+}
+
+func (s *inviteStore) ListActive(ctx context.Context, projectID string) ([]models.ProjectInvite, error) {
+	invites := make([]models.ProjectInvite, 0)
+	ids, ok := parseIDs(projectID)
+	if !ok {
+		return invites, nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	for _, inv := range s.invites {
+		if inv.ProjectID != ids[0] || inv.revoked {
+			continue
+		}
+		if inv.ExpiresAt != nil && !inv.ExpiresAt.After(now) {
+			continue
+		}
+		if inv.MaxUses != nil && inv.UseCount >= *inv.MaxUses {
+			continue
+		}
+		invites = append(invites, copyInvite(inv.ProjectInvite))
+	}
+	sort.Slice(invites, func(i, j int) bool { return invites[i].CreatedAt.After(invites[j].CreatedAt) })
+	return invites, nil
+}
+
+func (s *inviteStore) Revoke(ctx context.Context, projectID, inviteID string) error {
+	ids, ok := parseIDs(projectID, inviteID)
+	if !ok {
+		return store.ErrNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inv, ok := s.invites[ids[1]]
+	if !ok || inv.ProjectID != ids[0] || inv.revoked {
+		return store.ErrNotFound
+	}
+	inv.revoked = true
+	return nil
+}
+
+func (s *inviteStore) Accept(ctx context.Context, code, userID string, check func(models.ProjectInvite) error) (models.ProjectInvite, bool, error) {
+	ids, ok := parseIDs(userID)
+	if !ok {
+		return models.ProjectInvite{}, false, store.ErrNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var found *invite
+	for _, inv := range s.invites {
+		if inv.Code == code && !inv.revoked {
+			found = inv
+			break
+		}
+	}
+	if found == nil {
+		return models.ProjectInvite{}, false, store.ErrNotFound
+	}
+	if err := check(copyInvite(found.ProjectInvite)); err != nil {
+		return copyInvite(found.ProjectInvite), false, err
+	}
+
+	members := s.members[found.ProjectID]
+	if _, member := members[ids[0]]; member {
+		return copyInvite(found.ProjectInvite), false, nil
+	}
+	members[ids[0]] = membership{role: found.Role, joinedAt: time.Now()}
+	found.UseCount++
+	return copyInvite(found.ProjectInvite), true, nil
+}
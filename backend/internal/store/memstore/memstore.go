@@ -0,0 +1,262 @@
+// Package memstore implements the store interfaces in memory. It mirrors the
+// constraints and cascades of the Postgres schema closely enough for handler
+// tests and for running the server without a database.
+package memstore
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"project-meetings/backend/internal/audit"
+	"project-meetings/backend/internal/models"
+	"project-meetings/backend/internal/store"
+
+	"github.com/google/uuid"
+)
+
+type emailChange struct {
+	newEmail  string
+	tokenHash string
+	expiresAt time.Time
+}
+
+type membership struct {
+	role     string
+	joinedAt time.Time
+}
+
+type invite struct {
+	models.ProjectInvite
+	revoked bool
+}
+
+type rule struct {
+	projectID uuid.UUID
+	models.AccessRule
+}
+
+// db holds every table behind one lock, so multi-table operations are as
+// atomic as their Postgres transactions.
+type db struct {
+	mu sync.RWMutex
+
+	users        map[uuid.UUID]models.User
+	emailChanges map[uuid.UUID]emailChange
+
+	projects       map[uuid.UUID]models.Project
+	members        map[uuid.UUID]map[uuid.UUID]membership // project -> user
+	roles          map[uuid.UUID]map[string][]string      // project -> role -> permissions
+	invites        map[uuid.UUID]*invite
+	files          map[uuid.UUID]models.FileNode
+	rules          map[uuid.UUID]rule
+	shapes         map[uuid.UUID]map[string]json.RawMessage
+	gitLinks       map[uuid.UUID]models.GitLink
+	drafts         map[uuid.UUID]string
+	organizations  map[uuid.UUID]models.Organization
+	orgMembers     map[uuid.UUID]map[uuid.UUID]membership // organization -> user
+	auditLog       []audit.Entry
+	lastAuditEntry int64
+	orphanedBlobs  []string
+}
+
+// New returns an empty in-memory Store.
+func New() *store.Store {
+	d := &db{
+		users:         make(map[uuid.UUID]models.User),
+		emailChanges:  make(map[uuid.UUID]emailChange),
+		projects:      make(map[uuid.UUID]models.Project),
+		members:       make(map[uuid.UUID]map[uuid.UUID]membership),
+		roles:         make(map[uuid.UUID]map[string][]string),
+		invites:       make(map[uuid.UUID]*invite),
+		files:         make(map[uuid.UUID]models.FileNode),
+		rules:         make(map[uuid.UUID]rule),
+		shapes:        make(map[uuid.UUID]map[string]json.RawMessage),
+		gitLinks:      make(map[uuid.UUID]models.GitLink),
+		drafts:        make(map[uuid.UUID]string),
+		organizations: make(map[uuid.UUID]models.Organization),
+		orgMembers:    make(map[uuid.UUID]map[uuid.UUID]membership),
+	}
+	return &store.Store{
+		Users:         &userStore{d},
+		Projects:      &projectStore{d},
+		Files:         &fileStore{d},
+		Whiteboards:   &whiteboardStore{d},
+		Invites:       &inviteStore{d},
+		Organizations: &organizationStore{d},
+		Audit:         &auditStore{d},
+		Git:           &gitStore{d},
+		Drafts:        &draftStore{d},
+		Blobs:         &blobStore{blobs: make(map[string][]byte)},
+	}
+}
+
+// parseIDs parses string IDs, reporting false if any is malformed. A
+// malformed ID can never match a row, so callers treat it as not found.
+func parseIDs(ids ...string) ([]uuid.UUID, bool) {
+	parsed := make([]uuid.UUID, len(ids))
+	for i, id := range ids {
+		u, err := uuid.Parse(id)
+		if err != nil {
+			return nil, false
+		}
+		parsed[i] = u
+	}
+	return parsed, true
+}
+
+func copyString(s *string) *string {
+	if s == nil {
+		return nil
+	}
+	c := *s
+	return &c
+}
+
+func copyStrings(s []string) []string {
+	return append([]string(nil), s...)
+}
+
+// deleteProjectLocked removes a project and everything that cascades from it.
+func (d *db) deleteProjectLocked(projectID uuid.UUID) {
+	delete(d.projects, projectID)
+	delete(d.members, projectID)
+	delete(d.roles, projectID)
+	delete(d.shapes, projectID)
+	delete(d.gitLinks, projectID)
+	for id, p := range d.projects {
+		if p.ForkedFrom != nil && *p.ForkedFrom == projectID {
+			p.ForkedFrom = nil
+			d.projects[id] = p
+		}
+	}
+	for id, inv := range d.invites {
+		if inv.ProjectID == projectID {
+			delete(d.invites, id)
+		}
+	}
+	for id, node := range d.files {
+		if node.ProjectID == projectID {
+			d.orphanBlobLocked(node.Blob)
+			delete(d.files, id)
+			delete(d.drafts, id)
+		}
+	}
+	for id, r := range d.rules {
+		if r.projectID == projectID {
+			delete(d.rules, id)
+		}
+	}
+}
+
+// childLocked finds the node called name directly under parentID, or at the
+// top level of the project when parentID is nil.
+func (d *db) childLocked(projectID uuid.UUID, parentID *uuid.UUID, name string) (models.FileNode, bool) {
+	for _, node := range d.files {
+		if node.ProjectID != projectID || node.Name != name {
+			continue
+		}
+		if sameParent(node.ParentID, parentID) {
+			return node, true
+		}
+	}
+	return models.FileNode{}, false
+}
+
+// checkTreeLocked validates nodes about to be inserted into a project the
+// way the schema would and returns the bytes of their blobs. With keep set
+// they join the project's existing nodes; otherwise they replace them.
+func (d *db) checkTreeLocked(projectID uuid.UUID, nodes []models.FileNode, keep bool) (int64, error) {
+	type siblingKey struct {
+		parent uuid.UUID
+		name   string
+	}
+	seen := make(map[uuid.UUID]bool, len(nodes))
+	names := make(map[siblingKey]bool, len(nodes))
+	var size int64
+	for _, node := range nodes {
+		key := siblingKey{name: node.Name}
+		if node.ParentID != nil {
+			parent, existing := d.files[*node.ParentID]
+			existing = keep && existing && parent.ProjectID == projectID && parent.IsFolder
+			if !seen[*node.ParentID] && !existing {
+				return 0, store.ErrNotFound
+			}
+			key.parent = *node.ParentID
+		}
+		if seen[node.ID] || names[key] {
+			return 0, store.ErrConflict
+		}
+		// A replacement may bring back the IDs of the nodes it removes.
+		if old, taken := d.files[node.ID]; taken && (keep || old.ProjectID != projectID) {
+			return 0, store.ErrConflict
+		}
+		if _, taken := d.childLocked(projectID, node.ParentID, node.Name); keep && taken {
+			return 0, store.ErrConflict
+		}
+		seen[node.ID] = true
+		names[key] = true
+		if node.Blob != nil {
+			size += node.Blob.Size
+		}
+	}
+	return size, nil
+}
+
+// insertTreeLocked adds nodes checked by checkTreeLocked to a project.
+func (d *db) insertTreeLocked(projectID uuid.UUID, nodes []models.FileNode) {
+	now := time.Now()
+	for _, node := range nodes {
+		node.ProjectID = projectID
+		node.Revision = 1
+		node.CreatedAt = now
+		node.UpdatedAt = now
+		if node.Blob != nil {
+			node.Content = nil
+		}
+		d.files[node.ID] = copyNode(node)
+	}
+}
+
+// sameParent reports whether two parent IDs name the same folder, or are both
+// the top of a project.
+func sameParent(a, b *uuid.UUID) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+// deleteFileLocked removes a node, its descendants and their access rules.
+func (d *db) deleteFileLocked(fileID uuid.UUID) {
+	d.orphanBlobLocked(d.files[fileID].Blob)
+	delete(d.files, fileID)
+	delete(d.drafts, fileID)
+	for id, r := range d.rules {
+		if r.FileID == fileID {
+			delete(d.rules, id)
+		}
+	}
+	for id, node := range d.files {
+		if node.ParentID != nil && *node.ParentID == fileID {
+			d.deleteFileLocked(id)
+		}
+	}
+}
+
+// orphanBlobLocked queues a blob that lost its file for removal, like the
+// files_orphan_blob trigger.
+func (d *db) orphanBlobLocked(blob *models.Blob) {
+	if blob != nil {
+		d.orphanedBlobs = append(d.orphanedBlobs, blob.Key)
+	}
+}
+
+// deleteOrganizationLocked removes an organization; its projects stay.
+func (d *db) deleteOrganizationLocked(orgID uuid.UUID) {
+	delete(d.organizations, orgID)
+	delete(d.orgMembers, orgID)
+	for id, p := range d.projects {
+		if p.OrganizationID != nil && *p.OrganizationID == orgID {
+			p.OrganizationID = nil
+			d.projects[id] = p
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package memstore
+
+import (
+	"testing"
+
+	"project-meetings/backend/internal/store/storetest"
+)
+
+func TestDrafts(t *testing.T) {
+	storetest.Drafts(t, New())
+}
+
+func TestAudit(t *testing.T) {
+	storetest.Audit(t, New())
+}
+
+func TestUpdateContent(t *testing.T) {
+	storetest.UpdateContent(t, New())
+}
+
+func TestReplaceTree(t *testing.T) {
+	storetest.ReplaceTree(t, New())
+}
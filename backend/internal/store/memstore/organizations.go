@@ -0,0 +1,195 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"project-meetings/backend/internal/models"
+	"project-meetings/backend/internal/store"
+
+	"github.com/google/uuid"
+)
+
+type organizationStore struct{ *db }
+
+func (s *organizationStore) Create(ctx context.Context, name, defaultProjectRole, ownerID string) (models.Organization, error) {
+	ids, ok := parseIDs(ownerID)
+	if !ok {
+		return models.Organization{}, store.ErrNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[ids[0]]; !ok {
+		return models.Organization{}, store.ErrNotFound
+	}
+
+	now := time.Now()
+	org := models.Organization{
+		ID:                 uuid.New(),
+		Name:               name,
+		DefaultProjectRole: defaultProjectRole,
+		CreatedAt:          now,
+		UpdatedAt:          now,
+	}
+	s.organizations[org.ID] = org
+	s.orgMembers[org.ID] = map[uuid.UUID]membership{ids[0]: {role: "owner", joinedAt: now}}
+	org.Role = "owner"
+	return org, nil
+}
+
+func (s *organizationStore) Get(ctx context.Context, organizationID string) (models.Organization, error) {
+	ids, ok := parseIDs(organizationID)
+	if !ok {
+		return models.Organization{}, store.ErrNotFound
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	org, ok := s.organizations[ids[0]]
+	if !ok {
+		return models.Organization{}, store.ErrNotFound
+	}
+	return org, nil
+}
+
+func (s *organizationStore) ListForUser(ctx context.Context, userID string) ([]models.Organization, error) {
+	orgs := make([]models.Organization, 0)
+	ids, ok := parseIDs(userID)
+	if !ok {
+		return orgs, nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for orgID, members := range s.orgMembers {
+		if m, ok := members[ids[0]]; ok {
+			org := s.organizations[orgID]
+			org.Role = m.role
+			orgs = append(orgs, org)
+		}
+	}
+	sort.Slice(orgs, func(i, j int) bool { return orgs[i].Name < orgs[j].Name })
+	return orgs, nil
+}
+
+func (s *organizationStore) Update(ctx context.Context, organizationID string, name, defaultProjectRole *string) (models.Organization, error) {
+	ids, ok := parseIDs(organizationID)
+	if !ok {
+		return models.Organization{}, store.ErrNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	org, ok := s.organizations[ids[0]]
+	if !ok {
+		return models.Organization{}, store.ErrNotFound
+	}
+	if name != nil {
+		org.Name = *name
+	}
+	if defaultProjectRole != nil {
+		org.DefaultProjectRole = *defaultProjectRole
+	}
+	org.UpdatedAt = time.Now()
+	s.organizations[org.ID] = org
+	return org, nil
+}
+
+func (s *organizationStore) Delete(ctx context.Context, organizationID string) error {
+	ids, ok := parseIDs(organizationID)
+	if !ok {
+		return store.ErrNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.organizations[ids[0]]; !ok {
+		return store.ErrNotFound
+	}
+	s.deleteOrganizationLocked(ids[0])
+	return nil
+}
+
+func (s *organizationStore) MemberRole(ctx context.Context, organizationID, userID string) (string, error) {
+	ids, ok := parseIDs(organizationID, userID)
+	if !ok {
+		return "", store.ErrNotFound
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	m, ok := s.orgMembers[ids[0]][ids[1]]
+	if !ok {
+		return "", store.ErrNotFound
+	}
+	return m.role, nil
+}
+
+func (s *organizationStore) Members(ctx context.Context, organizationID string) ([]models.OrganizationMember, error) {
+	members := make([]models.OrganizationMember, 0)
+	ids, ok := parseIDs(organizationID)
+	if !ok {
+		return members, nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for userID, m := range s.orgMembers[ids[0]] {
+		u := s.users[userID]
+		members = append(members, models.OrganizationMember{
+			UserID:   userID,
+			Username: u.Username,
+			Email:    u.Email,
+			Role:     m.role,
+			JoinedAt: m.joinedAt,
+		})
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].Username < members[j].Username })
+	return members, nil
+}
+
+func (s *organizationStore) AddMember(ctx context.Context, organizationID, userID, role string) (bool, error) {
+	ids, ok := parseIDs(organizationID, userID)
+	if !ok {
+		return false, store.ErrNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	members, ok := s.orgMembers[ids[0]]
+	if !ok {
+		return false, store.ErrNotFound
+	}
+	if _, ok := s.users[ids[1]]; !ok {
+		return false, store.ErrNotFound
+	}
+	if _, exists := members[ids[1]]; exists {
+		return false, nil
+	}
+	members[ids[1]] = membership{role: role, joinedAt: time.Now()}
+	return true, nil
+}
+
+func (s *organizationStore) UpdateMemberRole(ctx context.Context, organizationID, userID, role string) error {
+	ids, ok := parseIDs(organizationID, userID)
+	if !ok {
+		return store.ErrNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, ok := s.orgMembers[ids[0]][ids[1]]
+	if !ok {
+		return store.ErrNotFound
+	}
+	m.role = role
+	s.orgMembers[ids[0]][ids[1]] = m
+	return nil
+}
+
+func (s *organizationStore) RemoveMember(ctx context.Context, organizationID, userID string) error {
+	ids, ok := parseIDs(organizationID, userID)
+	if !ok {
+		return store.ErrNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.orgMembers[ids[0]][ids[1]]; !ok {
+		return store.ErrNotFound
+	}
+	delete(s.orgMembers[ids[0]], ids[1])
+	return nil
+}
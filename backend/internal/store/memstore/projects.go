@@ -0,0 +1,404 @@
+package memstore
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"project-meetings/backend/internal/models"
+	"project-meetings/backend/internal/store"
+
+	"github.com/google/uuid"
+)
+
+type projectStore struct{ *db }
+
+func sortProjects(projects []models.Project) {
+	sort.Slice(projects, func(i, j int) bool { return projects[i].CreatedAt.After(projects[j].CreatedAt) })
+}
+
+func (s *projectStore) Create(ctx context.Context, name, ownerID string, organizationID *uuid.UUID) (models.Project, error) {
+	return s.CreateFrom(ctx, name, ownerID, organizationID, store.ProjectSeed{})
+}
+
+func (s *projectStore) CreateFrom(ctx context.Context, name, ownerID string, organizationID *uuid.UUID, seed store.ProjectSeed) (models.Project, error) {
+	ids, ok := parseIDs(ownerID)
+	if !ok {
+		return models.Project{}, store.ErrNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[ids[0]]; !ok {
+		return models.Project{}, store.ErrNotFound
+	}
+	if organizationID != nil {
+		if _, ok := s.organizations[*organizationID]; !ok {
+			return models.Project{}, store.ErrNotFound
+		}
+		org := *organizationID
+		organizationID = &org
+	}
+	if seed.ForkedFrom != nil {
+		if _, ok := s.projects[*seed.ForkedFrom]; !ok {
+			return models.Project{}, store.ErrNotFound
+		}
+		from := *seed.ForkedFrom
+		seed.ForkedFrom = &from
+	}
+
+	now := time.Now()
+	project := models.Project{
+		ID:             uuid.New(),
+		OwnerID:        ids[0],
+		OrganizationID: organizationID,
+		ForkedFrom:     seed.ForkedFrom,
+		Name:           name,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if _, err := s.checkTreeLocked(project.ID, seed.Files, false); err != nil {
+		return models.Project{}, err
+	}
+	s.projects[project.ID] = project
+	s.members[project.ID] = map[uuid.UUID]membership{ids[0]: {role: "owner", joinedAt: now}}
+	s.insertTreeLocked(project.ID, seed.Files)
+	if len(seed.Shapes) > 0 {
+		s.shapes[project.ID] = make(map[string]json.RawMessage, len(seed.Shapes))
+		for shapeID, data := range seed.Shapes {
+			s.shapes[project.ID][shapeID] = append(json.RawMessage(nil), data...)
+		}
+	}
+	return project, nil
+}
+
+func (s *projectStore) Get(ctx context.Context, projectID string) (models.Project, error) {
+	ids, ok := parseIDs(projectID)
+	if !ok {
+		return models.Project{}, store.ErrNotFound
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, ok := s.projects[ids[0]]
+	if !ok {
+		return models.Project{}, store.ErrNotFound
+	}
+	return p, nil
+}
+
+func (s *projectStore) ListForUser(ctx context.Context, userID string) ([]models.Project, error) {
+	projects := make([]models.Project, 0)
+	ids, ok := parseIDs(userID)
+	if !ok {
+		return projects, nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for id, p := range s.projects {
+		_, member := s.members[id][ids[0]]
+		if !member && p.OrganizationID != nil {
+			_, member = s.orgMembers[*p.OrganizationID][ids[0]]
+		}
+		if member {
+			projects = append(projects, p)
+		}
+	}
+	sortProjects(projects)
+	return projects, nil
+}
+
+func (s *projectStore) ListForOrganization(ctx context.Context, organizationID string) ([]models.Project, error) {
+	projects := make([]models.Project, 0)
+	ids, ok := parseIDs(organizationID)
+	if !ok {
+		return projects, nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, p := range s.projects {
+		if p.OrganizationID != nil && *p.OrganizationID == ids[0] {
+			projects = append(projects, p)
+		}
+	}
+	sortProjects(projects)
+	return projects, nil
+}
+
+// update applies fn to a stored project and bumps its UpdatedAt.
+func (s *projectStore) update(projectID string, fn func(p *models.Project) error) error {
+	ids, ok := parseIDs(projectID)
+	if !ok {
+		return store.ErrNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.projects[ids[0]]
+	if !ok {
+		return store.ErrNotFound
+	}
+	if err := fn(&p); err != nil {
+		return err
+	}
+	p.UpdatedAt = time.Now()
+	s.projects[p.ID] = p
+	return nil
+}
+
+func (s *projectStore) Rename(ctx context.Context, projectID, name string) error {
+	return s.update(projectID, func(p *models.Project) error {
+		p.Name = name
+		return nil
+	})
+}
+
+func (s *projectStore) Delete(ctx context.Context, projectID string) error {
+	ids, ok := parseIDs(projectID)
+	if !ok {
+		return store.ErrNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.projects[ids[0]]; !ok {
+		return store.ErrNotFound
+	}
+	s.deleteProjectLocked(ids[0])
+	return nil
+}
+
+func (s *projectStore) SetOrganization(ctx context.Context, projectID string, organizationID *uuid.UUID) error {
+	return s.update(projectID, func(p *models.Project) error {
+		if organizationID == nil {
+			p.OrganizationID = nil
+			return nil
+		}
+		if _, ok := s.organizations[*organizationID]; !ok {
+			return store.ErrNotFound
+		}
+		org := *organizationID
+		p.OrganizationID = &org
+		return nil
+	})
+}
+
+func (s *projectStore) SetTemplate(ctx context.Context, projectID string, isTemplate bool) error {
+	return s.update(projectID, func(p *models.Project) error {
+		p.IsTemplate = isTemplate
+		return nil
+	})
+}
+
+func (s *projectStore) TransferOwnership(ctx context.Context, projectID, fromUserID, toUserID string) error {
+	ids, ok := parseIDs(fromUserID, toUserID)
+	if !ok {
+		return store.ErrNotMember
+	}
+	from, to := ids[0], ids[1]
+	return s.update(projectID, func(p *models.Project) error {
+		if p.OwnerID != from {
+			return store.ErrNotOwner
+		}
+		members := s.members[p.ID]
+		newOwner, ok := members[to]
+		if !ok {
+			return store.ErrNotMember
+		}
+		newOwner.role = "owner"
+		members[to] = newOwner
+		// The previous owner stays on the project as an editor.
+		if previous, ok := members[from]; ok {
+			previous.role = "editor"
+			members[from] = previous
+		}
+		p.OwnerID = to
+		return nil
+	})
+}
+
+func (s *projectStore) ResolveRole(ctx context.Context, projectID, userID string) (string, error) {
+	ids, ok := parseIDs(projectID, userID)
+	if !ok {
+		return "", store.ErrNotFound
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	p, ok := s.projects[ids[0]]
+	if !ok {
+		return "", store.ErrNotFound
+	}
+	if m, ok := s.members[p.ID][ids[1]]; ok {
+		return m.role, nil
+	}
+	if p.OrganizationID != nil {
+		if m, ok := s.orgMembers[*p.OrganizationID][ids[1]]; ok {
+			if m.role == "owner" || m.role == "admin" {
+				return "owner", nil
+			}
+			return s.organizations[*p.OrganizationID].DefaultProjectRole, nil
+		}
+	}
+	return "", store.ErrNotFound
+}
+
+func (s *projectStore) Members(ctx context.Context, projectID string) ([]models.ProjectMember, error) {
+	members := make([]models.ProjectMember, 0)
+	ids, ok := parseIDs(projectID)
+	if !ok {
+		return members, nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for userID, m := range s.members[ids[0]] {
+		u := s.users[userID]
+		members = append(members, models.ProjectMember{UserID: userID, Username: u.Username, Email: u.Email, Role: m.role})
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].Username < members[j].Username })
+	return members, nil
+}
+
+func (s *projectStore) MemberIDsWithRole(ctx context.Context, projectID, role string) ([]string, error) {
+	userIDs := make([]string, 0)
+	ids, ok := parseIDs(projectID)
+	if !ok {
+		return userIDs, nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for userID, m := range s.members[ids[0]] {
+		if m.role == role {
+			userIDs = append(userIDs, userID.String())
+		}
+	}
+	return userIDs, nil
+}
+
+func (s *projectStore) UpdateMemberRole(ctx context.Context, projectID, userID, role string) error {
+	ids, ok := parseIDs(projectID, userID)
+	if !ok {
+		return store.ErrNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, ok := s.members[ids[0]][ids[1]]
+	if !ok {
+		return store.ErrNotFound
+	}
+	m.role = role
+	s.members[ids[0]][ids[1]] = m
+	return nil
+}
+
+func (s *projectStore) RemoveMember(ctx context.Context, projectID, userID string) error {
+	ids, ok := parseIDs(projectID, userID)
+	if !ok {
+		return store.ErrNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.members[ids[0]][ids[1]]; !ok {
+		return store.ErrNotFound
+	}
+	delete(s.members[ids[0]], ids[1])
+	return nil
+}
+
+func (s *projectStore) Roles(ctx context.Context, projectID string) ([]models.ProjectRole, error) {
+	roles := make([]models.ProjectRole, 0)
+	ids, ok := parseIDs(projectID)
+	if !ok {
+		return roles, nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for name, perms := range s.roles[ids[0]] {
+		roles = append(roles, models.ProjectRole{Name: name, Permissions: copyStrings(perms)})
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+	return roles, nil
+}
+
+func (s *projectStore) RolePermissions(ctx context.Context, projectID, role string) ([]string, error) {
+	ids, ok := parseIDs(projectID)
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	perms, ok := s.roles[ids[0]][role]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return copyStrings(perms), nil
+}
+
+func (s *projectStore) CreateRole(ctx context.Context, projectID string, role models.ProjectRole) error {
+	ids, ok := parseIDs(projectID)
+	if !ok {
+		return store.ErrNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.projects[ids[0]]; !ok {
+		return store.ErrNotFound
+	}
+	if _, exists := s.roles[ids[0]][role.Name]; exists {
+		return store.ErrConflict
+	}
+	if s.roles[ids[0]] == nil {
+		s.roles[ids[0]] = make(map[string][]string)
+	}
+	s.roles[ids[0]][role.Name] = copyStrings(role.Permissions)
+	return nil
+}
+
+func (s *projectStore) UpdateRole(ctx context.Context, projectID string, role models.ProjectRole) error {
+	ids, ok := parseIDs(projectID)
+	if !ok {
+		return store.ErrNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.roles[ids[0]][role.Name]; !exists {
+		return store.ErrNotFound
+	}
+	s.roles[ids[0]][role.Name] = copyStrings(role.Permissions)
+	return nil
+}
+
+func (s *projectStore) DeleteRole(ctx context.Context, projectID, role string) error {
+	ids, ok := parseIDs(projectID)
+	if !ok {
+		return store.ErrNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.roles[ids[0]][role]; !exists {
+		return store.ErrNotFound
+	}
+	delete(s.roles[ids[0]], role)
+	return nil
+}
+
+func (s *projectStore) RoleInUse(ctx context.Context, projectID, role string) (bool, error) {
+	ids, ok := parseIDs(projectID)
+	if !ok {
+		return false, nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, m := range s.members[ids[0]] {
+		if m.role == role {
+			return true, nil
+		}
+	}
+	for _, inv := range s.invites {
+		if inv.ProjectID == ids[0] && inv.Role == role && !inv.revoked {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,224 @@
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"project-meetings/backend/internal/models"
+	"project-meetings/backend/internal/store"
+
+	"github.com/google/uuid"
+)
+
+type userStore struct{ *db }
+
+func (s *userStore) Create(ctx context.Context, username, email, passwordHash string) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.Email == email || u.Username == username {
+			return models.User{}, store.ErrConflict
+		}
+	}
+	now := time.Now()
+	user := models.User{
+		ID:           uuid.New(),
+		Email:        email,
+		Username:     username,
+		Timezone:     "UTC",
+		PasswordHash: passwordHash,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	s.users[user.ID] = user
+	return user, nil
+}
+
+func (s *userStore) Get(ctx context.Context, userID string) (models.User, error) {
+	ids, ok := parseIDs(userID)
+	if !ok {
+		return models.User{}, store.ErrNotFound
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	user, ok := s.users[ids[0]]
+	if !ok {
+		return models.User{}, store.ErrNotFound
+	}
+	return user, nil
+}
+
+func (s *userStore) GetByEmail(ctx context.Context, email string) (models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, u := range s.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return models.User{}, store.ErrNotFound
+}
+
+func (s *userStore) EmailInUse(ctx context.Context, email string) (bool, error) {
+	_, err := s.GetByEmail(ctx, email)
+	return err == nil, nil
+}
+
+func (s *userStore) UpdateProfile(ctx context.Context, user *models.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.users[user.ID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	for id, u := range s.users {
+		if id != user.ID && u.Username == user.Username {
+			return store.ErrConflict
+		}
+	}
+	current.Username = user.Username
+	current.DisplayName = user.DisplayName
+	current.AvatarURL = user.AvatarURL
+	current.Timezone = user.Timezone
+	current.UpdatedAt = time.Now()
+	s.users[user.ID] = current
+	user.UpdatedAt = current.UpdatedAt
+	return nil
+}
+
+func (s *userStore) UpdatePassword(ctx context.Context, userID, passwordHash string) error {
+	ids, ok := parseIDs(userID)
+	if !ok {
+		return store.ErrNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[ids[0]]
+	if !ok {
+		return store.ErrNotFound
+	}
+	user.PasswordHash = passwordHash
+	user.UpdatedAt = time.Now()
+	s.users[user.ID] = user
+	return nil
+}
+
+func (s *userStore) RequestEmailChange(ctx context.Context, userID, newEmail, tokenHash string, expiresAt time.Time) error {
+	ids, ok := parseIDs(userID)
+	if !ok {
+		return store.ErrNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[ids[0]]; !ok {
+		return store.ErrNotFound
+	}
+	s.emailChanges[ids[0]] = emailChange{newEmail: newEmail, tokenHash: tokenHash, expiresAt: expiresAt}
+	return nil
+}
+
+func (s *userStore) ConfirmEmailChange(ctx context.Context, userID, tokenHash string) (string, error) {
+	ids, ok := parseIDs(userID)
+	if !ok {
+		return "", store.ErrNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	change, ok := s.emailChanges[ids[0]]
+	if !ok || change.tokenHash != tokenHash {
+		return "", store.ErrNotFound
+	}
+	if time.Now().After(change.expiresAt) {
+		return "", store.ErrExpired
+	}
+	for id, u := range s.users {
+		if id != ids[0] && u.Email == change.newEmail {
+			return "", store.ErrConflict
+		}
+	}
+
+	user := s.users[ids[0]]
+	user.Email = change.newEmail
+	user.UpdatedAt = time.Now()
+	s.users[user.ID] = user
+	delete(s.emailChanges, user.ID)
+	return change.newEmail, nil
+}
+
+func (s *userStore) Delete(ctx context.Context, userID string, transfers map[string]string) (map[string]string, error) {
+	ids, ok := parseIDs(userID)
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	uid := ids[0]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[uid]; !ok {
+		return nil, store.ErrNotFound
+	}
+
+	// Validate every transfer before changing anything, so a failure leaves
+	// the store untouched like a rolled back transaction.
+	for projectID, p := range s.projects {
+		if p.OwnerID != uid {
+			continue
+		}
+		newOwnerID, transfer := transfers[projectID.String()]
+		if !transfer {
+			continue
+		}
+		newOwner, ok := parseIDs(newOwnerID)
+		if !ok {
+			return nil, fmt.Errorf("project %s: %w", projectID, store.ErrNotMember)
+		}
+		if _, member := s.members[projectID][newOwner[0]]; !member {
+			return nil, fmt.Errorf("project %s: %w", projectID, store.ErrNotMember)
+		}
+	}
+
+	transferred := make(map[string]string)
+	for projectID, p := range s.projects {
+		if p.OwnerID != uid {
+			continue
+		}
+		newOwnerID, transfer := transfers[projectID.String()]
+		if !transfer {
+			s.deleteProjectLocked(projectID)
+			continue
+		}
+		newOwner, _ := parseIDs(newOwnerID)
+		m := s.members[projectID][newOwner[0]]
+		m.role = "owner"
+		s.members[projectID][newOwner[0]] = m
+		p.OwnerID = newOwner[0]
+		p.UpdatedAt = time.Now()
+		s.projects[projectID] = p
+		transferred[projectID.String()] = newOwnerID
+	}
+
+	for orgID, members := range s.orgMembers {
+		if m, ok := members[uid]; ok && m.role == "owner" {
+			s.deleteOrganizationLocked(orgID)
+		}
+	}
+
+	delete(s.users, uid)
+	delete(s.emailChanges, uid)
+	for _, members := range s.members {
+		delete(members, uid)
+	}
+	for _, members := range s.orgMembers {
+		delete(members, uid)
+	}
+	for id, inv := range s.invites {
+		if inv.CreatedBy == uid {
+			delete(s.invites, id)
+		}
+	}
+	return transferred, nil
+}
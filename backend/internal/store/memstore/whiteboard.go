@@ -0,0 +1,52 @@
+package memstore
+
+import (
+	"context"
+	"encoding/json"
+
+	"project-meetings/backend/internal/store"
+)
+
+type whiteboardStore struct{ *db }
+
+func (s *whiteboardStore) Shapes(ctx context.Context, projectID string) (map[string]json.RawMessage, error) {
+	shapes := make(map[string]json.RawMessage)
+	ids, ok := parseIDs(projectID)
+	if !ok {
+		return shapes, nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for id, data := range s.shapes[ids[0]] {
+		shapes[id] = append(json.RawMessage(nil), data...)
+	}
+	return shapes, nil
+}
+
+func (s *whiteboardStore) SaveShape(ctx context.Context, projectID, shapeID string, data json.RawMessage) error {
+	ids, ok := parseIDs(projectID)
+	if !ok {
+		return store.ErrNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.projects[ids[0]]; !ok {
+		return store.ErrNotFound
+	}
+	if s.shapes[ids[0]] == nil {
+		s.shapes[ids[0]] = make(map[string]json.RawMessage)
+	}
+	s.shapes[ids[0]][shapeID] = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+func (s *whiteboardStore) DeleteShape(ctx context.Context, projectID, shapeID string) error {
+	ids, ok := parseIDs(projectID)
+	if !ok {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.shapes[ids[0]], shapeID)
+	return nil
+}
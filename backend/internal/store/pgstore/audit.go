@@ -0,0 +1,97 @@
+package pgstore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"project-meetings/backend/internal/audit"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type auditStore struct {
+	db *pgxpool.Pool
+}
+
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func (s *auditStore) Append(ctx context.Context, e audit.Event) error {
+	details, err := audit.EncodeDetails(e.Details)
+	if err != nil {
+		return fmt.Errorf("encode details: %w", err)
+	}
+	query := `
+		INSERT INTO audit_log (project_id, actor_id, action, target_type, target_id, ip, user_agent, details)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err = s.db.Exec(ctx, query,
+		nullIfEmpty(e.ProjectID), nullIfEmpty(e.ActorID), e.Action, e.TargetType, e.TargetID, e.IP, e.UserAgent, details)
+	return err
+}
+
+func (s *auditStore) List(ctx context.Context, f audit.Filter) ([]audit.Entry, error) {
+	conditions := []string{"a.project_id = $1"}
+	args := []interface{}{f.ProjectID}
+	addCondition := func(format string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(format, len(args)))
+	}
+
+	if f.Action != "" {
+		if strings.HasSuffix(f.Action, ".*") {
+			addCondition("a.action LIKE $%d", likeEscaper.Replace(strings.TrimSuffix(f.Action, "*"))+"%")
+		} else {
+			addCondition("a.action = $%d", f.Action)
+		}
+	}
+	if f.ActorID != "" {
+		addCondition("a.actor_id = $%d", f.ActorID)
+	}
+	if f.TargetType != "" {
+		addCondition("a.target_type = $%d", f.TargetType)
+	}
+	if f.TargetID != "" {
+		addCondition("a.target_id = $%d", f.TargetID)
+	}
+	if f.Since != nil {
+		addCondition("a.created_at >= $%d", *f.Since)
+	}
+	if f.Until != nil {
+		addCondition("a.created_at < $%d", *f.Until)
+	}
+	if f.Before > 0 {
+		addCondition("a.id < $%d", f.Before)
+	}
+	args = append(args, f.Limit)
+
+	query := fmt.Sprintf(`
+		SELECT a.id, a.project_id::text, a.actor_id::text, u.username, a.action, a.target_type, a.target_id,
+		       a.ip, a.user_agent, a.details, a.created_at
+		FROM audit_log a
+		LEFT JOIN users u ON u.id = a.actor_id
+		WHERE %s
+		ORDER BY a.id DESC
+		LIMIT $%d`, strings.Join(conditions, " AND "), len(args))
+
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]audit.Entry, 0, f.Limit)
+	for rows.Next() {
+		var e audit.Entry
+		if err := rows.Scan(&e.ID, &e.ProjectID, &e.ActorID, &e.ActorName, &e.Action, &e.TargetType, &e.TargetID,
+			&e.IP, &e.UserAgent, &e.Details, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
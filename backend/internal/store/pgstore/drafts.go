@@ -0,0 +1,60 @@
+package pgstore
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type draftStore struct {
+	db *pgxpool.Pool
+}
+
+func (s *draftStore) Get(ctx context.Context, fileID uuid.UUID) (string, error) {
+	var content string
+	err := s.db.QueryRow(ctx, `SELECT content FROM file_drafts WHERE file_id = $1`, fileID).Scan(&content)
+	return content, mapErr(err)
+}
+
+func (s *draftStore) List(ctx context.Context, projectID uuid.UUID) (map[uuid.UUID]string, error) {
+	rows, err := s.db.Query(ctx, `SELECT file_id, content FROM file_drafts WHERE project_id = $1`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	drafts := make(map[uuid.UUID]string)
+	for rows.Next() {
+		var id uuid.UUID
+		var content string
+		if err := rows.Scan(&id, &content); err != nil {
+			return nil, err
+		}
+		drafts[id] = content
+	}
+	return drafts, rows.Err()
+}
+
+func (s *draftStore) Save(ctx context.Context, projectID uuid.UUID, drafts map[uuid.UUID]string) error {
+	if len(drafts) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, 0, len(drafts))
+	contents := make([]string, 0, len(drafts))
+	for id, content := range drafts {
+		ids = append(ids, id)
+		contents = append(contents, content)
+	}
+	// Joining on files drops drafts of files deleted in the meantime.
+	query := `
+		INSERT INTO file_drafts (file_id, project_id, content, updated_at)
+		SELECT f.id, f.project_id, d.content, NOW()
+		FROM unnest($2::uuid[], $3::text[]) AS d(file_id, content)
+		JOIN files f ON f.id = d.file_id AND f.project_id = $1
+		ON CONFLICT (file_id) DO UPDATE SET
+		content = EXCLUDED.content,
+		updated_at = NOW()`
+	_, err := s.db.Exec(ctx, query, projectID, ids, contents)
+	return err
+}
@@ -0,0 +1,474 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"project-meetings/backend/internal/models"
+	"project-meetings/backend/internal/search"
+	"project-meetings/backend/internal/store"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type fileStore struct {
+	db *pgxpool.Pool
+}
+
+const fileColumns = `id, project_id, parent_id, is_folder, name, content, blob_key, content_type, size, sha256, revision, created_at, updated_at`
+
+func scanFile(row interface{ Scan(...any) error }) (models.FileNode, error) {
+	var node models.FileNode
+	var blobKey, contentType, sha *string
+	var size *int64
+	err := row.Scan(&node.ID, &node.ProjectID, &node.ParentID, &node.IsFolder, &node.Name, &node.Content,
+		&blobKey, &contentType, &size, &sha, &node.Revision, &node.CreatedAt, &node.UpdatedAt)
+	if err == nil && blobKey != nil {
+		node.Blob = &models.Blob{Key: *blobKey}
+		if contentType != nil {
+			node.Blob.ContentType = *contentType
+		}
+		if size != nil {
+			node.Blob.Size = *size
+		}
+		if sha != nil {
+			node.Blob.SHA256 = *sha
+		}
+	}
+	return node, mapErr(err)
+}
+
+func collectFiles(rows pgx.Rows, err error) ([]models.FileNode, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	nodes := make([]models.FileNode, 0)
+	for rows.Next() {
+		node, err := scanFile(rows)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, rows.Err()
+}
+
+func (s *fileStore) List(ctx context.Context, projectID uuid.UUID) ([]models.FileNode, error) {
+	return collectFiles(s.db.Query(ctx, `SELECT `+fileColumns+` FROM files WHERE project_id = $1 ORDER BY name ASC`, projectID))
+}
+
+func (s *fileStore) Outline(ctx context.Context, projectID uuid.UUID) ([]models.FileNode, error) {
+	query := `SELECT id, project_id, parent_id, is_folder, name, NULL::text, blob_key, content_type, size, sha256, revision, created_at, updated_at FROM files WHERE project_id = $1 ORDER BY name ASC`
+	return collectFiles(s.db.Query(ctx, query, projectID))
+}
+
+func (s *fileStore) Children(ctx context.Context, projectID uuid.UUID, parentID *uuid.UUID, after string, limit int) ([]models.FileNode, error) {
+	// The condition matches files_sibling_name_idx, so a page is a range scan.
+	parent := uuid.Nil
+	if parentID != nil {
+		parent = *parentID
+	}
+	query := `SELECT id, project_id, parent_id, is_folder, name, NULL::text, blob_key, content_type, size, sha256, revision, created_at, updated_at FROM files
+		WHERE project_id = $1 AND COALESCE(parent_id, '00000000-0000-0000-0000-000000000000'::uuid) = $2 AND name > $3
+		ORDER BY name ASC LIMIT $4`
+	return collectFiles(s.db.Query(ctx, query, projectID, parent, after, limit))
+}
+
+// likeEscaper escapes the LIKE wildcards in a literal, using the default
+// backslash escape character.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func (s *fileStore) Search(ctx context.Context, projectID uuid.UUID, q search.Query) ([]models.FileNode, error) {
+	query := `SELECT ` + fileColumns + ` FROM files WHERE project_id = $1 AND NOT is_folder AND content IS NOT NULL`
+	args := []any{projectID}
+	if q.Mode == search.ModeText {
+		// Word queries go through the full-text index.
+		args = append(args, strings.Join(q.Terms(), " "))
+		query += ` AND to_tsvector('simple', COALESCE(content, '')) @@ plainto_tsquery('simple', $2)`
+	} else {
+		// Literal and regex queries are narrowed with the trigram index by the
+		// substrings every match must contain. The regex itself is evaluated
+		// by the caller, since Postgres and Go don't share a regex dialect.
+		for _, literal := range q.Literals() {
+			args = append(args, "%"+likeEscaper.Replace(literal)+"%")
+			query += fmt.Sprintf(` AND content ILIKE $%d`, len(args))
+		}
+	}
+	query += ` ORDER BY name ASC`
+	return collectFiles(s.db.Query(ctx, query, args...))
+}
+
+func (s *fileStore) Get(ctx context.Context, fileID uuid.UUID) (models.FileNode, error) {
+	return scanFile(s.db.QueryRow(ctx, `SELECT `+fileColumns+` FROM files WHERE id = $1`, fileID))
+}
+
+func (s *fileStore) ResolvePath(ctx context.Context, projectID uuid.UUID, segments []string) (models.FileNode, error) {
+	if len(segments) == 0 {
+		return models.FileNode{}, store.ErrNotFound
+	}
+	query := `
+		WITH RECURSIVE walk AS (
+			SELECT id, 1 AS depth FROM files
+			WHERE project_id = $1 AND parent_id IS NULL AND name = ($2::text[])[1]
+			UNION ALL
+			SELECT f.id, w.depth + 1 FROM files f JOIN walk w ON f.parent_id = w.id
+			WHERE w.depth < cardinality($2::text[]) AND f.name = ($2::text[])[w.depth + 1]
+		)
+		SELECT ` + fileColumns + ` FROM files
+		WHERE id = (SELECT id FROM walk WHERE depth = cardinality($2::text[]))`
+	return scanFile(s.db.QueryRow(ctx, query, projectID, segments))
+}
+
+func (s *fileStore) Create(ctx context.Context, node *models.FileNode) error {
+	query := `INSERT INTO files (project_id, parent_id, is_folder, name, content) VALUES ($1, $2, $3, $4, $5) RETURNING id, revision, created_at, updated_at`
+	err := s.db.QueryRow(ctx, query, node.ProjectID, node.ParentID, node.IsFolder, node.Name, node.Content).Scan(&node.ID, &node.Revision, &node.CreatedAt, &node.UpdatedAt)
+	return mapErr(err)
+}
+
+// staleOrMissing explains why a conditional write to a node matched no row.
+func staleOrMissing(ctx context.Context, q interface {
+	QueryRow(context.Context, string, ...any) pgx.Row
+}, fileID uuid.UUID) error {
+	var exists bool
+	if err := q.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM files WHERE id = $1)`, fileID).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return store.ErrStale
+	}
+	return store.ErrNotFound
+}
+
+// updateFile runs an UPDATE of one node that returns its new revision.
+func (s *fileStore) updateFile(ctx context.Context, fileID uuid.UUID, query string, args ...any) (int64, error) {
+	var revision int64
+	err := mapErr(s.db.QueryRow(ctx, query, args...).Scan(&revision))
+	if errors.Is(err, store.ErrNotFound) {
+		err = staleOrMissing(ctx, s.db, fileID)
+	}
+	return revision, err
+}
+
+func (s *fileStore) UpdateContent(ctx context.Context, fileID uuid.UUID, content string, rev int64) (int64, error) {
+	query := `
+		UPDATE files SET content = $1, blob_key = NULL, content_type = NULL, size = NULL, sha256 = NULL,
+		revision = revision + 1, updated_at = NOW()
+		WHERE id = $2 AND NOT is_folder AND ($3::bigint = 0 OR revision = $3)
+		RETURNING revision`
+	revision, err := s.updateFile(ctx, fileID, query, content, fileID, rev)
+	if errors.Is(err, store.ErrStale) {
+		// The node exists, but a folder has no content to save.
+		var isFolder bool
+		if s.db.QueryRow(ctx, `SELECT is_folder FROM files WHERE id = $1`, fileID).Scan(&isFolder) == nil && isFolder {
+			return 0, store.ErrNotFound
+		}
+	}
+	return revision, err
+}
+
+func (s *fileStore) Rename(ctx context.Context, fileID uuid.UUID, name string, rev int64) (int64, error) {
+	query := `
+		UPDATE files SET name = $1, revision = revision + 1, updated_at = NOW()
+		WHERE id = $2 AND ($3::bigint = 0 OR revision = $3)
+		RETURNING revision`
+	return s.updateFile(ctx, fileID, query, name, fileID, rev)
+}
+
+func (s *fileStore) Delete(ctx context.Context, fileID uuid.UUID, rev int64) (models.FileNode, error) {
+	// Children go too, through ON DELETE CASCADE on parent_id.
+	query := `DELETE FROM files WHERE id = $1 AND ($2::bigint = 0 OR revision = $2) RETURNING ` + fileColumns
+	node, err := scanFile(s.db.QueryRow(ctx, query, fileID, rev))
+	if errors.Is(err, store.ErrNotFound) {
+		err = staleOrMissing(ctx, s.db, fileID)
+	}
+	return node, err
+}
+
+func collectParents(rows pgx.Rows, err error) (map[uuid.UUID]*uuid.UUID, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	parents := make(map[uuid.UUID]*uuid.UUID)
+	for rows.Next() {
+		var id uuid.UUID
+		var parentID *uuid.UUID
+		if err := rows.Scan(&id, &parentID); err != nil {
+			return nil, err
+		}
+		parents[id] = parentID
+	}
+	return parents, rows.Err()
+}
+
+func (s *fileStore) Parents(ctx context.Context, projectID uuid.UUID) (map[uuid.UUID]*uuid.UUID, error) {
+	return collectParents(s.db.Query(ctx, `SELECT id, parent_id FROM files WHERE project_id = $1`, projectID))
+}
+
+func (s *fileStore) Ancestry(ctx context.Context, fileID uuid.UUID) (map[uuid.UUID]*uuid.UUID, error) {
+	query := `
+		WITH RECURSIVE chain AS (
+			SELECT id, parent_id FROM files WHERE id = $1
+			UNION ALL
+			SELECT f.id, f.parent_id FROM files f JOIN chain c ON f.id = c.parent_id
+		)
+		SELECT id, parent_id FROM chain`
+	return collectParents(s.db.Query(ctx, query, fileID))
+}
+
+func (s *fileStore) ChildParents(ctx context.Context, parentIDs []uuid.UUID) (map[uuid.UUID]*uuid.UUID, error) {
+	return collectParents(s.db.Query(ctx, `SELECT id, parent_id FROM files WHERE parent_id = ANY($1)`, parentIDs))
+}
+
+func collectRules(rows pgx.Rows, err error) ([]models.AccessRule, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	rules := make([]models.AccessRule, 0)
+	for rows.Next() {
+		var rule models.AccessRule
+		if err := rows.Scan(&rule.ID, &rule.FileID, &rule.SubjectType, &rule.Subject, &rule.Access); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+func (s *fileStore) ProjectRules(ctx context.Context, projectID uuid.UUID) ([]models.AccessRule, error) {
+	query := `SELECT id, file_id, subject_type, subject, access FROM file_acl_rules WHERE project_id = $1`
+	return collectRules(s.db.Query(ctx, query, projectID))
+}
+
+func (s *fileStore) Rules(ctx context.Context, fileIDs []uuid.UUID) ([]models.AccessRule, error) {
+	query := `SELECT id, file_id, subject_type, subject, access FROM file_acl_rules WHERE file_id = ANY($1) ORDER BY subject_type, subject`
+	return collectRules(s.db.Query(ctx, query, fileIDs))
+}
+
+func (s *fileStore) ReplaceRules(ctx context.Context, projectID string, fileID uuid.UUID, rules []models.AccessRule) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM file_acl_rules WHERE file_id = $1`, fileID); err != nil {
+		return err
+	}
+
+	insertQuery := `
+		INSERT INTO file_acl_rules (project_id, file_id, subject_type, subject, access)
+		VALUES ($1, $2, $3, $4, $5)`
+	for _, rule := range rules {
+		if _, err := tx.Exec(ctx, insertQuery, projectID, fileID, rule.SubjectType, rule.Subject, rule.Access); err != nil {
+			return mapErr(err)
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// lockProjectStorage serializes quota checks for a project by locking its
+// row, and returns the bytes its blobs use, not counting except.
+func lockProjectStorage(ctx context.Context, tx pgx.Tx, projectID, except uuid.UUID) (int64, error) {
+	var locked uuid.UUID
+	if err := tx.QueryRow(ctx, `SELECT id FROM projects WHERE id = $1 FOR UPDATE`, projectID).Scan(&locked); err != nil {
+		return 0, mapErr(err)
+	}
+	var used int64
+	query := `SELECT COALESCE(SUM(size), 0) FROM files WHERE project_id = $1 AND blob_key IS NOT NULL AND id <> $2`
+	err := tx.QueryRow(ctx, query, projectID, except).Scan(&used)
+	return used, err
+}
+
+func (s *fileStore) CreateBlob(ctx context.Context, node *models.FileNode, quota int64) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	used, err := lockProjectStorage(ctx, tx, node.ProjectID, uuid.Nil)
+	if err != nil {
+		return err
+	}
+	if used+node.Blob.Size > quota {
+		return store.ErrQuotaExceeded
+	}
+
+	query := `
+		INSERT INTO files (project_id, parent_id, is_folder, name, blob_key, content_type, size, sha256)
+		VALUES ($1, $2, FALSE, $3, $4, $5, $6, $7)
+		RETURNING id, revision, created_at, updated_at`
+	err = tx.QueryRow(ctx, query, node.ProjectID, node.ParentID, node.Name,
+		node.Blob.Key, node.Blob.ContentType, node.Blob.Size, node.Blob.SHA256).Scan(&node.ID, &node.Revision, &node.CreatedAt, &node.UpdatedAt)
+	if err != nil {
+		return mapErr(err)
+	}
+	node.Content = nil
+	return tx.Commit(ctx)
+}
+
+func (s *fileStore) ReplaceBlob(ctx context.Context, fileID uuid.UUID, blob models.Blob, quota int64, rev int64) (int64, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	var projectID uuid.UUID
+	err = tx.QueryRow(ctx, `SELECT project_id FROM files WHERE id = $1 AND NOT is_folder`, fileID).Scan(&projectID)
+	if err != nil {
+		return 0, mapErr(err)
+	}
+	used, err := lockProjectStorage(ctx, tx, projectID, fileID)
+	if err != nil {
+		return 0, err
+	}
+	if used+blob.Size > quota {
+		return 0, store.ErrQuotaExceeded
+	}
+
+	// The files_orphan_blob trigger queues the old blob for removal.
+	query := `
+		UPDATE files SET content = NULL, blob_key = $1, content_type = $2, size = $3, sha256 = $4,
+		revision = revision + 1, updated_at = NOW()
+		WHERE id = $5 AND ($6::bigint = 0 OR revision = $6)
+		RETURNING revision`
+	var revision int64
+	err = mapErr(tx.QueryRow(ctx, query, blob.Key, blob.ContentType, blob.Size, blob.SHA256, fileID, rev).Scan(&revision))
+	if errors.Is(err, store.ErrNotFound) {
+		err = staleOrMissing(ctx, tx, fileID)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return revision, tx.Commit(ctx)
+}
+
+func (s *fileStore) StorageUsed(ctx context.Context, projectID uuid.UUID) (int64, error) {
+	var used int64
+	query := `SELECT COALESCE(SUM(size), 0) FROM files WHERE project_id = $1 AND blob_key IS NOT NULL`
+	err := s.db.QueryRow(ctx, query, projectID).Scan(&used)
+	return used, err
+}
+
+func (s *fileStore) OrphanedBlobs(ctx context.Context, limit int) ([]string, error) {
+	query := `SELECT blob_key FROM orphaned_blobs ORDER BY orphaned_at LIMIT $1`
+	return collectStrings(s.db.Query(ctx, query, limit))
+}
+
+func (s *fileStore) ForgetOrphanedBlobs(ctx context.Context, keys []string) error {
+	_, err := s.db.Exec(ctx, `DELETE FROM orphaned_blobs WHERE blob_key = ANY($1)`, keys)
+	return err
+}
+
+func (s *fileStore) ReplaceTree(ctx context.Context, projectID uuid.UUID, nodes []models.FileNode, quota int64) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := lockProjectStorage(ctx, tx, projectID, uuid.Nil); err != nil {
+		return err
+	}
+	var size int64
+	for _, node := range nodes {
+		if node.Blob != nil {
+			size += node.Blob.Size
+		}
+	}
+	if size > quota {
+		return store.ErrQuotaExceeded
+	}
+
+	// The files_orphan_blob trigger queues the old blobs for removal, and
+	// access rules go with their files; those of nodes that come back are
+	// put back.
+	ids := make([]uuid.UUID, len(nodes))
+	for i, node := range nodes {
+		ids[i] = node.ID
+	}
+	query := `SELECT id, file_id, subject_type, subject, access FROM file_acl_rules WHERE project_id = $1 AND file_id = ANY($2)`
+	rules, err := collectRules(tx.Query(ctx, query, projectID, ids))
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM files WHERE project_id = $1`, projectID); err != nil {
+		return err
+	}
+	if err := insertTree(ctx, tx, projectID, nodes); err != nil {
+		return err
+	}
+	insertQuery := `
+		INSERT INTO file_acl_rules (id, project_id, file_id, subject_type, subject, access)
+		VALUES ($1, $2, $3, $4, $5, $6)`
+	for _, rule := range rules {
+		if _, err := tx.Exec(ctx, insertQuery, rule.ID, projectID, rule.FileID, rule.SubjectType, rule.Subject, rule.Access); err != nil {
+			return mapErr(err)
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+func (s *fileStore) CreateTree(ctx context.Context, projectID uuid.UUID, nodes []models.FileNode, quota int64) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	used, err := lockProjectStorage(ctx, tx, projectID, uuid.Nil)
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		if node.Blob != nil {
+			used += node.Blob.Size
+		}
+	}
+	if used > quota {
+		return store.ErrQuotaExceeded
+	}
+	if len(nodes) > 0 && nodes[0].ParentID != nil {
+		var isFolder bool
+		query := `SELECT is_folder FROM files WHERE id = $1 AND project_id = $2 FOR SHARE`
+		if err := tx.QueryRow(ctx, query, nodes[0].ParentID, projectID).Scan(&isFolder); err != nil {
+			return mapErr(err)
+		}
+		if !isFolder {
+			return store.ErrNotFound
+		}
+	}
+	if err := insertTree(ctx, tx, projectID, nodes); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// insertTree bulk-inserts nodes into a project. Their IDs are new and
+// parents come first, so the parent_id foreign key holds row by row.
+func insertTree(ctx context.Context, tx pgx.Tx, projectID uuid.UUID, nodes []models.FileNode) error {
+	if len(nodes) == 0 {
+		return nil
+	}
+	rows := make([][]any, len(nodes))
+	for i, node := range nodes {
+		row := []any{node.ID, projectID, node.ParentID, node.IsFolder, node.Name, node.Content, nil, nil, nil, nil}
+		if node.Blob != nil {
+			row[5] = nil
+			row[6], row[7], row[8], row[9] = node.Blob.Key, node.Blob.ContentType, node.Blob.Size, node.Blob.SHA256
+		}
+		rows[i] = row
+	}
+	columns := []string{"id", "project_id", "parent_id", "is_folder", "name", "content", "blob_key", "content_type", "size", "sha256"}
+	_, err := tx.CopyFrom(ctx, pgx.Identifier{"files"}, columns, pgx.CopyFromRows(rows))
+	return mapErr(err)
+}
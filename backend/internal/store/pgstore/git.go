@@ -0,0 +1,35 @@
+package pgstore
+
+import (
+	"context"
+
+	"project-meetings/backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type gitStore struct {
+	db *pgxpool.Pool
+}
+
+func (s *gitStore) Link(ctx context.Context, projectID uuid.UUID) (models.GitLink, error) {
+	var link models.GitLink
+	query := `SELECT project_id, remote_url, branch, head, updated_at FROM project_git_links WHERE project_id = $1`
+	err := s.db.QueryRow(ctx, query, projectID).Scan(&link.ProjectID, &link.RemoteURL, &link.Branch, &link.Head, &link.UpdatedAt)
+	return link, mapErr(err)
+}
+
+func (s *gitStore) SaveLink(ctx context.Context, link *models.GitLink) error {
+	query := `
+		INSERT INTO project_git_links (project_id, remote_url, branch, head, updated_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (project_id) DO UPDATE SET
+		remote_url = EXCLUDED.remote_url,
+		branch = EXCLUDED.branch,
+		head = EXCLUDED.head,
+		updated_at = NOW()
+		RETURNING updated_at`
+	err := s.db.QueryRow(ctx, query, link.ProjectID, link.RemoteURL, link.Branch, link.Head).Scan(&link.UpdatedAt)
+	return mapErr(err)
+}
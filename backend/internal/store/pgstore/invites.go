@@ -0,0 +1,103 @@
+package pgstore
+
+import (
+	"context"
+
+	"project-meetings/backend/internal/models"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type inviteStore struct {
+	db *pgxpool.Pool
+}
+
+const inviteColumns = `id, project_id, code, created_by, role, max_uses, use_count, email, expires_at, created_at`
+
+func scanInvite(row interface{ Scan(...any) error }) (models.ProjectInvite, error) {
+	var invite models.ProjectInvite
+	err := row.Scan(
+		&invite.ID, &invite.ProjectID, &invite.Code, &invite.CreatedBy, &invite.Role,
+		&invite.MaxUses, &invite.UseCount, &invite.Email, &invite.ExpiresAt, &invite.CreatedAt,
+	)
+	return invite, mapErr(err)
+}
+
+func (s *inviteStore) Create(ctx context.Context, invite *models.ProjectInvite) error {
+	query := `
+		INSERT INTO project_invites (project_id, code, created_by, role, max_uses, email, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, use_count, created_at`
+	err := s.db.QueryRow(ctx, query, invite.ProjectID, invite.Code, invite.CreatedBy, invite.Role,
+		invite.MaxUses, invite.Email, invite.ExpiresAt).Scan(&invite.ID, &invite.UseCount, &invite.CreatedAt)
+	return mapErr(err)
+}
+
+func (s *inviteStore) ListActive(ctx context.Context, projectID string) ([]models.ProjectInvite, error) {
+	query := `
+		SELECT ` + inviteColumns + `
+		FROM project_invites
+		WHERE project_id = $1
+		  AND revoked_at IS NULL
+		  AND (expires_at IS NULL OR expires_at > NOW())
+		  AND (max_uses IS NULL OR use_count < max_uses)
+		ORDER BY created_at DESC`
+	rows, err := s.db.Query(ctx, query, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invites := make([]models.ProjectInvite, 0)
+	for rows.Next() {
+		invite, err := scanInvite(rows)
+		if err != nil {
+			return nil, err
+		}
+		invites = append(invites, invite)
+	}
+	return invites, rows.Err()
+}
+
+func (s *inviteStore) Revoke(ctx context.Context, projectID, inviteID string) error {
+	query := `UPDATE project_invites SET revoked_at = NOW() WHERE id = $1 AND project_id = $2 AND revoked_at IS NULL`
+	return mustAffect(s.db.Exec(ctx, query, inviteID, projectID))
+}
+
+func (s *inviteStore) Accept(ctx context.Context, code, userID string, check func(models.ProjectInvite) error) (models.ProjectInvite, bool, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return models.ProjectInvite{}, false, err
+	}
+	defer tx.Rollback(ctx)
+
+	// FOR UPDATE serialises concurrent accepts of the same code, so the use
+	// count check can't be raced past the limit.
+	findQuery := `SELECT ` + inviteColumns + ` FROM project_invites WHERE code = $1 AND revoked_at IS NULL FOR UPDATE`
+	invite, err := scanInvite(tx.QueryRow(ctx, findQuery, code))
+	if err != nil {
+		return models.ProjectInvite{}, false, err
+	}
+	if err := check(invite); err != nil {
+		return invite, false, err
+	}
+
+	// ON CONFLICT DO NOTHING prevents an error if the user is already a member,
+	// in which case the invite is not consumed.
+	addMemberQuery := `
+		INSERT INTO project_members (project_id, user_id, role) VALUES ($1, $2, $3)
+		ON CONFLICT (project_id, user_id) DO NOTHING`
+	tag, err := tx.Exec(ctx, addMemberQuery, invite.ProjectID, userID, invite.Role)
+	if err != nil {
+		return invite, false, err
+	}
+
+	joined := tag.RowsAffected() > 0
+	if joined {
+		if _, err := tx.Exec(ctx, `UPDATE project_invites SET use_count = use_count + 1 WHERE id = $1`, invite.ID); err != nil {
+			return invite, false, err
+		}
+		invite.UseCount++
+	}
+	return invite, joined, tx.Commit(ctx)
+}
@@ -0,0 +1,137 @@
+package pgstore
+
+import (
+	"context"
+
+	"project-meetings/backend/internal/models"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type organizationStore struct {
+	db *pgxpool.Pool
+}
+
+const organizationColumns = `id, name, default_project_role, created_at, updated_at`
+
+func scanOrganization(row interface{ Scan(...any) error }) (models.Organization, error) {
+	var org models.Organization
+	err := row.Scan(&org.ID, &org.Name, &org.DefaultProjectRole, &org.CreatedAt, &org.UpdatedAt)
+	return org, mapErr(err)
+}
+
+func (s *organizationStore) Create(ctx context.Context, name, defaultProjectRole, ownerID string) (models.Organization, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return models.Organization{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	orgQuery := `INSERT INTO organizations (name, default_project_role) VALUES ($1, $2) RETURNING ` + organizationColumns
+	org, err := scanOrganization(tx.QueryRow(ctx, orgQuery, name, defaultProjectRole))
+	if err != nil {
+		return models.Organization{}, err
+	}
+
+	memberQuery := `INSERT INTO organization_members (organization_id, user_id, role) VALUES ($1, $2, 'owner')`
+	if _, err := tx.Exec(ctx, memberQuery, org.ID, ownerID); err != nil {
+		return models.Organization{}, err
+	}
+	org.Role = "owner"
+	return org, tx.Commit(ctx)
+}
+
+func (s *organizationStore) Get(ctx context.Context, organizationID string) (models.Organization, error) {
+	return scanOrganization(s.db.QueryRow(ctx, `SELECT `+organizationColumns+` FROM organizations WHERE id = $1`, organizationID))
+}
+
+func (s *organizationStore) ListForUser(ctx context.Context, userID string) ([]models.Organization, error) {
+	query := `
+		SELECT o.id, o.name, o.default_project_role, o.created_at, o.updated_at, om.role
+		FROM organizations o
+		JOIN organization_members om ON om.organization_id = o.id
+		WHERE om.user_id = $1
+		ORDER BY o.name`
+	rows, err := s.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orgs := make([]models.Organization, 0)
+	for rows.Next() {
+		var org models.Organization
+		if err := rows.Scan(&org.ID, &org.Name, &org.DefaultProjectRole, &org.CreatedAt, &org.UpdatedAt, &org.Role); err != nil {
+			return nil, err
+		}
+		orgs = append(orgs, org)
+	}
+	return orgs, rows.Err()
+}
+
+func (s *organizationStore) Update(ctx context.Context, organizationID string, name, defaultProjectRole *string) (models.Organization, error) {
+	query := `
+		UPDATE organizations SET
+			name = COALESCE($1, name),
+			default_project_role = COALESCE($2, default_project_role),
+			updated_at = NOW()
+		WHERE id = $3
+		RETURNING ` + organizationColumns
+	return scanOrganization(s.db.QueryRow(ctx, query, name, defaultProjectRole, organizationID))
+}
+
+func (s *organizationStore) Delete(ctx context.Context, organizationID string) error {
+	return mustAffect(s.db.Exec(ctx, `DELETE FROM organizations WHERE id = $1`, organizationID))
+}
+
+func (s *organizationStore) MemberRole(ctx context.Context, organizationID, userID string) (string, error) {
+	var role string
+	query := `SELECT role FROM organization_members WHERE organization_id = $1 AND user_id = $2`
+	err := s.db.QueryRow(ctx, query, organizationID, userID).Scan(&role)
+	return role, mapErr(err)
+}
+
+func (s *organizationStore) Members(ctx context.Context, organizationID string) ([]models.OrganizationMember, error) {
+	query := `
+		SELECT u.id, u.username, u.email, om.role, om.joined_at
+		FROM organization_members om
+		JOIN users u ON om.user_id = u.id
+		WHERE om.organization_id = $1
+		ORDER BY u.username`
+	rows, err := s.db.Query(ctx, query, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := make([]models.OrganizationMember, 0)
+	for rows.Next() {
+		var m models.OrganizationMember
+		if err := rows.Scan(&m.UserID, &m.Username, &m.Email, &m.Role, &m.JoinedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+func (s *organizationStore) AddMember(ctx context.Context, organizationID, userID, role string) (bool, error) {
+	query := `
+		INSERT INTO organization_members (organization_id, user_id, role) VALUES ($1, $2, $3)
+		ON CONFLICT (organization_id, user_id) DO NOTHING`
+	tag, err := s.db.Exec(ctx, query, organizationID, userID, role)
+	if err != nil {
+		return false, mapErr(err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (s *organizationStore) UpdateMemberRole(ctx context.Context, organizationID, userID, role string) error {
+	query := `UPDATE organization_members SET role = $1 WHERE organization_id = $2 AND user_id = $3`
+	return mustAffect(s.db.Exec(ctx, query, role, organizationID, userID))
+}
+
+func (s *organizationStore) RemoveMember(ctx context.Context, organizationID, userID string) error {
+	query := `DELETE FROM organization_members WHERE organization_id = $1 AND user_id = $2`
+	return mustAffect(s.db.Exec(ctx, query, organizationID, userID))
+}
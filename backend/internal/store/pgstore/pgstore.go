@@ -0,0 +1,73 @@
+// Package pgstore implements the store interfaces on Postgres through pgx.
+package pgstore
+
+import (
+	"errors"
+
+	"project-meetings/backend/internal/store"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// New returns a Store whose repositories all share pool.
+func New(pool *pgxpool.Pool) *store.Store {
+	return &store.Store{
+		Users:         &userStore{db: pool},
+		Projects:      &projectStore{db: pool},
+		Files:         &fileStore{db: pool},
+		Whiteboards:   &whiteboardStore{db: pool},
+		Invites:       &inviteStore{db: pool},
+		Organizations: &organizationStore{db: pool},
+		Audit:         &auditStore{db: pool},
+		Git:           &gitStore{db: pool},
+		Drafts:        &draftStore{db: pool},
+	}
+}
+
+// uniqueViolation is the Postgres error code for a unique constraint clash.
+const uniqueViolation = "23505"
+
+// mapErr translates pgx errors into the store's sentinel errors.
+func mapErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return store.ErrNotFound
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return store.ErrConflict
+	}
+	return err
+}
+
+// mustAffect turns an update that matched no rows into ErrNotFound.
+func mustAffect(tag pgconn.CommandTag, err error) error {
+	if err != nil {
+		return mapErr(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+// collectStrings reads a single text column from every row.
+func collectStrings(rows pgx.Rows, err error) ([]string, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	values := make([]string, 0)
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
@@ -0,0 +1,24 @@
+package pgstore
+
+import (
+	"testing"
+
+	"project-meetings/backend/internal/database/dbtest"
+	"project-meetings/backend/internal/store/storetest"
+)
+
+func TestDrafts(t *testing.T) {
+	storetest.Drafts(t, New(dbtest.Pool(t)))
+}
+
+func TestAudit(t *testing.T) {
+	storetest.Audit(t, New(dbtest.Pool(t)))
+}
+
+func TestUpdateContent(t *testing.T) {
+	storetest.UpdateContent(t, New(dbtest.Pool(t)))
+}
+
+func TestReplaceTree(t *testing.T) {
+	storetest.ReplaceTree(t, New(dbtest.Pool(t)))
+}
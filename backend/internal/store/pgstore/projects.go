@@ -0,0 +1,250 @@
+package pgstore
+
+import (
+	"context"
+
+	"project-meetings/backend/internal/models"
+	"project-meetings/backend/internal/store"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type projectStore struct {
+	db *pgxpool.Pool
+}
+
+const projectColumns = `id, owner_id, organization_id, forked_from, is_template, name, created_at, updated_at`
+
+func scanProject(row interface{ Scan(...any) error }) (models.Project, error) {
+	var p models.Project
+	err := row.Scan(&p.ID, &p.OwnerID, &p.OrganizationID, &p.ForkedFrom, &p.IsTemplate, &p.Name, &p.CreatedAt, &p.UpdatedAt)
+	return p, mapErr(err)
+}
+
+func collectProjects(rows pgx.Rows, err error) ([]models.Project, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	projects := make([]models.Project, 0)
+	for rows.Next() {
+		p, err := scanProject(rows)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, p)
+	}
+	return projects, rows.Err()
+}
+
+func (s *projectStore) Create(ctx context.Context, name, ownerID string, organizationID *uuid.UUID) (models.Project, error) {
+	return s.CreateFrom(ctx, name, ownerID, organizationID, store.ProjectSeed{})
+}
+
+func (s *projectStore) CreateFrom(ctx context.Context, name, ownerID string, organizationID *uuid.UUID, seed store.ProjectSeed) (models.Project, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return models.Project{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	projectQuery := `INSERT INTO projects (name, owner_id, organization_id, forked_from) VALUES ($1, $2, $3, $4) RETURNING ` + projectColumns
+	project, err := scanProject(tx.QueryRow(ctx, projectQuery, name, ownerID, organizationID, seed.ForkedFrom))
+	if err != nil {
+		return models.Project{}, err
+	}
+
+	memberQuery := `INSERT INTO project_members (project_id, user_id, role) VALUES ($1, $2, 'owner')`
+	if _, err := tx.Exec(ctx, memberQuery, project.ID, ownerID); err != nil {
+		return models.Project{}, err
+	}
+	if err := insertTree(ctx, tx, project.ID, seed.Files); err != nil {
+		return models.Project{}, err
+	}
+	for shapeID, data := range seed.Shapes {
+		shapeQuery := `INSERT INTO whiteboard_shapes (id, project_id, shape_data, updated_at) VALUES ($1, $2, $3, NOW())`
+		if _, err := tx.Exec(ctx, shapeQuery, shapeID, project.ID, []byte(data)); err != nil {
+			return models.Project{}, err
+		}
+	}
+	return project, tx.Commit(ctx)
+}
+
+func (s *projectStore) Get(ctx context.Context, projectID string) (models.Project, error) {
+	return scanProject(s.db.QueryRow(ctx, `SELECT `+projectColumns+` FROM projects WHERE id = $1`, projectID))
+}
+
+func (s *projectStore) ListForUser(ctx context.Context, userID string) ([]models.Project, error) {
+	query := `
+		SELECT p.id, p.owner_id, p.organization_id, p.forked_from, p.is_template, p.name, p.created_at, p.updated_at
+		FROM projects p
+		WHERE EXISTS (SELECT 1 FROM project_members pm WHERE pm.project_id = p.id AND pm.user_id = $1)
+		   OR p.organization_id IN (SELECT organization_id FROM organization_members WHERE user_id = $1)
+		ORDER BY p.created_at DESC`
+	return collectProjects(s.db.Query(ctx, query, userID))
+}
+
+func (s *projectStore) ListForOrganization(ctx context.Context, organizationID string) ([]models.Project, error) {
+	query := `SELECT ` + projectColumns + ` FROM projects WHERE organization_id = $1 ORDER BY created_at DESC`
+	return collectProjects(s.db.Query(ctx, query, organizationID))
+}
+
+func (s *projectStore) Rename(ctx context.Context, projectID, name string) error {
+	return mustAffect(s.db.Exec(ctx, `UPDATE projects SET name = $1, updated_at = NOW() WHERE id = $2`, name, projectID))
+}
+
+func (s *projectStore) Delete(ctx context.Context, projectID string) error {
+	// ON DELETE CASCADE cleans up members, files, whiteboard shapes, invites,
+	// roles and access rules.
+	return mustAffect(s.db.Exec(ctx, `DELETE FROM projects WHERE id = $1`, projectID))
+}
+
+func (s *projectStore) SetTemplate(ctx context.Context, projectID string, isTemplate bool) error {
+	query := `UPDATE projects SET is_template = $1, updated_at = NOW() WHERE id = $2`
+	return mustAffect(s.db.Exec(ctx, query, isTemplate, projectID))
+}
+
+func (s *projectStore) SetOrganization(ctx context.Context, projectID string, organizationID *uuid.UUID) error {
+	query := `UPDATE projects SET organization_id = $1, updated_at = NOW() WHERE id = $2`
+	return mustAffect(s.db.Exec(ctx, query, organizationID, projectID))
+}
+
+func (s *projectStore) TransferOwnership(ctx context.Context, projectID, fromUserID, toUserID string) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the project row so two concurrent transfers can't both succeed.
+	var currentOwner uuid.UUID
+	if err := tx.QueryRow(ctx, `SELECT owner_id FROM projects WHERE id = $1 FOR UPDATE`, projectID).Scan(&currentOwner); err != nil {
+		return mapErr(err)
+	}
+	if currentOwner.String() != fromUserID {
+		return store.ErrNotOwner
+	}
+
+	tag, err := tx.Exec(ctx, `UPDATE project_members SET role = 'owner' WHERE project_id = $1 AND user_id = $2`, projectID, toUserID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return store.ErrNotMember
+	}
+
+	// The previous owner stays on the project as an editor.
+	if _, err := tx.Exec(ctx, `UPDATE project_members SET role = 'editor' WHERE project_id = $1 AND user_id = $2`, projectID, fromUserID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE projects SET owner_id = $1, updated_at = NOW() WHERE id = $2`, toUserID, projectID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (s *projectStore) ResolveRole(ctx context.Context, projectID, userID string) (string, error) {
+	var role string
+	query := `
+		SELECT COALESCE(
+			pm.role,
+			CASE WHEN om.role IN ('owner', 'admin') THEN 'owner' ELSE o.default_project_role END
+		)
+		FROM projects p
+		LEFT JOIN project_members pm ON pm.project_id = p.id AND pm.user_id = $2
+		LEFT JOIN organizations o ON o.id = p.organization_id
+		LEFT JOIN organization_members om ON om.organization_id = o.id AND om.user_id = $2
+		WHERE p.id = $1 AND (pm.user_id IS NOT NULL OR om.user_id IS NOT NULL)`
+	err := s.db.QueryRow(ctx, query, projectID, userID).Scan(&role)
+	return role, mapErr(err)
+}
+
+func (s *projectStore) Members(ctx context.Context, projectID string) ([]models.ProjectMember, error) {
+	query := `
+		SELECT u.id, u.username, u.email, pm.role
+		FROM project_members pm
+		JOIN users u ON pm.user_id = u.id
+		WHERE pm.project_id = $1
+		ORDER BY u.username`
+	rows, err := s.db.Query(ctx, query, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := make([]models.ProjectMember, 0)
+	for rows.Next() {
+		var m models.ProjectMember
+		if err := rows.Scan(&m.UserID, &m.Username, &m.Email, &m.Role); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+func (s *projectStore) MemberIDsWithRole(ctx context.Context, projectID, role string) ([]string, error) {
+	query := `SELECT user_id::text FROM project_members WHERE project_id = $1 AND role = $2`
+	return collectStrings(s.db.Query(ctx, query, projectID, role))
+}
+
+func (s *projectStore) UpdateMemberRole(ctx context.Context, projectID, userID, role string) error {
+	query := `UPDATE project_members SET role = $1 WHERE project_id = $2 AND user_id = $3`
+	return mustAffect(s.db.Exec(ctx, query, role, projectID, userID))
+}
+
+func (s *projectStore) RemoveMember(ctx context.Context, projectID, userID string) error {
+	query := `DELETE FROM project_members WHERE project_id = $1 AND user_id = $2`
+	return mustAffect(s.db.Exec(ctx, query, projectID, userID))
+}
+
+func (s *projectStore) Roles(ctx context.Context, projectID string) ([]models.ProjectRole, error) {
+	rows, err := s.db.Query(ctx, `SELECT name, permissions FROM project_roles WHERE project_id = $1 ORDER BY name`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := make([]models.ProjectRole, 0)
+	for rows.Next() {
+		var role models.ProjectRole
+		if err := rows.Scan(&role.Name, &role.Permissions); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+func (s *projectStore) RolePermissions(ctx context.Context, projectID, role string) ([]string, error) {
+	var names []string
+	query := `SELECT permissions FROM project_roles WHERE project_id = $1 AND name = $2`
+	err := s.db.QueryRow(ctx, query, projectID, role).Scan(&names)
+	return names, mapErr(err)
+}
+
+func (s *projectStore) CreateRole(ctx context.Context, projectID string, role models.ProjectRole) error {
+	query := `INSERT INTO project_roles (project_id, name, permissions) VALUES ($1, $2, $3)`
+	_, err := s.db.Exec(ctx, query, projectID, role.Name, role.Permissions)
+	return mapErr(err)
+}
+
+func (s *projectStore) UpdateRole(ctx context.Context, projectID string, role models.ProjectRole) error {
+	query := `UPDATE project_roles SET permissions = $1 WHERE project_id = $2 AND name = $3`
+	return mustAffect(s.db.Exec(ctx, query, role.Permissions, projectID, role.Name))
+}
+
+func (s *projectStore) DeleteRole(ctx context.Context, projectID, role string) error {
+	return mustAffect(s.db.Exec(ctx, `DELETE FROM project_roles WHERE project_id = $1 AND name = $2`, projectID, role))
+}
+
+func (s *projectStore) RoleInUse(ctx context.Context, projectID, role string) (bool, error) {
+	var inUse bool
+	query := `
+		SELECT EXISTS(SELECT 1 FROM project_members WHERE project_id = $1 AND role = $2)
+		    OR EXISTS(SELECT 1 FROM project_invites WHERE project_id = $1 AND role = $2 AND revoked_at IS NULL)`
+	err := s.db.QueryRow(ctx, query, projectID, role).Scan(&inUse)
+	return inUse, err
+}
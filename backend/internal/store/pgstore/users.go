@@ -0,0 +1,148 @@
+package pgstore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"project-meetings/backend/internal/models"
+	"project-meetings/backend/internal/store"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type userStore struct {
+	db *pgxpool.Pool
+}
+
+const userColumns = `id, email, username, display_name, avatar_url, timezone, password_hash, created_at, updated_at`
+
+func scanUser(row interface{ Scan(...any) error }) (models.User, error) {
+	var user models.User
+	err := row.Scan(
+		&user.ID, &user.Email, &user.Username, &user.DisplayName, &user.AvatarURL,
+		&user.Timezone, &user.PasswordHash, &user.CreatedAt, &user.UpdatedAt,
+	)
+	return user, mapErr(err)
+}
+
+func (s *userStore) Create(ctx context.Context, username, email, passwordHash string) (models.User, error) {
+	query := `INSERT INTO users (username, email, password_hash) VALUES ($1, $2, $3) RETURNING ` + userColumns
+	return scanUser(s.db.QueryRow(ctx, query, username, email, passwordHash))
+}
+
+func (s *userStore) Get(ctx context.Context, userID string) (models.User, error) {
+	return scanUser(s.db.QueryRow(ctx, `SELECT `+userColumns+` FROM users WHERE id = $1`, userID))
+}
+
+func (s *userStore) GetByEmail(ctx context.Context, email string) (models.User, error) {
+	return scanUser(s.db.QueryRow(ctx, `SELECT `+userColumns+` FROM users WHERE email = $1`, email))
+}
+
+func (s *userStore) EmailInUse(ctx context.Context, email string) (bool, error) {
+	var exists bool
+	err := s.db.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM users WHERE email = $1)`, email).Scan(&exists)
+	return exists, err
+}
+
+func (s *userStore) UpdateProfile(ctx context.Context, user *models.User) error {
+	query := `
+		UPDATE users SET username = $1, display_name = $2, avatar_url = $3, timezone = $4, updated_at = NOW()
+		WHERE id = $5 RETURNING updated_at`
+	err := s.db.QueryRow(ctx, query, user.Username, user.DisplayName, user.AvatarURL, user.Timezone, user.ID).Scan(&user.UpdatedAt)
+	return mapErr(err)
+}
+
+func (s *userStore) UpdatePassword(ctx context.Context, userID, passwordHash string) error {
+	return mustAffect(s.db.Exec(ctx, `UPDATE users SET password_hash = $1, updated_at = NOW() WHERE id = $2`, passwordHash, userID))
+}
+
+func (s *userStore) RequestEmailChange(ctx context.Context, userID, newEmail, tokenHash string, expiresAt time.Time) error {
+	query := `
+		INSERT INTO email_change_requests (user_id, new_email, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE SET
+		new_email = EXCLUDED.new_email,
+		token_hash = EXCLUDED.token_hash,
+		expires_at = EXCLUDED.expires_at,
+		created_at = NOW()`
+	_, err := s.db.Exec(ctx, query, userID, newEmail, tokenHash, expiresAt)
+	return err
+}
+
+func (s *userStore) ConfirmEmailChange(ctx context.Context, userID, tokenHash string) (string, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
+
+	var newEmail string
+	var expiresAt time.Time
+	findQuery := `
+		DELETE FROM email_change_requests WHERE user_id = $1 AND token_hash = $2
+		RETURNING new_email, expires_at`
+	if err := tx.QueryRow(ctx, findQuery, userID, tokenHash).Scan(&newEmail, &expiresAt); err != nil {
+		return "", mapErr(err)
+	}
+	// Returning before commit keeps the request, so an expired code can be
+	// told apart from a wrong one until a new code is requested.
+	if time.Now().After(expiresAt) {
+		return "", store.ErrExpired
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE users SET email = $1, updated_at = NOW() WHERE id = $2`, newEmail, userID); err != nil {
+		return "", mapErr(err)
+	}
+	return newEmail, tx.Commit(ctx)
+}
+
+func (s *userStore) Delete(ctx context.Context, userID string, transfers map[string]string) (map[string]string, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	ownedProjects, err := collectStrings(tx.Query(ctx, `SELECT id::text FROM projects WHERE owner_id = $1 FOR UPDATE`, userID))
+	if err != nil {
+		return nil, fmt.Errorf("list owned projects: %w", err)
+	}
+
+	transferred := make(map[string]string)
+	for _, projectID := range ownedProjects {
+		newOwnerID, transfer := transfers[projectID]
+		if !transfer {
+			if _, err := tx.Exec(ctx, `DELETE FROM projects WHERE id = $1`, projectID); err != nil {
+				return nil, fmt.Errorf("delete project %s: %w", projectID, err)
+			}
+			continue
+		}
+
+		tag, err := tx.Exec(ctx, `UPDATE project_members SET role = 'owner' WHERE project_id = $1 AND user_id = $2`, projectID, newOwnerID)
+		if err != nil {
+			return nil, fmt.Errorf("promote new owner of project %s: %w", projectID, err)
+		}
+		if tag.RowsAffected() == 0 {
+			return nil, fmt.Errorf("project %s: %w", projectID, store.ErrNotMember)
+		}
+		if _, err := tx.Exec(ctx, `UPDATE projects SET owner_id = $1, updated_at = NOW() WHERE id = $2`, newOwnerID, projectID); err != nil {
+			return nil, fmt.Errorf("transfer project %s: %w", projectID, err)
+		}
+		transferred[projectID] = newOwnerID
+	}
+
+	// Organizations the user owns go with them; their projects survive
+	// without an organization (ON DELETE SET NULL).
+	orgQuery := `DELETE FROM organizations WHERE id IN (SELECT organization_id FROM organization_members WHERE user_id = $1 AND role = 'owner')`
+	if _, err := tx.Exec(ctx, orgQuery, userID); err != nil {
+		return nil, fmt.Errorf("delete organizations: %w", err)
+	}
+
+	// Memberships, invites and pending email changes go with the user via ON DELETE CASCADE.
+	if err := mustAffect(tx.Exec(ctx, `DELETE FROM users WHERE id = $1`, userID)); err != nil {
+		return nil, err
+	}
+
+	return transferred, tx.Commit(ctx)
+}
@@ -0,0 +1,47 @@
+package pgstore
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type whiteboardStore struct {
+	db *pgxpool.Pool
+}
+
+func (s *whiteboardStore) Shapes(ctx context.Context, projectID string) (map[string]json.RawMessage, error) {
+	rows, err := s.db.Query(ctx, `SELECT id, shape_data FROM whiteboard_shapes WHERE project_id = $1`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shapes := make(map[string]json.RawMessage)
+	for rows.Next() {
+		var id string
+		var data []byte
+		if err := rows.Scan(&id, &data); err != nil {
+			return nil, err
+		}
+		shapes[id] = data
+	}
+	return shapes, rows.Err()
+}
+
+func (s *whiteboardStore) SaveShape(ctx context.Context, projectID, shapeID string, data json.RawMessage) error {
+	query := `
+		INSERT INTO whiteboard_shapes (id, project_id, shape_data, updated_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (id, project_id) DO UPDATE SET
+		shape_data = EXCLUDED.shape_data,
+		updated_at = NOW()`
+	_, err := s.db.Exec(ctx, query, shapeID, projectID, []byte(data))
+	return err
+}
+
+func (s *whiteboardStore) DeleteShape(ctx context.Context, projectID, shapeID string) error {
+	_, err := s.db.Exec(ctx, `DELETE FROM whiteboard_shapes WHERE id = $1 AND project_id = $2`, shapeID, projectID)
+	return err
+}
@@ -0,0 +1,270 @@
+// Package store defines the persistence interfaces used by the HTTP handlers
+// and the WebSocket hub. The pgstore package implements them on Postgres and
+// memstore keeps everything in memory for tests and local experiments.
+//
+// Implementations report missing rows as ErrNotFound and unique-key clashes
+// as ErrConflict so callers never have to know which database is behind them.
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"project-meetings/backend/internal/audit"
+	"project-meetings/backend/internal/models"
+	"project-meetings/backend/internal/search"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNotFound = errors.New("not found")
+	ErrConflict = errors.New("already exists")
+	// ErrExpired is returned for one-time tokens used after their deadline.
+	ErrExpired = errors.New("expired")
+	// ErrNotOwner is returned when an ownership change is attempted by
+	// someone who no longer owns the project.
+	ErrNotOwner = errors.New("not the project owner")
+	// ErrNotMember is returned when an operation needs a user to already be
+	// an explicit member of a project.
+	ErrNotMember = errors.New("not a project member")
+	// ErrQuotaExceeded is returned when an upload would take a project over
+	// its storage quota.
+	ErrQuotaExceeded = errors.New("storage quota exceeded")
+	// ErrStale is returned by a conditional write when the row has changed
+	// since the revision the caller read.
+	ErrStale = errors.New("stale revision")
+)
+
+// Store bundles every repository the application needs.
+type Store struct {
+	Users         UserStore
+	Projects      ProjectStore
+	Files         FileStore
+	Whiteboards   WhiteboardStore
+	Invites       InviteStore
+	Organizations OrganizationStore
+	Audit         AuditStore
+	Blobs         BlobStore
+	Git           GitStore
+	Drafts        DraftStore
+}
+
+type UserStore interface {
+	// Create inserts a user and returns it with its generated fields filled in.
+	Create(ctx context.Context, username, email, passwordHash string) (models.User, error)
+	Get(ctx context.Context, userID string) (models.User, error)
+	GetByEmail(ctx context.Context, email string) (models.User, error)
+	EmailInUse(ctx context.Context, email string) (bool, error)
+	// UpdateProfile saves the username, display name, avatar and timezone of
+	// user and refreshes its UpdatedAt.
+	UpdateProfile(ctx context.Context, user *models.User) error
+	UpdatePassword(ctx context.Context, userID, passwordHash string) error
+
+	// RequestEmailChange stores a pending email change, replacing any earlier
+	// one for the same user.
+	RequestEmailChange(ctx context.Context, userID, newEmail, tokenHash string, expiresAt time.Time) error
+	// ConfirmEmailChange applies the pending change matching tokenHash and
+	// returns the new address. The request is consumed only on success.
+	ConfirmEmailChange(ctx context.Context, userID, tokenHash string) (string, error)
+
+	// Delete removes a user. Projects they own are handed to the member named
+	// in transfers (projectID -> userID) or deleted, and organizations they own
+	// are deleted. It returns the projects that changed hands.
+	Delete(ctx context.Context, userID string, transfers map[string]string) (map[string]string, error)
+}
+
+// ProjectSeed is what a new project starts out with.
+type ProjectSeed struct {
+	// Files carry their own IDs and come parents first.
+	Files []models.FileNode
+	// Shapes are whiteboard shapes keyed by their client-generated ID.
+	Shapes map[string]json.RawMessage
+	// ForkedFrom is the project the seed was copied from, if any.
+	ForkedFrom *uuid.UUID
+}
+
+type ProjectStore interface {
+	// Create inserts a project and makes ownerID its owner.
+	Create(ctx context.Context, name, ownerID string, organizationID *uuid.UUID) (models.Project, error)
+	// CreateFrom is Create for a project that starts out with the files and
+	// shapes of seed, all in one transaction.
+	CreateFrom(ctx context.Context, name, ownerID string, organizationID *uuid.UUID, seed ProjectSeed) (models.Project, error)
+	Get(ctx context.Context, projectID string) (models.Project, error)
+	// ListForUser returns the projects a user can open, directly or through
+	// one of their organizations, newest first.
+	ListForUser(ctx context.Context, userID string) ([]models.Project, error)
+	ListForOrganization(ctx context.Context, organizationID string) ([]models.Project, error)
+	Rename(ctx context.Context, projectID, name string) error
+	// Delete removes a project with its members, files, invites and rules.
+	Delete(ctx context.Context, projectID string) error
+	SetOrganization(ctx context.Context, projectID string, organizationID *uuid.UUID) error
+	// SetTemplate marks a project as a template for new projects, or unmarks it.
+	SetTemplate(ctx context.Context, projectID string, isTemplate bool) error
+	// TransferOwnership makes toUserID the owner and demotes fromUserID to
+	// editor, provided fromUserID still owns the project.
+	TransferOwnership(ctx context.Context, projectID, fromUserID, toUserID string) error
+
+	// ResolveRole returns the role a user holds in a project: an explicit
+	// membership first, then 'owner' for org owners and admins, then the
+	// organization's default role. ErrNotFound means no access at all.
+	ResolveRole(ctx context.Context, projectID, userID string) (string, error)
+	Members(ctx context.Context, projectID string) ([]models.ProjectMember, error)
+	MemberIDsWithRole(ctx context.Context, projectID, role string) ([]string, error)
+	UpdateMemberRole(ctx context.Context, projectID, userID, role string) error
+	RemoveMember(ctx context.Context, projectID, userID string) error
+
+	Roles(ctx context.Context, projectID string) ([]models.ProjectRole, error)
+	// RolePermissions returns the permission names of a custom role.
+	RolePermissions(ctx context.Context, projectID, role string) ([]string, error)
+	CreateRole(ctx context.Context, projectID string, role models.ProjectRole) error
+	UpdateRole(ctx context.Context, projectID string, role models.ProjectRole) error
+	DeleteRole(ctx context.Context, projectID, role string) error
+	// RoleInUse reports whether any member or open invite uses a role.
+	RoleInUse(ctx context.Context, projectID, role string) (bool, error)
+}
+
+// FileStore keeps a project's files and folders. Writes to a single node
+// take rev: when it is not zero the write only happens while the node is
+// still at that revision, and fails with ErrStale otherwise. Every write
+// bumps the node's revision and returns the new one.
+type FileStore interface {
+	// List returns every node of a project, including content, ordered by name.
+	List(ctx context.Context, projectID uuid.UUID) ([]models.FileNode, error)
+	// Outline returns every node of a project without content.
+	Outline(ctx context.Context, projectID uuid.UUID) ([]models.FileNode, error)
+	// Children returns up to limit nodes directly below parentID (the top of
+	// the project when nil) without content, ordered by name and starting
+	// after the name after.
+	Children(ctx context.Context, projectID uuid.UUID, parentID *uuid.UUID, after string, limit int) ([]models.FileNode, error)
+	Get(ctx context.Context, fileID uuid.UUID) (models.FileNode, error)
+	// ResolvePath follows segments by name from the top of a project down the
+	// parent_id chain and returns the node at the end, with content.
+	ResolvePath(ctx context.Context, projectID uuid.UUID, segments []string) (models.FileNode, error)
+	// Create inserts node and fills in its ID and timestamps. A sibling with
+	// the same name is an ErrConflict.
+	Create(ctx context.Context, node *models.FileNode) error
+	// UpdateContent saves text content. A binary file becomes a text file; a
+	// folder is ErrNotFound.
+	UpdateContent(ctx context.Context, fileID uuid.UUID, content string, rev int64) (int64, error)
+	// CreateBlob inserts a binary file described by node.Blob, failing with
+	// ErrQuotaExceeded if the project's blobs would then exceed quota bytes.
+	CreateBlob(ctx context.Context, node *models.FileNode, quota int64) error
+	// ReplaceBlob points an existing file at new bytes under the same quota
+	// rule, not counting the blob it replaces. A text file becomes binary.
+	ReplaceBlob(ctx context.Context, fileID uuid.UUID, blob models.Blob, quota int64, rev int64) (int64, error)
+	// StorageUsed returns the bytes of blob storage a project's files use.
+	StorageUsed(ctx context.Context, projectID uuid.UUID) (int64, error)
+	// OrphanedBlobs returns up to limit keys of blobs that no file refers to
+	// anymore, after a delete or a replacement.
+	OrphanedBlobs(ctx context.Context, limit int) ([]string, error)
+	// ForgetOrphanedBlobs drops keys from the orphan list once their blobs
+	// have been removed from blob storage.
+	ForgetOrphanedBlobs(ctx context.Context, keys []string) error
+	// ReplaceTree swaps every node of a project for nodes, atomically. The
+	// nodes carry their own IDs and come parents first; their blobs must fit
+	// in quota bytes. Access rules of nodes whose IDs come back are kept.
+	ReplaceTree(ctx context.Context, projectID uuid.UUID, nodes []models.FileNode, quota int64) error
+	// CreateTree adds nodes to a project, atomically. The nodes carry their
+	// own IDs and come parents first; the first may sit below an existing
+	// folder. Their blobs must fit in quota bytes next to the project's own.
+	CreateTree(ctx context.Context, projectID uuid.UUID, nodes []models.FileNode, quota int64) error
+	Rename(ctx context.Context, fileID uuid.UUID, name string, rev int64) (int64, error)
+	// Delete removes a node and everything below it, returning the node.
+	Delete(ctx context.Context, fileID uuid.UUID, rev int64) (models.FileNode, error)
+	// Search returns the files of a project, with content, whose saved
+	// content may match q. It can return false positives; callers confirm
+	// each file with a search.Matcher.
+	Search(ctx context.Context, projectID uuid.UUID, q search.Query) ([]models.FileNode, error)
+
+	// Parents maps every node of a project to its parent.
+	Parents(ctx context.Context, projectID uuid.UUID) (map[uuid.UUID]*uuid.UUID, error)
+	// Ancestry maps a node and each of its ancestors to their parent.
+	Ancestry(ctx context.Context, fileID uuid.UUID) (map[uuid.UUID]*uuid.UUID, error)
+	// ChildParents maps the nodes directly below any of parentIDs to their
+	// parent.
+	ChildParents(ctx context.Context, parentIDs []uuid.UUID) (map[uuid.UUID]*uuid.UUID, error)
+	ProjectRules(ctx context.Context, projectID uuid.UUID) ([]models.AccessRule, error)
+	// Rules returns the rules set directly on the given nodes, ordered by
+	// subject type and subject.
+	Rules(ctx context.Context, fileIDs []uuid.UUID) ([]models.AccessRule, error)
+	// ReplaceRules swaps every rule on a node for rules, atomically.
+	ReplaceRules(ctx context.Context, projectID string, fileID uuid.UUID, rules []models.AccessRule) error
+}
+
+// BlobStore keeps the bytes of uploaded binary files, addressed by key.
+type BlobStore interface {
+	// Put stores size bytes read from r under key, replacing any blob there.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Open returns a blob's content, or ErrNotFound.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes a blob; a missing blob is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+type WhiteboardStore interface {
+	// Shapes returns a project's shapes keyed by their client-generated ID.
+	Shapes(ctx context.Context, projectID string) (map[string]json.RawMessage, error)
+	SaveShape(ctx context.Context, projectID, shapeID string, data json.RawMessage) error
+	DeleteShape(ctx context.Context, projectID, shapeID string) error
+}
+
+// DraftStore keeps editor contents that are live but not yet saved, so every
+// backend instance sees the same document. Saving a file discards its draft.
+type DraftStore interface {
+	// Get returns a file's draft, or ErrNotFound when it has none.
+	Get(ctx context.Context, fileID uuid.UUID) (string, error)
+	// List returns a project's drafts keyed by file ID.
+	List(ctx context.Context, projectID uuid.UUID) (map[uuid.UUID]string, error)
+	// Save creates or replaces drafts. Files that no longer exist are skipped.
+	Save(ctx context.Context, projectID uuid.UUID, drafts map[uuid.UUID]string) error
+}
+
+type InviteStore interface {
+	// Create inserts invite and fills in its ID, use count and creation time.
+	Create(ctx context.Context, invite *models.ProjectInvite) error
+	// ListActive returns invites that are not revoked, expired or used up.
+	ListActive(ctx context.Context, projectID string) ([]models.ProjectInvite, error)
+	Revoke(ctx context.Context, projectID, inviteID string) error
+	// Accept adds userID to the invite's project. The unrevoked invite with
+	// the given code is locked and passed to check first; an error from check
+	// aborts the accept. The invite is only consumed if the user actually
+	// joined, which the returned bool reports.
+	Accept(ctx context.Context, code, userID string, check func(models.ProjectInvite) error) (models.ProjectInvite, bool, error)
+}
+
+type OrganizationStore interface {
+	// Create inserts an organization and makes ownerID its owner.
+	Create(ctx context.Context, name, defaultProjectRole, ownerID string) (models.Organization, error)
+	Get(ctx context.Context, organizationID string) (models.Organization, error)
+	// ListForUser returns the user's organizations with Role filled in.
+	ListForUser(ctx context.Context, userID string) ([]models.Organization, error)
+	// Update changes the non-nil fields and returns the updated organization.
+	Update(ctx context.Context, organizationID string, name, defaultProjectRole *string) (models.Organization, error)
+	// Delete removes an organization; its projects stay, without one.
+	Delete(ctx context.Context, organizationID string) error
+
+	MemberRole(ctx context.Context, organizationID, userID string) (string, error)
+	Members(ctx context.Context, organizationID string) ([]models.OrganizationMember, error)
+	// AddMember adds a user unless they already belong to the organization,
+	// reporting whether they were added.
+	AddMember(ctx context.Context, organizationID, userID, role string) (bool, error)
+	UpdateMemberRole(ctx context.Context, organizationID, userID, role string) error
+	RemoveMember(ctx context.Context, organizationID, userID string) error
+}
+
+type GitStore interface {
+	// Link returns the git branch a project is tied to, or ErrNotFound.
+	Link(ctx context.Context, projectID uuid.UUID) (models.GitLink, error)
+	// SaveLink creates or replaces a project's link and sets its UpdatedAt.
+	SaveLink(ctx context.Context, link *models.GitLink) error
+}
+
+type AuditStore interface {
+	Append(ctx context.Context, event audit.Event) error
+	// List returns entries matching filter, newest first.
+	List(ctx context.Context, filter audit.Filter) ([]audit.Entry, error)
+}
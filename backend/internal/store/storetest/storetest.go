@@ -0,0 +1,190 @@
+// Package storetest checks that a store.Store keeps the contracts its
+// interfaces document, so memstore and pgstore are held to the same ones.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"project-meetings/backend/internal/acl"
+	"project-meetings/backend/internal/audit"
+	"project-meetings/backend/internal/models"
+	"project-meetings/backend/internal/store"
+
+	"github.com/google/uuid"
+)
+
+// Project creates a user and a project of theirs with unique names.
+func Project(t *testing.T, st *store.Store) models.Project {
+	t.Helper()
+	ctx := context.Background()
+	name := "user-" + uuid.NewString()[:8]
+	user, err := st.Users.Create(ctx, name, name+"@example.com", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	project, err := st.Projects.Create(ctx, "project", user.ID.String(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return project
+}
+
+// File creates a text file at the top of project.
+func File(t *testing.T, st *store.Store, project models.Project, name, content string) models.FileNode {
+	t.Helper()
+	node := models.FileNode{ProjectID: project.ID, Name: name, Content: &content}
+	if err := st.Files.Create(context.Background(), &node); err != nil {
+		t.Fatal(err)
+	}
+	return node
+}
+
+// Drafts checks the DraftStore contract.
+func Drafts(t *testing.T, st *store.Store) {
+	ctx := context.Background()
+	project := Project(t, st)
+	other := Project(t, st)
+	a := File(t, st, project, "a.txt", "saved a")
+	b := File(t, st, project, "b.txt", "saved b")
+	foreign := File(t, st, other, "c.txt", "saved c")
+
+	if _, err := st.Drafts.Get(ctx, a.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Get without a draft: %v", err)
+	}
+	// Files of another project and unknown files are skipped.
+	err := st.Drafts.Save(ctx, project.ID, map[uuid.UUID]string{a.ID: "draft a", b.ID: "draft b", foreign.ID: "x", uuid.New(): "y"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Drafts.Save(ctx, project.ID, map[uuid.UUID]string{a.ID: "draft a2"}); err != nil {
+		t.Fatal(err)
+	}
+	if content, err := st.Drafts.Get(ctx, a.ID); err != nil || content != "draft a2" {
+		t.Errorf("Get(a) = %q, %v", content, err)
+	}
+	if _, err := st.Drafts.Get(ctx, foreign.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("draft saved across projects: %v", err)
+	}
+	drafts, err := st.Drafts.List(ctx, project.ID)
+	if err != nil || len(drafts) != 2 || drafts[b.ID] != "draft b" {
+		t.Errorf("List = %v, %v", drafts, err)
+	}
+
+	// Saving or deleting a file discards its draft.
+	if _, err := st.Files.UpdateContent(ctx, a.ID, "saved a2", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.Drafts.Get(ctx, a.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("draft kept after saving: %v", err)
+	}
+	if _, err := st.Files.Delete(ctx, b.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if drafts, err := st.Drafts.List(ctx, project.ID); err != nil || len(drafts) != 0 {
+		t.Errorf("List after save and delete = %v, %v", drafts, err)
+	}
+}
+
+// Audit checks that action filters match exactly, or by prefix when they end
+// in ".*", taking no other character as a wildcard.
+func Audit(t *testing.T, st *store.Store) {
+	ctx := context.Background()
+	project := Project(t, st)
+	for _, action := range []string{"org_member.add", "orgXmember.add", "org_member.remove", "org.rename", "100%.done", "100x.done"} {
+		if err := st.Audit.Append(ctx, audit.Event{ProjectID: project.ID.String(), Action: action}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, c := range []struct {
+		filter string
+		want   []string
+	}{
+		{"org_member.*", []string{"org_member.remove", "org_member.add"}},
+		{"org_member.add", []string{"org_member.add"}},
+		{"org_.*", nil},
+		{"org.*", []string{"org.rename"}},
+		{"100%.*", []string{"100%.done"}},
+		{"", []string{"100x.done", "100%.done", "org.rename", "org_member.remove", "orgXmember.add", "org_member.add"}},
+	} {
+		entries, err := st.Audit.List(ctx, audit.Filter{ProjectID: project.ID.String(), Action: c.filter, Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var actions []string
+		for _, e := range entries {
+			actions = append(actions, e.Action)
+		}
+		if fmt.Sprint(actions) != fmt.Sprint(c.want) {
+			t.Errorf("action %q: %v, want %v", c.filter, actions, c.want)
+		}
+	}
+}
+
+// UpdateContent checks that only files take content, and that revisions
+// guard the write.
+func UpdateContent(t *testing.T, st *store.Store) {
+	ctx := context.Background()
+	project := Project(t, st)
+	file := File(t, st, project, "a.txt", "saved")
+	folder := models.FileNode{ProjectID: project.ID, Name: "src", IsFolder: true}
+	if err := st.Files.Create(ctx, &folder); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, rev := range []int64{0, folder.Revision} {
+		if _, err := st.Files.UpdateContent(ctx, folder.ID, "text", rev); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("folder at rev %d: err = %v, want ErrNotFound", rev, err)
+		}
+	}
+	if node, err := st.Files.Get(ctx, folder.ID); err != nil || node.Content != nil {
+		t.Errorf("folder after update: %+v, %v", node, err)
+	}
+	if _, err := st.Files.UpdateContent(ctx, uuid.New(), "text", 0); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("missing file: err = %v, want ErrNotFound", err)
+	}
+
+	rev, err := st.Files.UpdateContent(ctx, file.ID, "edited", file.Revision)
+	if err != nil || rev != file.Revision+1 {
+		t.Fatalf("file: rev %d, err %v", rev, err)
+	}
+	if _, err := st.Files.UpdateContent(ctx, file.ID, "again", file.Revision); !errors.Is(err, store.ErrStale) {
+		t.Errorf("stale revision: err = %v, want ErrStale", err)
+	}
+}
+
+// ReplaceTree checks that access rules stay with the nodes that come back
+// from a replacement and go with the others.
+func ReplaceTree(t *testing.T, st *store.Store) {
+	ctx := context.Background()
+	project := Project(t, st)
+	kept := File(t, st, project, "kept.txt", "old")
+	gone := File(t, st, project, "gone.txt", "old")
+	for _, node := range []models.FileNode{kept, gone} {
+		rules := []models.AccessRule{{FileID: node.ID, SubjectType: acl.SubjectRole, Subject: "viewer", Access: "none"}}
+		if err := st.Files.ReplaceRules(ctx, project.ID.String(), node.ID, rules); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	content := "new"
+	nodes := []models.FileNode{
+		{ID: kept.ID, ProjectID: project.ID, Name: "kept.txt", Content: &content},
+		{ID: uuid.New(), ProjectID: project.ID, Name: "gone.txt", Content: &content},
+	}
+	if err := st.Files.ReplaceTree(ctx, project.ID, nodes, 1<<20); err != nil {
+		t.Fatal(err)
+	}
+	rules, err := st.Files.ProjectRules(ctx, project.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 1 || rules[0].FileID != kept.ID || rules[0].Access != "none" {
+		t.Errorf("rules after replace: %+v, want the one of %s", rules, kept.ID)
+	}
+	if node, err := st.Files.Get(ctx, kept.ID); err != nil || node.Content == nil || *node.Content != "new" {
+		t.Errorf("kept node: %+v, %v", node, err)
+	}
+}
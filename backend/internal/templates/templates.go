@@ -0,0 +1,74 @@
+// Package templates holds the built-in starter projects offered when a new
+// project is created. Each starter is a directory under starters/, with
+// every file stored under a .tmpl suffix so the Go tool leaves go.mod and
+// .go files of the starters alone.
+package templates
+
+import (
+	"embed"
+	"io/fs"
+	"path"
+	"strings"
+
+	"project-meetings/backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+//go:embed all:starters
+var starters embed.FS
+
+// Starter describes a built-in template.
+type Starter struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Starters lists the built-in templates in the order they are offered.
+var Starters = []Starter{
+	{ID: "empty", Name: "Empty", Description: "An empty project with a README."},
+	{ID: "node", Name: "Node.js", Description: "A Node.js app with package.json and an entry point."},
+	{ID: "python", Name: "Python", Description: "A Python script with requirements.txt."},
+	{ID: "go", Name: "Go", Description: "A Go module with a main package."},
+}
+
+// Lookup returns the built-in template with the given ID.
+func Lookup(id string) (Starter, bool) {
+	for _, s := range Starters {
+		if s.ID == id {
+			return s, true
+		}
+	}
+	return Starter{}, false
+}
+
+// Tree returns the files and folders of a built-in template as new nodes,
+// parents first, ready to seed a project.
+func Tree(id string) ([]models.FileNode, error) {
+	root := path.Join("starters", id)
+	nodes := make([]models.FileNode, 0)
+	folders := map[string]*uuid.UUID{root: nil}
+	// WalkDir visits a directory before its contents, in lexical order.
+	err := fs.WalkDir(starters, root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == root {
+			return err
+		}
+		node := models.FileNode{ID: uuid.New(), ParentID: folders[path.Dir(p)], IsFolder: d.IsDir(), Name: d.Name()}
+		if d.IsDir() {
+			id := node.ID
+			folders[p] = &id
+		} else {
+			content, err := starters.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			text := string(content)
+			node.Name = strings.TrimSuffix(node.Name, ".tmpl")
+			node.Content = &text
+		}
+		nodes = append(nodes, node)
+		return nil
+	})
+	return nodes, err
+}
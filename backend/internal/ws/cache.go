@@ -0,0 +1,278 @@
+package ws
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CacheLimits bound the memory rooms spend on live editor contents and
+// whiteboards. An evicted file or whiteboard is loaded from the database
+// again, drafts included, the next time someone asks for it.
+type CacheLimits struct {
+	// Budget is the most all rooms together may cache, in bytes. Past it the
+	// least recently used files and whiteboards of any room are evicted.
+	Budget int64
+	// RoomFiles and RoomBytes bound how many files one room caches and
+	// their total size. Past either, the room evicts its least recently
+	// used files.
+	RoomFiles int
+	RoomBytes int64
+	// IdleTimeout is how long a room with nobody connected and nothing to do
+	// keeps running before it stops and frees everything it holds.
+	IdleTimeout time.Duration
+}
+
+// DefaultCacheLimits suits a single instance with a few hundred MiB to spare.
+func DefaultCacheLimits() CacheLimits {
+	return CacheLimits{
+		Budget:      256 << 20,
+		RoomFiles:   500,
+		RoomBytes:   64 << 20,
+		IdleTimeout: 5 * time.Minute,
+	}
+}
+
+// CacheLimitsFromEnv starts from DefaultCacheLimits and applies
+// WS_CACHE_BUDGET_MB, WS_ROOM_MAX_FILES, WS_ROOM_MAX_MB and
+// WS_ROOM_IDLE_TIMEOUT, a duration such as "10m".
+func CacheLimitsFromEnv() (CacheLimits, error) {
+	limits := DefaultCacheLimits()
+	for _, setting := range []struct {
+		name  string
+		apply func(n int64)
+	}{
+		{"WS_CACHE_BUDGET_MB", func(n int64) { limits.Budget = n << 20 }},
+		{"WS_ROOM_MAX_FILES", func(n int64) { limits.RoomFiles = int(n) }},
+		{"WS_ROOM_MAX_MB", func(n int64) { limits.RoomBytes = n << 20 }},
+	} {
+		value := os.Getenv(setting.name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(value, 10, 32)
+		if err != nil || n <= 0 {
+			return limits, fmt.Errorf("invalid %s %q, expected a positive number", setting.name, value)
+		}
+		setting.apply(n)
+	}
+	if value := os.Getenv("WS_ROOM_IDLE_TIMEOUT"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			return limits, fmt.Errorf("invalid WS_ROOM_IDLE_TIMEOUT %q, expected a duration such as 10m", value)
+		}
+		limits.IdleTimeout = timeout
+	}
+	return limits, nil
+}
+
+// SetCacheLimits replaces the hub's cache limits. Call it before Run.
+func (h *Hub) SetCacheLimits(limits CacheLimits) {
+	h.limits = limits
+	h.cache.mu.Lock()
+	h.cache.limit = limits.Budget
+	h.cache.mu.Unlock()
+}
+
+// cacheEntry is one thing a room caches and can evict: a file's editor
+// contents, or with an empty fileID the room's whiteboard. Only its room
+// creates, resizes and drops it; size and elem change under the budget's
+// lock.
+type cacheEntry struct {
+	room   *room
+	fileID string
+	size   int64
+	elem   *list.Element
+	// evicting is set when the budget picked the entry to make room, and
+	// cleared if it is used again before its room gets to evict it.
+	evicting bool
+	// used orders the room's own entries, for its per-room limits.
+	used uint64
+}
+
+// cacheBudget puts the entries of every room in one least-recently-used
+// order and keeps their total size under limit.
+type cacheBudget struct {
+	mu    sync.Mutex
+	limit int64
+	total int64
+	lru   *list.List // most recently used first
+}
+
+func newCacheBudget(limit int64) *cacheBudget {
+	return &cacheBudget{limit: limit, lru: list.New()}
+}
+
+// touch records that e was just used and is now size bytes. It returns the
+// entries to evict to get back under the limit, oldest first, never e
+// itself.
+func (b *cacheBudget) touch(e *cacheEntry, size int64) []*cacheEntry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.total += size - e.size
+	e.size = size
+	e.evicting = false
+	if e.elem == nil {
+		e.elem = b.lru.PushFront(e)
+	} else {
+		b.lru.MoveToFront(e.elem)
+	}
+	if b.limit <= 0 || b.total <= b.limit {
+		return nil
+	}
+	var victims []*cacheEntry
+	excess := b.total - b.limit
+	for el := b.lru.Back(); el != nil && el != e.elem && excess > 0; el = el.Prev() {
+		victim := el.Value.(*cacheEntry)
+		if !victim.evicting {
+			victim.evicting = true
+			victims = append(victims, victim)
+		}
+		excess -= victim.size
+	}
+	return victims
+}
+
+// picked reports whether e is still due for eviction.
+func (b *cacheBudget) picked(e *cacheEntry) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return e.evicting
+}
+
+// remove takes e out of the budget.
+func (b *cacheBudget) remove(e *cacheEntry) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if e.elem != nil {
+		b.lru.Remove(e.elem)
+		b.total -= e.size
+		e.elem = nil
+	}
+}
+
+// setContent caches a file's live contents.
+func (r *room) setContent(fileID, content string) {
+	r.state.EditorContents[fileID] = content
+	r.cacheTouched(fileID, int64(len(content)))
+}
+
+// setShape caches a whiteboard shape, or removes it when data is empty.
+func (r *room) setShape(shapeID, data string) {
+	shapes := r.state.WhiteboardShapes
+	r.shapeBytes += int64(len(data) - len(shapes[shapeID]))
+	if data == "" {
+		delete(shapes, shapeID)
+	} else {
+		shapes[shapeID] = data
+	}
+	r.cacheTouched("", r.shapeBytes)
+}
+
+// cacheTouched records that a cached file, or the whiteboard when fileID is
+// empty, was used and how big it is now, then evicts whatever that puts over
+// the limits. Files picked from other rooms are evicted by those rooms.
+func (r *room) cacheTouched(fileID string, size int64) {
+	e, ok := r.entries[fileID]
+	if !ok {
+		e = &cacheEntry{room: r, fileID: fileID}
+		r.entries[fileID] = e
+	}
+	if fileID != "" {
+		r.fileBytes += size - e.size
+	}
+	r.clock++
+	e.used = r.clock
+	h := r.hub
+	if victims := h.cache.touch(e, size); len(victims) > 0 {
+		log.Printf("[Hub] Cache over budget, evicting %d entries", len(victims))
+		for _, victim := range victims {
+			victim.room.enqueue(func(owner *room) { owner.evictIfPicked(victim) })
+		}
+	}
+
+	// The room's own limits apply to files only, never the one just used.
+	for len(r.entries)-r.whiteboardEntries() > r.hub.limits.RoomFiles || r.fileBytes > r.hub.limits.RoomBytes {
+		var oldest *cacheEntry
+		for id, candidate := range r.entries {
+			if id != "" && id != fileID && (oldest == nil || candidate.used < oldest.used) {
+				oldest = candidate
+			}
+		}
+		if oldest == nil {
+			break
+		}
+		r.dropEntry(oldest)
+	}
+}
+
+// whiteboardEntries is 1 when the room caches its whiteboard, else 0.
+func (r *room) whiteboardEntries() int {
+	if _, ok := r.entries[""]; ok {
+		return 1
+	}
+	return 0
+}
+
+// evictIfPicked evicts an entry the budget picked, unless it was used again
+// or already dropped since.
+func (r *room) evictIfPicked(e *cacheEntry) {
+	if r.entries[e.fileID] == e && r.hub.cache.picked(e) {
+		r.dropEntry(e)
+	}
+}
+
+// dropEntry frees a cached file or the whiteboard. A file with unsaved
+// edits has its draft queued first.
+func (r *room) dropEntry(e *cacheEntry) {
+	r.hub.cache.remove(e)
+	delete(r.entries, e.fileID)
+	state := r.state
+	if e.fileID == "" {
+		// Shape writes are already queued; the next snapshot reloads them.
+		state.WhiteboardShapes = make(map[string]string)
+		state.shapesLoaded = false
+		r.shapeBytes = 0
+		return
+	}
+	r.fileBytes -= e.size
+	if state.dirty[e.fileID] {
+		if projectID, err := uuid.Parse(r.projectID); err == nil {
+			if id, err := uuid.Parse(e.fileID); err == nil {
+				drafts := map[uuid.UUID]string{id: state.EditorContents[e.fileID]}
+				r.write(func(ctx context.Context) {
+					if err := r.hub.store.Drafts.Save(ctx, projectID, drafts); err != nil {
+						log.Printf("[Hub] Failed to save the draft of evicted file %s: %v", id, err)
+					}
+				})
+				r.evictedDirty = true
+			}
+		}
+		delete(state.dirty, e.fileID)
+	}
+	delete(state.EditorContents, e.fileID)
+}
+
+// awaitEvictedDrafts waits for the drafts of evicted files to be written, so
+// reading them back from the database gets the latest contents.
+func (r *room) awaitEvictedDrafts() {
+	if r.evictedDirty {
+		r.waitForWrites()
+		r.evictedDirty = false
+	}
+}
+
+// releaseCache takes all of a stopping room's entries out of the budget.
+func (r *room) releaseCache() {
+	for _, e := range r.entries {
+		r.hub.cache.remove(e)
+	}
+	r.entries = nil
+}
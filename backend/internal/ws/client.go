@@ -3,6 +3,9 @@ package ws
 import (
 	"github.com/gorilla/websocket"
 	"log"
+	"project-meetings/backend/internal/acl"
+	"project-meetings/backend/internal/permissions"
+	"sync"
 	"time"
 )
 
@@ -26,13 +29,91 @@ type Client struct {
 	UserID    string
 	Username  string
 	Role      string
-	
+	// IP and UserAgent are captured at connect time for the audit log.
+	IP        string
+	UserAgent string
+	// ResumeEpoch and ResumeSeq are the last broadcast seen on a previous
+	// connection, for a client that asked to resume. See syncClient.
+	ResumeEpoch string
+	ResumeSeq   uint64
+	// Protocol is the protocol version the client asked for on connect.
+	Protocol int
+	// Permissions granted by Role. Only touched on the room goroutine after registration.
+	Permissions permissions.Set
+	// fileAccess caches folder-level access decisions per file ID. Room goroutine only.
+	fileAccess map[string]acl.Access
+	// editedFiles remembers which files this connection has already been
+	// audited as editing, so live edits are logged once rather than per keystroke.
+	editedFiles map[string]bool
+	// openFile is the file the user last showed a cursor in or opened, and
+	// following the user whose file switches it follows. Room goroutine only.
+	openFile  string
+	following string
+	// closed is set once the hub closed Send, and closeCode is the close
+	// code the write pump then sends. Written by the goroutine that owns
+	// the client, its room's or for the SFU the hub's, before Send is closed.
+	closed    bool
+	closeCode int
+	// room is the room the client joined. Room goroutine only.
+	room *room
+
+	// pending holds the latest coalesced message per key, in the order the
+	// keys first appeared, until the write pump takes them. wake tells it
+	// there are some.
+	pendingMu   sync.Mutex
+	pending     map[string][]byte
+	pendingKeys []string
+	wake        chan struct{}
+}
+
+// wakeChan returns the channel that signals pending coalesced messages.
+func (c *Client) wakeChan() chan struct{} {
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+	if c.wake == nil {
+		c.wake = make(chan struct{}, 1)
+	}
+	return c.wake
+}
+
+// coalesce queues data under key, replacing an unsent message with the same
+// key, which it reports.
+func (c *Client) coalesce(key string, data []byte) bool {
+	wake := c.wakeChan()
+	c.pendingMu.Lock()
+	if c.pending == nil {
+		c.pending = make(map[string][]byte)
+	}
+	_, replaced := c.pending[key]
+	if !replaced {
+		c.pendingKeys = append(c.pendingKeys, key)
+	}
+	c.pending[key] = data
+	c.pendingMu.Unlock()
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+	return replaced
+}
+
+// takePending removes and returns the queued coalesced messages.
+func (c *Client) takePending() [][]byte {
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+	messages := make([][]byte, 0, len(c.pendingKeys))
+	for _, key := range c.pendingKeys {
+		messages = append(messages, c.pending[key])
+	}
+	c.pending = nil
+	c.pendingKeys = nil
+	return messages
 }
 
 // readPump pumps messages from the websocket connection to the hub.
 func (c *Client) ReadPump() {
 	defer func() {
-		c.Hub.Unregister <- c
+		c.Hub.Unregister(c)
 		c.Conn.Close()
 	}()
 	c.Conn.SetReadDeadline(time.Now().Add(pongWait))
@@ -47,7 +128,7 @@ func (c *Client) ReadPump() {
 		}
 
 		// **CRITICAL FIX**: Check if this client is the SFU.
-		if c.ProjectID == "sfu-internal-channel" {
+		if c.ProjectID == sfuChannel {
 			// If it's the SFU, send the message to the dedicated SFU channel.
 			c.Hub.sfuMessages <- message
 		} else {
@@ -57,7 +138,7 @@ func (c *Client) ReadPump() {
 				Data:      message,
 				Sender:    c,
 			}
-			c.Hub.Broadcast <- &m
+			c.Hub.Broadcast(&m)
 		}
 	}
 }
@@ -65,6 +146,7 @@ func (c *Client) ReadPump() {
 // writePump pumps messages from the hub to the websocket connection.
 func (c *Client) WritePump() {
 	ticker := time.NewTicker(pingPeriod)
+	wake := c.wakeChan()
 	defer func() {
 		ticker.Stop()
 		c.Conn.Close()
@@ -74,13 +156,24 @@ func (c *Client) WritePump() {
 		case message, ok := <-c.Send:
 			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if !ok {
-				c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
+				closeMessage := []byte{}
+				if c.closeCode != 0 {
+					closeMessage = websocket.FormatCloseMessage(c.closeCode, "")
+				}
+				c.Conn.WriteMessage(websocket.CloseMessage, closeMessage)
 				return
 			}
 
 			if err := c.Conn.WriteMessage(websocket.TextMessage, message); err != nil {
 				return
 			}
+		case <-wake:
+			for _, message := range c.takePending() {
+				c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+				if err := c.Conn.WriteMessage(websocket.TextMessage, message); err != nil {
+					return
+				}
+			}
 		case <-ticker.C:
 			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if err := c.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
@@ -0,0 +1,247 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"project-meetings/backend/internal/permissions"
+)
+
+// Kinds of event hubs exchange over the backplane.
+const (
+	// eventRoom carries a room broadcast to the other instances' clients.
+	eventRoom = "room"
+	// eventPresence lists who is connected to a project on one instance.
+	eventPresence = "presence"
+	// eventKick disconnects a user wherever they are connected, and
+	// eventDirect sends them a message.
+	eventKick   = "kick"
+	eventDirect = "direct"
+	// eventClose disconnects everyone from a deleted project.
+	eventClose = "close"
+	// eventPermissions and eventACL route role changes and access rule
+	// invalidations to whichever instance holds the affected clients.
+	eventPermissions = "permissions"
+	eventACL         = "acl"
+	// eventFromSFU and eventToSFU carry signaling between the instance the
+	// SFU is connected to and the instances its peers are connected to.
+	eventFromSFU = "sfu"
+	eventToSFU   = "to_sfu"
+)
+
+const (
+	// presenceHeartbeat is how often each instance repeats who is connected
+	// to it, and presenceTTL how long a silent instance's users are shown.
+	presenceHeartbeat = 30 * time.Second
+	presenceTTL       = 3 * presenceHeartbeat
+	// draftFlushInterval is how often unsaved editor contents are written to
+	// the draft store.
+	draftFlushInterval = 2 * time.Second
+	// outboxSize is how many events may wait to be published.
+	outboxSize = 1024
+	// clusterTimeout bounds each backplane publish and room database write.
+	clusterTimeout = 5 * time.Second
+)
+
+// clusterEvent is one message between hubs on different instances. Events an
+// instance published itself come back to it and are ignored.
+type clusterEvent struct {
+	Instance  string `json:"instance"`
+	Kind      string `json:"kind"`
+	ProjectID string `json:"projectId,omitempty"`
+	UserID    string `json:"userId,omitempty"`
+	// FileID limits a room event to clients that can read the file.
+	FileID      string                   `json:"fileId,omitempty"`
+	Users       []UserPresence           `json:"users,omitempty"`
+	Role        string                   `json:"role,omitempty"`
+	Permissions []permissions.Permission `json:"permissions,omitempty"`
+	Data        json.RawMessage          `json:"data,omitempty"`
+}
+
+// remoteRoom is who another instance last reported in a project.
+type remoteRoom struct {
+	users []UserPresence
+	seen  time.Time
+}
+
+// publish queues an event for the other instances. Without a backplane it
+// does nothing; with one that cannot keep up, the event is dropped.
+func (h *Hub) publish(ev clusterEvent) {
+	if h.backplane == nil {
+		return
+	}
+	ev.Instance = h.instanceID
+	data, err := json.Marshal(ev)
+	if err != nil {
+		log.Printf("[Hub] Failed to encode %s event: %v", ev.Kind, err)
+		return
+	}
+	select {
+	case h.outbox <- data:
+	default:
+		log.Printf("[Hub] Backplane outbox full, dropping %s event for project %s", ev.Kind, ev.ProjectID)
+	}
+}
+
+// runPublisher sends queued events in order, off the hub goroutine.
+func (h *Hub) runPublisher() {
+	for data := range h.outbox {
+		ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+		if err := h.backplane.Publish(ctx, data); err != nil {
+			log.Printf("[Hub] Failed to publish to the backplane: %v", err)
+		}
+		cancel()
+	}
+}
+
+// handleClusterEvent applies an event published by another instance.
+func (h *Hub) handleClusterEvent(data []byte) {
+	var ev clusterEvent
+	if err := json.Unmarshal(data, &ev); err != nil {
+		log.Printf("[Hub] Error unmarshalling backplane event: %v", err)
+		return
+	}
+	if ev.Instance == h.instanceID {
+		return
+	}
+	switch ev.Kind {
+	case eventRoom:
+		h.post(ev.ProjectID, false, func(r *room) { r.applyRemoteRoomEvent(ev) })
+	case eventPresence:
+		h.setRemotePresence(ev.ProjectID, ev.Instance, ev.Users)
+	case eventKick:
+		h.postToUser(ev.UserID, ev.ProjectID, func(r *room, client *Client) {
+			r.kick(client, ev.Data)
+		})
+	case eventClose:
+		h.post(ev.ProjectID, false, func(r *room) { r.close(ev.Data) })
+	case eventDirect:
+		var msg WsMessage
+		json.Unmarshal(ev.Data, &msg)
+		h.postToUser(ev.UserID, ev.ProjectID, func(r *room, client *Client) {
+			h.send(client, msg.Type, "", ev.Data)
+		})
+	case eventPermissions:
+		h.applyPermissions(&PermissionUpdate{
+			UserID:      ev.UserID,
+			ProjectID:   ev.ProjectID,
+			Role:        ev.Role,
+			Permissions: permissions.NewSet(ev.Permissions...),
+		})
+	case eventACL:
+		h.post(ev.ProjectID, false, (*room).invalidateFileAccess)
+	case eventFromSFU:
+		h.handleSFUMessage(ev.Data, true)
+	case eventToSFU:
+		if h.sfuClient != nil {
+			var msg WsMessage
+			json.Unmarshal(ev.Data, &msg)
+			h.send(h.sfuClient, msg.Type, "", ev.Data)
+		}
+	default:
+		log.Printf("[Hub] Ignoring unknown backplane event %q", ev.Kind)
+	}
+}
+
+// applyRemoteRoomEvent keeps the room's caches in step with a broadcast made
+// elsewhere, then passes it on to its clients. The origin already persisted
+// whatever the message changed. Rooms that are not running have nobody
+// listening, so the hub drops the event for them.
+func (r *room) applyRemoteRoomEvent(ev clusterEvent) {
+	msg, payload, failure := parseMessage(ev.Data, false)
+	if failure != nil {
+		log.Printf("[Hub] Dropping invalid relayed message: %s", failure.Message)
+		return
+	}
+	state := r.state
+	switch p := payload.(type) {
+	case *EditorUpdatePayload:
+		r.setContent(p.FileID, p.Content)
+	case *WhiteboardUpdatePayload:
+		// A cache that is not loaded yet gets the shape from the database.
+		if state.shapesLoaded {
+			r.setShape(p.ShapeID(), string(p.Shape))
+		}
+	case *WhiteboardObjectRemovePayload:
+		r.setShape(p.ID, "")
+	case *CursorUpdatePayload:
+		// Cursor moves are passed on without a sequence number.
+		r.deliverCursor(ev.Data, ev.UserID, p.FileID)
+		return
+	}
+	// Relayed broadcasts are numbered in this instance's sequence.
+	data := state.replayBuffer().add(msg, ev.UserID, ev.FileID)
+	r.deliver(msg.Type, data, nil, ev.UserID, ev.FileID)
+}
+
+// publishPresence tells the other instances who is connected to the project
+// here. An empty list withdraws this instance from the project.
+func (r *room) publishPresence() {
+	h := r.hub
+	if h.backplane == nil {
+		return
+	}
+	h.publish(clusterEvent{Kind: eventPresence, ProjectID: r.projectID, Users: r.localPresence()})
+}
+
+// setRemotePresence records who another instance has in a project and
+// refreshes the presence of local clients.
+func (h *Hub) setRemotePresence(projectID, instance string, users []UserPresence) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	rooms := h.remotePresence[projectID]
+	_, known := rooms[instance]
+	if len(users) == 0 {
+		delete(rooms, instance)
+		if len(rooms) == 0 {
+			delete(h.remotePresence, projectID)
+		}
+	} else {
+		if rooms == nil {
+			rooms = make(map[string]*remoteRoom)
+			h.remotePresence[projectID] = rooms
+		}
+		rooms[instance] = &remoteRoom{users: users, seen: time.Now()}
+	}
+	h.postLocked(projectID, false, func(r *room) {
+		if len(r.clients) == 0 {
+			return
+		}
+		r.broadcastPresence()
+		// An instance we have not heard from before has not heard about our
+		// users either, e.g. because it just started.
+		if !known && len(users) > 0 {
+			r.publishPresence()
+		}
+	})
+}
+
+// heartbeat repeats local presence and forgets instances that went silent.
+func (h *Hub) heartbeat() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for projectID := range h.rooms {
+		h.postLocked(projectID, false, func(r *room) {
+			if len(r.clients) > 0 {
+				r.publishPresence()
+			}
+		})
+	}
+	for projectID, rooms := range h.remotePresence {
+		expired := false
+		for instance, room := range rooms {
+			if time.Since(room.seen) > presenceTTL {
+				delete(rooms, instance)
+				expired = true
+			}
+		}
+		if len(rooms) == 0 {
+			delete(h.remotePresence, projectID)
+		}
+		if expired {
+			h.postLocked(projectID, false, (*room).broadcastPresence)
+		}
+	}
+}
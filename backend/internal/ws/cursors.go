@@ -0,0 +1,145 @@
+package ws
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"log"
+
+	"project-meetings/backend/internal/acl"
+)
+
+// userColors are the colors users are told apart by in presence and cursors.
+var userColors = []string{
+	"#e6194b", "#3cb44b", "#4363d8", "#f58231", "#911eb4", "#42d4f4",
+	"#f032e6", "#9a6324", "#469990", "#800000", "#808000", "#000075",
+}
+
+// userColor picks a user's color from their ID, so every instance and every
+// connection agree on it.
+func userColor(userID string) string {
+	h := fnv.New32a()
+	h.Write([]byte(userID))
+	return userColors[h.Sum32()%uint32(len(userColors))]
+}
+
+// updateCursor records where a client's cursors are and passes them on to
+// everyone else who can read the file. Moving to another file also updates
+// presence, which tells followers.
+func (r *room) updateCursor(client *Client, msg WsMessage, cursor *CursorUpdatePayload) {
+	h := r.hub
+	if cursor.FileID != "" && h.fileAccess(client, cursor.FileID) < acl.Read {
+		h.reject(client, permissionDenied(msg, "fileId", cursor.FileID))
+		return
+	}
+	cursor.UserID = client.UserID
+	payload, _ := json.Marshal(cursor)
+	data, _ := json.Marshal(WsMessage{Type: "cursor_update", Payload: payload})
+	r.setOpenFile(client, cursor.FileID)
+	r.deliverCursor(data, client.UserID, cursor.FileID)
+	h.publish(clusterEvent{Kind: eventRoom, ProjectID: r.projectID, UserID: client.UserID, FileID: cursor.FileID, Data: data})
+	h.ack(client, msg, 0)
+}
+
+// deliverCursor sends a user's cursors to the other clients that can read
+// the file. Cursor moves are not numbered or replayed: only the latest one
+// matters, so they are coalesced per user.
+func (r *room) deliverCursor(data []byte, userID, fileID string) {
+	h := r.hub
+	key := coalesceKey("cursor_update", userID, "")
+	for _, client := range r.clients {
+		if client.UserID == userID || (fileID != "" && h.fileAccess(client, fileID) < acl.Read) {
+			continue
+		}
+		h.send(client, "cursor_update", key, data)
+	}
+}
+
+// setOpenFile records the file a client has open and, if it changed, tells
+// everyone through presence.
+func (r *room) setOpenFile(client *Client, fileID string) {
+	if client.openFile == fileID {
+		return
+	}
+	client.openFile = fileID
+	r.broadcastPresence()
+	r.publishPresence()
+}
+
+// follow makes a client follow another user's file switches, or stop with an
+// empty userID. The follower is sent the leader's current file right away.
+func (r *room) follow(client *Client, msg WsMessage, leaderID string) {
+	h := r.hub
+	if leaderID == "" {
+		client.following = ""
+		h.ack(client, msg, 0)
+		return
+	}
+	fileID, present := r.openFiles[leaderID]
+	if !present || leaderID == client.UserID {
+		h.reject(client, &ErrorPayload{
+			RequestID: msg.RequestID,
+			Type:      msg.Type,
+			Code:      ErrorInvalidPayload,
+			Message:   "Only another user in the project can be followed",
+			Details:   map[string]string{"userId": leaderID},
+		})
+		return
+	}
+	log.Printf("[Hub] %s follows user %s in project %s", client.Username, leaderID, r.projectID)
+	client.following = leaderID
+	h.ack(client, msg, 0)
+	r.sendFollowFile(client, leaderID, fileID)
+}
+
+// updateFollowers compares who has which file open with the last presence
+// and tells followers of users that switched file. Followers of users that
+// left stop following.
+func (r *room) updateFollowers(users []UserPresence) {
+	h := r.hub
+	openFiles := make(map[string]string, len(users))
+	for _, user := range users {
+		openFiles[user.UserID] = user.FileID
+	}
+	for _, client := range r.clients {
+		leaderID := client.following
+		if leaderID == "" {
+			continue
+		}
+		fileID, present := openFiles[leaderID]
+		if !present {
+			client.following = ""
+			h.sendMessage(client, "follow_stopped", FollowStoppedPayload{UserID: leaderID, Reason: "left"})
+			continue
+		}
+		if fileID != r.openFiles[leaderID] {
+			r.sendFollowFile(client, leaderID, fileID)
+		}
+	}
+	r.openFiles = openFiles
+}
+
+// sendFollowFile tells a follower which file its leader has open, unless
+// none or one the follower cannot read.
+func (r *room) sendFollowFile(client *Client, leaderID, fileID string) {
+	h := r.hub
+	if fileID == "" || h.fileAccess(client, fileID) < acl.Read {
+		return
+	}
+	h.sendMessage(client, "follow_file", FollowFilePayload{UserID: leaderID, FileID: fileID})
+}
+
+// presenceFor is the presence a client may see: the users' open files it
+// cannot read are left out. It returns nil when the client can see it all.
+func (r *room) presenceFor(client *Client, users []UserPresence) []UserPresence {
+	var visible []UserPresence
+	for i, user := range users {
+		if user.FileID == "" || r.hub.fileAccess(client, user.FileID) >= acl.Read {
+			continue
+		}
+		if visible == nil {
+			visible = append([]UserPresence(nil), users...)
+		}
+		visible[i].FileID = ""
+	}
+	return visible
+}
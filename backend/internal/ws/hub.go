@@ -3,31 +3,62 @@ package ws
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
-	"project-meetings/backend/internal/database"
+	"project-meetings/backend/internal/acl"
+	"project-meetings/backend/internal/audit"
+	"project-meetings/backend/internal/backplane"
+	"project-meetings/backend/internal/permissions"
+	"project-meetings/backend/internal/store"
+	"sync"
+	"time"
 
-	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/google/uuid"
 )
 
 type WsMessage struct {
 	Type    string          `json:"type"`
 	Payload json.RawMessage `json:"payload"`
+	// Seq numbers room broadcasts, see replayBuffer. Other messages have none.
+	Seq uint64 `json:"seq,omitempty"`
+	// RequestID, when a client sets it, is echoed in the ack or error that
+	// answers the message.
+	RequestID string `json:"requestId,omitempty"`
 }
 
 type Message struct {
 	ProjectID string
 	Data      []byte
 	Sender    *Client
+	// msg and payload are Data decoded and validated by Broadcast.
+	msg     WsMessage
+	payload interface{}
 }
 
 type UserPresence struct {
 	UserID   string `json:"userId"`
 	Username string `json:"username"`
+	// FileID is the file the user has open, left out for clients that cannot
+	// read it.
+	FileID string `json:"fileId,omitempty"`
+	// Color is the user's cursor and avatar color, the same on every instance.
+	Color string `json:"color"`
 }
 
+// ProjectState is a project's live state. It belongs to the goroutine of the
+// project's room; other goroutines go through the hub's methods.
 type ProjectState struct {
+	// EditorContents and WhiteboardShapes cache what clients work on, within
+	// the hub's CacheLimits.
 	EditorContents   map[string]string
 	WhiteboardShapes map[string]string
+	// shapesLoaded is set once WhiteboardShapes holds every saved shape.
+	shapesLoaded bool
+	// dirty holds the files edited on this instance since their contents
+	// were last written to the draft store.
+	dirty map[string]bool
+	// replay numbers and keeps the room's broadcasts.
+	replay *replayBuffer
 }
 
 type SignalPayload struct {
@@ -36,6 +67,21 @@ type SignalPayload struct {
 	Data   json.RawMessage `json:"data"`
 }
 
+// PermissionUpdate tells the hub that a member's role in a project changed,
+// so the connected client can be told and its cached permissions refreshed.
+type PermissionUpdate struct {
+	UserID      string
+	ProjectID   string
+	Role        string
+	Permissions permissions.Set
+}
+
+// snapshotReply carries a room's whiteboard back to GetWhiteboardSnapshot.
+type snapshotReply struct {
+	shapes []json.RawMessage
+	err    error
+}
+
 type ICEBuffer struct {
 	Candidates   [][]byte
 	PendingOffer []byte
@@ -43,332 +89,516 @@ type ICEBuffer struct {
 	AnswerSent   bool
 }
 
+// sfuChannel is the project ID the SFU connects with.
+const sfuChannel = "sfu-internal-channel"
+
+// Hub routes messages to the room of each project, see room, and handles
+// what is not tied to a project: the SFU connection and call signaling, and
+// the link to other instances. Those run on the Run goroutine; the registry
+// of rooms and users is shared under mu.
 type Hub struct {
-	Clients       map[string]map[string]*Client // projectID -> userID -> Client
-	UserMap       map[string]*Client            // userID -> Client
-	Broadcast     chan *Message
-	Register      chan *Client
-	Unregister    chan *Client
+	sfuRegister   chan *Client
+	sfuUnregister chan *Client
 	sfuMessages   chan []byte
-	ProjectStates map[string]*ProjectState
-	sfuClient     *Client
-	iceBuffers    map[string]*ICEBuffer
+	// calls holds functions to run on the Run goroutine.
+	calls chan func()
+
+	// mu guards the running rooms, the stopped rooms still writing, which
+	// project each user is connected to here, and who the other instances
+	// report in each project. Rooms take it briefly and never wait while
+	// holding it.
+	mu             sync.Mutex
+	rooms          map[string]*room
+	retiring       map[string]*room
+	users          map[string]*Client                // userID -> Client
+	remotePresence map[string]map[string]*remoteRoom // projectID -> instance -> members
+
+	// Run goroutine only.
+	sfuClient  *Client
+	iceBuffers map[string]*ICEBuffer
+
+	store *store.Store
+
+	// backplane links this hub to the hubs of other backend instances; nil
+	// when running as a single instance. instanceID tells their events apart.
+	backplane  backplane.Backplane
+	instanceID string
+	outbox     chan []byte
+
+	// policies decide what happens to messages for clients that cannot keep
+	// up, and counters record it.
+	policies SendPolicies
+	counters sendCounters
+
+	// limits bound what rooms cache, and cache tracks it across rooms.
+	limits CacheLimits
+	cache  *cacheBudget
 }
 
-func NewHub() *Hub {
+// NewHub creates a hub. bp may be nil, in which case every client must be
+// connected to this instance.
+func NewHub(st *store.Store, bp backplane.Backplane) *Hub {
 	return &Hub{
-		Broadcast:     make(chan *Message),
-		Register:      make(chan *Client),
-		Unregister:    make(chan *Client),
-		sfuMessages:   make(chan []byte, 256),
-		Clients:       make(map[string]map[string]*Client),
-		UserMap:       make(map[string]*Client),
-		ProjectStates: make(map[string]*ProjectState),
-		iceBuffers:    make(map[string]*ICEBuffer),
+		store:          st,
+		sfuRegister:    make(chan *Client),
+		sfuUnregister:  make(chan *Client),
+		sfuMessages:    make(chan []byte, 256),
+		calls:          make(chan func(), 256),
+		rooms:          make(map[string]*room),
+		retiring:       make(map[string]*room),
+		users:          make(map[string]*Client),
+		remotePresence: make(map[string]map[string]*remoteRoom),
+		iceBuffers:     make(map[string]*ICEBuffer),
+		backplane:      bp,
+		instanceID:     uuid.NewString(),
+		outbox:         make(chan []byte, outboxSize),
+		policies:       DefaultSendPolicies(),
+		limits:         DefaultCacheLimits(),
+		cache:          newCacheBudget(DefaultCacheLimits().Budget),
 	}
 }
 
-func (h *Hub) broadcastPresence(projectID string) {
-	if clientsInRoom, ok := h.Clients[projectID]; ok {
-		var presenceInfo []UserPresence
-		for _, client := range clientsInRoom {
-			presenceInfo = append(presenceInfo, UserPresence{
-				UserID:   client.UserID,
-				Username: client.Username,
-			})
+// do runs fn on the Run goroutine.
+func (h *Hub) do(fn func()) {
+	h.calls <- fn
+}
+
+// Register connects a client to its project's room, starting the room if it
+// is the first, and closes any older connection the same user had to this
+// instance.
+func (h *Hub) Register(client *Client) {
+	if client.ProjectID == sfuChannel {
+		h.sfuRegister <- client
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if old, ok := h.users[client.UserID]; ok {
+		moved := old.ProjectID != client.ProjectID
+		h.postLocked(old.ProjectID, false, func(r *room) { r.replace(old, moved) })
+	}
+	h.users[client.UserID] = client
+	h.postLocked(client.ProjectID, true, func(r *room) { r.join(client) })
+}
+
+// Unregister disconnects a client. Clients that were already replaced or
+// kicked are ignored.
+func (h *Hub) Unregister(client *Client) {
+	if client.ProjectID == sfuChannel {
+		h.sfuUnregister <- client
+		return
+	}
+	h.post(client.ProjectID, false, func(r *room) { r.unregister(client) })
+}
+
+// Broadcast validates a message and hands it to its project's room. Messages
+// without a sender come from the server; when the room is not running nobody
+// is connected to hear them. A client whose message is invalid is told why.
+func (h *Hub) Broadcast(message *Message) {
+	sender := message.Sender
+	msg, payload, failure := parseMessage(message.Data, sender != nil)
+	if failure != nil {
+		if sender == nil {
+			log.Printf("[Hub] Dropping invalid server message for project %s: %s", message.ProjectID, failure.Message)
+			return
+		}
+		log.Printf("[Hub] Refusing message from %s: %s", sender.Username, failure.Message)
+		h.post(message.ProjectID, false, func(r *room) {
+			if r.clients[sender.UserID] == sender {
+				h.reject(sender, failure)
+			}
+		})
+		return
+	}
+	message.msg, message.payload = msg, payload
+	h.post(message.ProjectID, false, func(r *room) { r.handle(message) })
+}
+
+// UpdatePermissions tells a connected user their role changed, on whichever
+// instance holds their connection. It is safe to call from HTTP handlers.
+func (h *Hub) UpdatePermissions(update *PermissionUpdate) {
+	h.applyPermissions(update)
+	h.publish(clusterEvent{
+		Kind:        eventPermissions,
+		ProjectID:   update.ProjectID,
+		UserID:      update.UserID,
+		Role:        update.Role,
+		Permissions: update.Permissions.List(),
+	})
+}
+
+// GetWhiteboardSnapshot returns a copy of a project's whiteboard shapes,
+// loading them from the database the first time the project is asked for.
+func (h *Hub) GetWhiteboardSnapshot(ctx context.Context, projectID string) ([]json.RawMessage, error) {
+	reply := make(chan snapshotReply, 1)
+	running := h.post(projectID, false, func(r *room) {
+		shapes, err := r.whiteboardSnapshot()
+		reply <- snapshotReply{shapes: shapes, err: err}
+	})
+	if !running {
+		if err := h.waitRetired(ctx, projectID); err != nil {
+			return nil, err
+		}
+		saved, err := h.store.Whiteboards.Shapes(ctx, projectID)
+		if err != nil {
+			return nil, err
 		}
-		payloadBytes, _ := json.Marshal(map[string]interface{}{"users": presenceInfo})
-		message := WsMessage{
-			Type:    "presence_update",
-			Payload: payloadBytes,
+		shapes := make([]json.RawMessage, 0, len(saved))
+		for _, shape := range saved {
+			shapes = append(shapes, shape)
 		}
-		jsonMessage, _ := json.Marshal(message)
-		for _, client := range clientsInRoom {
-			select {
-			case client.Send <- jsonMessage:
-			default:
+		return shapes, nil
+	}
+	select {
+	case r := <-reply:
+		return r.shapes, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// NotifyUser sends a user a server message on their connection to
+// projectID, on whichever instance holds it. An empty projectID reaches them
+// in any project.
+func (h *Hub) NotifyUser(userID, projectID, msgType string, payload interface{}) {
+	payloadBytes, _ := json.Marshal(payload)
+	msg, _ := json.Marshal(WsMessage{Type: msgType, Payload: payloadBytes})
+	h.postToUser(userID, projectID, func(r *room, client *Client) {
+		h.send(client, msgType, "", msg)
+	})
+	h.publish(clusterEvent{Kind: eventDirect, ProjectID: projectID, UserID: userID, Data: msg})
+}
+
+// KickUser sends a user a force_disconnect with the given reason and closes
+// their connection to projectID, on whichever instance holds it. An empty
+// projectID disconnects them from any project.
+func (h *Hub) KickUser(userID, projectID, reason string) {
+	payload, _ := json.Marshal(ForceDisconnectPayload{Reason: reason})
+	msg, _ := json.Marshal(WsMessage{Type: "force_disconnect", Payload: payload})
+	h.postToUser(userID, projectID, func(r *room, client *Client) {
+		r.kick(client, msg)
+	})
+	h.publish(clusterEvent{Kind: eventKick, ProjectID: projectID, UserID: userID, Data: msg})
+}
+
+// CloseProject disconnects everyone from a deleted project with a
+// force_disconnect carrying reason, on every instance.
+func (h *Hub) CloseProject(projectID, reason string) {
+	payload, _ := json.Marshal(ForceDisconnectPayload{Reason: reason})
+	msg, _ := json.Marshal(WsMessage{Type: "force_disconnect", Payload: payload})
+	h.post(projectID, false, func(r *room) { r.close(msg) })
+	h.publish(clusterEvent{Kind: eventClose, ProjectID: projectID, Data: msg})
+}
+
+// InvalidateFileAccess drops every cached folder-access decision for a
+// project, so the next request re-reads the rules.
+func (h *Hub) InvalidateFileAccess(projectID string) {
+	h.post(projectID, false, (*room).invalidateFileAccess)
+	h.publish(clusterEvent{Kind: eventACL, ProjectID: projectID})
+}
+
+// EditorContents returns the live contents of every file being edited in a
+// project, keyed by file ID. These may be ahead of what is saved. Drafts
+// written by any instance are included, overlaid with this instance's
+// memory, which can be newer still.
+func (h *Hub) EditorContents(ctx context.Context, projectID string) (map[string]string, error) {
+	reply := make(chan map[string]string, 1)
+	var live map[string]string
+	if h.post(projectID, false, func(r *room) { reply <- r.editorContents() }) {
+		select {
+		case live = <-reply:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	} else if err := h.waitRetired(ctx, projectID); err != nil {
+		return nil, err
+	}
+	contents := make(map[string]string)
+	if id, err := uuid.Parse(projectID); err == nil {
+		drafts, err := h.store.Drafts.List(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		for fileID, content := range drafts {
+			contents[fileID.String()] = content
+		}
+	}
+	for fileID, content := range live {
+		contents[fileID] = content
+	}
+	return contents, nil
+}
+
+// applyPermissions updates a local client's role, if the user has one here.
+func (h *Hub) applyPermissions(update *PermissionUpdate) {
+	h.postToUser(update.UserID, update.ProjectID, func(r *room, client *Client) {
+		r.applyPermissions(client, update)
+	})
+}
+
+// localUser returns the user's connection to this instance, if any.
+func (h *Hub) localUser(userID string) (*Client, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	client, ok := h.users[userID]
+	return client, ok
+}
+
+// fileAccess returns the client's access to a file, consulting the database
+// only the first time the client touches it. Files of other projects are
+// never accessible. Call it on the client's room goroutine.
+func (h *Hub) fileAccess(client *Client, fileID string) acl.Access {
+	if access, ok := client.fileAccess[fileID]; ok {
+		return access
+	}
+	id, err := uuid.Parse(fileID)
+	if err != nil {
+		return acl.None
+	}
+	ctx := context.Background()
+	node, err := h.store.Files.Get(ctx, id)
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			log.Printf("[Hub] Failed to load file %s: %v", fileID, err)
+		}
+		return acl.None
+	}
+	access := acl.None
+	if node.ProjectID.String() == client.ProjectID {
+		subject := acl.Subject{UserID: client.UserID, Role: client.Role, Permissions: client.Permissions}
+		evaluator, err := acl.LoadForFile(ctx, h.store.Files, id, subject)
+		if err != nil {
+			log.Printf("[Hub] Failed to load access rules for %s: %v", fileID, err)
+			return acl.None
+		}
+		access = evaluator.AccessTo(id)
+	}
+	if client.fileAccess == nil {
+		client.fileAccess = make(map[string]acl.Access)
+	}
+	client.fileAccess[fileID] = access
+	return access
+}
+
+// auditClient records an action taken by a connected client. The write happens
+// off the room goroutine so a slow database never stalls the room.
+func (h *Hub) auditClient(c *Client, action, targetType, targetID string, details map[string]interface{}) {
+	event := audit.Event{
+		ProjectID:  c.ProjectID,
+		ActorID:    c.UserID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		IP:         c.IP,
+		UserAgent:  c.UserAgent,
+		Details:    details,
+	}
+	go func() {
+		if err := h.store.Audit.Append(context.Background(), event); err != nil {
+			log.Printf("[Hub] Failed to record audit event %s: %v", action, err)
+		}
+	}()
+}
+
+// sendMessage sends a server message to one client.
+func (h *Hub) sendMessage(client *Client, msgType string, payload interface{}) {
+	payloadBytes, _ := json.Marshal(payload)
+	msg, _ := json.Marshal(WsMessage{Type: msgType, Payload: payloadBytes})
+	h.send(client, msgType, "", msg)
+}
+
+// coalesceKey identifies messages that replace each other: those of one type
+// from one sender about one file.
+func coalesceKey(msgType, senderID, fileID string) string {
+	return msgType + ":" + senderID + ":" + fileID
+}
+
+// sfuReachable reports whether messages for the SFU can go anywhere. With a
+// backplane the SFU may be connected to another instance.
+func (h *Hub) sfuReachable() bool {
+	return h.sfuClient != nil || h.backplane != nil
+}
+
+// sendToSFU passes a message of type msgType to the SFU, through the
+// backplane when it is not connected to this instance.
+func (h *Hub) sendToSFU(msgType string, msg []byte) {
+	if h.sfuClient != nil {
+		h.send(h.sfuClient, msgType, "", msg)
+		return
+	}
+	h.publish(clusterEvent{Kind: eventToSFU, Data: msg})
+}
+
+// handleSFUMessage forwards signaling from the SFU to its target user.
+// relayed is set for messages another instance received from the SFU.
+func (h *Hub) handleSFUMessage(messageData []byte, relayed bool) {
+	var msg WsMessage
+	if err := json.Unmarshal(messageData, &msg); err != nil {
+		log.Printf("[Hub] Error unmarshalling SFU message: %v", err)
+		return
+	}
+
+	var payload SignalPayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		log.Printf("[Hub] Error unmarshalling SFU payload: %v", err)
+		return
+	}
+
+	targetClient, online := h.localUser(payload.Target)
+	if !online && h.backplane != nil {
+		// The target is connected to another instance, which buffers and
+		// forwards for them.
+		if !relayed {
+			h.publish(clusterEvent{Kind: eventFromSFU, UserID: payload.Target, Data: messageData})
+		}
+		return
+	}
+
+	switch msg.Type {
+	case "webrtc_offer":
+		h.ensureICEBuffer(payload.Target)
+		if online {
+			// User is online → send immediately
+			log.Printf("[Hub] Forwarding OFFER to %s", payload.Target)
+			h.iceBuffers[payload.Target].OfferSent = true
+			h.sendToClient(targetClient, msg.Type, messageData)
+			h.flushICE(payload.Target, targetClient)
+		} else {
+			// User not yet connected → buffer offer
+			log.Printf("[Hub] Buffering OFFER for %s until they join", payload.Target)
+			h.iceBuffers[payload.Target].PendingOffer = messageData
+		}
+
+	case "webrtc_ice_candidate":
+		h.ensureICEBuffer(payload.Target)
+		if online {
+			log.Printf("[Hub] Forwarding ICE candidate to %s", payload.Target)
+			if !h.iceBuffers[payload.Target].OfferSent && !h.iceBuffers[payload.Target].AnswerSent {
+				log.Printf("[Hub] Buffering ICE candidate for %s until offer/answer", payload.Target)
+				h.iceBuffers[payload.Target].Candidates = append(h.iceBuffers[payload.Target].Candidates, payload.Data)
+			} else {
+				h.sendToClient(targetClient, msg.Type, messageData)
 			}
+		} else {
+			// User not connected yet → buffer ICE
+			log.Printf("[Hub] Buffering ICE candidate for %s (not connected)", payload.Target)
+			h.iceBuffers[payload.Target].Candidates = append(h.iceBuffers[payload.Target].Candidates, payload.Data)
+		}
+
+	default:
+		if online {
+			h.sendToClient(targetClient, msg.Type, messageData)
+		}
+	}
+}
+
+// handleSignal passes a client's call signaling on to the SFU. Its room has
+// already checked the sender's permissions.
+func (h *Hub) handleSignal(message *Message, msg WsMessage) {
+	switch msg.Type {
+	case "webrtc_join":
+		log.Printf("[Hub] %s requested to join WebRTC in project %s", message.Sender.UserID, message.ProjectID)
+		if !h.sfuReachable() {
+			log.Println("[Hub] No SFU available, cannot join")
+			return
+		}
+		connectPayload, _ := json.Marshal(map[string]string{
+			"userId":    message.Sender.UserID,
+			"projectId": message.Sender.ProjectID,
+		})
+		h.ensureICEBuffer(message.Sender.UserID)
+		if len(h.iceBuffers[message.Sender.UserID].PendingOffer) > 0 {
+			log.Printf("[Hub] Sending buffered OFFER to %s", message.Sender.UserID)
+			h.sendToClient(message.Sender, "webrtc_offer", h.iceBuffers[message.Sender.UserID].PendingOffer)
+			h.iceBuffers[message.Sender.UserID].OfferSent = true
+			h.iceBuffers[message.Sender.UserID].PendingOffer = nil
+			h.flushICE(message.Sender.UserID, message.Sender)
+		}
+		sfuMsg, _ := json.Marshal(WsMessage{Type: "webrtc_connect_request", Payload: connectPayload})
+		h.sendToSFU("webrtc_connect_request", sfuMsg)
+		log.Printf("[Hub] Sent connect request to SFU for %s", message.Sender.UserID)
+
+	case "webrtc_answer":
+		if !h.sfuReachable() {
+			return
+		}
+		var payload SignalPayload
+		json.Unmarshal(msg.Payload, &payload)
+		h.ensureICEBuffer(payload.Sender)
+		h.iceBuffers[payload.Sender].AnswerSent = true
+		sfuPayload, _ := json.Marshal(SignalPayload{Sender: message.Sender.UserID, Data: payload.Data})
+		finalMsg, _ := json.Marshal(WsMessage{Type: "webrtc_answer", Payload: sfuPayload})
+		h.sendToSFU("webrtc_answer", finalMsg)
+		h.flushICE(payload.Sender, nil)
+		log.Printf("[Hub] Forwarded ANSWER from %s to SFU", payload.Sender)
+
+	case "webrtc_ice_candidate":
+		if !h.sfuReachable() {
+			return
+		}
+		var payload SignalPayload
+		json.Unmarshal(msg.Payload, &payload)
+		h.ensureICEBuffer(payload.Sender)
+		if !h.iceBuffers[payload.Sender].OfferSent && !h.iceBuffers[payload.Sender].AnswerSent {
+			h.iceBuffers[payload.Sender].Candidates = append(h.iceBuffers[payload.Sender].Candidates, payload.Data)
+		} else {
+			sfuPayload, _ := json.Marshal(SignalPayload{Sender: message.Sender.UserID, Data: payload.Data})
+			finalMsg, _ := json.Marshal(WsMessage{Type: "webrtc_ice_candidate", Payload: sfuPayload})
+			h.sendToSFU("webrtc_ice_candidate", finalMsg)
 		}
 	}
 }
 
 func (h *Hub) Run() {
+	var incoming <-chan []byte
+	var heartbeat <-chan time.Time
+	if h.backplane != nil {
+		incoming = h.backplane.Messages()
+		ticker := time.NewTicker(presenceHeartbeat)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+		go h.runPublisher()
+		log.Printf("[Hub] Instance %s joined the backplane", h.instanceID)
+	}
+	statsTicker := time.NewTicker(time.Minute)
+	defer statsTicker.Stop()
+	var lastStats SendStats
+
 	for {
 		select {
-		case client := <-h.Register:
-			if client.ProjectID == "sfu-internal-channel" {
-				if h.sfuClient != nil {
-					log.Println("[Hub] New SFU connected, closing old SFU connection")
-					h.sfuClient.Conn.Close()
-				}
-				h.sfuClient = client
-				log.Println("[Hub] SFU Server connected")
-				continue
+		case client := <-h.sfuRegister:
+			if h.sfuClient != nil {
+				log.Println("[Hub] New SFU connected, closing old SFU connection")
+				h.sfuClient.Conn.Close()
 			}
-			if _, ok := h.Clients[client.ProjectID]; !ok {
-				h.Clients[client.ProjectID] = make(map[string]*Client)
-			}
-			if oldClient, ok := h.UserMap[client.UserID]; ok {
-				log.Printf("[Hub] User %s reconnecting, closing old channel", oldClient.UserID)
-				close(oldClient.Send)
-			}
-			h.Clients[client.ProjectID][client.UserID] = client
-			h.UserMap[client.UserID] = client
-			log.Printf("[Hub] Client %s registered to project %s", client.Username, client.ProjectID)
-			h.broadcastPresence(client.ProjectID)
+			h.sfuClient = client
+			log.Println("[Hub] SFU Server connected")
 
-		case client := <-h.Unregister:
+		case client := <-h.sfuUnregister:
 			if h.sfuClient == client {
 				h.sfuClient = nil
 				log.Println("[Hub] SFU Server disconnected")
-				continue
-			}
-			if room, ok := h.Clients[client.ProjectID]; ok {
-				if C, ok := room[client.UserID]; ok && C == client {
-					delete(room, client.UserID)
-					close(client.Send)
-					delete(h.UserMap, client.UserID)
-					log.Printf("[Hub] Client %s left project %s", client.Username, client.ProjectID)
-					if h.sfuClient != nil {
-						disconnectPayload, _ := json.Marshal(map[string]string{"userId": client.UserID})
-						msg, _ := json.Marshal(WsMessage{Type: "webrtc_disconnect", Payload: disconnectPayload})
-						h.sfuClient.Send <- msg
-					}
-				}
-				if len(room) == 0 {
-					delete(h.Clients, client.ProjectID)
-				} else {
-					h.broadcastPresence(client.ProjectID)
-				}
 			}
 
-			// In Run()
-		case messageData := <-h.sfuMessages:
-			var msg WsMessage
-			if err := json.Unmarshal(messageData, &msg); err != nil {
-				log.Printf("[Hub] Error unmarshalling SFU message: %v", err)
-				continue
-			}
+		case fn := <-h.calls:
+			fn()
 
-			var payload SignalPayload
-			if err := json.Unmarshal(msg.Payload, &payload); err != nil {
-				log.Printf("[Hub] Error unmarshalling SFU payload: %v", err)
+		case data, ok := <-incoming:
+			if !ok {
+				log.Println("[Hub] Backplane closed, continuing as a single instance")
+				incoming = nil
 				continue
 			}
+			h.handleClusterEvent(data)
 
-			switch msg.Type {
-			case "webrtc_offer":
-				h.ensureICEBuffer(payload.Target)
-				if targetClient, ok := h.UserMap[payload.Target]; ok {
-					// User is online → send immediately
-					log.Printf("[Hub] Forwarding OFFER to %s", payload.Target)
-					h.iceBuffers[payload.Target].OfferSent = true
-					targetClient.Send <- messageData
-					h.flushICE(payload.Target, targetClient)
-				} else {
-					// User not yet connected → buffer offer
-					log.Printf("[Hub] Buffering OFFER for %s until they join", payload.Target)
-					h.iceBuffers[payload.Target].PendingOffer = messageData
-				}
-
-			case "webrtc_ice_candidate":
-				h.ensureICEBuffer(payload.Target)
-				if targetClient, ok := h.UserMap[payload.Target]; ok {
-					log.Printf("[Hub] Forwarding ICE candidate to %s", payload.Target)
-					if !h.iceBuffers[payload.Target].OfferSent && !h.iceBuffers[payload.Target].AnswerSent {
-						log.Printf("[Hub] Buffering ICE candidate for %s until offer/answer", payload.Target)
-						h.iceBuffers[payload.Target].Candidates = append(h.iceBuffers[payload.Target].Candidates, payload.Data)
-					} else {
-						targetClient.Send <- messageData
-					}
-				} else {
-					// User not connected yet → buffer ICE
-					log.Printf("[Hub] Buffering ICE candidate for %s (not connected)", payload.Target)
-					h.iceBuffers[payload.Target].Candidates = append(h.iceBuffers[payload.Target].Candidates, payload.Data)
-				}
-
-			default:
-				if targetClient, ok := h.UserMap[payload.Target]; ok {
-					targetClient.Send <- messageData
-				}
-			}
+		case <-heartbeat:
+			h.heartbeat()
 
-		case message := <-h.Broadcast:
-			var msg WsMessage
-			if err := json.Unmarshal(message.Data, &msg); err != nil {
-				log.Printf("[Hub] Error unmarshalling message: %v", err)
-				continue
-			}
-			switch msg.Type {
-			case "webrtc_join":
-				log.Printf("[Hub] %s requested to join WebRTC in project %s", message.Sender.UserID, message.ProjectID)
-				if h.sfuClient == nil {
-					log.Println("[Hub] No SFU available, cannot join")
-					continue
-				}
-				connectPayload, _ := json.Marshal(map[string]string{
-					"userId":    message.Sender.UserID,
-					"projectId": message.Sender.ProjectID,
-				})
-				h.ensureICEBuffer(message.Sender.UserID)
-				if len(h.iceBuffers[message.Sender.UserID].PendingOffer) > 0 {
-					log.Printf("[Hub] Sending buffered OFFER to %s", message.Sender.UserID)
-					message.Sender.Send <- h.iceBuffers[message.Sender.UserID].PendingOffer
-					h.iceBuffers[message.Sender.UserID].OfferSent = true
-					h.iceBuffers[message.Sender.UserID].PendingOffer = nil
-					h.flushICE(message.Sender.UserID, message.Sender)
-				}
-				sfuMsg, _ := json.Marshal(WsMessage{Type: "webrtc_connect_request", Payload: connectPayload})
-				h.sfuClient.Send <- sfuMsg
-				log.Printf("[Hub] Sent connect request to SFU for %s", message.Sender.UserID)
-
-			case "webrtc_answer":
-				if h.sfuClient == nil {
-					continue
-				}
-				var payload SignalPayload
-				json.Unmarshal(msg.Payload, &payload)
-				h.ensureICEBuffer(payload.Sender)
-				h.iceBuffers[payload.Sender].AnswerSent = true
-				sfuPayload, _ := json.Marshal(SignalPayload{Sender: message.Sender.UserID, Data: payload.Data})
-				finalMsg, _ := json.Marshal(WsMessage{Type: "webrtc_answer", Payload: sfuPayload})
-				h.sfuClient.Send <- finalMsg
-				h.flushICE(payload.Sender, nil)
-				log.Printf("[Hub] Forwarded ANSWER from %s to SFU", payload.Sender)
-
-			case "webrtc_ice_candidate":
-				if h.sfuClient == nil {
-					continue
-				}
-				var payload SignalPayload
-				json.Unmarshal(msg.Payload, &payload)
-				h.ensureICEBuffer(payload.Sender)
-				if !h.iceBuffers[payload.Sender].OfferSent && !h.iceBuffers[payload.Sender].AnswerSent {
-					h.iceBuffers[payload.Sender].Candidates = append(h.iceBuffers[payload.Sender].Candidates, payload.Data)
-				} else {
-					sfuPayload, _ := json.Marshal(SignalPayload{Sender: message.Sender.UserID, Data: payload.Data})
-					finalMsg, _ := json.Marshal(WsMessage{Type: "webrtc_ice_candidate", Payload: sfuPayload})
-					h.sfuClient.Send <- finalMsg
-				}
-
-			default:
-				if _, ok := h.ProjectStates[message.ProjectID]; !ok {
-					h.ProjectStates[message.ProjectID] = &ProjectState{
-						EditorContents:   make(map[string]string),
-						WhiteboardShapes: make(map[string]string),
-					}
-				}
-				projectState := h.ProjectStates[message.ProjectID]
-				shouldBroadcast := true
-				switch msg.Type {
-				case "request_file_content":
-					shouldBroadcast = false
-					var payload map[string]string
-					if err := json.Unmarshal(msg.Payload, &payload); err == nil {
-						if fileID, ok := payload["fileId"]; ok {
-
-							var contentToSend string
-
-							// First, check if we have a "live" version in our in-memory map.
-							content, contentExists := projectState.EditorContents[fileID]
-
-							if contentExists {
-								// --- HOT PATH ---
-								// The file is active. Serve the latest version from memory.
-								contentToSend = content
-							} else {
-								// --- COLD PATH ---
-								// No one has touched this file since the server started.
-								// Load it from the database for the first time.
-								log.Printf("No in-memory version for file %s. Loading from DB.", fileID)
-								var dbContent pgtype.Text
-								query := `SELECT content FROM files WHERE id = $1`
-								err := database.DB.QueryRow(context.Background(), query, fileID).Scan(&dbContent)
-								if err != nil {
-									log.Printf("Failed to query file content for %s: %v", fileID, err)
-									contentToSend = "// File content could not be loaded."
-								} else {
-									contentToSend = dbContent.String
-								}
-								// Store it in memory for the next person who asks.
-								projectState.EditorContents[fileID] = contentToSend
-							}
-
-							// Send the definitive content to the requester.
-							responsePayload, _ := json.Marshal(map[string]string{"fileId": fileID, "content": contentToSend})
-							response := WsMessage{Type: "editor_update", Payload: responsePayload}
-							jsonMsg, _ := json.Marshal(response)
-							message.Sender.Send <- jsonMsg
-						}
-					}
-				case "editor_update":
-					var payload map[string]string
-					if err := json.Unmarshal(msg.Payload, &payload); err == nil {
-						if fileID, ok := payload["fileId"]; ok {
-							projectState.EditorContents[fileID] = payload["content"]
-						}
-					}
-				case "whiteboard_update":
-					var payload map[string]json.RawMessage
-					if err := json.Unmarshal(msg.Payload, &payload); err == nil {
-						if shapeData, ok := payload["shape"]; ok {
-							var shape map[string]interface{}
-							if err := json.Unmarshal(shapeData, &shape); err == nil {
-								if shapeID, ok := shape["id"].(string); ok {
-									// 1. Update in-memory state for live broadcast
-									projectState.WhiteboardShapes[shapeID] = string(shapeData)
-									// 2. NEW: Persist to the database (UPSERT logic)
-									query := `
-                            INSERT INTO whiteboard_shapes (id, project_id, shape_data, updated_at)
-                            VALUES ($1, $2, $3, NOW())
-                            ON CONFLICT (id, project_id) DO UPDATE SET
-                            shape_data = EXCLUDED.shape_data,
-                            updated_at = NOW();
-                        `
-									_, err := database.DB.Exec(context.Background(), query, shapeID, message.ProjectID, shapeData)
-									if err != nil {
-										log.Printf("Failed to save whiteboard shape: %v", err)
-									}
-									projectState.WhiteboardShapes[shapeID] = string(shapeData)
-								}
-							}
-						}
-					}
-				case "whiteboard_object_remove":
-					var payload map[string]string
-					if err := json.Unmarshal(msg.Payload, &payload); err == nil {
-						if shapeID, ok := payload["id"]; ok {
-							// 1. Remove from in-memory state
-							delete(projectState.WhiteboardShapes, shapeID)
-
-							// 2. NEW: Delete from the database
-							query := `DELETE FROM whiteboard_shapes WHERE id = $1 AND project_id = $2`
-							_, err := database.DB.Exec(context.Background(), query, shapeID, message.ProjectID)
-							if err != nil {
-								log.Printf("Failed to delete whiteboard shape: %v", err)
-							}
-						}
-					}
-				case "file_created", "file_deleted", "file_renamed":
-					// These are just notifications for other clients. We don't need to store
-					// any state for them here, just let them be broadcast.
-				}
-				if shouldBroadcast {
-					if clientsInRoom, ok := h.Clients[message.ProjectID]; ok {
-						for _, client := range clientsInRoom {
-							if client != message.Sender {
-								select {
-								case client.Send <- message.Data:
-								default:
-									close(client.Send)
-									delete(h.Clients[message.ProjectID], client.UserID)
-									delete(h.UserMap, client.UserID)
-								}
-							}
-						}
-					}
-				}
-			}
+		case <-statsTicker.C:
+			h.logSendStats(&lastStats)
+
+		case messageData := <-h.sfuMessages:
+			h.handleSFUMessage(messageData, false)
 		}
 	}
 }
@@ -382,14 +612,15 @@ func (h *Hub) ensureICEBuffer(userID string) {
 func (h *Hub) flushICE(userID string, target *Client) {
 	if buf, ok := h.iceBuffers[userID]; ok {
 		log.Printf("[Hub] Flushing %d buffered ICE candidates for %s", len(buf.Candidates), userID)
+		if target == nil {
+			target, _ = h.localUser(userID)
+		}
 		for _, ice := range buf.Candidates {
 			signalPayload := SignalPayload{Target: userID, Sender: "sfu", Data: ice}
 			payloadBytes, _ := json.Marshal(signalPayload)
 			msg, _ := json.Marshal(WsMessage{Type: "webrtc_ice_candidate", Payload: payloadBytes})
 			if target != nil {
-				target.Send <- msg
-			} else if c, ok := h.UserMap[userID]; ok {
-				c.Send <- msg
+				h.sendToClient(target, "webrtc_ice_candidate", msg)
 			}
 		}
 		buf.Candidates = nil
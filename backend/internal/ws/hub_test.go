@@ -0,0 +1,703 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"project-meetings/backend/internal/models"
+	"project-meetings/backend/internal/permissions"
+	"project-meetings/backend/internal/store"
+	"project-meetings/backend/internal/store/memstore"
+)
+
+type testRoom struct {
+	hub     *Hub
+	store   *store.Store
+	project models.Project
+	owner   models.User
+}
+
+func newTestRoom(t *testing.T) testRoom {
+	t.Helper()
+	st := memstore.New()
+	ctx := context.Background()
+	owner, err := st.Users.Create(ctx, "owner", "owner@example.com", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	project, err := st.Projects.Create(ctx, "project", owner.ID.String(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub := NewHub(st, nil)
+	go hub.Run()
+	return testRoom{hub: hub, store: st, project: project, owner: owner}
+}
+
+// join registers a client without a connection; tests read its Send channel.
+// configure, if given, adjusts the client before it is registered.
+func (r testRoom) join(t *testing.T, name string, configure ...func(*Client)) *Client {
+	t.Helper()
+	user, err := r.store.Users.Create(context.Background(), name, name+"@example.com", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &Client{
+		Hub:         r.hub,
+		Send:        make(chan []byte, 256),
+		ProjectID:   r.project.ID.String(),
+		UserID:      user.ID.String(),
+		Username:    name,
+		Role:        permissions.OwnerRole,
+		Permissions: permissions.NewSet(permissions.BuiltinRoles[permissions.OwnerRole]...),
+	}
+	for _, fn := range configure {
+		fn(client)
+	}
+	r.hub.Register(client)
+	return client
+}
+
+func (r testRoom) broadcast(sender *Client, msgType string, payload interface{}) {
+	payloadBytes, _ := json.Marshal(payload)
+	data, _ := json.Marshal(WsMessage{Type: msgType, Payload: payloadBytes})
+	r.hub.Broadcast(&Message{ProjectID: r.project.ID.String(), Data: data, Sender: sender})
+}
+
+// waitFor reads from a client, coalesced messages included, until a message
+// of msgType arrives. It reports false if the channel was closed first.
+func waitFor(t *testing.T, client *Client, msgType string) (WsMessage, bool) {
+	t.Helper()
+	msgs, ok := readUntil(t, client, msgType)
+	if !ok {
+		return WsMessage{}, false
+	}
+	return msgs[len(msgs)-1], true
+}
+
+// readUntil is waitFor for any of msgTypes, returning every message read
+// with the matching one last.
+func readUntil(t *testing.T, client *Client, msgTypes ...string) ([]WsMessage, bool) {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	var queue [][]byte
+	var read []WsMessage
+	for {
+		for len(queue) > 0 {
+			var msg WsMessage
+			if err := json.Unmarshal(queue[0], &msg); err != nil {
+				t.Fatalf("invalid message %s: %v", queue[0], err)
+			}
+			queue = queue[1:]
+			read = append(read, msg)
+			for _, msgType := range msgTypes {
+				if msg.Type == msgType {
+					return read, true
+				}
+			}
+		}
+		select {
+		case data, ok := <-client.Send:
+			if !ok {
+				return read, false
+			}
+			queue = append(queue, data)
+		case <-client.wakeChan():
+			queue = append(queue, client.takePending()...)
+		case <-timeout:
+			t.Fatalf("%s did not receive %s", client.Username, strings.Join(msgTypes, " or "))
+		}
+	}
+}
+
+func TestGetWhiteboardSnapshot(t *testing.T) {
+	room := newTestRoom(t)
+	ctx := context.Background()
+	projectID := room.project.ID.String()
+	if err := room.store.Whiteboards.SaveShape(ctx, projectID, "saved", json.RawMessage(`{"id":"saved"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	shapes, err := room.hub.GetWhiteboardSnapshot(ctx, projectID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shapes) != 1 || !strings.Contains(string(shapes[0]), "saved") {
+		t.Fatalf("snapshot = %s, want the saved shape", shapes)
+	}
+
+	alice := room.join(t, "alice")
+	room.broadcast(alice, "whiteboard_update", map[string]interface{}{"shape": map[string]string{"id": "drawn"}})
+	room.broadcast(alice, "whiteboard_object_remove", map[string]string{"id": "saved"})
+
+	shapes, err = room.hub.GetWhiteboardSnapshot(ctx, projectID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shapes) != 1 || !strings.Contains(string(shapes[0]), "drawn") {
+		t.Fatalf("snapshot = %s, want only the drawn shape", shapes)
+	}
+
+	// The snapshot is a copy: changing it must not reach the hub.
+	shapes[0] = json.RawMessage(`{"id":"changed"}`)
+	again, _ := room.hub.GetWhiteboardSnapshot(ctx, projectID)
+	if strings.Contains(string(again[0]), "changed") {
+		t.Fatal("snapshot shares memory with the hub")
+	}
+}
+
+func TestGetWhiteboardSnapshotHonoursContext(t *testing.T) {
+	test := newTestRoom(t)
+	test.join(t, "alice")
+	// Keep the room busy so nothing answers.
+	busy := make(chan struct{})
+	defer close(busy)
+	test.hub.post(test.project.ID.String(), false, func(r *room) { <-busy })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := test.hub.GetWhiteboardSnapshot(ctx, test.project.ID.String()); err != context.DeadlineExceeded {
+		t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestEditorContentsHonoursContext(t *testing.T) {
+	test := newTestRoom(t)
+	test.join(t, "alice")
+	busy := make(chan struct{})
+	defer close(busy)
+	test.hub.post(test.project.ID.String(), false, func(r *room) { <-busy })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := test.hub.EditorContents(ctx, test.project.ID.String()); err != context.DeadlineExceeded {
+		t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestNotifyUser(t *testing.T) {
+	room := newTestRoom(t)
+	alice := room.join(t, "alice")
+	bob := room.join(t, "bob")
+
+	room.hub.NotifyUser(alice.UserID, "another-project", "notice", map[string]string{"text": "wrong room"})
+	room.hub.NotifyUser(alice.UserID, room.project.ID.String(), "notice", map[string]string{"text": "hello"})
+
+	msg, ok := waitFor(t, alice, "notice")
+	if !ok || !strings.Contains(string(msg.Payload), "hello") {
+		t.Fatalf("alice got %s, want the notice for her project", msg.Payload)
+	}
+	// Round-trip through the hub so any stray notice would have arrived.
+	room.hub.EditorContents(context.Background(), room.project.ID.String())
+	for len(bob.Send) > 0 {
+		if data := <-bob.Send; strings.Contains(string(data), `"notice"`) {
+			t.Fatalf("bob received alice's notice: %s", data)
+		}
+	}
+}
+
+func TestKickUser(t *testing.T) {
+	room := newTestRoom(t)
+	alice := room.join(t, "alice")
+	bob := room.join(t, "bob")
+	waitFor(t, alice, "presence_update")
+
+	room.hub.KickUser(bob.UserID, room.project.ID.String(), "removed")
+
+	msg, ok := waitFor(t, bob, "force_disconnect")
+	if !ok || !strings.Contains(string(msg.Payload), "removed") {
+		t.Fatalf("bob got %s, want force_disconnect with the reason", msg.Payload)
+	}
+	if _, ok := waitFor(t, bob, "never"); ok {
+		t.Fatal("bob's channel is still open")
+	}
+	for {
+		msg, _ := waitFor(t, alice, "presence_update")
+		if !strings.Contains(string(msg.Payload), bob.UserID) {
+			break
+		}
+	}
+
+	// The kicked client's own unregister must be harmless.
+	room.hub.Unregister(bob)
+	room.hub.KickUser(bob.UserID, "", "again")
+	room.hub.EditorContents(context.Background(), room.project.ID.String())
+}
+
+func TestCloseProject(t *testing.T) {
+	room := newTestRoom(t)
+	alice := room.join(t, "alice")
+	bob := room.join(t, "bob")
+
+	room.hub.CloseProject(room.project.ID.String(), "deleted")
+
+	for _, client := range []*Client{alice, bob} {
+		msg, ok := waitFor(t, client, "force_disconnect")
+		if !ok || !strings.Contains(string(msg.Payload), "deleted") {
+			t.Fatalf("%s got %s, want force_disconnect with the reason", client.Username, msg.Payload)
+		}
+		if _, ok := waitFor(t, client, "never"); ok {
+			t.Fatalf("%s's channel is still open", client.Username)
+		}
+	}
+}
+
+// TestConcurrentAccess drives every entry point from many goroutines at once.
+// Run it with -race: it checks that no caller touches hub state directly.
+func TestConcurrentAccess(t *testing.T) {
+	room := newTestRoom(t)
+	ctx := context.Background()
+	projectID := room.project.ID.String()
+	content := "package main"
+	file := models.FileNode{ProjectID: room.project.ID, Name: "main.go", Content: &content}
+	if err := room.store.Files.Create(ctx, &file); err != nil {
+		t.Fatal(err)
+	}
+
+	clients := make([]*Client, 8)
+	for i := range clients {
+		clients[i] = room.join(t, fmt.Sprintf("user%d", i))
+	}
+	var readers sync.WaitGroup
+	for _, c := range clients {
+		readers.Add(1)
+		go func(c *Client) {
+			defer readers.Done()
+			wake := c.wakeChan()
+			for {
+				select {
+				case _, ok := <-c.Send:
+					if !ok {
+						return
+					}
+				case <-wake:
+					c.takePending()
+				}
+			}
+		}(c)
+	}
+
+	var wg sync.WaitGroup
+	for i, c := range clients {
+		wg.Add(1)
+		go func(i int, c *Client) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				switch j % 6 {
+				case 0:
+					room.broadcast(c, "editor_update", map[string]string{"fileId": file.ID.String(), "content": fmt.Sprint(i, j)})
+				case 1:
+					room.broadcast(c, "whiteboard_update", map[string]interface{}{"shape": map[string]string{"id": fmt.Sprint("shape", i)}})
+				case 2:
+					if _, err := room.hub.GetWhiteboardSnapshot(ctx, projectID); err != nil {
+						t.Error(err)
+					}
+				case 3:
+					room.hub.NotifyUser(clients[(i+1)%len(clients)].UserID, projectID, "notice", j)
+				case 4:
+					room.hub.UpdatePermissions(&PermissionUpdate{UserID: c.UserID, ProjectID: projectID, Role: c.Role, Permissions: permissions.NewSet(permissions.BuiltinRoles[permissions.OwnerRole]...)})
+				case 5:
+					room.hub.EditorContents(context.Background(), projectID)
+					room.hub.InvalidateFileAccess(projectID)
+				}
+			}
+		}(i, c)
+	}
+	wg.Wait()
+
+	for _, c := range clients {
+		room.hub.KickUser(c.UserID, projectID, "done")
+	}
+	readers.Wait()
+
+	contents, _ := room.hub.EditorContents(context.Background(), projectID)
+	if _, ok := contents[file.ID.String()]; !ok {
+		t.Fatalf("live contents = %v, want main.go", contents)
+	}
+}
+
+func TestReplayBufferBounds(t *testing.T) {
+	buf := newReplayBuffer()
+	for i := 0; i < replayBufferMessages+10; i++ {
+		buf.add(WsMessage{Type: "file_created"}, "", "")
+	}
+	oldest := buf.entries[0].seq
+	if oldest != 11 || len(buf.entries) != replayBufferMessages {
+		t.Fatalf("buffer holds %d entries from %d", len(buf.entries), oldest)
+	}
+	// Anything that would need a dropped message is refused, never cut short.
+	for _, seq := range []uint64{0, oldest - 2, buf.seq + 1} {
+		if entries, ok := buf.since(seq); ok {
+			t.Errorf("since(%d) = %d entries", seq, len(entries))
+		}
+	}
+	for _, seq := range []uint64{oldest - 1, oldest + 100, buf.seq} {
+		entries, ok := buf.since(seq)
+		if !ok || uint64(len(entries)) != buf.seq-seq {
+			t.Fatalf("since(%d) = %d entries, %v", seq, len(entries), ok)
+		}
+		for i, entry := range entries {
+			if entry.seq != seq+1+uint64(i) {
+				t.Fatalf("since(%d): entry %d has seq %d", seq, i, entry.seq)
+			}
+		}
+	}
+}
+
+func TestReconnectReplay(t *testing.T) {
+	test := newTestRoom(t)
+	bob := test.join(t, "bob")
+	msg, _ := waitFor(t, bob, "sequence_start")
+	var start SequencePayload
+	json.Unmarshal(msg.Payload, &start)
+	for i := 0; i < 5; i++ {
+		test.broadcast(bob, "whiteboard_object_remove", map[string]string{"id": fmt.Sprint("shape", i)})
+	}
+
+	// resume joins a client that last saw seq and returns what it was sent
+	// up to the final replay_complete or resync_required.
+	resume := func(name, epoch string, seq uint64) ([]WsMessage, SequencePayload) {
+		client := test.join(t, name, func(c *Client) { c.ResumeEpoch, c.ResumeSeq = epoch, seq })
+		msgs, ok := readUntil(t, client, "replay_complete", "resync_required")
+		if !ok {
+			t.Fatalf("%s was disconnected", name)
+		}
+		var position SequencePayload
+		json.Unmarshal(msgs[len(msgs)-1].Payload, &position)
+		return msgs, position
+	}
+	replays := func(msgs []WsMessage) []uint64 {
+		var seqs []uint64
+		for _, m := range msgs {
+			if m.Type == "whiteboard_object_remove" {
+				seqs = append(seqs, m.Seq)
+			}
+		}
+		return seqs
+	}
+
+	msgs, position := resume("alice", start.Epoch, start.Seq+2)
+	if seqs := replays(msgs); len(seqs) != 3 || seqs[0] != start.Seq+3 || seqs[2] != start.Seq+5 {
+		t.Errorf("replayed %v", seqs)
+	}
+	if msgs[len(msgs)-1].Type == "resync_required" || position.Replayed == nil || *position.Replayed != 3 {
+		t.Errorf("replay ended with %+v", position)
+	}
+
+	msgs, position = resume("carol", "another-epoch", start.Seq+2)
+	if seqs := replays(msgs); len(seqs) != 0 || position.Reason != "epoch_changed" || position.Epoch != start.Epoch {
+		t.Errorf("stale epoch: replayed %v, then %+v", seqs, position)
+	}
+
+	// Push the first messages out of the buffer: resuming from before them
+	// must resync rather than replay what is left.
+	for i := 0; i < replayBufferMessages; i++ {
+		test.broadcast(bob, "whiteboard_object_remove", map[string]string{"id": "gone"})
+	}
+	msgs, position = resume("dave", start.Epoch, start.Seq+1)
+	if seqs := replays(msgs); len(seqs) != 0 || position.Reason != "too_far_behind" {
+		t.Errorf("seq older than the buffer: replayed %d, then %+v", len(seqs), position)
+	}
+}
+
+func TestSlowClientPolicies(t *testing.T) {
+	for _, policy := range []SendPolicy{PolicyDrop, PolicyCoalesce, PolicyDisconnect} {
+		t.Run(policy.String(), func(t *testing.T) {
+			test := newTestRoom(t)
+			test.hub.SetSendPolicies(SendPolicies{
+				Default: PolicyDisconnect,
+				ByType:  map[string]SendPolicy{"presence_update": PolicyCoalesce, "whiteboard_object_remove": policy},
+			})
+			projectID := test.project.ID.String()
+			bob := test.join(t, "bob")
+			alice := test.join(t, "alice", func(c *Client) { c.Send = make(chan []byte, 4) })
+			waitFor(t, alice, "sequence_start")
+
+			// Alice stops reading: fill her queue, then have bob send three
+			// removals that replace each other.
+		fill:
+			for {
+				select {
+				case alice.Send <- []byte(`{"type":"filler"}`):
+				default:
+					break fill
+				}
+			}
+			for i := 0; i < 3; i++ {
+				test.broadcast(bob, "whiteboard_object_remove", map[string]string{"id": fmt.Sprint("shape", i)})
+			}
+			connected := make(chan bool, 1)
+			test.hub.post(projectID, false, func(r *room) { connected <- r.clients[alice.UserID] == alice })
+			still := <-connected
+
+			// Drain what alice would still get once she catches up.
+			var removed []string
+			open := true
+			for open && len(alice.Send) > 0 {
+				var data []byte
+				if data, open = <-alice.Send; open {
+					var msg WsMessage
+					json.Unmarshal(data, &msg)
+					if msg.Type == "whiteboard_object_remove" {
+						removed = append(removed, string(msg.Payload))
+					}
+				}
+			}
+			for _, data := range alice.takePending() {
+				var msg WsMessage
+				json.Unmarshal(data, &msg)
+				if msg.Type == "whiteboard_object_remove" {
+					removed = append(removed, string(msg.Payload))
+				}
+			}
+
+			stats := test.hub.Stats()
+			switch policy {
+			case PolicyDrop:
+				if !still || len(removed) != 0 || stats.Dropped != 3 || stats.Evicted != 0 {
+					t.Errorf("connected %v, received %v, %+v", still, removed, stats)
+				}
+			case PolicyCoalesce:
+				if !still || len(removed) != 1 || !strings.Contains(removed[0], "shape2") || stats.Coalesced < 2 {
+					t.Errorf("connected %v, received %v, %+v", still, removed, stats)
+				}
+			case PolicyDisconnect:
+				if _, open := <-alice.Send; still || open || len(removed) != 0 || stats.Evicted != 1 {
+					t.Errorf("connected %v, channel open %v, received %v, %+v", still, open, removed, stats)
+				}
+			}
+		})
+	}
+}
+
+func TestSendPoliciesFromEnv(t *testing.T) {
+	t.Setenv("WS_SEND_POLICY", "drop")
+	t.Setenv("WS_SEND_POLICIES", " editor_update = coalesce,,presence_update=disconnect")
+	policies, err := SendPoliciesFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policies.Default != PolicyDrop || policies.forType("editor_update") != PolicyCoalesce ||
+		policies.forType("presence_update") != PolicyDisconnect || policies.forType("cursor_update") != PolicyCoalesce {
+		t.Errorf("policies = %+v", policies)
+	}
+
+	for _, c := range []struct{ policy, byType string }{
+		{"slow", ""},
+		{"Drop", ""},
+		{"", "editor_update"},
+		{"", "=drop"},
+		{"", "editor_update=wait"},
+		{"", "editor_update=drop,cursor_update"},
+	} {
+		t.Setenv("WS_SEND_POLICY", c.policy)
+		t.Setenv("WS_SEND_POLICIES", c.byType)
+		if _, err := SendPoliciesFromEnv(); err == nil {
+			t.Errorf("WS_SEND_POLICY=%q WS_SEND_POLICIES=%q accepted", c.policy, c.byType)
+		}
+	}
+}
+
+// TestRoomsRunIndependently checks that a room stuck on something slow, such
+// as a query, does not hold up other projects.
+func TestRoomsRunIndependently(t *testing.T) {
+	stuck := newTestRoom(t)
+	stuck.join(t, "alice")
+	busy := make(chan struct{})
+	defer close(busy)
+	stuck.hub.post(stuck.project.ID.String(), false, func(r *room) { <-busy })
+
+	project, err := stuck.store.Projects.Create(context.Background(), "other", stuck.owner.ID.String(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := testRoom{hub: stuck.hub, store: stuck.store, project: project, owner: stuck.owner}
+	bob := other.join(t, "bob")
+	carol := other.join(t, "carol")
+	other.broadcast(bob, "file_created", map[string]string{"name": "notes.md"})
+	if _, ok := waitFor(t, carol, "file_created"); !ok {
+		t.Fatal("carol's channel was closed")
+	}
+}
+
+// TestCacheLimits checks that rooms evict files past their limits without
+// losing unsaved edits.
+func TestCacheLimits(t *testing.T) {
+	test := newTestRoom(t)
+	limits := DefaultCacheLimits()
+	limits.Budget, limits.RoomFiles = 1000, 3
+	test.hub.SetCacheLimits(limits)
+	ctx := context.Background()
+	projectID := test.project.ID.String()
+	alice := test.join(t, "alice")
+
+	var fileIDs []string
+	for i := 0; i < 5; i++ {
+		content := "saved"
+		file := models.FileNode{ProjectID: test.project.ID, Name: fmt.Sprint("file", i), Content: &content}
+		if err := test.store.Files.Create(ctx, &file); err != nil {
+			t.Fatal(err)
+		}
+		fileIDs = append(fileIDs, file.ID.String())
+		test.broadcast(alice, "editor_update", map[string]string{"fileId": file.ID.String(), "content": fmt.Sprint("edited ", i)})
+	}
+	contents, _ := test.hub.EditorContents(context.Background(), projectID)
+	for i, fileID := range fileIDs {
+		if want := fmt.Sprint("edited ", i); contents[fileID] != want {
+			t.Errorf("file %d = %q, want %q", i, contents[fileID], want)
+		}
+	}
+
+	// Past the budget, everything but the file just edited goes.
+	test.broadcast(alice, "editor_update", map[string]string{"fileId": fileIDs[0], "content": strings.Repeat("x", 999)})
+	test.hub.EditorContents(context.Background(), projectID)
+	cached := make(chan int, 1)
+	test.hub.post(projectID, false, func(r *room) { cached <- len(r.state.EditorContents) })
+	if n := <-cached; n != 1 {
+		t.Fatalf("%d files cached, want 1", n)
+	}
+}
+
+func TestProtocolErrorsAndAcks(t *testing.T) {
+	test := newTestRoom(t)
+	alice := test.join(t, "alice", func(c *Client) { c.Protocol = ProtocolVersion })
+	if msg, ok := waitFor(t, alice, "hello"); !ok || !strings.Contains(string(msg.Payload), `"maxProtocol":2`) {
+		t.Fatalf("hello = %s", msg.Payload)
+	}
+
+	send := func(data string) {
+		test.hub.Broadcast(&Message{ProjectID: test.project.ID.String(), Data: []byte(data), Sender: alice})
+	}
+	for _, c := range []struct{ data, code string }{
+		{`{"type":`, ErrorMalformed},
+		{`{"type":"no_such_type","requestId":"r1"}`, ErrorUnknownType},
+		{`{"type":"hello","requestId":"r2"}`, ErrorUnknownType},
+		{`{"type":"editor_update","payload":{"fileId":"x"},"requestId":"r3"}`, ErrorInvalidPayload},
+		{`{"type":"whiteboard_update","payload":{"shape":{}},"requestId":"r4"}`, ErrorInvalidPayload},
+	} {
+		send(c.data)
+		msg, ok := waitFor(t, alice, "error")
+		var failure ErrorPayload
+		if !ok || json.Unmarshal(msg.Payload, &failure) != nil || failure.Code != c.code {
+			t.Fatalf("%s: error = %s, want code %s", c.data, msg.Payload, c.code)
+		}
+	}
+
+	send(`{"type":"whiteboard_update","payload":{"shape":{"id":"s1"}},"requestId":"r5"}`)
+	msg, ok := waitFor(t, alice, "ack")
+	var ack AckPayload
+	if !ok || json.Unmarshal(msg.Payload, &ack) != nil || ack.RequestID != "r5" || ack.Seq == 0 {
+		t.Fatalf("ack = %s", msg.Payload)
+	}
+
+	// Protocol 1 clients only hear about refused permissions.
+	bob := test.join(t, "bob", func(c *Client) { c.Permissions = permissions.NewSet(permissions.ProjectRead) })
+	test.broadcast(bob, "whiteboard_object_remove", map[string]string{"id": "s1"})
+	if msg, ok := waitFor(t, bob, "permission_denied"); !ok || !strings.Contains(string(msg.Payload), `"whiteboard.edit"`) {
+		t.Fatalf("permission_denied = %s", msg.Payload)
+	}
+}
+
+// TestForeignFiles checks that a room never serves, caches or relays files
+// of another project, whatever the client's permissions.
+func TestForeignFiles(t *testing.T) {
+	test := newTestRoom(t)
+	ctx := context.Background()
+	other, err := test.store.Projects.Create(ctx, "other", test.owner.ID.String(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := "secret"
+	secret := models.FileNode{ProjectID: other.ID, Name: "secret.txt", Content: &content}
+	if err := test.store.Files.Create(ctx, &secret); err != nil {
+		t.Fatal(err)
+	}
+	fileID := secret.ID.String()
+	alice := test.join(t, "alice")
+	bob := test.join(t, "bob")
+
+	for _, c := range []struct {
+		msgType string
+		payload interface{}
+	}{
+		{"request_file_content", map[string]string{"fileId": fileID}},
+		{"editor_update", map[string]string{"fileId": fileID, "content": "overwritten"}},
+	} {
+		test.broadcast(alice, c.msgType, c.payload)
+		if msg, ok := waitFor(t, alice, "permission_denied"); !ok || !strings.Contains(string(msg.Payload), fileID) {
+			t.Fatalf("%s: permission_denied = %s", c.msgType, msg.Payload)
+		}
+	}
+	test.broadcast(alice, "cursor_update", CursorUpdatePayload{FileID: fileID})
+
+	if contents, _ := test.hub.EditorContents(context.Background(), test.project.ID.String()); len(contents) != 0 {
+		t.Errorf("room caches %v", contents)
+	}
+	for len(bob.Send) > 0 {
+		if data := <-bob.Send; strings.Contains(string(data), fileID) {
+			t.Errorf("bob received %s", data)
+		}
+	}
+	for _, data := range bob.takePending() {
+		if strings.Contains(string(data), fileID) {
+			t.Errorf("bob received %s", data)
+		}
+	}
+}
+
+func TestCursorsAndFollow(t *testing.T) {
+	test := newTestRoom(t)
+	ctx := context.Background()
+	var fileIDs []string
+	for i := 0; i < 2; i++ {
+		file := models.FileNode{ProjectID: test.project.ID, Name: fmt.Sprint("file", i)}
+		if err := test.store.Files.Create(ctx, &file); err != nil {
+			t.Fatal(err)
+		}
+		fileIDs = append(fileIDs, file.ID.String())
+	}
+	alice := test.join(t, "alice")
+	bob := test.join(t, "bob", func(c *Client) { c.Protocol = ProtocolVersion })
+
+	data, _ := json.Marshal(WsMessage{Type: "follow_user", Payload: json.RawMessage(`{"userId":"` + alice.UserID + `"}`), RequestID: "f1"})
+	test.hub.Broadcast(&Message{ProjectID: test.project.ID.String(), Data: data, Sender: bob})
+	if _, ok := waitFor(t, bob, "ack"); !ok {
+		t.Fatal("follow_user was not acked")
+	}
+
+	// Opening a file and moving the cursor to another both lead bob along.
+	test.broadcast(alice, "request_file_content", map[string]string{"fileId": fileIDs[0]})
+	msg, ok := waitFor(t, bob, "follow_file")
+	if !ok || !strings.Contains(string(msg.Payload), fileIDs[0]) {
+		t.Fatalf("follow_file = %s", msg.Payload)
+	}
+	cursor := CursorUpdatePayload{FileID: fileIDs[1], Ranges: []CursorRange{{Anchor: CursorPosition{1, 1}, Head: CursorPosition{2, 5}}}}
+	test.broadcast(alice, "cursor_update", cursor)
+	if msg, ok := waitFor(t, bob, "follow_file"); !ok || !strings.Contains(string(msg.Payload), fileIDs[1]) {
+		t.Fatalf("follow_file = %s", msg.Payload)
+	}
+	msg, ok = waitFor(t, bob, "cursor_update")
+	var got CursorUpdatePayload
+	if !ok || json.Unmarshal(msg.Payload, &got) != nil || got.UserID != alice.UserID || got.FileID != fileIDs[1] || len(got.Ranges) != 1 {
+		t.Fatalf("cursor_update = %s", msg.Payload)
+	}
+	carol := test.join(t, "carol")
+	msg, _ = waitFor(t, carol, "presence_update")
+	var presence PresenceUpdatePayload
+	json.Unmarshal(msg.Payload, &presence)
+	for _, user := range presence.Users {
+		if user.UserID == alice.UserID && (user.FileID != fileIDs[1] || user.Color != userColor(alice.UserID)) {
+			t.Errorf("alice's presence = %+v", user)
+		}
+	}
+
+	test.hub.Unregister(alice)
+	if msg, ok := waitFor(t, bob, "follow_stopped"); !ok || !strings.Contains(string(msg.Payload), `"left"`) {
+		t.Fatalf("follow_stopped = %s", msg.Payload)
+	}
+}
@@ -0,0 +1,193 @@
+package ws
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gorilla/websocket"
+)
+
+// SendPolicy decides what the hub does with a message for a client whose
+// send buffer is full. The hub never waits for a client.
+type SendPolicy int
+
+const (
+	// PolicyDisconnect closes the client's connection with
+	// CloseTryAgainLater, so it reconnects and resumes or resyncs.
+	PolicyDisconnect SendPolicy = iota
+	// PolicyDrop discards the message.
+	PolicyDrop
+	// PolicyCoalesce keeps only the latest message per key, e.g. per user
+	// for cursor moves, and sends it when the connection catches up.
+	// Coalesced messages always take this path, so they never overtake a
+	// newer one.
+	PolicyCoalesce
+)
+
+var policyNames = map[string]SendPolicy{
+	"disconnect": PolicyDisconnect,
+	"drop":       PolicyDrop,
+	"coalesce":   PolicyCoalesce,
+}
+
+func (p SendPolicy) String() string {
+	for name, policy := range policyNames {
+		if policy == p {
+			return name
+		}
+	}
+	return fmt.Sprintf("SendPolicy(%d)", int(p))
+}
+
+// SendPolicies picks a policy per message type, falling back to Default.
+type SendPolicies struct {
+	Default SendPolicy
+	ByType  map[string]SendPolicy
+}
+
+// DefaultSendPolicies disconnects clients that miss room state and drops or
+// coalesces what is safe to lose or replace.
+func DefaultSendPolicies() SendPolicies {
+	return SendPolicies{
+		Default: PolicyDisconnect,
+		ByType: map[string]SendPolicy{
+			"presence_update":    PolicyCoalesce,
+			"cursor_update":      PolicyCoalesce,
+			"permission_denied":  PolicyDrop,
+			"permission_updated": PolicyDrop,
+			"force_disconnect":   PolicyDrop,
+		},
+	}
+}
+
+// SendPoliciesFromEnv starts from DefaultSendPolicies and applies
+// WS_SEND_POLICY, the default policy, and WS_SEND_POLICIES, a comma-separated
+// list of type=policy overrides such as "editor_update=coalesce".
+func SendPoliciesFromEnv() (SendPolicies, error) {
+	policies := DefaultSendPolicies()
+	if name := os.Getenv("WS_SEND_POLICY"); name != "" {
+		policy, ok := policyNames[name]
+		if !ok {
+			return policies, fmt.Errorf("unknown WS_SEND_POLICY %q, expected disconnect, drop or coalesce", name)
+		}
+		policies.Default = policy
+	}
+	for _, entry := range strings.Split(os.Getenv("WS_SEND_POLICIES"), ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		msgType, name, _ := strings.Cut(entry, "=")
+		policy, ok := policyNames[strings.TrimSpace(name)]
+		if !ok || strings.TrimSpace(msgType) == "" {
+			return policies, fmt.Errorf("invalid WS_SEND_POLICIES entry %q, expected type=disconnect|drop|coalesce", entry)
+		}
+		policies.ByType[strings.TrimSpace(msgType)] = policy
+	}
+	return policies, nil
+}
+
+func (p SendPolicies) forType(msgType string) SendPolicy {
+	if policy, ok := p.ByType[msgType]; ok {
+		return policy
+	}
+	return p.Default
+}
+
+// SendStats counts what the send policies did since the hub started.
+type SendStats struct {
+	Dropped   int64 `json:"dropped"`
+	Coalesced int64 `json:"coalesced"`
+	Evicted   int64 `json:"evicted"`
+}
+
+type sendCounters struct {
+	dropped   atomic.Int64
+	coalesced atomic.Int64
+	evicted   atomic.Int64
+}
+
+// SetSendPolicies replaces the hub's send policies. Call it before Run.
+func (h *Hub) SetSendPolicies(policies SendPolicies) {
+	h.policies = policies
+}
+
+// Stats returns the hub's send counters. It is safe to call from any
+// goroutine.
+func (h *Hub) Stats() SendStats {
+	return SendStats{
+		Dropped:   h.counters.dropped.Load(),
+		Coalesced: h.counters.coalesced.Load(),
+		Evicted:   h.counters.evicted.Load(),
+	}
+}
+
+// send queues data for a client under the policy for msgType. key groups
+// messages that replace each other when coalescing; it defaults to msgType.
+// It reports whether the message was queued.
+func (h *Hub) send(client *Client, msgType, key string, data []byte) bool {
+	if key == "" {
+		key = msgType
+	}
+	return h.sendWith(client, h.policies.forType(msgType), key, data)
+}
+
+func (h *Hub) sendWith(client *Client, policy SendPolicy, key string, data []byte) bool {
+	if client == nil || client.closed {
+		return false
+	}
+	if policy == PolicyCoalesce {
+		if client.coalesce(key, data) {
+			h.counters.coalesced.Add(1)
+		}
+		return true
+	}
+	select {
+	case client.Send <- data:
+		return true
+	default:
+	}
+	if policy == PolicyDrop {
+		h.counters.dropped.Add(1)
+		return false
+	}
+	log.Printf("[Hub] Disconnecting %s: send buffer full", client.Username)
+	h.evict(client)
+	return false
+}
+
+// evict disconnects a client that cannot keep up. It runs on the goroutine
+// that owns the client.
+func (h *Hub) evict(client *Client) {
+	h.counters.evicted.Add(1)
+	h.closeClient(client, websocket.CloseTryAgainLater)
+	if client.room != nil {
+		client.room.removeClient(client)
+	} else if client == h.sfuClient {
+		h.sfuClient = nil
+		log.Println("[Hub] SFU Server evicted")
+	}
+}
+
+// closeClient closes a client's send channel, making its write pump close
+// the connection with code, or with an empty close frame when code is 0.
+// Nothing may be sent to the client afterwards.
+func (h *Hub) closeClient(client *Client, code int) {
+	if client.closed {
+		return
+	}
+	client.closed = true
+	client.closeCode = code
+	close(client.Send)
+}
+
+// logSendStats reports the counters when they changed since last time.
+func (h *Hub) logSendStats(last *SendStats) {
+	stats := h.Stats()
+	if stats != *last {
+		log.Printf("[Hub] Slow clients so far: %d messages dropped, %d coalesced, %d clients evicted", stats.Dropped, stats.Coalesced, stats.Evicted)
+		*last = stats
+	}
+}
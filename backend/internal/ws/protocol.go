@@ -0,0 +1,418 @@
+package ws
+
+//go:generate go run ../../cmd/api ws-schema ../../../frontend/src/api/wsProtocol.schema.json
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"project-meetings/backend/internal/permissions"
+
+	"github.com/google/uuid"
+)
+
+// The protocol version is agreed on connect: clients ask for one with the
+// protocol query parameter and are told the outcome in hello. Version 1 is
+// the untyped protocol clients spoke before the handshake existed, and what
+// a client that does not ask gets. From version 2, refused messages are
+// answered with error frames rather than permission_denied.
+const (
+	ProtocolVersion    = 2
+	MinProtocolVersion = 1
+)
+
+// Codes of error frames.
+const (
+	ErrorMalformed        = "malformed"
+	ErrorUnknownType      = "unknown_type"
+	ErrorInvalidPayload   = "invalid_payload"
+	ErrorPermissionDenied = "permission_denied"
+)
+
+// Direction says who sends a message type.
+type Direction int
+
+const (
+	FromClient Direction = 1 << iota
+	FromServer
+)
+
+// MessageType describes one message of the protocol.
+type MessageType struct {
+	Type      string
+	Direction Direction
+	// Payload is a zero value of the payload's struct type.
+	Payload interface{}
+	// Permission, when set, is what a client needs to send the message.
+	Permission  permissions.Permission
+	Description string
+}
+
+// Validator is implemented by payloads that check their fields once
+// decoded.
+type Validator interface {
+	Validate() error
+}
+
+// --- CLIENT AND SERVER PAYLOADS ---
+
+type RequestFileContentPayload struct {
+	FileID string `json:"fileId"`
+}
+
+func (p *RequestFileContentPayload) Validate() error {
+	return validateID("fileId", p.FileID)
+}
+
+type EditorUpdatePayload struct {
+	FileID  string `json:"fileId"`
+	Content string `json:"content"`
+}
+
+func (p *EditorUpdatePayload) Validate() error {
+	return validateID("fileId", p.FileID)
+}
+
+// FileChangePayload names the file that was created, renamed or deleted.
+// Clients may leave it out and just ask others to refresh.
+type FileChangePayload struct {
+	ID string `json:"id,omitempty"`
+}
+
+type WhiteboardUpdatePayload struct {
+	// Shape is the shape as the whiteboard draws it, with at least an id.
+	Shape   json.RawMessage `json:"shape"`
+	shapeID string
+}
+
+func (p *WhiteboardUpdatePayload) Validate() error {
+	var shape struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(p.Shape, &shape); err != nil || shape.ID == "" {
+		return errors.New("shape must be an object with an id")
+	}
+	p.shapeID = shape.ID
+	return nil
+}
+
+// ShapeID returns the id of the shape, once validated.
+func (p *WhiteboardUpdatePayload) ShapeID() string {
+	return p.shapeID
+}
+
+type WhiteboardObjectRemovePayload struct {
+	ID string `json:"id"`
+}
+
+func (p *WhiteboardObjectRemovePayload) Validate() error {
+	if p.ID == "" {
+		return errors.New("id is required")
+	}
+	return nil
+}
+
+// maxCursorRanges bounds the selections of one cursor_update.
+const maxCursorRanges = 64
+
+// CursorPosition is a 1-based line and column.
+type CursorPosition struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// CursorRange is a selection from Anchor to Head, where the cursor is. An
+// empty selection has both at the same position.
+type CursorRange struct {
+	Anchor CursorPosition `json:"anchor"`
+	Head   CursorPosition `json:"head"`
+}
+
+// CursorUpdatePayload says where a user's cursors are. Without a file the
+// user has no file open. The hub fills in UserID.
+type CursorUpdatePayload struct {
+	UserID string        `json:"userId,omitempty"`
+	FileID string        `json:"fileId,omitempty"`
+	Ranges []CursorRange `json:"ranges"`
+}
+
+func (p *CursorUpdatePayload) Validate() error {
+	if p.FileID == "" {
+		if len(p.Ranges) > 0 {
+			return errors.New("ranges need a fileId")
+		}
+		return nil
+	}
+	if err := validateID("fileId", p.FileID); err != nil {
+		return err
+	}
+	if len(p.Ranges) > maxCursorRanges {
+		return fmt.Errorf("at most %d ranges are allowed", maxCursorRanges)
+	}
+	for _, r := range p.Ranges {
+		for _, pos := range []CursorPosition{r.Anchor, r.Head} {
+			if pos.Line < 1 || pos.Column < 1 {
+				return errors.New("lines and columns start at 1")
+			}
+		}
+	}
+	return nil
+}
+
+// FollowUserPayload names the user to follow; without one the client stops
+// following.
+type FollowUserPayload struct {
+	UserID string `json:"userId,omitempty"`
+}
+
+type WebRTCJoinPayload struct{}
+
+// Validate checks a signal sent by a client; the hub fills in the sender.
+func (p *SignalPayload) Validate() error {
+	if len(p.Data) == 0 {
+		return errors.New("data is required")
+	}
+	return nil
+}
+
+// --- SERVER PAYLOADS ---
+
+type HelloPayload struct {
+	// Protocol is the version the connection speaks.
+	Protocol    int `json:"protocol"`
+	MinProtocol int `json:"minProtocol"`
+	MaxProtocol int `json:"maxProtocol"`
+}
+
+type AckPayload struct {
+	RequestID string `json:"requestId"`
+	// Seq is the sequence number the message was broadcast with, if it was.
+	Seq uint64 `json:"seq,omitempty"`
+}
+
+type ErrorPayload struct {
+	RequestID string `json:"requestId,omitempty"`
+	// Type is the type of the refused message, when known.
+	Type    string            `json:"type,omitempty"`
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+type PresenceUpdatePayload struct {
+	Users []UserPresence `json:"users"`
+}
+
+// PermissionDeniedPayload is how protocol 1 clients learn that a message was
+// refused; later versions get an ErrorPayload.
+type PermissionDeniedPayload struct {
+	Type       string `json:"type"`
+	Permission string `json:"permission,omitempty"`
+	FileID     string `json:"fileId,omitempty"`
+}
+
+type PermissionUpdatedPayload struct {
+	NewRole     string                   `json:"newRole"`
+	Permissions []permissions.Permission `json:"permissions"`
+}
+
+type ForceDisconnectPayload struct {
+	Reason string `json:"reason"`
+}
+
+// SequencePayload is a position in a room's broadcasts, see syncClient.
+type SequencePayload struct {
+	Epoch    string `json:"epoch"`
+	Seq      uint64 `json:"seq"`
+	Reason   string `json:"reason,omitempty"`
+	Replayed *int   `json:"replayed,omitempty"`
+}
+
+type OwnershipTransferredPayload struct {
+	PreviousOwnerID string `json:"previousOwnerId"`
+	NewOwnerID      string `json:"newOwnerId"`
+}
+
+type MemberLeftPayload struct {
+	UserID string `json:"userId"`
+}
+
+type FollowFilePayload struct {
+	UserID string `json:"userId"`
+	FileID string `json:"fileId"`
+}
+
+type FollowStoppedPayload struct {
+	UserID string `json:"userId"`
+	// Reason is "left" when the followed user left the project.
+	Reason string `json:"reason"`
+}
+
+var registry = map[string]*MessageType{}
+
+func init() {
+	for _, t := range []MessageType{
+		{Type: "request_file_content", Direction: FromClient, Payload: RequestFileContentPayload{}, Permission: permissions.ProjectRead,
+			Description: "Asks for a file's live contents, answered with editor_update."},
+		{Type: "editor_update", Direction: FromClient | FromServer, Payload: EditorUpdatePayload{}, Permission: permissions.FileWrite,
+			Description: "A file's full contents after an edit."},
+		{Type: "file_created", Direction: FromClient | FromServer, Payload: FileChangePayload{}, Permission: permissions.FileWrite,
+			Description: "A file or folder was created; refresh the tree."},
+		{Type: "file_renamed", Direction: FromClient | FromServer, Payload: FileChangePayload{}, Permission: permissions.FileWrite,
+			Description: "A file or folder was renamed or moved; refresh the tree."},
+		{Type: "file_deleted", Direction: FromClient | FromServer, Payload: FileChangePayload{}, Permission: permissions.FileDelete,
+			Description: "A file or folder was deleted; refresh the tree."},
+		{Type: "whiteboard_update", Direction: FromClient | FromServer, Payload: WhiteboardUpdatePayload{}, Permission: permissions.WhiteboardEdit,
+			Description: "A whiteboard shape was drawn or changed."},
+		{Type: "whiteboard_object_remove", Direction: FromClient | FromServer, Payload: WhiteboardObjectRemovePayload{}, Permission: permissions.WhiteboardEdit,
+			Description: "A whiteboard shape was erased."},
+		{Type: "cursor_update", Direction: FromClient | FromServer, Payload: CursorUpdatePayload{}, Permission: permissions.ProjectRead,
+			Description: "Where a user's cursor and selections are. Coalesced per user; the hub fills in userId."},
+		{Type: "follow_user", Direction: FromClient, Payload: FollowUserPayload{}, Permission: permissions.ProjectRead,
+			Description: "Follows a user's file switches, or without a userId stops following."},
+		{Type: "webrtc_join", Direction: FromClient, Payload: WebRTCJoinPayload{}, Permission: permissions.CallJoin,
+			Description: "Joins the project's call."},
+		{Type: "webrtc_answer", Direction: FromClient, Payload: SignalPayload{}, Permission: permissions.CallJoin,
+			Description: "The client's answer to the SFU's offer."},
+		{Type: "webrtc_ice_candidate", Direction: FromClient | FromServer, Payload: SignalPayload{}, Permission: permissions.CallJoin,
+			Description: "An ICE candidate, to or from the SFU."},
+		{Type: "webrtc_offer", Direction: FromServer, Payload: SignalPayload{},
+			Description: "The SFU's offer to a client joining the call."},
+		{Type: "hello", Direction: FromServer, Payload: HelloPayload{},
+			Description: "First message on a connection: the protocol version it speaks."},
+		{Type: "ack", Direction: FromServer, Payload: AckPayload{},
+			Description: "A message sent with a requestId was accepted."},
+		{Type: "error", Direction: FromServer, Payload: ErrorPayload{},
+			Description: "A message was refused, with the requestId it was sent with, if any."},
+		{Type: "presence_update", Direction: FromServer, Payload: PresenceUpdatePayload{},
+			Description: "Who is connected to the project."},
+		{Type: "permission_denied", Direction: FromServer, Payload: PermissionDeniedPayload{},
+			Description: "Protocol 1 only: a message was refused for lack of a permission."},
+		{Type: "permission_updated", Direction: FromServer, Payload: PermissionUpdatedPayload{},
+			Description: "The user's role in the project changed."},
+		{Type: "force_disconnect", Direction: FromServer, Payload: ForceDisconnectPayload{},
+			Description: "The user was removed from the project; the connection closes next."},
+		{Type: "sequence_start", Direction: FromServer, Payload: SequencePayload{},
+			Description: "The position to resume from after a reconnect."},
+		{Type: "resync_required", Direction: FromServer, Payload: SequencePayload{},
+			Description: "Missed broadcasts are gone; reload the project's state."},
+		{Type: "replay_complete", Direction: FromServer, Payload: SequencePayload{},
+			Description: "Missed broadcasts were replayed."},
+		{Type: "ownership_transferred", Direction: FromServer, Payload: OwnershipTransferredPayload{},
+			Description: "The project has a new owner."},
+		{Type: "member_left", Direction: FromServer, Payload: MemberLeftPayload{},
+			Description: "A member left the project."},
+		{Type: "follow_file", Direction: FromServer, Payload: FollowFilePayload{},
+			Description: "The followed user switched to another file; open it too."},
+		{Type: "follow_stopped", Direction: FromServer, Payload: FollowStoppedPayload{},
+			Description: "Following ended because the followed user left."},
+	} {
+		t := t
+		registry[t.Type] = &t
+	}
+}
+
+// Messages returns every message type of the protocol, sorted by type.
+func Messages() []MessageType {
+	types := make([]MessageType, 0, len(registry))
+	for _, t := range registry {
+		types = append(types, *t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].Type < types[j].Type })
+	return types
+}
+
+// parseMessage decodes and validates a message. A message from a client
+// must be of a type clients send. The error payload says what was wrong.
+func parseMessage(data []byte, fromClient bool) (WsMessage, interface{}, *ErrorPayload) {
+	var msg WsMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return msg, nil, &ErrorPayload{Code: ErrorMalformed, Message: "Message is not valid JSON: " + err.Error()}
+	}
+	failure := &ErrorPayload{RequestID: msg.RequestID, Type: msg.Type}
+	t, ok := registry[msg.Type]
+	if !ok || (fromClient && t.Direction&FromClient == 0) {
+		failure.Code = ErrorUnknownType
+		failure.Message = fmt.Sprintf("Unknown message type %q", msg.Type)
+		return msg, nil, failure
+	}
+	payload, err := decodePayload(t, msg.Payload)
+	if err != nil {
+		failure.Code = ErrorInvalidPayload
+		failure.Message = err.Error()
+		return msg, nil, failure
+	}
+	return msg, payload, nil
+}
+
+// decodePayload decodes a payload into a new value of its type's struct and
+// validates it. A missing payload is the zero value.
+func decodePayload(t *MessageType, raw json.RawMessage) (interface{}, error) {
+	payload := reflect.New(reflect.TypeOf(t.Payload)).Interface()
+	if len(raw) > 0 && string(raw) != "null" {
+		if err := json.Unmarshal(raw, payload); err != nil {
+			return nil, fmt.Errorf("Invalid %s payload: %v", t.Type, err)
+		}
+	}
+	if v, ok := payload.(Validator); ok {
+		if err := v.Validate(); err != nil {
+			return nil, fmt.Errorf("Invalid %s payload: %v", t.Type, err)
+		}
+	}
+	return payload, nil
+}
+
+func validateID(field, value string) error {
+	if _, err := uuid.Parse(value); err != nil {
+		return fmt.Errorf("%s must be a UUID", field)
+	}
+	return nil
+}
+
+// protocolVersion is the version a client speaks; clients registered
+// without one speak version 1.
+func (c *Client) protocolVersion() int {
+	if c.Protocol == 0 {
+		return MinProtocolVersion
+	}
+	return c.Protocol
+}
+
+// reject tells a client one of its messages was refused. Protocol 1 clients
+// only understand permission_denied, so other refusals are just logged for
+// them.
+func (h *Hub) reject(client *Client, failure *ErrorPayload) {
+	if client.protocolVersion() < 2 {
+		if failure.Code == ErrorPermissionDenied {
+			h.sendMessage(client, "permission_denied", PermissionDeniedPayload{
+				Type:       failure.Type,
+				Permission: failure.Details["permission"],
+				FileID:     failure.Details["fileId"],
+			})
+		}
+		return
+	}
+	h.sendMessage(client, "error", failure)
+}
+
+// ack confirms a message a client sent with a request ID.
+func (h *Hub) ack(client *Client, msg WsMessage, seq uint64) {
+	if client != nil && msg.RequestID != "" && client.protocolVersion() >= 2 {
+		h.sendMessage(client, "ack", AckPayload{RequestID: msg.RequestID, Seq: seq})
+	}
+}
+
+// permissionDenied describes a refusal for lack of access, with the missing
+// permission or the file in details.
+func permissionDenied(msg WsMessage, key, value string) *ErrorPayload {
+	return &ErrorPayload{
+		RequestID: msg.RequestID,
+		Type:      msg.Type,
+		Code:      ErrorPermissionDenied,
+		Message:   "You are not allowed to send this message",
+		Details:   map[string]string{key: value},
+	}
+}
@@ -0,0 +1,135 @@
+package ws
+
+import (
+	"encoding/json"
+	"log"
+
+	"project-meetings/backend/internal/acl"
+
+	"github.com/google/uuid"
+)
+
+// A room keeps its most recent broadcasts for clients that reconnect, up to
+// these bounds, whichever is reached first.
+const (
+	replayBufferMessages = 1024
+	replayBufferBytes    = 4 << 20
+)
+
+// replayEntry is one numbered broadcast.
+type replayEntry struct {
+	seq  uint64
+	data []byte
+	// senderID is left out of its own replay; fileID, when set, limits
+	// the replay to clients that can read the file.
+	senderID string
+	fileID   string
+}
+
+// replayBuffer numbers a room's broadcasts and keeps the latest of them.
+// Sequence numbers only mean something together with the epoch: a client
+// that comes back to a room with another epoch, e.g. because this instance
+// restarted or it reconnected to a different one, has to resync.
+type replayBuffer struct {
+	epoch   string
+	seq     uint64
+	entries []replayEntry // oldest first
+	bytes   int
+}
+
+func newReplayBuffer() *replayBuffer {
+	return &replayBuffer{epoch: uuid.NewString()}
+}
+
+// add numbers msg, records it and returns it encoded with its sequence. The
+// request ID is only for the sender's ack, so it is left out.
+func (b *replayBuffer) add(msg WsMessage, senderID, fileID string) []byte {
+	b.seq++
+	msg.Seq = b.seq
+	msg.RequestID = ""
+	data, _ := json.Marshal(msg)
+	b.entries = append(b.entries, replayEntry{seq: b.seq, data: data, senderID: senderID, fileID: fileID})
+	b.bytes += len(data)
+	for len(b.entries) > replayBufferMessages || (b.bytes > replayBufferBytes && len(b.entries) > 1) {
+		b.bytes -= len(b.entries[0].data)
+		b.entries[0] = replayEntry{}
+		b.entries = b.entries[1:]
+	}
+	return data
+}
+
+// since returns the broadcasts after seq, or false when some of them have
+// already been dropped.
+func (b *replayBuffer) since(seq uint64) ([]replayEntry, bool) {
+	if seq > b.seq {
+		return nil, false
+	}
+	if seq == b.seq {
+		return nil, true
+	}
+	if len(b.entries) == 0 || seq+1 < b.entries[0].seq {
+		return nil, false
+	}
+	return b.entries[seq+1-b.entries[0].seq:], true
+}
+
+// replayBuffer returns the state's buffer, creating it on first use.
+func (s *ProjectState) replayBuffer() *replayBuffer {
+	if s.replay == nil {
+		s.replay = newReplayBuffer()
+	}
+	return s.replay
+}
+
+// syncClient starts a newly registered client's sequence. A client resuming
+// from an earlier connection is sent the broadcasts it missed and then
+// replay_complete, or resync_required when they are no longer available
+// and it must reload the room's state. Anyone else gets sequence_start.
+// Clients see gaps in the numbering for their own messages and for files
+// they cannot read.
+func (r *room) syncClient(client *Client) {
+	h := r.hub
+	buf := r.state.replayBuffer()
+	position := SequencePayload{Epoch: buf.epoch, Seq: buf.seq}
+	if client.ResumeEpoch == "" {
+		h.sendMessage(client, "sequence_start", position)
+		return
+	}
+
+	entries, ok := buf.since(client.ResumeSeq)
+	switch {
+	case client.ResumeEpoch != buf.epoch:
+		position.Reason = "epoch_changed"
+		ok = false
+	case client.ResumeSeq > buf.seq:
+		position.Reason = "unknown_sequence"
+	case !ok:
+		position.Reason = "too_far_behind"
+	case len(entries) > cap(client.Send)/2:
+		// The replay has to fit the send buffer, as the write pump may not
+		// be running yet.
+		position.Reason = "too_far_behind"
+		ok = false
+	}
+	if !ok {
+		log.Printf("[Hub] %s must resync project %s: %s", client.Username, client.ProjectID, position.Reason)
+		h.sendMessage(client, "resync_required", position)
+		return
+	}
+
+	replayed := 0
+	for _, entry := range entries {
+		if entry.senderID == client.UserID {
+			continue
+		}
+		if entry.fileID != "" && h.fileAccess(client, entry.fileID) < acl.Read {
+			continue
+		}
+		if h.sendWith(client, PolicyDrop, "", entry.data) {
+			replayed++
+		}
+	}
+	log.Printf("[Hub] Replayed %d messages to %s in project %s", replayed, client.Username, client.ProjectID)
+	position.Replayed = &replayed
+	h.sendMessage(client, "replay_complete", position)
+}
@@ -0,0 +1,669 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"project-meetings/backend/internal/acl"
+	"project-meetings/backend/internal/store"
+
+	"github.com/google/uuid"
+)
+
+// persistQueueSize is how many database writes a room may have waiting.
+const persistQueueSize = 256
+
+// room is the actor for one project. Its goroutine owns the project's live
+// state and the clients connected to it, and its writer goroutine performs
+// the project's database writes in order, so a slow query only ever holds up
+// its own project. Rooms start when the first client joins and stop once
+// they have been idle for CacheLimits.IdleTimeout.
+type room struct {
+	hub       *Hub
+	projectID string
+	state     *ProjectState
+	clients   map[string]*Client // userID -> Client
+	// openFiles is who had which file open at the last presence update,
+	// across instances, to tell followers when it changes.
+	openFiles map[string]string
+	// lastActive is when the room last ran anything.
+	lastActive time.Time
+
+	// entries are the room's share of the hub's cache budget, keyed by file
+	// ID with the whiteboard under "". fileBytes and shapeBytes are the
+	// size of the cached files and of the whiteboard, and clock orders
+	// entries by use. evictedDirty is set while drafts of evicted files may
+	// still be waiting to be written.
+	entries      map[string]*cacheEntry
+	fileBytes    int64
+	shapeBytes   int64
+	clock        uint64
+	evictedDirty bool
+
+	// mailbox holds the functions posted to the room until its goroutine
+	// runs them, in order. Posting never blocks, so neither does the hub.
+	mu      sync.Mutex
+	mailbox []func(*room)
+	wake    chan struct{}
+
+	// persist queues the room's database writes.
+	persist chan func(context.Context)
+	// after, when set, is closed once the writer of the project's previous
+	// room has finished, and done once this room's has.
+	after <-chan struct{}
+	done  chan struct{}
+}
+
+// startRoom creates and starts a project's room. The caller holds h.mu.
+func (h *Hub) startRoom(projectID string) *room {
+	r := &room{
+		hub:       h,
+		projectID: projectID,
+		state: &ProjectState{
+			EditorContents:   make(map[string]string),
+			WhiteboardShapes: make(map[string]string),
+		},
+		clients:    make(map[string]*Client),
+		lastActive: time.Now(),
+		entries:    make(map[string]*cacheEntry),
+		wake:       make(chan struct{}, 1),
+		persist:    make(chan func(context.Context), persistQueueSize),
+		done:       make(chan struct{}),
+	}
+	// A room that just stopped may still be writing; this one must not read
+	// the database before it is done.
+	if previous, ok := h.retiring[projectID]; ok {
+		r.after = previous.done
+	}
+	h.rooms[projectID] = r
+	go r.run()
+	log.Printf("[Hub] Room for project %s started", projectID)
+	return r
+}
+
+// post runs fn on the project's room goroutine, starting the room first if
+// create is set. It reports whether there was a room to run it.
+func (h *Hub) post(projectID string, create bool, fn func(*room)) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.postLocked(projectID, create, fn)
+}
+
+// postLocked is post for callers that hold h.mu.
+func (h *Hub) postLocked(projectID string, create bool, fn func(*room)) bool {
+	r, ok := h.rooms[projectID]
+	if !ok {
+		if !create {
+			return false
+		}
+		r = h.startRoom(projectID)
+	}
+	r.enqueue(fn)
+	return true
+}
+
+// enqueue adds fn to the room's mailbox. Functions enqueued after the room
+// stopped never run.
+func (r *room) enqueue(fn func(*room)) {
+	r.mu.Lock()
+	r.mailbox = append(r.mailbox, fn)
+	r.mu.Unlock()
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// postToUser runs fn on the room holding the user's connection to projectID,
+// if they have one on this instance. An empty projectID matches any project.
+func (h *Hub) postToUser(userID, projectID string, fn func(*room, *Client)) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	client, ok := h.users[userID]
+	if !ok || (projectID != "" && client.ProjectID != projectID) {
+		return false
+	}
+	return h.postLocked(client.ProjectID, false, func(r *room) {
+		if r.clients[client.UserID] == client {
+			fn(r, client)
+		}
+	})
+}
+
+// sendToClient sends a client a message from any goroutine, by way of its
+// room. Nothing is sent if the client has left in the meantime.
+func (h *Hub) sendToClient(client *Client, msgType string, data []byte) {
+	h.post(client.ProjectID, false, func(r *room) {
+		if r.clients[client.UserID] == client {
+			h.send(client, msgType, "", data)
+		}
+	})
+}
+
+// waitRetired waits until a stopped room of the project, if any, has
+// written everything it had queued, or ctx is done.
+func (h *Hub) waitRetired(ctx context.Context, projectID string) error {
+	h.mu.Lock()
+	previous, ok := h.retiring[projectID]
+	h.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	select {
+	case <-previous.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *room) run() {
+	if r.after != nil {
+		<-r.after
+	}
+	go r.runWriter()
+	flush := time.NewTicker(draftFlushInterval)
+	defer flush.Stop()
+	idleTimeout := r.hub.limits.IdleTimeout
+	idle := time.NewTimer(idleTimeout)
+	defer idle.Stop()
+
+	for {
+		select {
+		case <-r.wake:
+			for _, fn := range r.takeMailbox() {
+				fn(r)
+			}
+			r.lastActive = time.Now()
+
+		case <-flush.C:
+			r.queueDrafts()
+
+		case <-idle.C:
+			if len(r.clients) > 0 {
+				idle.Reset(idleTimeout)
+				continue
+			}
+			if remaining := idleTimeout - time.Since(r.lastActive); remaining > 0 {
+				idle.Reset(remaining)
+				continue
+			}
+			if r.retire() {
+				return
+			}
+			idle.Reset(idleTimeout)
+		}
+	}
+}
+
+func (r *room) takeMailbox() []func(*room) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	mailbox := r.mailbox
+	r.mailbox = nil
+	return mailbox
+}
+
+// retire takes the room out of the registry, unless something was posted to
+// it in the meantime, and closes its write queue after the last drafts.
+func (r *room) retire() bool {
+	h := r.hub
+	h.mu.Lock()
+	r.mu.Lock()
+	busy := len(r.mailbox) > 0
+	r.mu.Unlock()
+	if busy {
+		h.mu.Unlock()
+		return false
+	}
+	delete(h.rooms, r.projectID)
+	h.retiring[r.projectID] = r
+	h.mu.Unlock()
+
+	if batch := r.draftBatch(); batch != nil {
+		r.persist <- batch
+	}
+	close(r.persist)
+	r.releaseCache()
+	log.Printf("[Hub] Room for project %s stopped after being idle", r.projectID)
+	return true
+}
+
+// runWriter performs the room's queued writes, each with its own timeout.
+func (r *room) runWriter() {
+	for job := range r.persist {
+		ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+		job(ctx)
+		cancel()
+	}
+	h := r.hub
+	h.mu.Lock()
+	if h.retiring[r.projectID] == r {
+		delete(h.retiring, r.projectID)
+	}
+	h.mu.Unlock()
+	close(r.done)
+}
+
+// write queues a database write, waiting while the queue is full.
+func (r *room) write(job func(context.Context)) {
+	r.persist <- job
+}
+
+// waitForWrites returns once every write queued so far has been performed.
+func (r *room) waitForWrites() {
+	written := make(chan struct{})
+	r.write(func(context.Context) { close(written) })
+	<-written
+}
+
+// join adds a client to the room.
+func (r *room) join(client *Client) {
+	client.room = r
+	r.clients[client.UserID] = client
+	log.Printf("[Hub] Client %s registered to project %s", client.Username, client.ProjectID)
+	r.hub.sendMessage(client, "hello", HelloPayload{
+		Protocol:    client.protocolVersion(),
+		MinProtocol: MinProtocolVersion,
+		MaxProtocol: ProtocolVersion,
+	})
+	r.syncClient(client)
+	r.broadcastPresence()
+	r.publishPresence()
+}
+
+// replace closes a connection that the same user's newer one took over.
+// moved is set when the new connection is to another project, in which case
+// the user leaves this one.
+func (r *room) replace(old *Client, moved bool) {
+	log.Printf("[Hub] User %s reconnecting, closing old channel", old.UserID)
+	r.hub.closeClient(old, 0)
+	if moved {
+		r.removeClient(old)
+	} else if r.clients[old.UserID] == old {
+		delete(r.clients, old.UserID)
+	}
+}
+
+// unregister closes a client's connection and tells the room and the SFU it
+// has gone.
+func (r *room) unregister(client *Client) {
+	if r.clients[client.UserID] != client {
+		return
+	}
+	h := r.hub
+	h.closeClient(client, 0)
+	r.removeClient(client)
+	log.Printf("[Hub] Client %s left project %s", client.Username, client.ProjectID)
+	disconnectPayload, _ := json.Marshal(map[string]string{"userId": client.UserID})
+	msg, _ := json.Marshal(WsMessage{Type: "webrtc_disconnect", Payload: disconnectPayload})
+	h.do(func() {
+		if h.sfuReachable() {
+			h.sendToSFU("webrtc_disconnect", msg)
+		}
+	})
+}
+
+// removeClient drops a client from the room and the hub's registry and
+// updates presence. The caller closes its Send channel.
+func (r *room) removeClient(client *Client) {
+	if r.clients[client.UserID] != client {
+		return
+	}
+	delete(r.clients, client.UserID)
+	h := r.hub
+	h.mu.Lock()
+	if h.users[client.UserID] == client {
+		delete(h.users, client.UserID)
+	}
+	h.mu.Unlock()
+	r.broadcastPresence()
+	r.publishPresence()
+}
+
+// kick sends a client its force_disconnect message and unregisters it.
+func (r *room) kick(client *Client, msg []byte) {
+	log.Printf("[Hub] Disconnecting user %s from project %s", client.Username, client.ProjectID)
+	r.hub.send(client, "force_disconnect", "", msg)
+	r.unregister(client)
+}
+
+// close disconnects every client from a deleted project and forgets its
+// unsaved drafts, which have nowhere left to go.
+func (r *room) close(msg []byte) {
+	for _, client := range r.clients {
+		r.kick(client, msg)
+	}
+	r.state.dirty = nil
+}
+
+// applyPermissions updates a client's role and tells it so.
+func (r *room) applyPermissions(client *Client, update *PermissionUpdate) {
+	client.Role = update.Role
+	client.Permissions = update.Permissions
+	client.fileAccess = nil
+	log.Printf("[Hub] Notifying user %s of role change to %s", client.Username, update.Role)
+	payload, _ := json.Marshal(PermissionUpdatedPayload{
+		NewRole:     update.Role,
+		Permissions: update.Permissions.List(),
+	})
+	msg, _ := json.Marshal(WsMessage{Type: "permission_updated", Payload: payload})
+	r.hub.send(client, "permission_updated", "", msg)
+}
+
+func (r *room) invalidateFileAccess() {
+	for _, client := range r.clients {
+		client.fileAccess = nil
+	}
+}
+
+// localPresence lists the users connected to the project on this instance.
+func (r *room) localPresence() []UserPresence {
+	var presenceInfo []UserPresence
+	for _, client := range r.clients {
+		presenceInfo = append(presenceInfo, UserPresence{
+			UserID:   client.UserID,
+			Username: client.Username,
+			FileID:   client.openFile,
+			Color:    userColor(client.UserID),
+		})
+	}
+	return presenceInfo
+}
+
+// broadcastPresence tells the room's clients who is in the project and which
+// file each has open, across all instances, and followers where their
+// leaders went.
+func (r *room) broadcastPresence() {
+	if len(r.clients) == 0 {
+		return
+	}
+	presenceInfo := r.localPresence()
+	seen := make(map[string]bool, len(presenceInfo))
+	for _, user := range presenceInfo {
+		seen[user.UserID] = true
+	}
+	h := r.hub
+	h.mu.Lock()
+	for _, remote := range h.remotePresence[r.projectID] {
+		for _, user := range remote.users {
+			if !seen[user.UserID] {
+				seen[user.UserID] = true
+				presenceInfo = append(presenceInfo, user)
+			}
+		}
+	}
+	h.mu.Unlock()
+	r.updateFollowers(presenceInfo)
+	encode := func(users []UserPresence) []byte {
+		payloadBytes, _ := json.Marshal(PresenceUpdatePayload{Users: users})
+		message := WsMessage{
+			Type:    "presence_update",
+			Payload: payloadBytes,
+		}
+		jsonMessage, _ := json.Marshal(message)
+		return jsonMessage
+	}
+	shared := encode(presenceInfo)
+	for _, client := range r.clients {
+		if visible := r.presenceFor(client, presenceInfo); visible != nil {
+			h.send(client, "presence_update", "", encode(visible))
+		} else {
+			h.send(client, "presence_update", "", shared)
+		}
+	}
+}
+
+// deliver sends a broadcast of type msgType to every client in the room
+// except its sender. When restrictToFile is set, only clients that can read
+// that file receive it.
+func (r *room) deliver(msgType string, data []byte, sender *Client, senderID, restrictToFile string) {
+	h := r.hub
+	key := coalesceKey(msgType, senderID, restrictToFile)
+	for _, client := range r.clients {
+		if restrictToFile != "" && h.fileAccess(client, restrictToFile) < acl.Read {
+			continue
+		}
+		if client != sender {
+			h.send(client, msgType, key, data)
+		}
+	}
+}
+
+// editorContents copies the live contents of the files edited in the room.
+// Drafts of files it evicted are written first, for the caller to read.
+func (r *room) editorContents() map[string]string {
+	r.awaitEvictedDrafts()
+	contents := make(map[string]string, len(r.state.EditorContents))
+	for fileID, content := range r.state.EditorContents {
+		contents[fileID] = content
+	}
+	return contents
+}
+
+// whiteboardSnapshot copies the room's shapes, filling the cache from the
+// database first if needed. It waits for the room's queued shape writes, so
+// the database is never behind the cache.
+func (r *room) whiteboardSnapshot() ([]json.RawMessage, error) {
+	state := r.state
+	if !state.shapesLoaded {
+		r.waitForWrites()
+		saved, err := r.hub.store.Whiteboards.Shapes(context.Background(), r.projectID)
+		if err != nil {
+			return nil, err
+		}
+		state.WhiteboardShapes = make(map[string]string, len(saved))
+		r.shapeBytes = 0
+		for id, data := range saved {
+			state.WhiteboardShapes[id] = string(data)
+			r.shapeBytes += int64(len(data))
+		}
+		state.shapesLoaded = true
+		log.Printf("[Hub] Loaded %d whiteboard shapes for project %s", len(saved), r.projectID)
+	}
+	shapes := make([]json.RawMessage, 0, len(state.WhiteboardShapes))
+	for _, shapeJSON := range state.WhiteboardShapes {
+		shapes = append(shapes, json.RawMessage(shapeJSON))
+	}
+	r.cacheTouched("", r.shapeBytes)
+	return shapes, nil
+}
+
+// draftBatch returns a write of the contents of files edited since the last
+// flush, or nil when there are none.
+func (r *room) draftBatch() func(context.Context) {
+	state := r.state
+	if len(state.dirty) == 0 {
+		return nil
+	}
+	projectID, err := uuid.Parse(r.projectID)
+	if err != nil {
+		state.dirty = nil
+		return nil
+	}
+	drafts := make(map[uuid.UUID]string, len(state.dirty))
+	for fileID := range state.dirty {
+		if id, err := uuid.Parse(fileID); err == nil {
+			drafts[id] = state.EditorContents[fileID]
+		}
+	}
+	state.dirty = nil
+	return func(ctx context.Context) {
+		if err := r.hub.store.Drafts.Save(ctx, projectID, drafts); err != nil {
+			log.Printf("[Hub] Failed to save %d drafts for project %s: %v", len(drafts), projectID, err)
+		}
+	}
+}
+
+// queueDrafts hands unsaved editor contents to the writer. A batch that does
+// not fit stays dirty for the next tick.
+func (r *room) queueDrafts() {
+	dirty := r.state.dirty
+	batch := r.draftBatch()
+	if batch == nil {
+		return
+	}
+	select {
+	case r.persist <- batch:
+	default:
+		r.state.dirty = dirty
+	}
+}
+
+// handle processes a message sent to the room by a client or, with no
+// sender, by the server. Broadcast already decoded and validated it.
+func (r *room) handle(message *Message) {
+	h := r.hub
+	msg := message.msg
+	sender := message.Sender
+	if sender != nil && r.clients[sender.UserID] != sender {
+		// The sender left before its message got here.
+		return
+	}
+	// Server-originated messages have no sender and are always allowed.
+	if required := registry[msg.Type].Permission; required != "" && sender != nil && !sender.Permissions.Has(required) {
+		log.Printf("[Hub] Dropping %s from %s: missing permission %s", msg.Type, sender.Username, required)
+		h.reject(sender, permissionDenied(msg, "permission", string(required)))
+		return
+	}
+
+	projectState := r.state
+	// When set, only clients that can read this file receive the broadcast.
+	restrictToFile := ""
+	switch payload := message.payload.(type) {
+	case *WebRTCJoinPayload, *SignalPayload:
+		// Call signaling goes through the hub, which talks to the SFU.
+		h.do(func() { h.handleSignal(message, msg) })
+		h.ack(sender, msg, 0)
+		return
+	case *RequestFileContentPayload:
+		fileID := payload.FileID
+		if h.fileAccess(sender, fileID) < acl.Read {
+			h.reject(sender, permissionDenied(msg, "fileId", fileID))
+			return
+		}
+
+		var contentToSend string
+
+		// First, check if we have a "live" version in our in-memory map.
+		content, contentExists := projectState.EditorContents[fileID]
+
+		if contentExists {
+			// --- HOT PATH ---
+			// The file is active. Serve the latest version from memory.
+			contentToSend = content
+			r.cacheTouched(fileID, int64(len(content)))
+		} else {
+			// --- COLD PATH ---
+			// No one has touched this file in this room yet. Load it from
+			// the database for the first time, preferring a draft that
+			// another instance wrote over the saved content.
+			log.Printf("No in-memory version for file %s. Loading from DB.", fileID)
+			r.awaitEvictedDrafts()
+			draft, err := h.store.Drafts.Get(context.Background(), uuid.MustParse(fileID))
+			if err == nil {
+				contentToSend = draft
+			} else {
+				if !errors.Is(err, store.ErrNotFound) {
+					log.Printf("Failed to query draft for %s: %v", fileID, err)
+				}
+				file, err := h.store.Files.Get(context.Background(), uuid.MustParse(fileID))
+				if err != nil {
+					log.Printf("Failed to query file content for %s: %v", fileID, err)
+					contentToSend = "// File content could not be loaded."
+				} else if file.Content != nil {
+					contentToSend = *file.Content
+				}
+			}
+			// Store it in memory for the next person who asks.
+			r.setContent(fileID, contentToSend)
+		}
+
+		// Send the definitive content to the requester.
+		responsePayload, _ := json.Marshal(EditorUpdatePayload{FileID: fileID, Content: contentToSend})
+		response := WsMessage{Type: "editor_update", Payload: responsePayload}
+		jsonMsg, _ := json.Marshal(response)
+		h.send(sender, "editor_update", coalesceKey("editor_update", "", fileID), jsonMsg)
+		h.ack(sender, msg, 0)
+		r.setOpenFile(sender, fileID)
+		return
+	case *CursorUpdatePayload:
+		// Only clients have cursors.
+		if sender != nil {
+			r.updateCursor(sender, msg, payload)
+		}
+		return
+	case *FollowUserPayload:
+		if sender != nil {
+			r.follow(sender, msg, payload.UserID)
+		}
+		return
+	case *EditorUpdatePayload:
+		fileID := payload.FileID
+		if sender != nil && h.fileAccess(sender, fileID) < acl.Write {
+			h.reject(sender, permissionDenied(msg, "fileId", fileID))
+			return
+		}
+		if sender != nil && !sender.editedFiles[fileID] {
+			if sender.editedFiles == nil {
+				sender.editedFiles = make(map[string]bool)
+			}
+			sender.editedFiles[fileID] = true
+			h.auditClient(sender, "file.live_edit", "file", fileID, nil)
+		}
+		r.setContent(fileID, payload.Content)
+		// Server-originated updates carry content that was just saved.
+		if sender != nil {
+			if projectState.dirty == nil {
+				projectState.dirty = make(map[string]bool)
+			}
+			projectState.dirty[fileID] = true
+		}
+		restrictToFile = fileID
+	case *WhiteboardUpdatePayload:
+		shapeID := payload.ShapeID()
+		if _, exists := projectState.WhiteboardShapes[shapeID]; !exists && sender != nil {
+			h.auditClient(sender, "whiteboard.shape_create", "shape", shapeID, nil)
+		}
+		// 1. Update in-memory state for live broadcast
+		r.setShape(shapeID, string(payload.Shape))
+		// 2. Persist to the database (UPSERT logic), in the room's write queue
+		r.write(func(ctx context.Context) {
+			if err := h.store.Whiteboards.SaveShape(ctx, r.projectID, shapeID, payload.Shape); err != nil {
+				log.Printf("Failed to save whiteboard shape: %v", err)
+			}
+		})
+	case *WhiteboardObjectRemovePayload:
+		shapeID := payload.ID
+		// 1. Remove from in-memory state
+		r.setShape(shapeID, "")
+
+		// 2. Delete from the database, in the room's write queue
+		r.write(func(ctx context.Context) {
+			if err := h.store.Whiteboards.DeleteShape(ctx, r.projectID, shapeID); err != nil {
+				log.Printf("Failed to delete whiteboard shape: %v", err)
+			}
+		})
+		if sender != nil {
+			h.auditClient(sender, "whiteboard.shape_delete", "shape", shapeID, nil)
+		}
+	default:
+		// Notifications such as file_created are only passed on. We don't
+		// need to store any state for them here.
+	}
+
+	senderID := ""
+	if sender != nil {
+		senderID = sender.UserID
+	}
+	data := projectState.replayBuffer().add(msg, senderID, restrictToFile)
+	r.deliver(msg.Type, data, sender, senderID, restrictToFile)
+	h.publish(clusterEvent{Kind: eventRoom, ProjectID: r.projectID, UserID: senderID, FileID: restrictToFile, Data: message.Data})
+	if sender != nil {
+		h.ack(sender, msg, projectState.replay.seq)
+	}
+}
@@ -0,0 +1,125 @@
+package ws
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// JSONSchema describes every message of the protocol as a JSON Schema
+// (draft 2020-12), built from the registry so the frontend can generate its
+// types from the same definitions the hub validates against. ClientMessage
+// is what clients may send and ServerMessage what they receive.
+func JSONSchema() map[string]interface{} {
+	defs := map[string]interface{}{}
+	var clientVariants, serverVariants []interface{}
+	for _, t := range Messages() {
+		payload := schemaRef(reflect.TypeOf(t.Payload), defs)
+		if t.Direction&FromClient != 0 {
+			clientVariants = append(clientVariants, messageSchema(t, payload, false))
+		}
+		if t.Direction&FromServer != 0 {
+			serverVariants = append(serverVariants, messageSchema(t, payload, true))
+		}
+	}
+	defs["ClientMessage"] = map[string]interface{}{"oneOf": clientVariants}
+	defs["ServerMessage"] = map[string]interface{}{"oneOf": serverVariants}
+	return map[string]interface{}{
+		"$schema":              "https://json-schema.org/draft/2020-12/schema",
+		"title":                "WebSocket protocol",
+		"x-protocolVersion":    ProtocolVersion,
+		"x-minProtocolVersion": MinProtocolVersion,
+		"$defs":                defs,
+	}
+}
+
+// messageSchema is the envelope of one message type. Only clients set
+// requestId and only the server numbers broadcasts.
+func messageSchema(t MessageType, payload interface{}, fromServer bool) map[string]interface{} {
+	properties := map[string]interface{}{
+		"type":    map[string]interface{}{"const": t.Type},
+		"payload": payload,
+	}
+	if fromServer {
+		properties["seq"] = map[string]interface{}{"type": "integer", "minimum": 1}
+	} else {
+		properties["requestId"] = map[string]interface{}{"type": "string"}
+	}
+	schema := map[string]interface{}{
+		"title":                t.Type,
+		"description":          t.Description,
+		"type":                 "object",
+		"properties":           properties,
+		"required":             []string{"type"},
+		"additionalProperties": false,
+	}
+	if t.Permission != "" {
+		schema["x-permission"] = string(t.Permission)
+	}
+	return schema
+}
+
+var rawMessageType = reflect.TypeOf(json.RawMessage{})
+
+// schemaRef returns the schema of a Go type, adding structs to defs under
+// their name and referring to them.
+func schemaRef(t reflect.Type, defs map[string]interface{}) interface{} {
+	if t == rawMessageType {
+		return map[string]interface{}{}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaRef(t.Elem(), defs)
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaRef(t.Elem(), defs)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaRef(t.Elem(), defs)}
+	case reflect.Struct:
+		if _, ok := defs[t.Name()]; !ok {
+			defs[t.Name()] = nil // placeholder against recursion
+			defs[t.Name()] = structSchema(t, defs)
+		}
+		return map[string]interface{}{"$ref": "#/$defs/" + t.Name()}
+	}
+	return map[string]interface{}{}
+}
+
+// structSchema follows encoding/json: exported fields by their json name,
+// required unless omitempty.
+func structSchema(t reflect.Type, defs map[string]interface{}) map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = schemaRef(field.Type, defs)
+		if !strings.Contains(options, "omitempty") {
+			required = append(required, name)
+		}
+	}
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}